@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
@@ -11,8 +13,39 @@ import (
 	db "github.com/maxeth/go-bank-app/db/sqlc"
 )
 
+// accountResponse is what the api returns for an account. the serial id stays internal, accounts are addressed by their number
+type accountResponse struct {
+	Number    string     `json:"number"`
+	Owner     string     `json:"owner"`
+	Nickname  string     `json:"nickname"`
+	Type      string     `json:"type"`
+	Balance   int64      `json:"balance"`
+	Currency  string     `json:"currency"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ClosedAt  *time.Time `json:"closedAt"` // null while the account is open
+}
+
+func newAccountResponse(acc db.Account) accountResponse {
+	resp := accountResponse{
+		Number:    acc.Number,
+		Owner:     acc.Owner,
+		Nickname:  acc.Nickname,
+		Type:      acc.Type,
+		Balance:   acc.Balance,
+		Currency:  acc.Currency,
+		CreatedAt: acc.CreatedAt,
+	}
+	if acc.ClosedAt.Valid {
+		resp.ClosedAt = &acc.ClosedAt.Time
+	}
+
+	return resp
+}
+
 type createAccountRequest struct {
 	Currency string `json:"currency" binding:"required,currency"` // currency is a custom validation function, defined in api/validation/currency and applied inside server.go
+	Nickname string `json:"nickname" binding:"max=50"`
+	Type     string `json:"type" binding:"omitempty,oneof=checking savings"` // defaults to checking
 }
 
 func (server *Server) createAccount(ctx *gin.Context) {
@@ -29,14 +62,19 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		Owner:    authPayload.Username,
 		Currency: req.Currency,
 		Balance:  0,
+		Nickname: req.Nickname,
+		Type:     req.Type,
+	}
+	if arg.Type == "" {
+		arg.Type = db.AccountTypeChecking
 	}
 
-	acc, err := server.repository.CreateAccount(ctx, arg)
+	acc, err := server.repository.CreateAccountTx(ctx, arg)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			// error is a pgError
 			switch pqErr.Code.Name() {
-			case "foreign_key_violation", "unique_violation":
+			case "foreign_key_violation":
 				{
 					ctx.JSON(http.StatusForbidden, pqErr.Error())
 					return
@@ -47,46 +85,61 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, acc)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionAccountCreated, db.AuditResourceAccount, strconv.FormatInt(acc.ID, 10), nil, acc)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountResponse(acc))
 }
 
 type getAccountRequest struct {
-	ID int64 `uri:"id" binding:"required,min=1"`
+	Number string `uri:"number" binding:"required,accountnumber"` // accountnumber is a custom validation function, defined in api/validator.go
 }
 
-func (server *Server) getAccount(ctx *gin.Context) {
+// loadAccount binds the account number from the uri and looks the account up.
+// the error response has already been sent when false is returned
+func (server *Server) loadAccount(ctx *gin.Context) (db.Account, bool) {
 	var req getAccountRequest
-
 	if err := ctx.ShouldBindUri(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
+		return db.Account{}, false
 	}
 
-	acc, err := server.repository.GetAccount(ctx, req.ID)
+	acc, err := server.repository.GetAccountByNumber(ctx, req.Number)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
-
 		} else {
 			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		}
+		return db.Account{}, false
+	}
+
+	return acc, true
+}
+
+func (server *Server) getAccount(ctx *gin.Context) {
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
 		return
 	}
 
-	// only return if the fetched account has the same username as the one in the auth-token
+	// only return if the user in the auth-token is a member of the account, every role can read it
 	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
-	if acc.Owner != authPayload.Username {
-		err := errors.New("not authorized to fetch this account")
-		ctx.JSON(http.StatusUnauthorized, err)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username); !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, acc)
+	ctx.JSON(http.StatusOK, newAccountResponse(acc))
 }
 
 type listAccountsRequest struct {
-	PageID int32 `form:"page" binding:"required,min=1"`
-	Limit  int32 `form:"limit" binding:"required,min=5,max=50"`
+	PageID   int32  `form:"page" binding:"required,min=1"`
+	Limit    int32  `form:"limit" binding:"required,min=5,max=50"`
+	Currency string `form:"currency" binding:"omitempty,currency"`
+	Type     string `form:"type" binding:"omitempty,oneof=checking savings"`
 }
 
 func (server *Server) listAccounts(ctx *gin.Context) {
@@ -97,21 +150,100 @@ func (server *Server) listAccounts(ctx *gin.Context) {
 		return
 	}
 
-	// only list the accounts from the owner of the query
+	// only list the accounts the user of the query is a member of
 	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
-	args := db.ListAccountsParams{
-		Owner:  authPayload.Username,
-		Limit:  req.Limit,
-		Offset: (req.PageID - 1) * req.Limit,
+	args := db.ListMemberAccountsParams{
+		Username: authPayload.Username,
+		Currency: sql.NullString{String: req.Currency, Valid: req.Currency != ""},
+		Type:     sql.NullString{String: req.Type, Valid: req.Type != ""},
+		Limit:    req.Limit,
+		Offset:   (req.PageID - 1) * req.Limit,
 	}
 
-	acc, err := server.repository.ListAccounts(ctx, args)
-	// if page will be "out of reach", acc will be an empty array, because we set emit_empty_slices to true in sqlc.yml
+	accs, err := server.repository.ListMemberAccounts(ctx, args)
+	// if page will be "out of reach", accs will be an empty array, because we set emit_empty_slices to true in sqlc.yml
 	// but no error will be thrown
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, acc)
+	resp := make([]accountResponse, 0, len(accs))
+	for _, acc := range accs {
+		resp = append(resp, newAccountResponse(acc))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// closeAccount closes an empty account of the caller. closed accounts stay visible with their history,
+// but cannot send or receive transfers anymore
+func (server *Server) closeAccount(ctx *gin.Context) {
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, db.AccountRoleOwner); !ok {
+		return
+	}
+	if acc.ClosedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("account is already closed")))
+		return
+	}
+	if acc.Balance != 0 {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("only accounts with a balance of 0 can be closed")))
+		return
+	}
+
+	closed, err := server.repository.CloseAccountTx(ctx, db.CloseAccountTxParams{
+		AccountID: acc.ID,
+		Audit:     auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// a transfer or another close request came in between
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("account cannot be closed anymore")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountResponse(closed))
+}
+
+type updateAccountRequest struct {
+	Nickname string `json:"nickname" binding:"max=50"`
+}
+
+// updateAccount renames an account, every member who can move money from it can do that
+func (server *Server) updateAccount(ctx *gin.Context) {
+	var req updateAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, spendingRoles...); !ok {
+		return
+	}
+
+	acc, err := server.repository.UpdateAccountNickname(ctx, db.UpdateAccountNicknameParams{ID: acc.ID, Nickname: req.Nickname})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountResponse(acc))
 }
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+const (
+	// comment line sent on idle streams, it keeps proxies from closing the connection and shows when the client is gone
+	eventStreamHeartbeat = 15 * time.Second
+	// how many stored events are read at once while catching up
+	eventStreamBatchSize = 100
+)
+
+// eventBroker wakes up the event streams of an account when new events were commited for it. it only passes the
+// account id on, the streams read the events themselves from the outbox
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan struct{}]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[int64]map[chan struct{}]struct{})}
+}
+
+// subscribe returns a channel that receives a value whenever the account got new events and a function that has
+// to be called once the stream ends
+func (b *eventBroker) subscribe(accountID int64) (<-chan struct{}, func()) {
+	// a single buffered value is enough, a stream that is still busy reads all new events once it is done
+	c := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	if b.subscribers[accountID] == nil {
+		b.subscribers[accountID] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[accountID][c] = struct{}{}
+	b.mu.Unlock()
+
+	return c, func() {
+		b.mu.Lock()
+		delete(b.subscribers[accountID], c)
+		if len(b.subscribers[accountID]) == 0 {
+			delete(b.subscribers, accountID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// notify wakes up all streams of the account, or every stream if the account id is 0
+func (b *eventBroker) notify(accountID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, subscribers := range b.subscribers {
+		if accountID != 0 && id != accountID {
+			continue
+		}
+		for c := range subscribers {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// NotifyAccountEvents tells the server that events of the account were commited, see db.ListenOutbox
+func (server *Server) NotifyAccountEvents(accountID int64) {
+	server.events.notify(accountID)
+}
+
+// accountSnapshot is the first message of a new stream, so clients dont need to fetch the balance on their own
+type accountSnapshot struct {
+	Number   string `json:"number"`
+	Balance  int64  `json:"balance"`
+	Currency string `json:"currency"`
+}
+
+// streamAccountEvents streams the outbox events of an account as server-sent events, see db.TransferEvent for the
+// payload. every event carries its outbox id, clients that reconnect with Last-Event-ID get everything they missed.
+// new streams start with a snapshot of the balance
+func (server *Server) streamAccountEvents(ctx *gin.Context) {
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username); !ok {
+		return
+	}
+
+	var lastID int64
+	resume := ctx.GetHeader("Last-Event-ID")
+	if resume != "" {
+		var err error
+		lastID, err = strconv.ParseInt(resume, 10, 64)
+		if err != nil || lastID < 0 {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("Last-Event-ID must be the id of an event")))
+			return
+		}
+	}
+
+	// subscribe before reading anything, so no event can be commited unnoticed in between
+	wakeup, unsubscribe := server.events.subscribe(acc.ID)
+	defer unsubscribe()
+
+	var snapshot *accountSnapshot
+	if resume == "" {
+		var err error
+		lastID, err = server.repository.GetLastAccountOutboxEventID(ctx, acc.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		// read after the last event id, every later change arrives as an event
+		acc, err = server.repository.GetAccount(ctx, acc.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		snapshot = &accountSnapshot{Number: acc.Number, Balance: acc.Balance, Currency: acc.Currency}
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// stops nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	if snapshot != nil {
+		ctx.SSEvent("balance", snapshot)
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		lastID, err = server.writeAccountEvents(ctx, acc.ID, lastID)
+		if err != nil {
+			// the status is already sent, the client reconnects and continues after the last event it got
+			ctx.SSEvent("error", errorResponse(err))
+			ctx.Writer.Flush()
+			return
+		}
+		ctx.Writer.Flush()
+
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-wakeup:
+		case <-heartbeat.C:
+			if !server.streamStillAllowed(ctx, acc, authPayload) {
+				return
+			}
+			if _, err := fmt.Fprint(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeAccountEvents writes all events of the account after lastID and returns the id of the last written event
+func (server *Server) writeAccountEvents(ctx *gin.Context, accountID int64, lastID int64) (int64, error) {
+	for {
+		events, err := server.repository.ListAccountOutboxEvents(ctx, db.ListAccountOutboxEventsParams{
+			AccountID:  accountID,
+			ID:         lastID,
+			LimitCount: eventStreamBatchSize,
+		})
+		if err != nil {
+			return lastID, err
+		}
+
+		for _, event := range events {
+			// the payload is written as it is, gin would encode a json.RawMessage as a list of bytes
+			_, err = fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.EventType, event.Payload)
+			if err != nil {
+				return lastID, err
+			}
+			lastID = event.ID
+		}
+
+		if len(events) < eventStreamBatchSize {
+			return lastID, nil
+		}
+	}
+}
+
+// streamStillAllowed checks whether the caller may still read the account. streams end once the access token
+// expired or the caller was removed from the account, the client has to reconnect with a valid token
+func (server *Server) streamStillAllowed(ctx *gin.Context, acc db.Account, authPayload *auth.Payload) bool {
+	if time.Now().After(authPayload.ExpiredAt) {
+		return false
+	}
+
+	_, err := server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: acc.ID, Username: authPayload.Username})
+	if err == sql.ErrNoRows {
+		return false
+	}
+	// a failed lookup doesnt end the stream, it is checked again with the next heartbeat
+	return true
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamAccountEventsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
+	acc := generateRandomAccount(user.Username)
+
+	events := []db.Outbox{
+		{ID: 6, EventType: db.EventTransferReceived, AccountID: acc.ID, Payload: json.RawMessage(`{"amount":10,"balance":110}`)},
+		{ID: 8, EventType: db.EventTransferCreated, AccountID: acc.ID, Payload: json.RawMessage(`{"amount":30,"balance":80}`)},
+	}
+
+	testCases := []struct {
+		name          string
+		lastEventID   string
+		setupAuth     func(t *testing.T, req *http.Request, tm auth.TokenMaker)
+		buildStubs    func(repo *mockdb.MockRepository, disconnect context.CancelFunc)
+		checkResponse func(t *testing.T, resRec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "NewStream",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, viewer.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, disconnect context.CancelFunc) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, viewer.Username, db.AccountRoleViewer)
+				repo.EXPECT().
+					GetLastAccountOutboxEventID(gomock.Any(), gomock.Eq(acc.ID)).
+					Times(1).
+					Return(int64(5), nil)
+				repo.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(acc.ID)).
+					Times(1).
+					Return(acc, nil)
+				// events commited after the snapshot are streamed right away
+				repo.EXPECT().
+					ListAccountOutboxEvents(gomock.Any(), gomock.Eq(db.ListAccountOutboxEventsParams{AccountID: acc.ID, ID: 5, LimitCount: eventStreamBatchSize})).
+					Times(1).
+					DoAndReturn(func(_ interface{}, _ db.ListAccountOutboxEventsParams) ([]db.Outbox, error) {
+						disconnect()
+						return events[:1], nil
+					})
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+				require.Equal(t, "text/event-stream", resRec.Header().Get("Content-Type"))
+
+				snapshot := fmt.Sprintf(`event:balance`+"\n"+`data:{"number":"%s","balance":%d,"currency":"%s"}`+"\n\n", acc.Number, acc.Balance, acc.Currency)
+				event := "id: 6\nevent: transfer.received\ndata: {\"amount\":10,\"balance\":110}\n\n"
+				require.Equal(t, snapshot+event, resRec.Body.String())
+			},
+		},
+		{
+			name:        "Resume",
+			lastEventID: "5",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, disconnect context.CancelFunc) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetLastAccountOutboxEventID(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().
+					ListAccountOutboxEvents(gomock.Any(), gomock.Eq(db.ListAccountOutboxEventsParams{AccountID: acc.ID, ID: 5, LimitCount: eventStreamBatchSize})).
+					Times(1).
+					DoAndReturn(func(_ interface{}, _ db.ListAccountOutboxEventsParams) ([]db.Outbox, error) {
+						disconnect()
+						return events, nil
+					})
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+				require.Equal(t, "id: 6\nevent: transfer.received\ndata: {\"amount\":10,\"balance\":110}\n\n"+
+					"id: 8\nevent: transfer.created\ndata: {\"amount\":30,\"balance\":80}\n\n", resRec.Body.String())
+			},
+		},
+		{
+			name:        "InvalidLastEventID",
+			lastEventID: "latest",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, disconnect context.CancelFunc) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().ListAccountOutboxEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "NotMember",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, viewer.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, disconnect context.CancelFunc) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().GetAccountMember(gomock.Any(), gomock.Any()).Times(1).Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().ListAccountOutboxEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+			},
+			buildStubs: func(repo *mockdb.MockRepository, disconnect context.CancelFunc) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// cancelling the request context is what a disconnecting client looks like to the handler
+			ctx, disconnect := context.WithCancel(context.Background())
+			defer disconnect()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo, disconnect)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%s/events", acc.Number)
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			require.NoError(t, err)
+			if tc.lastEventID != "" {
+				request.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestEventBroker(t *testing.T) {
+	broker := newEventBroker()
+
+	first, unsubscribeFirst := broker.subscribe(1)
+	second, unsubscribeSecond := broker.subscribe(2)
+	defer unsubscribeSecond()
+
+	// notifications of the same account are merged until the stream reads them
+	broker.notify(1)
+	broker.notify(1)
+	require.Len(t, first, 1)
+	require.Len(t, second, 0)
+	<-first
+
+	// 0 wakes up every stream, e.g. after the listener reconnected
+	broker.notify(0)
+	require.Len(t, first, 1)
+	require.Len(t, second, 1)
+
+	unsubscribeFirst()
+	require.NotContains(t, broker.subscribers, int64(1))
+	require.Contains(t, broker.subscribers, int64(2))
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+// roles that are allowed to move money from an account
+var spendingRoles = []string{db.AccountRoleOwner, db.AccountRoleCoOwner}
+
+// authorizeAccount checks that the user is a member of the account and, if any roles are passed, has one of them.
+// the error response has already been sent when false is returned
+func (server *Server) authorizeAccount(ctx *gin.Context, acc db.Account, username string, roles ...string) (db.AccountMember, bool) {
+	member, err := server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: acc.ID, Username: username})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("not authorized to access account [%s]", acc.Number)
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return db.AccountMember{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.AccountMember{}, false
+	}
+
+	if len(roles) == 0 {
+		return member, true
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return member, true
+		}
+	}
+
+	err = fmt.Errorf("the %s role is not allowed to do this on account [%s]", member.Role, acc.Number)
+	ctx.JSON(http.StatusForbidden, errorResponse(err))
+	return db.AccountMember{}, false
+}
+
+type accountMemberResponse struct {
+	AccountNumber string    `json:"accountNumber"`
+	Username      string    `json:"username"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func newAccountMemberResponse(acc db.Account, member db.AccountMember) accountMemberResponse {
+	return accountMemberResponse{
+		AccountNumber: acc.Number,
+		Username:      member.Username,
+		Role:          member.Role,
+		CreatedAt:     member.CreatedAt,
+	}
+}
+
+type accountInvitationResponse struct {
+	ID            int64     `json:"id"`
+	AccountNumber string    `json:"accountNumber"`
+	Inviter       string    `json:"inviter"`
+	Invitee       string    `json:"invitee"`
+	Role          string    `json:"role"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (server *Server) listAccountMembers(ctx *gin.Context) {
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username); !ok {
+		return
+	}
+
+	members, err := server.repository.ListAccountMembers(ctx, acc.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]accountMemberResponse, 0, len(members))
+	for _, member := range members {
+		resp = append(resp, newAccountMemberResponse(acc, member))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type inviteAccountMemberRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Role     string `json:"role" binding:"required,oneof=co-owner viewer"` // ownership can only be handed over with transferAccountOwnership
+}
+
+// inviteAccountMember lets the owner of an account invite another user, who becomes a member once they accept
+func (server *Server) inviteAccountMember(ctx *gin.Context) {
+	var req inviteAccountMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, db.AccountRoleOwner); !ok {
+		return
+	}
+
+	_, err := server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: acc.ID, Username: req.Username})
+	if err == nil {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("user is already a member of this account")))
+		return
+	}
+	if err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	invitation, err := server.repository.CreateAccountInvitation(ctx, db.CreateAccountInvitationParams{
+		AccountID: acc.ID,
+		Inviter:   authPayload.Username,
+		Invitee:   req.Username,
+		Role:      req.Role,
+		ExpiresAt: time.Now().Add(server.config.AccountInvitationDuration),
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation", "unique_violation":
+				{
+					// the invitee doesnt exist or already has a pending invitation
+					ctx.JSON(http.StatusForbidden, pqErr.Error())
+					return
+				}
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionAccountMemberInvited, db.AuditResourceAccount, strconv.FormatInt(acc.ID, 10), nil, invitation)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, accountInvitationResponse{
+		ID:            invitation.ID,
+		AccountNumber: acc.Number,
+		Inviter:       invitation.Inviter,
+		Invitee:       invitation.Invitee,
+		Role:          invitation.Role,
+		ExpiresAt:     invitation.ExpiresAt,
+		CreatedAt:     invitation.CreatedAt,
+	})
+}
+
+// listAccountInvitations returns the pending invitations of the logged in user
+func (server *Server) listAccountInvitations(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	invitations, err := server.repository.ListPendingAccountInvitations(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]accountInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		resp = append(resp, accountInvitationResponse{
+			ID:            invitation.ID,
+			AccountNumber: invitation.AccountNumber,
+			Inviter:       invitation.Inviter,
+			Invitee:       invitation.Invitee,
+			Role:          invitation.Role,
+			ExpiresAt:     invitation.ExpiresAt,
+			CreatedAt:     invitation.CreatedAt,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type invitationRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) acceptAccountInvitation(ctx *gin.Context) {
+	var req invitationRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	member, err := server.repository.AcceptAccountInvitationTx(ctx, db.AcceptAccountInvitationTxParams{
+		InvitationID: req.ID,
+		Invitee:      authPayload.Username,
+		Audit:        auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		if err == db.ErrInvalidInvitation {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "unique_violation":
+				{
+					// joined through another invitation in the meantime
+					ctx.JSON(http.StatusForbidden, pqErr.Error())
+					return
+				}
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	acc, err := server.repository.GetAccount(ctx, member.AccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountMemberResponse(acc, member))
+}
+
+// deleteAccountInvitation declines an invitation when called by the invitee, or revokes it when called by the owner of the account
+func (server *Server) deleteAccountInvitation(ctx *gin.Context) {
+	var req invitationRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	invitation, err := server.repository.GetAccountInvitation(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if invitation.Invitee != authPayload.Username {
+		acc, err := server.repository.GetAccount(ctx, invitation.AccountID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, db.AccountRoleOwner); !ok {
+			return
+		}
+	}
+
+	if invitation.AcceptedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("invitation has already been accepted")))
+		return
+	}
+
+	if err := server.repository.DeleteAccountInvitation(ctx, invitation.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type accountMemberRequest struct {
+	Number   string `uri:"number" binding:"required,accountnumber"`
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// removeAccountMember lets the owner remove a member, or a member leave the account. the owner cant leave,
+// they have to hand the account over first
+func (server *Server) removeAccountMember(ctx *gin.Context) {
+	var req accountMemberRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	var roles []string
+	if req.Username != authPayload.Username {
+		roles = []string{db.AccountRoleOwner}
+	}
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, roles...); !ok {
+		return
+	}
+
+	member, err := server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: acc.ID, Username: req.Username})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if member.Role == db.AccountRoleOwner {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("the owner cannot be removed, transfer the ownership first")))
+		return
+	}
+
+	err = server.repository.DeleteAccountMember(ctx, db.DeleteAccountMemberParams{AccountID: acc.ID, Username: req.Username})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionAccountMemberRemoved, db.AuditResourceAccount, strconv.FormatInt(acc.ID, 10), member, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type transferAccountOwnershipRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+}
+
+// transferAccountOwnership hands the account over to another member, the caller stays a co-owner
+func (server *Server) transferAccountOwnership(ctx *gin.Context) {
+	var req transferAccountOwnershipRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username, db.AccountRoleOwner); !ok {
+		return
+	}
+	if req.Username == authPayload.Username {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("you already own this account")))
+		return
+	}
+
+	acc, err := server.repository.TransferAccountOwnershipTx(ctx, db.TransferAccountOwnershipTxParams{
+		AccountID: acc.ID,
+		NewOwner:  req.Username,
+		Audit:     auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		if err == db.ErrNotAccountMember {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountResponse(acc))
+}
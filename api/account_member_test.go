@@ -0,0 +1,450 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteAccountMemberAPI(t *testing.T) {
+	owner, _ := randomUser(t)
+	invitee, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: owner.Username,
+			body:     gin.H{"username": invitee.Username, "role": db.AccountRoleCoOwner},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: invitee.Username})).
+					Times(1).
+					Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().
+					CreateAccountInvitation(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateAccountInvitationParams) (db.AccountInvitation, error) {
+						require.Equal(t, acc.ID, arg.AccountID)
+						require.Equal(t, owner.Username, arg.Inviter)
+						require.Equal(t, invitee.Username, arg.Invitee)
+						require.Equal(t, db.AccountRoleCoOwner, arg.Role)
+						require.WithinDuration(t, time.Now().Add(time.Hour), arg.ExpiresAt, time.Second)
+						return db.AccountInvitation{ID: 1, AccountID: arg.AccountID, Inviter: arg.Inviter, Invitee: arg.Invitee, Role: arg.Role}, nil
+					})
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountMemberInvited)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OwnerRoleNotInvitable",
+			username: owner.Username,
+			body:     gin.H{"username": invitee.Username, "role": db.AccountRoleOwner},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateAccountInvitation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "CoOwnerCannotInvite",
+			username: invitee.Username,
+			body:     gin.H{"username": owner.Username, "role": db.AccountRoleViewer},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, invitee.Username, db.AccountRoleCoOwner)
+				repo.EXPECT().CreateAccountInvitation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "AlreadyMember",
+			username: owner.Username,
+			body:     gin.H{"username": invitee.Username, "role": db.AccountRoleViewer},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				expectAccountMember(repo, acc, invitee.Username, db.AccountRoleViewer)
+				repo.EXPECT().CreateAccountInvitation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/accounts/%s/invitations", acc.Number)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestAcceptAccountInvitationAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	owner, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					AcceptAccountInvitationTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.AcceptAccountInvitationTxParams) (db.AccountMember, error) {
+						require.Equal(t, int64(7), arg.InvitationID)
+						require.Equal(t, user.Username, arg.Invitee)
+						return db.AccountMember{AccountID: acc.ID, Username: user.Username, Role: db.AccountRoleViewer}, nil
+					})
+				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(acc.ID)).Times(1).Return(acc, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got accountMemberResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, acc.Number, got.AccountNumber)
+				require.Equal(t, db.AccountRoleViewer, got.Role)
+			},
+		},
+		{
+			name: "InvalidInvitation",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AcceptAccountInvitationTx(gomock.Any(), gomock.Any()).Times(1).Return(db.AccountMember{}, db.ErrInvalidInvitation)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodPost, "/invitations/7/accept", nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteAccountInvitationAPI(t *testing.T) {
+	owner, _ := randomUser(t)
+	invitee, _ := randomUser(t)
+	other, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+	invitation := db.AccountInvitation{ID: 7, AccountID: acc.ID, Inviter: owner.Username, Invitee: invitee.Username, Role: db.AccountRoleViewer}
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "DeclinedByInvitee",
+			username: invitee.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountInvitation(gomock.Any(), gomock.Eq(invitation.ID)).Times(1).Return(invitation, nil)
+				repo.EXPECT().DeleteAccountInvitation(gomock.Any(), gomock.Eq(invitation.ID)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:     "RevokedByOwner",
+			username: owner.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountInvitation(gomock.Any(), gomock.Eq(invitation.ID)).Times(1).Return(invitation, nil)
+				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(acc.ID)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				repo.EXPECT().DeleteAccountInvitation(gomock.Any(), gomock.Eq(invitation.ID)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:     "Stranger",
+			username: other.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountInvitation(gomock.Any(), gomock.Eq(invitation.ID)).Times(1).Return(invitation, nil)
+				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(acc.ID)).Times(1).Return(acc, nil)
+				repo.EXPECT().GetAccountMember(gomock.Any(), gomock.Any()).Times(1).Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().DeleteAccountInvitation(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/invitations/%d", invitation.ID)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestRemoveAccountMemberAPI(t *testing.T) {
+	owner, _ := randomUser(t)
+	member, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+
+	testCases := []struct {
+		name          string
+		caller        string
+		removed       string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "RemovedByOwner",
+			caller:  owner.Username,
+			removed: member.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				expectAccountMember(repo, acc, member.Username, db.AccountRoleViewer)
+				repo.EXPECT().
+					DeleteAccountMember(gomock.Any(), gomock.Eq(db.DeleteAccountMemberParams{AccountID: acc.ID, Username: member.Username})).
+					Times(1)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountMemberRemoved)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:    "Leave",
+			caller:  member.Username,
+			removed: member.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: member.Username})).
+					Times(2).
+					Return(db.AccountMember{AccountID: acc.ID, Username: member.Username, Role: db.AccountRoleCoOwner}, nil)
+				repo.EXPECT().DeleteAccountMember(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountMemberRemoved)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:    "OwnerCannotLeave",
+			caller:  owner.Username,
+			removed: owner.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: owner.Username})).
+					Times(2).
+					Return(db.AccountMember{AccountID: acc.ID, Username: owner.Username, Role: db.AccountRoleOwner}, nil)
+				repo.EXPECT().DeleteAccountMember(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:    "CoOwnerCannotRemoveOthers",
+			caller:  member.Username,
+			removed: owner.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, member.Username, db.AccountRoleCoOwner)
+				repo.EXPECT().DeleteAccountMember(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%s/members/%s", acc.Number, tc.removed)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.caller)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestTransferAccountOwnershipAPI(t *testing.T) {
+	owner, _ := randomUser(t)
+	member, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				transferred := acc
+				transferred.Owner = member.Username
+
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				repo.EXPECT().
+					TransferAccountOwnershipTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.TransferAccountOwnershipTxParams) (db.Account, error) {
+						require.Equal(t, acc.ID, arg.AccountID)
+						require.Equal(t, member.Username, arg.NewOwner)
+						require.Equal(t, owner.Username, arg.Audit.Actor)
+						return transferred, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got accountResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, member.Username, got.Owner)
+			},
+		},
+		{
+			name: "NotAMember",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, owner.Username, db.AccountRoleOwner)
+				repo.EXPECT().TransferAccountOwnershipTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, db.ErrNotAccountMember)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"username": member.Username})
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/accounts/%s/owner", acc.Number)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, owner.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/maxeth/go-bank-app/auth"
 	mockdb "github.com/maxeth/go-bank-app/db/mock"
@@ -22,30 +23,32 @@ import (
 func TestGetAccountAPI(t *testing.T) {
 	// generate random acc that is then being returned by the stub
 	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
 	acc := generateRandomAccount(user.Username)
 
 	// define all our different test cases to get more coverage
 	testCases := []struct {
 		name          string
-		accountID     int64
+		accountNumber string
 		setupAuth     func(t *testing.T, req *http.Request, tm auth.TokenMaker)
 		buildStubs    func(repo *mockdb.MockRepository)
 		checkResponse func(t *testing.T, resRec *httptest.ResponseRecorder)
 	}{
 		{
-			name:      "OK",
-			accountID: acc.ID,
+			name:          "OK",
+			accountNumber: acc.Number,
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				// create a token witht he randomly created users username so that the requests are not being rejected
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(acc.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).
 					Times(1).
 					Return(acc, nil)
-				// expect the mock repo GetAccount method to be called once with arguments: (context, acc.ID)
+				// expect the mock repo GetAccountByNumber method to be called once with arguments: (context, acc.Number)
 				// and let it return the acc defined above and a nil err
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
 
 			},
 			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
@@ -55,17 +58,46 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 		},
 		{
-			name:      "NotFound",
-			accountID: acc.ID,
+			name:          "Viewer",
+			accountNumber: acc.Number,
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, viewer.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, viewer.Username, db.AccountRoleViewer)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+				requireBodyAccountMatch(t, resRec.Body, acc)
+			},
+		},
+		{
+			name:          "NotMember",
+			accountNumber: acc.Number,
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, viewer.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().GetAccountMember(gomock.Any(), gomock.Any()).Times(1).Return(db.AccountMember{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name:          "NotFound",
+			accountNumber: acc.Number,
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(acc.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).
 					Times(1).
 					Return(db.Account{}, sql.ErrNoRows)
-				// expect the mock repo GetAccount method to be called once with arguments: (context, acc.ID)
+				// expect the mock repo GetAccountByNumber method to be called once with arguments: (context, acc.Number)
 				// and make it return an empty account struct  and a sql.ErrNoRows error
 			},
 			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
@@ -74,17 +106,17 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 		},
 		{
-			name:      "InternalServerError",
-			accountID: acc.ID,
+			name:          "InternalServerError",
+			accountNumber: acc.Number,
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(acc.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).
 					Times(1).
 					Return(db.Account{}, sql.ErrConnDone)
-				// expect the mock repo GetAccount method to be called once with arguments: (context, acc.ID)
+				// expect the mock repo GetAccountByNumber method to be called once with arguments: (context, acc.Number)
 				// and make it return an empty account struct and an error different to sql.ErrNoRows which will throw an internal error in the account handler
 			},
 			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
@@ -93,16 +125,29 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 		},
 		{
-			name:      "InvalidID",
-			accountID: -1337,
+			name:          "InternalID",
+			accountNumber: fmt.Sprint(acc.ID),
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name:          "InvalidChecksum",
+			accountNumber: typoAccountNumber(acc.Number),
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Any()).
+					GetAccountByNumber(gomock.Any(), gomock.Any()).
 					Times(0)
-				// expect the mock repo GetAccount method to not be called at all (any, any)  because an invalid id will abrupt instantly
+				// expect the mock repo GetAccountByNumber method to not be called at all (any, any)  because a mistyped number will abrupt instantly
 				// and make it return an empty account struct and an error different to sql.ErrNoRows which will throw an internal error in the account handler
 			},
 			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
@@ -121,13 +166,14 @@ func TestGetAccountAPI(t *testing.T) {
 
 			repo := mockdb.NewMockRepository(ctrl)
 			tc.buildStubs(repo)
+			allowAnyToken(repo)
 
 			// start server and send request
 			server := newTestServer(t, repo)
 			recorder := httptest.NewRecorder()
 
 			// request finding the random user with the id of each individual test case
-			url := fmt.Sprintf("/accounts/%d", tc.accountID)
+			url := fmt.Sprintf("/accounts/%s", tc.accountNumber)
 			req, err := http.NewRequest(http.MethodGet, url, nil)
 
 			require.NoError(t, err)
@@ -151,8 +197,10 @@ func TestListAccountsAPI(t *testing.T) {
 	}
 
 	type Query struct {
-		Page  int
-		Limit int
+		Page     int
+		Limit    int
+		Currency string
+		Type     string
 	}
 
 	testCases := []struct {
@@ -173,13 +221,13 @@ func TestListAccountsAPI(t *testing.T) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
-				args := db.ListAccountsParams{
-					Owner:  user.Username,
-					Limit:  int32(n),
-					Offset: 0,
+				args := db.ListMemberAccountsParams{
+					Username: user.Username,
+					Limit:    int32(n),
+					Offset:   0,
 				}
 				repo.EXPECT().
-					ListAccounts(gomock.Any(), gomock.Eq(args)).
+					ListMemberAccounts(gomock.Any(), gomock.Eq(args)).
 					Times(1).
 					Return(accs, nil)
 
@@ -190,6 +238,51 @@ func TestListAccountsAPI(t *testing.T) {
 				requireBodyAccountsArrayMatch(t, resRec.Body, accs)
 			},
 		},
+		{
+			name: "Filtered",
+			query: Query{
+				Page:     2,
+				Limit:    5,
+				Currency: "EUR",
+				Type:     db.AccountTypeSavings,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				args := db.ListMemberAccountsParams{
+					Username: user.Username,
+					Currency: sql.NullString{String: "EUR", Valid: true},
+					Type:     sql.NullString{String: db.AccountTypeSavings, Valid: true},
+					Limit:    5,
+					Offset:   5,
+				}
+				repo.EXPECT().
+					ListMemberAccounts(gomock.Any(), gomock.Eq(args)).
+					Times(1).
+					Return([]db.Account{}, nil)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name: "InvalidType",
+			query: Query{
+				Page:  1,
+				Limit: 5,
+				Type:  "credit",
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -201,6 +294,7 @@ func TestListAccountsAPI(t *testing.T) {
 
 			repo := mockdb.NewMockRepository(ctrl)
 			tc.buildStubs(repo)
+			allowAnyToken(repo)
 
 			// start server and send request
 			server := newTestServer(t, repo)
@@ -215,6 +309,12 @@ func TestListAccountsAPI(t *testing.T) {
 			q := req.URL.Query()
 			q.Add("page", fmt.Sprintf("%v", tc.query.Page))
 			q.Add("limit", fmt.Sprintf("%v", tc.query.Limit))
+			if tc.query.Currency != "" {
+				q.Add("currency", tc.query.Currency)
+			}
+			if tc.query.Type != "" {
+				q.Add("type", tc.query.Type)
+			}
 			req.URL.RawQuery = q.Encode()
 
 			tc.setupAuth(t, req, server.tokenMaker)
@@ -227,32 +327,311 @@ func TestListAccountsAPI(t *testing.T) {
 	}
 }
 
+func TestCreateAccountAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, resRec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "DefaultType",
+			body: gin.H{"currency": "EUR"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.CreateAccountParams{Owner: user.Username, Currency: "EUR", Type: db.AccountTypeChecking}
+				repo.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.Account{ID: 1, Owner: arg.Owner, Currency: arg.Currency, Type: arg.Type}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountCreated)).Times(1)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name: "SavingsWithNickname",
+			body: gin.H{"currency": "EUR", "type": db.AccountTypeSavings, "nickname": "holidays"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.CreateAccountParams{Owner: user.Username, Currency: "EUR", Type: db.AccountTypeSavings, Nickname: "holidays"}
+				repo.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.Account{ID: 2, Owner: arg.Owner, Currency: arg.Currency, Type: arg.Type, Nickname: arg.Nickname}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountCreated)).Times(1)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+
+				var got accountResponse
+				require.NoError(t, json.Unmarshal(resRec.Body.Bytes(), &got))
+				require.Equal(t, "holidays", got.Nickname)
+				require.Equal(t, db.AccountTypeSavings, got.Type)
+			},
+		},
+		{
+			name: "InvalidType",
+			body: gin.H{"currency": "EUR", "type": "credit"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateAccountAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	acc := generateRandomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	renamed := acc
+	renamed.Nickname = "spending"
+
+	repo := mockdb.NewMockRepository(ctrl)
+	expectAccountLookup(repo, acc)
+	expectAccountMember(repo, acc, user.Username, db.AccountRoleCoOwner)
+	repo.EXPECT().
+		UpdateAccountNickname(gomock.Any(), gomock.Eq(db.UpdateAccountNicknameParams{ID: acc.ID, Nickname: "spending"})).
+		Times(1).
+		Return(renamed, nil)
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{"nickname": "spending"})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("/accounts/%s", acc.Number), bytes.NewReader(data))
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+	server.router.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	requireBodyAccountMatch(t, recorder.Body, renamed)
+}
+
+func TestCloseAccountAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+
+	empty := generateRandomAccount(user.Username)
+	empty.Balance = 0
+	requestID := "close-test-request"
+
+	testCases := []struct {
+		name          string
+		account       func() db.Account
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository, acc db.Account)
+		checkResponse func(t *testing.T, resRec *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			account:  func() db.Account { return empty },
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository, acc db.Account) {
+				closed := acc
+				closed.ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				arg := db.CloseAccountTxParams{AccountID: acc.ID, Audit: db.AuditMeta{Actor: user.Username, RequestID: requestID}}
+				repo.EXPECT().CloseAccountTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(closed, nil)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name:     "ClosedConcurrently",
+			account:  func() db.Account { return empty },
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository, acc db.Account) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().CloseAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, resRec.Code)
+			},
+		},
+		{
+			name: "NonZeroBalance",
+			account: func() db.Account {
+				acc := empty
+				acc.Balance = 10
+				return acc
+			},
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository, acc db.Account) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().CloseAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, resRec.Code)
+			},
+		},
+		{
+			name: "AlreadyClosed",
+			account: func() db.Account {
+				acc := empty
+				acc.ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				return acc
+			},
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository, acc db.Account) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().CloseAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, resRec.Code)
+			},
+		},
+		{
+			name:     "CoOwner",
+			account:  func() db.Account { return empty },
+			username: other.Username,
+			buildStubs: func(repo *mockdb.MockRepository, acc db.Account) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, other.Username, db.AccountRoleCoOwner)
+				repo.EXPECT().CloseAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, resRec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			acc := tc.account()
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo, acc)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%s/close", acc.Number)
+			req, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			req.Header.Set(requestIDHeader, requestID)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// lets the account be found by its number
+func expectAccountLookup(repo *mockdb.MockRepository, acc db.Account) {
+	repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+}
+
+// makes the user a member of the account with the given role
+func expectAccountMember(repo *mockdb.MockRepository, acc db.Account, username, role string) {
+	repo.EXPECT().
+		GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: username})).
+		Times(1).
+		Return(db.AccountMember{AccountID: acc.ID, Username: username, Role: role}, nil)
+}
+
 func generateRandomAccount(owner string) db.Account {
+	number, err := library.NewAccountNumber()
+	if err != nil {
+		panic(err)
+	}
+
 	return db.Account{
 		ID:       library.RandomInt(1, 100),
+		Number:   number,
 		Owner:    owner,
 		Balance:  library.RandomBalance(),
 		Currency: library.RandomCurrency(),
 	}
 }
 
+// typoAccountNumber swaps two digits of the number, which the mod-97 check digits catch
+func typoAccountNumber(number string) string {
+	b := []byte(number)
+	i := len(b) - 2
+	if b[i] == b[i+1] {
+		b[i+1] = '0' + (b[i+1]-'0'+1)%10
+	} else {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+
+	return string(b)
+}
+
 func requireBodyAccountMatch(t *testing.T, body *bytes.Buffer, got db.Account) {
 	data, err := ioutil.ReadAll(body)
 	require.NoError(t, err)
 
-	var have db.Account
+	var have map[string]interface{}
 	err = json.Unmarshal(data, &have)
 	require.NoError(t, err)
+	require.NotContains(t, have, "id")
 
-	require.Equal(t, got, have)
+	var resp accountResponse
+	err = json.Unmarshal(data, &resp)
+	require.NoError(t, err)
+
+	require.Equal(t, newAccountResponse(got), resp)
 }
 
 func requireBodyAccountsArrayMatch(t *testing.T, body *bytes.Buffer, accounts []db.Account) {
 	data, err := ioutil.ReadAll(body)
 	require.NoError(t, err)
 
-	var gotAccounts []db.Account
+	var gotAccounts []accountResponse
 	err = json.Unmarshal(data, &gotAccounts)
 	require.NoError(t, err)
-	require.Equal(t, accounts, gotAccounts)
+	require.Len(t, gotAccounts, len(accounts))
+	for i, acc := range accounts {
+		require.Equal(t, newAccountResponse(acc), gotAccounts[i])
+	}
 }
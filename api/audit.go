@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+// returns who sent the request and from where, so it can be recorded in the audit log
+func auditMeta(ctx *gin.Context, actor string) db.AuditMeta {
+	return db.AuditMeta{
+		Actor:     actor,
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: ctx.GetString(requestIDKey),
+	}
+}
+
+// records an event that doesnt move money in the audit log. changes of balances are being recorded
+// inside the repository transaction that performs them instead
+func (server *Server) recordAuditEvent(ctx *gin.Context, actor, action, resourceType, resourceID string, before, after interface{}) error {
+	_, err := server.repository.AppendAuditEvent(ctx, db.AppendAuditEventParams{
+		Meta:         auditMeta(ctx, actor),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       before,
+		After:        after,
+	})
+	return err
+}
+
+type listAuditEventsRequest struct {
+	Actor        string    `form:"actor"`
+	Action       string    `form:"action"`
+	ResourceType string    `form:"resourceType"`
+	ResourceID   string    `form:"resourceID"`
+	From         time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To           time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	PageID       int32     `form:"page" binding:"required,min=1"`
+	Limit        int32     `form:"limit" binding:"required,min=5,max=100"`
+}
+
+func (server *Server) listAuditEvents(ctx *gin.Context) {
+	var req listAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// without an upper bound, list everything up until now
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	arg := db.ListAuditEventsParams{
+		Actor:        req.Actor,
+		Action:       req.Action,
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		CreatedFrom:  req.From,
+		CreatedTo:    to,
+		LimitCount:   req.Limit,
+		OffsetCount:  (req.PageID - 1) * req.Limit,
+	}
+
+	events, err := server.repository.ListAuditEvents(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
+
+func (server *Server) verifyAuditChain(ctx *gin.Context) {
+	report, err := server.repository.VerifyAuditChain(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
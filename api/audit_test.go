@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+type eqAuditActionMatcher struct {
+	action string
+}
+
+// matches any AppendAuditEventParams that records the given action. the rest of the params depends on
+// request specific values like the generated request id
+func (eq eqAuditActionMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.AppendAuditEventParams)
+	if !ok {
+		return false
+	}
+
+	return arg.Action == eq.action
+}
+
+func (eq eqAuditActionMatcher) String() string {
+	return fmt.Sprintf("records audit action %v", eq.action)
+}
+
+func eqAuditAction(action string) gomock.Matcher {
+	return eqAuditActionMatcher{action}
+}
+
+// requireNoPersonalData checks that an audit event keeps neither the name nor the email of the users,
+// the audit log is append-only and cant be cleaned up when a user is deleted
+func requireNoPersonalData(t *testing.T, arg db.AppendAuditEventParams, users ...db.User) {
+	data, err := json.Marshal([]interface{}{arg.Before, arg.After})
+	require.NoError(t, err)
+	for _, user := range users {
+		require.NotContains(t, string(data), user.FullName)
+		require.NotContains(t, string(data), user.Email)
+	}
+}
+
+func TestListAuditEventsAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+	user, _ := randomUser(t)
+	user.Role = auth.RoleDepositor
+
+	events := []db.AuditEvent{
+		randomAuditEvent(user.Username, db.AuditActionLoginSucceeded),
+		randomAuditEvent(user.Username, db.AuditActionAccountCreated),
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		setupAuth     func(t *testing.T, req *http.Request, tm auth.TokenMaker)
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, resRec *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: fmt.Sprintf("page=1&limit=10&actor=%s&action=%s", user.Username, db.AuditActionLoginSucceeded),
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, admin.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+						require.Equal(t, user.Username, arg.Actor)
+						require.Equal(t, db.AuditActionLoginSucceeded, arg.Action)
+						require.Equal(t, int32(10), arg.LimitCount)
+						require.Equal(t, int32(0), arg.OffsetCount)
+						require.WithinDuration(t, time.Now(), arg.CreatedTo, time.Second)
+						return events, nil
+					})
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+				requireBodyAuditEventsMatch(t, resRec.Body, events)
+			},
+		},
+		{
+			name:  "NotAdmin",
+			query: "page=1&limit=10",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, resRec.Code)
+			},
+		},
+		{
+			name:  "NoAuthorization",
+			query: "page=1&limit=10",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name:  "InvalidLimit",
+			query: "page=1&limit=1000",
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, admin.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, "/audit-events?"+tc.query, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestVerifyAuditChainAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	report := db.AuditChainReport{Checked: 3, Valid: false, BrokenAtID: 2}
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+	repo.EXPECT().VerifyAuditChain(gomock.Any()).Times(1).Return(report, nil)
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/audit-events/verify", nil)
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, admin.Username)
+	server.router.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var gotReport db.AuditChainReport
+	err = json.Unmarshal(recorder.Body.Bytes(), &gotReport)
+	require.NoError(t, err)
+	require.Equal(t, report, gotReport)
+}
+
+func randomAuditEvent(actor, action string) db.AuditEvent {
+	return db.AuditEvent{
+		ID:           library.RandomInt(1, 1000),
+		Actor:        actor,
+		Action:       action,
+		ResourceType: db.AuditResourceUser,
+		ResourceID:   actor,
+		RequestID:    library.RandomString(16),
+		Before:       json.RawMessage("null"),
+		After:        json.RawMessage(`{"username":"` + actor + `"}`),
+		PrevHash:     library.RandomString(64),
+		Hash:         library.RandomString(64),
+	}
+}
+
+func requireBodyAuditEventsMatch(t *testing.T, body *bytes.Buffer, events []db.AuditEvent) {
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotEvents []db.AuditEvent
+	err = json.Unmarshal(data, &gotEvents)
+	require.NoError(t, err)
+	require.Equal(t, events, gotEvents)
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+type beneficiaryResponse struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	AccountNumber string    `json:"accountNumber"`
+	Currency      string    `json:"currency"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func newBeneficiaryResponse(beneficiary db.Beneficiary) beneficiaryResponse {
+	return beneficiaryResponse{
+		ID:            beneficiary.ID,
+		Name:          beneficiary.Name,
+		AccountNumber: beneficiary.AccountNumber,
+		Currency:      beneficiary.Currency,
+		Note:          beneficiary.Note,
+		CreatedAt:     beneficiary.CreatedAt,
+	}
+}
+
+type createBeneficiaryRequest struct {
+	Name          string `json:"name" binding:"required,max=100"`
+	AccountNumber string `json:"accountNumber" binding:"required,accountnumber"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	Note          string `json:"note" binding:"max=200"`
+}
+
+// createBeneficiary saves an account to the payee directory of the caller. the account has to exist, be open and use the given currency
+func (server *Server) createBeneficiary(ctx *gin.Context) {
+	var req createBeneficiaryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if ok, _ := server.checkValidAccount(ctx, req.AccountNumber, req.Currency); !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	beneficiary, err := server.repository.CreateBeneficiary(ctx, db.CreateBeneficiaryParams{
+		Owner:         authPayload.Username,
+		Name:          req.Name,
+		AccountNumber: req.AccountNumber,
+		Currency:      req.Currency,
+		Note:          req.Note,
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation", "unique_violation":
+				{
+					// the account is already saved as a beneficiary or disappeared in the meantime
+					ctx.JSON(http.StatusForbidden, pqErr.Error())
+					return
+				}
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionBeneficiaryCreated, db.AuditResourceBeneficiary, strconv.FormatInt(beneficiary.ID, 10), nil, beneficiary)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newBeneficiaryResponse(beneficiary))
+}
+
+type listBeneficiariesRequest struct {
+	PageID int32 `form:"page" binding:"required,min=1"`
+	Limit  int32 `form:"limit" binding:"required,min=5,max=50"`
+}
+
+func (server *Server) listBeneficiaries(ctx *gin.Context) {
+	var req listBeneficiariesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	beneficiaries, err := server.repository.ListBeneficiaries(ctx, db.ListBeneficiariesParams{
+		Owner:  authPayload.Username,
+		Limit:  req.Limit,
+		Offset: (req.PageID - 1) * req.Limit,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]beneficiaryResponse, 0, len(beneficiaries))
+	for _, beneficiary := range beneficiaries {
+		resp = append(resp, newBeneficiaryResponse(beneficiary))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type beneficiaryRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// loadBeneficiary binds the beneficiary id from the uri and returns the beneficiary if it belongs to the caller.
+// the error response has already been sent when false is returned
+func (server *Server) loadBeneficiary(ctx *gin.Context) (db.Beneficiary, bool) {
+	var req beneficiaryRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Beneficiary{}, false
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	return server.getOwnBeneficiary(ctx, req.ID, authPayload.Username)
+}
+
+// getOwnBeneficiary looks the beneficiary up and checks that it was saved by the user
+func (server *Server) getOwnBeneficiary(ctx *gin.Context, id int64, username string) (db.Beneficiary, bool) {
+	beneficiary, err := server.repository.GetBeneficiary(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return db.Beneficiary{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.Beneficiary{}, false
+	}
+
+	if beneficiary.Owner != username {
+		err := fmt.Errorf("not authorized to access beneficiary [%d]", id)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return db.Beneficiary{}, false
+	}
+
+	return beneficiary, true
+}
+
+func (server *Server) getBeneficiary(ctx *gin.Context) {
+	beneficiary, ok := server.loadBeneficiary(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newBeneficiaryResponse(beneficiary))
+}
+
+type updateBeneficiaryRequest struct {
+	Name *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Note *string `json:"note" binding:"omitempty,max=200"`
+}
+
+// updateBeneficiary renames a beneficiary or changes its note, the account number cant be changed since
+// that would turn it into a different payee
+func (server *Server) updateBeneficiary(ctx *gin.Context) {
+	var req updateBeneficiaryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Name == nil && req.Note == nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("nothing to update")))
+		return
+	}
+
+	beneficiary, ok := server.loadBeneficiary(ctx)
+	if !ok {
+		return
+	}
+
+	arg := db.UpdateBeneficiaryParams{ID: beneficiary.ID}
+	if req.Name != nil {
+		arg.Name = sql.NullString{String: *req.Name, Valid: true}
+	}
+	if req.Note != nil {
+		arg.Note = sql.NullString{String: *req.Note, Valid: true}
+	}
+
+	updated, err := server.repository.UpdateBeneficiary(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.recordAuditEvent(ctx, beneficiary.Owner, db.AuditActionBeneficiaryUpdated, db.AuditResourceBeneficiary, strconv.FormatInt(beneficiary.ID, 10), beneficiary, updated)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newBeneficiaryResponse(updated))
+}
+
+func (server *Server) deleteBeneficiary(ctx *gin.Context) {
+	beneficiary, ok := server.loadBeneficiary(ctx)
+	if !ok {
+		return
+	}
+
+	if err := server.repository.DeleteBeneficiary(ctx, beneficiary.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err := server.recordAuditEvent(ctx, beneficiary.Owner, db.AuditActionBeneficiaryDeleted, db.AuditResourceBeneficiary, strconv.FormatInt(beneficiary.ID, 10), beneficiary, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type payeeResponse struct {
+	AccountNumber string `json:"accountNumber"`
+	Currency      string `json:"currency"`
+	Name          string `json:"name"` // masked full name of the account owner
+}
+
+// confirmPayee lets the caller check who they are about to send money to. only a masked version of the owners
+// full name is returned, enough to notice a wrong account number without handing out names for any number
+func (server *Server) confirmPayee(ctx *gin.Context) {
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+	// system accounts hold fees and arent payees, they look like any account that doesnt exist
+	if acc.Owner == db.SystemUsername {
+		err := fmt.Errorf("account [%s] does not exist", acc.Number)
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+	if acc.ClosedAt.Valid {
+		err := fmt.Errorf("account [%s] is closed", acc.Number)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	owner, err := server.repository.GetUser(ctx, acc.Owner)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payeeResponse{
+		AccountNumber: acc.Number,
+		Currency:      acc.Currency,
+		Name:          maskName(owner.FullName),
+	})
+}
+
+// maskName keeps the first letter of every word and replaces the rest with asterisks, "John Doe" becomes "J*** D**"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBeneficiaryAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	payee, _ := randomUser(t)
+	acc := generateRandomAccount(payee.Username)
+	acc.Currency = "EUR"
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"name": "landlord", "accountNumber": acc.Number, "currency": "EUR", "note": "rent"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.CreateBeneficiaryParams{
+					Owner:         user.Username,
+					Name:          "landlord",
+					AccountNumber: acc.Number,
+					Currency:      "EUR",
+					Note:          "rent",
+				}
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().
+					CreateBeneficiary(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.Beneficiary{ID: 1, Owner: arg.Owner, Name: arg.Name, AccountNumber: arg.AccountNumber, Currency: arg.Currency, Note: arg.Note}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionBeneficiaryCreated)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got beneficiaryResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, acc.Number, got.AccountNumber)
+				require.Equal(t, "landlord", got.Name)
+			},
+		},
+		{
+			name: "CurrencyMismatch",
+			body: gin.H{"name": "landlord", "accountNumber": acc.Number, "currency": "USD"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().CreateBeneficiary(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AccountNotFound",
+			body: gin.H{"name": "landlord", "accountNumber": acc.Number, "currency": "EUR"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				repo.EXPECT().CreateBeneficiary(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadySaved",
+			body: gin.H{"name": "landlord", "accountNumber": acc.Number, "currency": "EUR"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().
+					CreateBeneficiary(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Beneficiary{}, &pq.Error{Code: "23505"})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidAccountNumber",
+			body: gin.H{"name": "landlord", "accountNumber": typoAccountNumber(acc.Number), "currency": "EUR"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/beneficiaries", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetBeneficiaryAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	beneficiary := randomBeneficiary(user.Username)
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(beneficiary, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got beneficiaryResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, newBeneficiaryResponse(beneficiary), got)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: other.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(beneficiary, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(db.Beneficiary{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/beneficiaries/%d", beneficiary.ID)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateBeneficiaryAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	beneficiary := randomBeneficiary(user.Username)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "NoteOnly",
+			body: gin.H{"note": "groceries"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				updated := beneficiary
+				updated.Note = "groceries"
+
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(beneficiary, nil)
+				repo.EXPECT().
+					UpdateBeneficiary(gomock.Any(), gomock.Eq(db.UpdateBeneficiaryParams{
+						ID:   beneficiary.ID,
+						Note: sql.NullString{String: "groceries", Valid: true},
+					})).
+					Times(1).
+					Return(updated, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionBeneficiaryUpdated)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got beneficiaryResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, beneficiary.Name, got.Name)
+				require.Equal(t, "groceries", got.Note)
+			},
+		},
+		{
+			name: "NothingToUpdate",
+			body: gin.H{},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().UpdateBeneficiary(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/beneficiaries/%d", beneficiary.ID)
+			req, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteBeneficiaryAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	beneficiary := randomBeneficiary(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(beneficiary, nil)
+	repo.EXPECT().DeleteBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1)
+	repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionBeneficiaryDeleted)).Times(1)
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/beneficiaries/%d", beneficiary.ID)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestConfirmPayeeAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	payee, _ := randomUser(t)
+	payee.FullName = "Jane van Doe"
+	acc := generateRandomAccount(payee.Username)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(payee.Username)).Times(1).Return(payee, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got payeeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, payeeResponse{AccountNumber: acc.Number, Currency: acc.Currency, Name: "J*** v** D**"}, got)
+			},
+		},
+		{
+			name: "Closed",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				closed := acc
+				closed.ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(closed, nil)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SystemAccount",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				system := acc
+				system.Owner = db.SystemUsername
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(system, nil)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, "/payees/"+acc.Number, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestMaskName(t *testing.T) {
+	require.Equal(t, "J*** D**", maskName("John Doe"))
+	require.Equal(t, "Z**", maskName(" Zoë "))
+	require.Equal(t, "", maskName(""))
+}
+
+func randomBeneficiary(owner string) db.Beneficiary {
+	acc := generateRandomAccount(owner + "payee")
+
+	return db.Beneficiary{
+		ID:            acc.ID,
+		Owner:         owner,
+		Name:          acc.Owner,
+		AccountNumber: acc.Number,
+		Currency:      acc.Currency,
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/config"
+)
+
+// response headers browser apps may read besides the simple ones
+var corsExposedHeaders = []string{requestIDHeader, "Deprecation", "Sunset", "Link"}
+
+// corsPolicy answers preflight requests and adds the CORS headers for the allowed origins, see the CORS_* config
+type corsPolicy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+func newCORSPolicy(conf config.Config) corsPolicy {
+	policy := corsPolicy{
+		origins:     map[string]bool{},
+		methods:     joinList(conf.CORSAllowedMethods),
+		headers:     joinList(conf.CORSAllowedHeaders),
+		credentials: conf.CORSAllowCredentials,
+		maxAge:      strconv.Itoa(int(conf.CORSMaxAge.Seconds())),
+	}
+	for _, origin := range splitList(conf.CORSAllowedOrigins) {
+		if origin == "*" {
+			policy.anyOrigin = true
+		}
+		policy.origins[origin] = true
+	}
+	return policy
+}
+
+func (p corsPolicy) enabled() bool {
+	return len(p.origins) > 0
+}
+
+func (p corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[origin]
+}
+
+// corsMiddleware has to run for unmatched routes as well, preflight requests use OPTIONS which no route handles
+func corsMiddleware(p corsPolicy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if origin == "" {
+			ctx.Next()
+			return
+		}
+
+		header := ctx.Writer.Header()
+		// the response depends on the origin, caches must not hand it to other origins
+		header.Add("Vary", "Origin")
+
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+		if !p.allows(origin) {
+			if preflight {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// without the headers the browser doesnt hand the response to the page
+			ctx.Next()
+			return
+		}
+
+		if p.anyOrigin && !p.credentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", p.methods)
+			header.Set("Access-Control-Allow-Headers", p.headers)
+			header.Set("Access-Control-Max-Age", p.maxAge)
+			ctx.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		header.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		ctx.Next()
+	}
+}
+
+// splitList splits a comma separated config value and drops empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func joinList(value string) string {
+	return strings.Join(splitList(value), ", ")
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/config"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	const appOrigin = "https://app.example.com"
+
+	testCases := []struct {
+		name          string
+		origins       string
+		credentials   bool
+		setupRequest  func(req *http.Request)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "AllowedOrigin",
+			origins: appOrigin + ", http://localhost:3000",
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Origin", appOrigin)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Equal(t, appOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+				require.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), requestIDHeader)
+				require.Equal(t, "Origin", rec.Header().Get("Vary"))
+			},
+		},
+		{
+			name:    "OtherOrigin",
+			origins: appOrigin,
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Origin", "https://evil.example.com")
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				// the request is handled, the browser just doesnt show the response to the page
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:    "Preflight",
+			origins: appOrigin,
+			setupRequest: func(req *http.Request) {
+				req.Method = http.MethodOptions
+				req.Header.Set("Origin", appOrigin)
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, rec.Code)
+				require.Equal(t, appOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+				require.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+				require.Equal(t, "Authorization, Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+				require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+			},
+		},
+		{
+			name:    "PreflightOtherOrigin",
+			origins: appOrigin,
+			setupRequest: func(req *http.Request) {
+				req.Method = http.MethodOptions
+				req.Header.Set("Origin", "https://evil.example.com")
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rec.Code)
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:    "AnyOrigin",
+			origins: "*",
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Origin", appOrigin)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:        "Credentials",
+			origins:     appOrigin,
+			credentials: true,
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Origin", appOrigin)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, appOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+				require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+			},
+		},
+		{
+			name: "Disabled",
+			setupRequest: func(req *http.Request) {
+				req.Method = http.MethodOptions
+				req.Header.Set("Origin", appOrigin)
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.NotEqual(t, http.StatusNoContent, rec.Code)
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+			},
+		},
+		{
+			name:    "NoOrigin",
+			origins: appOrigin,
+			setupRequest: func(req *http.Request) {
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+				require.Empty(t, rec.Header().Get("Vary"))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockRepository(ctrl))
+			server.config.CORSAllowedOrigins = tc.origins
+			server.config.CORSAllowCredentials = tc.credentials
+			server.config.CORSAllowedMethods = "GET,POST"
+			server.config.CORSAllowedHeaders = "Authorization, Content-Type"
+			server.config.CORSMaxAge = 10 * time.Minute
+			require.NoError(t, server.applyRoutes())
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+			require.NoError(t, err)
+			tc.setupRequest(req)
+
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+// a browser app that logs in has to get its token through the preflight with the default CORS_ALLOWED_HEADERS
+func TestCORSPreflightAuthHeader(t *testing.T) {
+	const appOrigin = "https://app.example.com"
+	user, _ := randomUser(t)
+
+	defaults, err := config.Load(t.TempDir())
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	allowAnyToken(repo)
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	repo.EXPECT().ListOwnerAccounts(gomock.Any(), gomock.Any()).Times(1)
+
+	server := newTestServer(t, repo)
+	server.config.CORSAllowedOrigins = appOrigin
+	server.config.CORSAllowedMethods = defaults.CORSAllowedMethods
+	server.config.CORSAllowedHeaders = defaults.CORSAllowedHeaders
+	server.config.CORSMaxAge = defaults.CORSMaxAge
+	require.NoError(t, server.applyRoutes())
+
+	// browsers list the requested headers in lower case
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodOptions, "/v1/users/me", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", appOrigin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", authHeaderKey+",content-type")
+
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	allowed := map[string]bool{}
+	for _, header := range splitList(rec.Header().Get("Access-Control-Allow-Headers")) {
+		allowed[strings.ToLower(header)] = true
+	}
+	for _, header := range splitList(req.Header.Get("Access-Control-Request-Headers")) {
+		require.True(t, allowed[header], header)
+	}
+
+	// the request the browser sends after the preflight
+	rec = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", appOrigin)
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, appOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/notify"
+)
+
+var errEmailNotVerified = errors.New("email address has to be verified first")
+
+// sendEmailVerification creates a single-use token for the users current email and sends it to that address
+func (server *Server) sendEmailVerification(ctx *gin.Context, user db.User) error {
+	token, hash, err := auth.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	verification, err := server.repository.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		TokenHash: hash,
+		Username:  user.Username,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(server.config.EmailVerificationTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	return server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Use the following token to verify your email address. It expires at %s.\n\n%s\n\n"+
+			"If you didnt sign up, you can ignore this message.", verification.ExpiresAt.Format(time.RFC1123), token),
+	})
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// verifyEmail redeems a token sent by sendEmailVerification. it doesnt require being logged in,
+// since the token is usually opened from a mail client
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.repository.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		TokenHash: auth.HashSecretToken(req.Token),
+		Audit:     auditMeta(ctx, ""),
+	})
+	if err != nil {
+		if err == db.ErrInvalidVerificationToken {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// resendEmailVerification sends a new token to the logged in user, e.g. because the first one expired
+func (server *Server) resendEmailVerification(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	user, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("email address is already verified")))
+		return
+	}
+
+	if err := server.sendEmailVerification(ctx, user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{})
+}
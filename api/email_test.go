@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	token, hash, err := auth.NewSecretToken()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"token": token},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.VerifyEmailTxParams) (db.User, error) {
+						require.Equal(t, hash, arg.TokenHash)
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.NotNil(t, resp.EmailVerifiedAt)
+			},
+		},
+		{
+			name: "InvalidToken",
+			body: gin.H{"token": token},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, db.ErrInvalidVerificationToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "MissingToken",
+			body: gin.H{},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"token": token},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/verify-email", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestResendEmailVerificationAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	verifiedUser, _ := randomUser(t)
+	verifiedUser.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *recordingNotifier)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					CreateEmailVerificationToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.EmailVerificationToken{Username: user.Username, Email: user.Email, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *recordingNotifier) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, notifier.messages, 1)
+				require.Equal(t, user.Email, notifier.messages[0].To)
+			},
+		},
+		{
+			name:     "AlreadyVerified",
+			username: verifiedUser.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(verifiedUser.Username)).Times(1).Return(verifiedUser, nil)
+				repo.EXPECT().CreateEmailVerificationToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *recordingNotifier) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Empty(t, notifier.messages)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			notifier := &recordingNotifier{}
+			server.notifier = notifier
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodPost, "/users/verify-email/resend", nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	user, _ := randomUser(t)
+	verifiedUser, _ := randomUser(t)
+	verifiedUser.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Verified",
+			user: verifiedUser,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(verifiedUser.Username)).Times(1).Return(verifiedUser, nil)
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(generateRandomAccount(verifiedUser.Username), nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountCreated)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotVerified",
+			user: user,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.True(t, strings.Contains(recorder.Body.String(), errEmailNotVerified.Error()))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			server.config.RequireVerifiedEmail = true
+			server.applyRoutes()
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"currency": "EUR"})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+type feeRuleResponse struct {
+	ID            int64     `json:"id"`
+	Currency      string    `json:"currency"`
+	AccountType   string    `json:"accountType"` // empty for the rule that applies to all account types
+	FlatAmount    int64     `json:"flatAmount"`
+	PercentageBps int32     `json:"percentageBps"`
+	MinAmount     int64     `json:"minAmount"`
+	MaxAmount     *int64    `json:"maxAmount"`
+	FreePerMonth  int32     `json:"freePerMonth"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func newFeeRuleResponse(rule db.FeeRule) feeRuleResponse {
+	resp := feeRuleResponse{
+		ID:            rule.ID,
+		Currency:      rule.Currency,
+		AccountType:   rule.AccountType,
+		FlatAmount:    rule.FlatAmount,
+		PercentageBps: rule.PercentageBps,
+		MinAmount:     rule.MinAmount,
+		FreePerMonth:  rule.FreePerMonth,
+		UpdatedAt:     rule.UpdatedAt,
+	}
+	if rule.MaxAmount.Valid {
+		resp.MaxAmount = &rule.MaxAmount.Int64
+	}
+
+	return resp
+}
+
+func (server *Server) listFeeRules(ctx *gin.Context) {
+	rules, err := server.repository.ListFeeRules(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]feeRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, newFeeRuleResponse(rule))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type setFeeRuleRequest struct {
+	Currency      string `json:"currency" binding:"required,currency"`
+	AccountType   string `json:"accountType" binding:"omitempty,oneof=checking savings"` // empty applies to all types without a rule of their own
+	FlatAmount    int64  `json:"flatAmount" binding:"min=0"`
+	PercentageBps int32  `json:"percentageBps" binding:"min=0,max=10000"` // 50 is 0.5% of the amount
+	MinAmount     int64  `json:"minAmount" binding:"min=0"`
+	MaxAmount     *int64 `json:"maxAmount" binding:"omitempty,min=0"` // null for no upper limit
+	FreePerMonth  int32  `json:"freePerMonth" binding:"min=0"`        // outgoing transfers per account and month without fee
+}
+
+// setFeeRule creates or replaces the fee rule of a currency and account type, it applies to transfers created from
+// then on. transfers that were already made keep their fee
+func (server *Server) setFeeRule(ctx *gin.Context) {
+	var req setFeeRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.MaxAmount != nil && *req.MaxAmount < req.MinAmount {
+		err := errors.New("maxAmount must not be lower than minAmount")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the before-state of the audit event stays empty for new rules
+	var before interface{}
+	current, err := server.repository.GetFeeRuleByScope(ctx, db.GetFeeRuleByScopeParams{Currency: req.Currency, AccountType: req.AccountType})
+	switch {
+	case err == nil:
+		before = current
+	case err != sql.ErrNoRows:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.UpsertFeeRuleParams{
+		Currency:      req.Currency,
+		AccountType:   req.AccountType,
+		FlatAmount:    req.FlatAmount,
+		PercentageBps: req.PercentageBps,
+		MinAmount:     req.MinAmount,
+		FreePerMonth:  req.FreePerMonth,
+	}
+	if req.MaxAmount != nil {
+		arg.MaxAmount = sql.NullInt64{Int64: *req.MaxAmount, Valid: true}
+	}
+
+	rule, err := server.repository.UpsertFeeRule(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionFeeRuleChanged, db.AuditResourceFeeRule, strconv.FormatInt(rule.ID, 10), before, rule)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newFeeRuleResponse(rule))
+}
+
+type feeRuleRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) deleteFeeRule(ctx *gin.Context) {
+	var req feeRuleRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rule, err := server.repository.GetFeeRule(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.repository.DeleteFeeRule(ctx, rule.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionFeeRuleDeleted, db.AuditResourceFeeRule, strconv.FormatInt(rule.ID, 10), rule, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFeeRuleAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+	user, _ := randomUser(t)
+	user.Role = auth.RoleDepositor
+
+	rule := db.FeeRule{
+		ID:            1,
+		Currency:      "USD",
+		AccountType:   db.AccountTypeChecking,
+		FlatAmount:    10,
+		PercentageBps: 50,
+		MaxAmount:     sql.NullInt64{Int64: 500, Valid: true},
+		FreePerMonth:  5,
+	}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: admin,
+			body: gin.H{
+				"currency":      rule.Currency,
+				"accountType":   rule.AccountType,
+				"flatAmount":    rule.FlatAmount,
+				"percentageBps": rule.PercentageBps,
+				"maxAmount":     rule.MaxAmount.Int64,
+				"freePerMonth":  rule.FreePerMonth,
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().
+					GetFeeRuleByScope(gomock.Any(), gomock.Eq(db.GetFeeRuleByScopeParams{Currency: rule.Currency, AccountType: rule.AccountType})).
+					Times(1).
+					Return(db.FeeRule{}, sql.ErrNoRows)
+				arg := db.UpsertFeeRuleParams{
+					Currency:      rule.Currency,
+					AccountType:   rule.AccountType,
+					FlatAmount:    rule.FlatAmount,
+					PercentageBps: rule.PercentageBps,
+					MaxAmount:     rule.MaxAmount,
+					FreePerMonth:  rule.FreePerMonth,
+				}
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rule, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionFeeRuleChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got feeRuleResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, newFeeRuleResponse(rule), got)
+			},
+		},
+		{
+			name: "AllAccountTypes",
+			user: admin,
+			body: gin.H{"currency": "EUR", "flatAmount": 5},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().GetFeeRuleByScope(gomock.Any(), gomock.Eq(db.GetFeeRuleByScopeParams{Currency: "EUR"})).Times(1).Return(db.FeeRule{}, sql.ErrNoRows)
+				arg := db.UpsertFeeRuleParams{Currency: "EUR", FlatAmount: 5}
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.FeeRule{ID: 2, Currency: "EUR", FlatAmount: 5}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionFeeRuleChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "MaxBelowMin",
+			user: admin,
+			body: gin.H{"currency": "USD", "minAmount": 100, "maxAmount": 50},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidPercentage",
+			user: admin,
+			body: gin.H{"currency": "USD", "percentageBps": 10001},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SystemAccountType",
+			user: admin,
+			body: gin.H{"currency": "USD", "accountType": db.AccountTypeFeeRevenue},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			user: user,
+			body: gin.H{"currency": "USD", "flatAmount": 5},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().UpsertFeeRule(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPut, "/fee-rules", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteFeeRuleAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+
+	rule := db.FeeRule{ID: 7, Currency: "USD", FlatAmount: 10}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetFeeRule(gomock.Any(), gomock.Eq(rule.ID)).Times(1).Return(rule, nil)
+				repo.EXPECT().DeleteFeeRule(gomock.Any(), gomock.Eq(rule.ID)).Times(1).Return(nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionFeeRuleDeleted)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetFeeRule(gomock.Any(), gomock.Eq(rule.ID)).Times(1).Return(db.FeeRule{}, sql.ErrNoRows)
+				repo.EXPECT().DeleteFeeRule(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("/fee-rules/%d", rule.ID), nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, admin.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
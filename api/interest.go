@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+type interestRateResponse struct {
+	AccountType   string    `json:"accountType"`
+	Currency      string    `json:"currency"`
+	AnnualRateBps int32     `json:"annualRateBps"` // 250 is 2.50% per year
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func newInterestRateResponse(rate db.InterestRate) interestRateResponse {
+	return interestRateResponse{
+		AccountType:   rate.AccountType,
+		Currency:      rate.Currency,
+		AnnualRateBps: rate.AnnualRateBps,
+		UpdatedAt:     rate.UpdatedAt,
+	}
+}
+
+func (server *Server) listInterestRates(ctx *gin.Context) {
+	rates, err := server.repository.ListInterestRates(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]interestRateResponse, 0, len(rates))
+	for _, rate := range rates {
+		resp = append(resp, newInterestRateResponse(rate))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type setInterestRateRequest struct {
+	AccountType   string `json:"accountType" binding:"required,oneof=checking savings"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	AnnualRateBps *int32 `json:"annualRateBps" binding:"required,min=0,max=10000"` // a pointer so 0 can be set explicitly
+}
+
+// setInterestRate sets the annual rate of an account type in a currency. it applies to every account of that type
+// without a rate of its own from the next accrual on, interest accrued so far is not recalculated
+func (server *Server) setInterestRate(ctx *gin.Context) {
+	var req setInterestRateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the before-state of the audit event stays empty for rates that are set for the first time
+	var before interface{}
+	current, err := server.repository.GetInterestRate(ctx, db.GetInterestRateParams{AccountType: req.AccountType, Currency: req.Currency})
+	switch {
+	case err == nil:
+		before = current
+	case err != sql.ErrNoRows:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rate, err := server.repository.UpsertInterestRate(ctx, db.UpsertInterestRateParams{
+		AccountType:   req.AccountType,
+		Currency:      req.Currency,
+		AnnualRateBps: *req.AnnualRateBps,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionInterestRateChanged, db.AuditResourceInterestRate, rate.AccountType+"/"+rate.Currency, before, rate)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newInterestRateResponse(rate))
+}
+
+type setAccountInterestRateRequest struct {
+	AnnualRateBps *int32 `json:"annualRateBps" binding:"omitempty,min=0,max=10000"` // null falls back to the rate of the account type
+}
+
+type accountInterestRateResponse struct {
+	Number        string `json:"number"`
+	AnnualRateBps *int32 `json:"annualRateBps"`
+}
+
+// setAccountInterestRate gives a single account a rate of its own, e.g. for a promotion, or removes it again
+func (server *Server) setAccountInterestRate(ctx *gin.Context) {
+	var req setAccountInterestRateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	arg := db.UpdateAccountInterestRateParams{ID: acc.ID}
+	if req.AnnualRateBps != nil {
+		arg.InterestRateBps = sql.NullInt32{Int32: *req.AnnualRateBps, Valid: true}
+	}
+
+	updated, err := server.repository.UpdateAccountInterestRate(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionAccountInterestRateChanged, db.AuditResourceAccount, strconv.FormatInt(acc.ID, 10), acc, updated)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := accountInterestRateResponse{Number: updated.Number}
+	if updated.InterestRateBps.Valid {
+		resp.AnnualRateBps = &updated.InterestRateBps.Int32
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
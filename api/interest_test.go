@@ -0,0 +1,273 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListInterestRatesAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+
+	rates := []db.InterestRate{
+		{AccountType: db.AccountTypeSavings, Currency: "EUR", AnnualRateBps: 150},
+		{AccountType: db.AccountTypeSavings, Currency: "USD", AnnualRateBps: 250},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+	repo.EXPECT().ListInterestRates(gomock.Any()).Times(1).Return(rates, nil)
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/interest-rates", nil)
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, admin.Username)
+	server.router.ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var got []interestRateResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+	require.Equal(t, []interestRateResponse{newInterestRateResponse(rates[0]), newInterestRateResponse(rates[1])}, got)
+}
+
+func TestSetInterestRateAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+	user, _ := randomUser(t)
+	user.Role = auth.RoleDepositor
+
+	rate := db.InterestRate{AccountType: db.AccountTypeSavings, Currency: "USD", AnnualRateBps: 250}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: admin,
+			body: gin.H{"accountType": rate.AccountType, "currency": rate.Currency, "annualRateBps": rate.AnnualRateBps},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().
+					GetInterestRate(gomock.Any(), gomock.Eq(db.GetInterestRateParams{AccountType: rate.AccountType, Currency: rate.Currency})).
+					Times(1).
+					Return(db.InterestRate{}, sql.ErrNoRows)
+				arg := db.UpsertInterestRateParams{AccountType: rate.AccountType, Currency: rate.Currency, AnnualRateBps: rate.AnnualRateBps}
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rate, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionInterestRateChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got interestRateResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, newInterestRateResponse(rate), got)
+			},
+		},
+		{
+			name: "ZeroRate",
+			user: admin,
+			body: gin.H{"accountType": rate.AccountType, "currency": rate.Currency, "annualRateBps": 0},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().GetInterestRate(gomock.Any(), gomock.Any()).Times(1).Return(rate, nil)
+				arg := db.UpsertInterestRateParams{AccountType: rate.AccountType, Currency: rate.Currency, AnnualRateBps: 0}
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.InterestRate{AccountType: rate.AccountType, Currency: rate.Currency}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionInterestRateChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "MissingRate",
+			user: admin,
+			body: gin.H{"accountType": rate.AccountType, "currency": rate.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "RateTooHigh",
+			user: admin,
+			body: gin.H{"accountType": rate.AccountType, "currency": rate.Currency, "annualRateBps": 10001},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SystemAccountType",
+			user: admin,
+			body: gin.H{"accountType": db.AccountTypeInterestExpense, "currency": rate.Currency, "annualRateBps": 100},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			user: user,
+			body: gin.H{"accountType": rate.AccountType, "currency": rate.Currency, "annualRateBps": rate.AnnualRateBps},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().UpsertInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPut, "/interest-rates", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestSetAccountInterestRateAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = auth.RoleAdmin
+	owner, _ := randomUser(t)
+	acc := generateRandomAccount(owner.Username)
+
+	withRate := acc
+	withRate.InterestRateBps = sql.NullInt32{Int32: 400, Valid: true}
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: `{"annualRateBps": 400}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				arg := db.UpdateAccountInterestRateParams{ID: acc.ID, InterestRateBps: withRate.InterestRateBps}
+				repo.EXPECT().UpdateAccountInterestRate(gomock.Any(), gomock.Eq(arg)).Times(1).Return(withRate, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountInterestRateChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, fmt.Sprintf(`{"number": %q, "annualRateBps": 400}`, acc.Number), recorder.Body.String())
+			},
+		},
+		{
+			name: "Reset",
+			body: `{"annualRateBps": null}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, withRate)
+				arg := db.UpdateAccountInterestRateParams{ID: acc.ID}
+				repo.EXPECT().UpdateAccountInterestRate(gomock.Any(), gomock.Eq(arg)).Times(1).Return(acc, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountInterestRateChanged)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, fmt.Sprintf(`{"number": %q, "annualRateBps": null}`, acc.Number), recorder.Body.String())
+			},
+		},
+		{
+			name: "NegativeRate",
+			body: `{"annualRateBps": -1}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().UpdateAccountInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AccountNotFound",
+			body: `{"annualRateBps": 400}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				repo.EXPECT().UpdateAccountInterestRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%s/interest-rate", acc.Number)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, admin.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
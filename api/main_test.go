@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/maxeth/go-bank-app/config"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
 	"github.com/maxeth/go-bank-app/library"
 	"github.com/stretchr/testify/require"
@@ -23,6 +25,14 @@ func newTestServer(t *testing.T, repo db.Repository) *Server {
 	conf := config.Config{
 		TokenSummetricKey:   library.RandomString(32),
 		AccessTokenDuration: time.Second * 15,
+		PasswordMinLength:   8,
+
+		PasswordResetTokenDuration: time.Minute,
+		TOTPIssuer:                 "go-bank-app",
+		TwoFactorTokenDuration:     time.Minute,
+		AccountInvitationDuration:  time.Hour,
+		TransferQuoteDuration:      time.Minute,
+		MaxBodySize:                1 << 20,
 	}
 	server, err := NewServer(conf, repo)
 	require.NoError(t, err)
@@ -30,3 +40,8 @@ func newTestServer(t *testing.T, repo db.Repository) *Server {
 
 	return server
 }
+
+// makes authMiddleware accept every token, for tests that arent about password changes
+func allowAnyToken(repo *mockdb.MockRepository) {
+	repo.EXPECT().GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).AnyTimes().Return(time.Time{}, nil)
+}
@@ -1,22 +1,33 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
 )
 
 const (
-	authHeaderKey  = "authoriz"
+	authHeaderKey  = "authorization"
 	authTypeBearer = "bearer"
 	authPayloadKey = "authorization_payload" // the auth payload will be accessible under this key in gin.Context
+
+	requestIDHeader    = "X-Request-ID"
+	requestIDKey       = "request_id" // the request id will be accessible under this key in gin.Context
+	maxRequestIDLength = 128
 )
 
-func authMiddleware(tokenMaker auth.TokenMaker) gin.HandlerFunc {
+// authMiddleware rejects requests without a valid token. tokens issued before the users last password change
+// are rejected as well, so changing the password logs out every other session
+func authMiddleware(tokenMaker auth.TokenMaker, repo db.Repository) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader((authHeaderKey))
 		if len(authHeader) == 0 {
@@ -47,8 +58,158 @@ func authMiddleware(tokenMaker auth.TokenMaker) gin.HandlerFunc {
 			return
 		}
 
+		if payload.Scope != auth.ScopeAccess {
+			// e.g. the token of an unfinished two-factor login
+			err := errors.New("token is not an access token")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
+		passwordChangedAt, err := repo.GetUserPasswordChangedAt(ctx, payload.Username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if payload.IssuedAt.Before(passwordChangedAt) {
+			err := errors.New("token was issued before the last password change")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
 		ctx.Set(authPayloadKey, payload) // save the users payload in the context
 		ctx.Next()
 	}
 
 }
+
+// requestIDMiddleware makes sure every request carries an id that can be correlated across logs and the audit trail.
+// an id sent by the client (or a proxy in front of us) is being reused, otherwise a new one is generated
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeader)
+		if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeader, requestID)
+		ctx.Next()
+	}
+}
+
+// adminMiddleware only lets requests through whose authenticated user has the admin role.
+// it has to be applied after authMiddleware
+func adminMiddleware(repo db.Repository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+		user, err := repo.GetUser(ctx, authPayload.Username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if user.Role != auth.RoleAdmin {
+			err := errors.New("admin role required")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// verifiedEmailMiddleware only lets requests through whose authenticated user has verified their email.
+// it has to be applied after authMiddleware
+func verifiedEmailMiddleware(repo db.Repository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+		user, err := repo.GetUser(ctx, authPayload.Username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if !user.EmailVerifiedAt.Valid {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// securityHeadersMiddleware adds the headers browsers use to protect API responses. the api is never meant to be
+// rendered in a frame or sniffed as something other than the declared content type
+func securityHeadersMiddleware(hstsMaxAge time.Duration) gin.HandlerFunc {
+	hsts := fmt.Sprintf("max-age=%d", int64(hstsMaxAge.Seconds()))
+
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		header.Set("Referrer-Policy", "no-referrer")
+		// browsers ignore the header on plain http, so it only takes effect behind tls
+		if hstsMaxAge > 0 {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		ctx.Next()
+	}
+}
+
+// errBodyTooLarge is returned while reading a request body beyond the configured limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// bodyLimitMiddleware rejects request bodies larger than limit bytes. bodies that announce their size are rejected
+// right away, all others fail once the handler reads past the limit
+func bodyLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > limit {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorResponse(errBodyTooLarge))
+			return
+		}
+		if ctx.Request.Body != nil {
+			ctx.Request.Body = &limitedBody{ReadCloser: ctx.Request.Body, remaining: limit}
+		}
+
+		ctx.Next()
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// read one byte more than allowed, to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+
+	return n, err
+}
@@ -1,14 +1,20 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	"github.com/maxeth/go-bank-app/library"
 	"github.com/stretchr/testify/require"
 )
 
@@ -86,12 +92,18 @@ func TestAuthMiddleware(t *testing.T) {
 		tc := testCases[i]
 
 		t.Run(tc.name, func(t *testing.T) {
-			server := newTestServer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
 
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.tokenMaker), // apply the tested middleware
+				authMiddleware(server.tokenMaker, server.repository), // apply the tested middleware
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{}) // dummy handler
 				},
@@ -112,3 +124,245 @@ func TestAuthMiddleware(t *testing.T) {
 
 	}
 }
+
+func TestAuthMiddlewarePasswordChange(t *testing.T) {
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "TokenIssuedAfterChange",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(-time.Hour), nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name: "TokenIssuedBeforeChange",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(time.Second), nil)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(time.Time{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(time.Time{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, rec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			server := newTestServer(t, repo)
+
+			authPath := "/auth"
+			server.router.GET(authPath, authMiddleware(server.tokenMaker, server.repository), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, "user")
+			server.router.ServeHTTP(rec, req)
+
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name          string
+		requestID     string
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "ReusesClientID",
+			requestID: "client-request-id",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, "client-request-id", rec.Header().Get(requestIDHeader))
+			},
+		},
+		{
+			name:      "GeneratesMissingID",
+			requestID: "",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.NotEmpty(t, rec.Header().Get(requestIDHeader))
+			},
+		},
+		{
+			name:      "ReplacesTooLongID",
+			requestID: library.RandomString(maxRequestIDLength + 1),
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				id := rec.Header().Get(requestIDHeader)
+				require.NotEmpty(t, id)
+				require.LessOrEqual(t, len(id), maxRequestIDLength)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			path := "/request-id"
+			server.router.GET(path, func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			if tc.requestID != "" {
+				req.Header.Set(requestIDHeader, tc.requestID)
+			}
+
+			server.router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.config.HSTSMaxAge = 24 * time.Hour
+	require.NoError(t, server.applyRoutes())
+
+	// the headers are set on every response, including unknown routes
+	for _, path := range []string{"/v1/users/me", "/unknown"} {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+
+		server.router.ServeHTTP(rec, req)
+		require.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"), path)
+		require.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"), path)
+		require.Equal(t, "max-age=86400", rec.Header().Get("Strict-Transport-Security"), path)
+	}
+
+	server.config.HSTSMaxAge = 0
+	require.NoError(t, server.applyRoutes())
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(rec, req)
+	require.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	const limit = 16
+
+	testCases := []struct {
+		name          string
+		body          string
+		chunked       bool
+		checkResponse func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "AtLimit",
+			body: strings.Repeat("a", limit),
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rec.Code)
+				require.Equal(t, strings.Repeat("a", limit), rec.Body.String())
+			},
+		},
+		{
+			name: "TooLarge",
+			body: strings.Repeat("a", limit+1),
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				// rejected from the content length, before the handler runs
+				require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+			},
+		},
+		{
+			name:    "ChunkedAtLimit",
+			body:    strings.Repeat("a", limit),
+			chunked: true,
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name:    "ChunkedTooLarge",
+			body:    strings.Repeat("a", 4*limit),
+			chunked: true,
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+				require.Contains(t, rec.Body.String(), errBodyTooLarge.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/body", bodyLimitMiddleware(limit), func(ctx *gin.Context) {
+				data, err := ioutil.ReadAll(ctx.Request.Body)
+				if err != nil {
+					ctx.JSON(http.StatusBadRequest, errorResponse(err))
+					return
+				}
+				ctx.String(http.StatusOK, string(data))
+			})
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/body", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.chunked {
+				// the size is unknown up front, like with Transfer-Encoding: chunked
+				req.ContentLength = -1
+			}
+
+			router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
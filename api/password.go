@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/notify"
+)
+
+type changePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// changePassword sets a new password for the logged in user. every token issued before the change stops working,
+// including the one used for this request
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	user, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := auth.CheckPassword(user.HashedPassword, req.OldPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("old password is wrong")))
+		return
+	}
+
+	if req.NewPassword == req.OldPassword {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("new password must differ from the old one")))
+		return
+	}
+
+	hashedPw, ok := server.hashNewPassword(ctx, req.NewPassword, user.Username)
+	if !ok {
+		return
+	}
+
+	user, err = server.repository.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashedPw,
+		Audit:          auditMeta(ctx, user.Username),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// requestPasswordReset sends a single-use reset token to the user with the given email.
+// the response is the same whether the email exists or not, so it cannot be used to find out who has an account
+func (server *Server) requestPasswordReset(ctx *gin.Context) {
+	var req requestPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.repository.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusAccepted, gin.H{})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	token, hash, err := auth.NewSecretToken()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resetToken, err := server.repository.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		TokenHash: hash,
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(server.config.PasswordResetTokenDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.recordAuditEvent(ctx, user.Username, db.AuditActionPasswordResetRequested, db.AuditResourceUser, user.Username, nil, gin.H{"expiresAt": resetToken.ExpiresAt})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the following token to choose a new password. It expires at %s and can only be used once.\n\n%s\n\n"+
+			"If you didnt request a password reset, you can ignore this message.", resetToken.ExpiresAt.Format(time.RFC1123), token),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the username isnt known before the token is redeemed, so the policy cant check whether the password contains it
+	hashedPw, ok := server.hashNewPassword(ctx, req.NewPassword, "")
+	if !ok {
+		return
+	}
+
+	user, err := server.repository.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash:      auth.HashSecretToken(req.Token),
+		HashedPassword: hashedPw,
+		Audit:          auditMeta(ctx, ""),
+	})
+	if err != nil {
+		if err == db.ErrInvalidResetToken {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// hashNewPassword validates a new password against the policy and hashes it.
+// if it returns false, the error response has already been written
+func (server *Server) hashNewPassword(ctx *gin.Context, password, username string) (string, bool) {
+	if err := server.passwordPolicy.Validate(password, username); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return "", false
+	}
+
+	hashedPw, err := auth.HashPassword(password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return "", false
+	}
+
+	return hashedPw, true
+}
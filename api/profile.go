@@ -0,0 +1,199 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+type accountSummary struct {
+	Number   string     `json:"number"`
+	Nickname string     `json:"nickname"`
+	Type     string     `json:"type"`
+	Balance  int64      `json:"balance"`
+	Currency string     `json:"currency"`
+	ClosedAt *time.Time `json:"closedAt"` // null while the account is open
+}
+
+type profileResponse struct {
+	User     userResponse     `json:"user"`
+	Accounts []accountSummary `json:"accounts"`
+}
+
+// getProfile returns the logged in user together with a summary of all of their accounts
+func (server *Server) getProfile(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	user, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	accounts, err := server.repository.ListOwnerAccounts(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := profileResponse{User: newUserResponse(user), Accounts: make([]accountSummary, 0, len(accounts))}
+	for _, acc := range accounts {
+		summary := accountSummary{Number: acc.Number, Nickname: acc.Nickname, Type: acc.Type, Balance: acc.Balance, Currency: acc.Currency}
+		if acc.ClosedAt.Valid {
+			summary.ClosedAt = &acc.ClosedAt.Time
+		}
+		resp.Accounts = append(resp.Accounts, summary)
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type updateProfileRequest struct {
+	FullName *string `json:"fullName" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// updateProfile changes the full name and/or email of the logged in user. a new email has to be verified again,
+// tokens that were sent to the old address stop working since they are bound to it
+func (server *Server) updateProfile(ctx *gin.Context) {
+	var req updateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.FullName == nil && req.Email == nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("nothing to update")))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	before, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateUserProfileParams{Username: before.Username}
+	if req.FullName != nil {
+		arg.FullName = sql.NullString{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = sql.NullString{String: strings.TrimSpace(*req.Email), Valid: true}
+	}
+
+	user, err := server.repository.UpdateUserProfile(ctx, arg)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "unique_violation":
+				{
+					// the email is already taken by another user
+					ctx.JSON(http.StatusForbidden, pqErr.Error())
+					return
+				}
+			}
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := newUserResponse(user)
+
+	// only the names of the changed fields, the audit log cant be cleaned up when the user is deleted
+	err = server.recordAuditEvent(ctx, user.Username, db.AuditActionUserUpdated, db.AuditResourceUser, user.Username, nil, newProfileChanges(before, user))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// same as on signup, the change is done and a new token can be requested through the resend route
+	if user.Email != before.Email {
+		if err := server.sendEmailVerification(ctx, user); err != nil {
+			log.Printf("cannot send verification email to %s: %v", user.Username, err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// profileChanges records which fields of a profile were changed, without their values
+type profileChanges struct {
+	Changed []string `json:"changed"`
+}
+
+func newProfileChanges(before, after db.User) profileChanges {
+	changes := profileChanges{Changed: []string{}}
+	if before.FullName != after.FullName {
+		changes.Changed = append(changes.Changed, "fullName")
+	}
+	if before.Email != after.Email {
+		changes.Changed = append(changes.Changed, "email")
+	}
+	return changes
+}
+
+type deleteProfileRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// deleteProfile anonymizes the logged in user once all of their accounts are closed. the password has to be
+// confirmed, a leaked token alone shouldnt be enough to delete someone
+func (server *Server) deleteProfile(ctx *gin.Context) {
+	var req deleteProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	user, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := auth.CheckPassword(user.HashedPassword, req.Password); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("password is wrong")))
+		return
+	}
+
+	_, err = server.repository.DeleteUserTx(ctx, db.DeleteUserTxParams{
+		Username: user.Username,
+		Audit:    auditMeta(ctx, user.Username),
+	})
+	if err != nil {
+		switch err {
+		case db.ErrOpenAccounts:
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case db.ErrUserNotFound:
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProfileAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	open := generateRandomAccount(user.Username)
+	closed := generateRandomAccount(user.Username)
+	closed.Balance = 0
+	closed.ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	repo.EXPECT().ListOwnerAccounts(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return([]db.Account{open, closed}, nil)
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodGet, "/users/me", nil)
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp profileResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	require.Equal(t, user.Username, resp.User.Username)
+	require.Equal(t, user.Email, resp.User.Email)
+	require.Len(t, resp.Accounts, 2)
+	require.Equal(t, open.Balance, resp.Accounts[0].Balance)
+	require.Nil(t, resp.Accounts[0].ClosedAt)
+	require.NotNil(t, resp.Accounts[1].ClosedAt)
+}
+
+func TestUpdateProfileAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	newEmail := "new." + user.Email
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "FullNameOnly",
+			body: gin.H{"fullName": "New Name"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				updated := user
+				updated.FullName = "New Name"
+
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					UpdateUserProfile(gomock.Any(), gomock.Eq(db.UpdateUserProfileParams{
+						Username: user.Username,
+						FullName: sql.NullString{String: "New Name", Valid: true},
+					})).
+					Times(1).
+					Return(updated, nil)
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionUserUpdated)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.AppendAuditEventParams) (db.AuditEvent, error) {
+						requireNoPersonalData(t, arg, user, updated)
+						require.Equal(t, profileChanges{Changed: []string{"fullName"}}, arg.After)
+						return db.AuditEvent{}, nil
+					})
+				repo.EXPECT().CreateEmailVerificationToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, "New Name", resp.FullName)
+				require.NotNil(t, resp.EmailVerifiedAt)
+			},
+		},
+		{
+			name: "EmailChangeRequiresVerification",
+			body: gin.H{"email": newEmail},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				updated := user
+				updated.Email = newEmail
+				updated.EmailVerifiedAt = sql.NullTime{}
+
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					UpdateUserProfile(gomock.Any(), gomock.Eq(db.UpdateUserProfileParams{
+						Username: user.Username,
+						Email:    sql.NullString{String: newEmail, Valid: true},
+					})).
+					Times(1).
+					Return(updated, nil)
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionUserUpdated)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.AppendAuditEventParams) (db.AuditEvent, error) {
+						requireNoPersonalData(t, arg, user, updated)
+						require.Equal(t, profileChanges{Changed: []string{"email"}}, arg.After)
+						return db.AuditEvent{}, nil
+					})
+				repo.EXPECT().
+					CreateEmailVerificationToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateEmailVerificationTokenParams) (db.EmailVerificationToken, error) {
+						require.Equal(t, newEmail, arg.Email)
+						return db.EmailVerificationToken{TokenHash: arg.TokenHash, Username: arg.Username, Email: arg.Email, ExpiresAt: arg.ExpiresAt}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, newEmail, resp.Email)
+				require.Nil(t, resp.EmailVerifiedAt)
+			},
+		},
+		{
+			name: "EmailTaken",
+			body: gin.H{"email": newEmail},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().UpdateUserProfile(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, &pq.Error{Code: "23505"})
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidEmail",
+			body: gin.H{"email": "not-an-email"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().UpdateUserProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NothingToUpdate",
+			body: gin.H{},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().UpdateUserProfile(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPatch, "/users/me", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteProfileAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"password": password},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.DeleteUserTxParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						return db.User{Username: user.Username}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "OpenAccounts",
+			body: gin.H{"password": password},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, db.ErrOpenAccounts)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "WrongPassword",
+			body: gin.H{"password": password + "x"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "MissingPassword",
+			body: gin.H{},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodDelete, "/users/me", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -10,6 +12,7 @@ import (
 	"github.com/maxeth/go-bank-app/auth"
 	"github.com/maxeth/go-bank-app/config"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/notify"
 )
 
 type Server struct {
@@ -17,48 +20,247 @@ type Server struct {
 	repository db.Repository
 	router     *gin.Engine
 	tokenMaker auth.TokenMaker
+	notifier   notify.Notifier
+	events     *eventBroker
+
+	passwordPolicy auth.PasswordPolicy
 }
 
 func NewServer(conf config.Config, repo db.Repository) (*Server, error) {
-	tokenMaker, err := auth.NewPasetoMaker(conf.TokenSummetricKey)
+	tokenMaker, err := NewTokenMaker(conf)
 	if err != nil {
 		return nil, fmt.Errorf("cannt create token maker: %w", err)
 	}
+	notifier, err := NewNotifier(conf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create notifier: %w", err)
+	}
 	server := &Server{
 		config:     conf,
 		repository: repo,
 		tokenMaker: tokenMaker,
+		notifier:   notifier,
+		events:     newEventBroker(),
+		passwordPolicy: auth.PasswordPolicy{
+			MinLength:     conf.PasswordMinLength,
+			RequireUpper:  conf.PasswordRequireUpper,
+			RequireLower:  conf.PasswordRequireLower,
+			RequireDigit:  conf.PasswordRequireDigit,
+			RequireSymbol: conf.PasswordRequireSymbol,
+		},
 	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("accountnumber", validAccountNumber)
+		v.RegisterValidation("freetext", validFreeText)
+		v.RegisterValidation("reference", validReference)
+		v.RegisterValidation("eventtype", validEventType)
 	}
 
-	server.applyRoutes()
+	if err = server.applyRoutes(); err != nil {
+		return nil, fmt.Errorf("cannot apply routes: %w", err)
+	}
 
 	return server, nil
 }
 
-func (server *Server) applyRoutes() {
+func (server *Server) applyRoutes() error {
 	router := gin.Default()
+	router.Use(requestIDMiddleware(), securityHeadersMiddleware(server.config.HSTSMaxAge))
+	if cors := newCORSPolicy(server.config); cors.enabled() {
+		router.Use(corsMiddleware(cors))
+	}
+	router.Use(bodyLimitMiddleware(server.config.MaxBodySize))
+
+	identities, err := parseClientIdentities(server.config.TLSClientIdentities)
+	if err != nil {
+		return err
+	}
+	router.Use(clientIdentityMiddleware(identities))
+
+	deprecations, err := parseDeprecations(server.config.APIDeprecations)
+	if err != nil {
+		return err
+	}
+	// the unversioned routes were replaced by /v1, they still work but respond with deprecation headers
+	var unversioned deprecation
+	if server.config.APIUnversionedDeprecated != "" {
+		unversioned.at, err = time.Parse(deprecationDateFormat, server.config.APIUnversionedDeprecated)
+		if err != nil {
+			return err
+		}
+	}
+	if server.config.APIUnversionedSunset != "" {
+		unversioned.sunset, err = time.Parse(deprecationDateFormat, server.config.APIUnversionedSunset)
+		if err != nil {
+			return err
+		}
+	}
+
+	versions := []apiVersion{
+		{name: "v1", routes: server.v1Routes()},
+		{name: "v2", routes: server.v2Routes()},
+	}
+	if err = mountVersions(router, versions, deprecations, unversioned); err != nil {
+		return err
+	}
+
+	server.router = router
+	return nil
+}
+
+// v1Routes are the routes of the first api version
+func (server *Server) v1Routes() routes {
+	router := newRoutes()
 
 	router.POST("/users", server.createUser)
 	router.POST("/users/login", server.loginUser)
+	router.POST("/users/login/2fa", server.loginTwoFactor)
+	router.POST("/users/password-reset", server.requestPasswordReset)
+	router.POST("/users/password-reset/confirm", server.resetPassword)
+	router.POST("/users/verify-email", server.verifyEmail)
+	router.GET("/token-keys", server.listTokenKeys)
 
 	// create a group of routes that are going to be protected
-	authGroup := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	authGroup := router.Use(authMiddleware(server.tokenMaker, server.repository))
+
+	authGroup.GET("/users/me", server.getProfile)
+	authGroup.PATCH("/users/me", server.updateProfile)
+	authGroup.DELETE("/users/me", server.deleteProfile)
+	authGroup.PUT("/users/me/password", server.changePassword)
+	authGroup.POST("/users/verify-email/resend", server.resendEmailVerification)
+	authGroup.POST("/users/me/totp", server.enrollTOTP)
+	authGroup.POST("/users/me/totp/confirm", server.confirmTOTP)
+	authGroup.DELETE("/users/me/totp", server.disableTOTP)
+
+	// routes that create accounts or move money can be restricted to users with a verified email
+	var verified []gin.HandlerFunc
+	if server.config.RequireVerifiedEmail {
+		verified = append(verified, verifiedEmailMiddleware(server.repository))
+	}
 
-	authGroup.POST("/accounts", server.createAccount)
-	authGroup.GET("/accounts/:id", server.getAccount)
+	authGroup.POST("/accounts", append(verified, server.createAccount)...)
+	authGroup.GET("/accounts/:number", server.getAccount)
+	authGroup.PATCH("/accounts/:number", server.updateAccount)
 	authGroup.GET("/accounts", server.listAccounts)
+	authGroup.POST("/accounts/:number/close", server.closeAccount)
+	authGroup.GET("/accounts/:number/transfers", server.listAccountTransfers)
+	authGroup.GET("/accounts/:number/events", server.streamAccountEvents)
+	authGroup.GET("/accounts/:number/members", server.listAccountMembers)
+	authGroup.DELETE("/accounts/:number/members/:username", server.removeAccountMember)
+	authGroup.POST("/accounts/:number/invitations", server.inviteAccountMember)
+	authGroup.PUT("/accounts/:number/owner", server.transferAccountOwnership)
+	authGroup.GET("/invitations", server.listAccountInvitations)
+	authGroup.POST("/invitations/:id/accept", server.acceptAccountInvitation)
+	authGroup.DELETE("/invitations/:id", server.deleteAccountInvitation)
 
-	authGroup.POST("/transfers", server.createTransfer)
+	authGroup.POST("/transfers", append(verified, server.createTransfer)...)
+	authGroup.POST("/transfers/quote", server.quoteTransfer)
+	authGroup.POST("/transfers/batch", append(verified, server.createTransferBatch)...)
+	authGroup.GET("/transfers/batch/:id", server.getTransferBatch)
 
-	server.router = router
+	authGroup.POST("/beneficiaries", server.createBeneficiary)
+	authGroup.GET("/beneficiaries", server.listBeneficiaries)
+	authGroup.GET("/beneficiaries/:id", server.getBeneficiary)
+	authGroup.PATCH("/beneficiaries/:id", server.updateBeneficiary)
+	authGroup.DELETE("/beneficiaries/:id", server.deleteBeneficiary)
+	authGroup.GET("/payees/:number", server.confirmPayee)
+
+	authGroup.POST("/webhooks", server.createWebhook)
+	authGroup.GET("/webhooks", server.listWebhooks)
+	authGroup.GET("/webhooks/:id", server.getWebhook)
+	authGroup.DELETE("/webhooks/:id", server.deleteWebhook)
+	authGroup.GET("/webhooks/:id/deliveries", server.listWebhookDeliveries)
+	authGroup.POST("/webhooks/:id/deliveries/:deliveryID/retry", server.retryWebhookDelivery)
+
+	// routes that are only accessible to admins
+	adminGroup := router.Use(authMiddleware(server.tokenMaker, server.repository), adminMiddleware(server.repository))
+
+	adminGroup.GET("/audit-events", server.listAuditEvents)
+	adminGroup.GET("/audit-events/verify", server.verifyAuditChain)
+	adminGroup.GET("/interest-rates", server.listInterestRates)
+	adminGroup.PUT("/interest-rates", server.setInterestRate)
+	adminGroup.PUT("/accounts/:number/interest-rate", server.setAccountInterestRate)
+	adminGroup.GET("/fee-rules", server.listFeeRules)
+	adminGroup.PUT("/fee-rules", server.setFeeRule)
+	adminGroup.DELETE("/fee-rules/:id", server.deleteFeeRule)
+
+	// read-only routes for internal services calling with a client certificate, see TLS_CLIENT_IDENTITIES
+	serviceGroup := router.Use(serviceMiddleware())
+
+	serviceGroup.GET("/internal/audit-events", server.listAuditEvents)
+	serviceGroup.GET("/internal/audit-events/verify", server.verifyAuditChain)
+	serviceGroup.GET("/internal/interest-rates", server.listInterestRates)
+	serviceGroup.GET("/internal/fee-rules", server.listFeeRules)
+
+	return router
+}
+
+// v2Routes are the routes that changed in the second api version, e.g. because a response got renamed fields.
+// /v2 is mounted once it has its first route and serves every route of v1 that isnt listed here
+func (server *Server) v2Routes() routes {
+	return newRoutes()
+}
+
+// NewTokenMaker creates the token maker selected in the config. a TOKEN_KEYS list takes precedence over the single TOKEN_SYMMETRIC_KEY.
+// the grpc api uses it as well, so tokens work for both
+func NewTokenMaker(conf config.Config) (auth.TokenMaker, error) {
+	keys := auth.KeySet{Keys: []auth.Key{{Secret: []byte(conf.TokenSummetricKey)}}}
+	if conf.TokenKeys != "" {
+		var err error
+		keys, err = auth.ParseKeySet(conf.TokenKeys, conf.TokenCurrentKeyID, conf.TokenAsymmetric)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch conf.TokenMaker {
+	case config.TokenMakerJWT:
+		return auth.NewJWTMakerFromKeys(keys)
+	case config.TokenMakerPaseto, "":
+		return auth.NewPasetoMakerFromKeys(keys)
+	default:
+		return nil, fmt.Errorf("unsupported token maker %q", conf.TokenMaker)
+	}
+}
+
+// NewNotifier creates the notifier selected in the config
+func NewNotifier(conf config.Config) (notify.Notifier, error) {
+	switch conf.Notifier {
+	case config.NotifierFile:
+		return notify.NewFileNotifier(conf.NotifierFile)
+	case config.NotifierSMTP:
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Address:  conf.SMTPAddress,
+			Username: conf.SMTPUsername,
+			Password: conf.SMTPPassword,
+			From:     conf.SMTPFrom,
+		})
+	case config.NotifierLog, "":
+		return notify.NewLogNotifier(nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported notifier %q", conf.Notifier)
+	}
 }
 
+// Start serves https if a certificate is configured, plain http otherwise
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	tlsConfig, err := server.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		return server.router.Run(address)
+	}
+
+	httpServer := &http.Server{
+		Addr:      address,
+		Handler:   server.router,
+		TLSConfig: tlsConfig,
+	}
+	// the certificate comes from tlsConfig.GetCertificate, so no files are passed here
+	return httpServer.ListenAndServeTLS("", "")
 }
 
 func errorResponse(err error) gin.H {
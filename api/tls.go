@@ -0,0 +1,186 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/config"
+)
+
+// how often the certificate files are checked for changes, renewed certificates are picked up without a restart
+const tlsReloadInterval = 10 * time.Second
+
+// the service identity of requests with a mapped client certificate is accessible under this key in gin.Context
+const serviceIdentityKey = "service_identity"
+
+// certReloader serves the certificate from the cert and key files and reloads it once one of the files changed.
+// a pair that cannot be loaded, e.g. because only one of the files was replaced yet, keeps the previous certificate
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTimes  [2]time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= r.interval {
+		if err := r.reload(); err != nil {
+			log.Printf("cannot reload tls certificate, keeping the current one: %v", err)
+		}
+	}
+
+	return r.cert, nil
+}
+
+// reload loads the pair if the modification time of one of the files changed. the caller holds the lock
+func (r *certReloader) reload() error {
+	r.checkedAt = time.Now()
+
+	var modTimes [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+
+	return nil
+}
+
+// tlsConfig returns the tls settings of the TLS_* config, or nil if the server listens in plain http
+func (server *Server) tlsConfig() (*tls.Config, error) {
+	return NewTLSConfig(server.config)
+}
+
+// NewTLSConfig returns the tls settings of the TLS_* config, or nil if no certificate is configured. the grpc
+// server is served with the same settings as the http api
+func NewTLSConfig(conf config.Config) (*tls.Config, error) {
+	if conf.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(conf.TLSCertFile, conf.TLSKeyFile, tlsReloadInterval)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load tls certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if conf.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(conf.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client ca file contains no certificate")
+		}
+
+		tlsConfig.ClientCAs = pool
+		// browsers have no client certificate, they keep using access tokens on the same listener
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if conf.TLSClientAuthRequired {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseClientIdentities parses a "common-name=service,..." list mapping the subject common name of client
+// certificates to the identity of the calling service
+func parseClientIdentities(spec string) (map[string]string, error) {
+	identities := map[string]string{}
+
+	for _, entry := range splitList(spec) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid client identity %q, expected common-name=service", entry)
+		}
+		identities[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return identities, nil
+}
+
+// clientIdentityMiddleware stores the service identity of requests with a verified client certificate whose
+// subject is listed in TLS_CLIENT_IDENTITIES. certificates of unknown subjects dont get an identity
+func clientIdentityMiddleware(identities map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		state := ctx.Request.TLS
+		// only chains verified against the client ca count, an unverified certificate says nothing
+		if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			if service, ok := identities[state.VerifiedChains[0][0].Subject.CommonName]; ok {
+				ctx.Set(serviceIdentityKey, service)
+			}
+		}
+
+		ctx.Next()
+	}
+}
+
+// serviceMiddleware only lets requests through that come from one of the given services, or from any mapped
+// service if none are given. it has to be applied after clientIdentityMiddleware
+func serviceMiddleware(services ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		service := ctx.GetString(serviceIdentityKey)
+		if service == "" {
+			err := errors.New("client certificate of an internal service required")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
+		if len(services) > 0 {
+			allowed := false
+			for _, s := range services {
+				allowed = allowed || s == service
+			}
+			if !allowed {
+				err := fmt.Errorf("service %s is not allowed to call this route", service)
+				ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates for tests, like a small internal pki
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the pem encoded certificate and key for the common name, usable by servers for localhost and by clients
+func (ca testCA) issue(t *testing.T, commonName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes the file and moves its modification time, so reloads dont depend on the file system resolution
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	certPEM, keyPEM := ca.issue(t, "first")
+	start := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, certPEM, start)
+	writeFile(t, keyFile, keyPEM, start)
+
+	reloader, err := newCertReloader(certFile, keyFile, 0)
+	require.NoError(t, err)
+	requireCommonName(t, reloader, "first")
+
+	// a renewed pair is picked up with the next handshake
+	certPEM, keyPEM = ca.issue(t, "second")
+	writeFile(t, certFile, certPEM, start.Add(time.Second))
+	writeFile(t, keyFile, keyPEM, start.Add(time.Second))
+	requireCommonName(t, reloader, "second")
+
+	// the new certificate is there, the matching key isnt yet
+	certPEM, _ = ca.issue(t, "third")
+	writeFile(t, certFile, certPEM, start.Add(2*time.Second))
+	requireCommonName(t, reloader, "second")
+
+	// files are only checked once per interval
+	reloader.interval = time.Hour
+	certPEM, keyPEM = ca.issue(t, "fourth")
+	writeFile(t, certFile, certPEM, start.Add(3*time.Second))
+	writeFile(t, keyFile, keyPEM, start.Add(3*time.Second))
+	requireCommonName(t, reloader, "second")
+
+	_, err = newCertReloader(filepath.Join(dir, "missing.crt"), keyFile, 0)
+	require.Error(t, err)
+}
+
+func requireCommonName(t *testing.T, reloader *certReloader, commonName string) {
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, commonName, leaf.Subject.CommonName)
+}
+
+func TestTLSServer(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, data, 0600))
+		return path
+	}
+	serverCert, serverKey := ca.issue(t, "localhost")
+	certFile, keyFile := write("tls.crt", serverCert), write("tls.key", serverKey)
+	caFile := write("ca.crt", ca.pem)
+
+	client := func(ca testCA, commonName string) tls.Certificate {
+		if commonName == "" {
+			return tls.Certificate{}
+		}
+		certPEM, keyPEM := ca.issue(t, commonName)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		return cert
+	}
+
+	testCases := []struct {
+		name          string
+		clientCA      string
+		required      bool
+		clientCert    tls.Certificate
+		path          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, res *http.Response, err error)
+	}{
+		{
+			name: "HTTPS",
+			path: "/v1/users/me",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			},
+		},
+		{
+			name:       "Service",
+			clientCA:   caFile,
+			clientCert: client(ca, "ledger.internal"),
+			path:       "/v1/internal/fee-rules",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListFeeRules(gomock.Any()).Times(1).Return([]db.FeeRule{}, nil)
+			},
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, res.StatusCode)
+			},
+		},
+		{
+			name:     "NoClientCertificate",
+			clientCA: caFile,
+			path:     "/v1/internal/fee-rules",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			},
+		},
+		{
+			name:       "UnknownService",
+			clientCA:   caFile,
+			clientCert: client(ca, "someone.internal"),
+			path:       "/v1/internal/fee-rules",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			},
+		},
+		{
+			name:       "OtherCA",
+			clientCA:   caFile,
+			clientCert: client(otherCA, "ledger.internal"),
+			path:       "/v1/internal/fee-rules",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name:     "ClientCertificateRequired",
+			clientCA: caFile,
+			required: true,
+			path:     "/v1/users/me",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			// without a client ca, certificates arent verified and dont identify anyone
+			name:       "ClientCertificateWithoutCA",
+			clientCert: client(ca, "ledger.internal"),
+			path:       "/v1/internal/fee-rules",
+			checkResponse: func(t *testing.T, res *http.Response, err error) {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			if tc.buildStubs != nil {
+				tc.buildStubs(repo)
+			}
+
+			server := newTestServer(t, repo)
+			server.config.TLSCertFile = certFile
+			server.config.TLSKeyFile = keyFile
+			server.config.TLSClientCAFile = tc.clientCA
+			server.config.TLSClientAuthRequired = tc.required
+			server.config.TLSClientIdentities = "ledger.internal=ledger"
+			require.NoError(t, server.applyRoutes())
+
+			tlsConfig, err := server.tlsConfig()
+			require.NoError(t, err)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			httpServer := &http.Server{Handler: server.router, TLSConfig: tlsConfig, ErrorLog: log.New(ioutil.Discard, "", 0)}
+			go httpServer.ServeTLS(listener, "", "")
+			defer httpServer.Close()
+
+			roots := x509.NewCertPool()
+			roots.AppendCertsFromPEM(ca.pem)
+			clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+			if tc.clientCert.Certificate != nil {
+				clientConfig.Certificates = []tls.Certificate{tc.clientCert}
+			}
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+			res, err := httpClient.Get(fmt.Sprintf("https://%s%s", listener.Addr(), tc.path))
+			if err == nil {
+				defer res.Body.Close()
+			}
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
+
+func TestServiceMiddleware(t *testing.T) {
+	testCases := []struct {
+		name    string
+		service string
+		allowed []string
+		code    int
+	}{
+		{name: "AnyService", service: "ledger", code: http.StatusOK},
+		{name: "AllowedService", service: "ledger", allowed: []string{"reporting", "ledger"}, code: http.StatusOK},
+		{name: "OtherService", service: "ledger", allowed: []string{"reporting"}, code: http.StatusForbidden},
+		{name: "NoService", allowed: []string{"reporting"}, code: http.StatusUnauthorized},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/internal", func(ctx *gin.Context) {
+				if tc.service != "" {
+					ctx.Set(serviceIdentityKey, tc.service)
+				}
+			}, serviceMiddleware(tc.allowed...), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/internal", nil)
+			require.NoError(t, err)
+
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tc.code, rec.Code)
+		})
+	}
+}
+
+func TestParseClientIdentities(t *testing.T) {
+	identities, err := parseClientIdentities(" ledger.internal = ledger,,reporting.internal=reporting ")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ledger.internal": "ledger", "reporting.internal": "reporting"}, identities)
+
+	for _, spec := range []string{"ledger.internal", "=ledger", "ledger.internal="} {
+		_, err := parseClientIdentities(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lists the public keys other services can verify our access tokens with. empty unless asymmetric tokens are enabled
+func (server *Server) listTokenKeys(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, server.tokenMaker.PublicKeys())
+}
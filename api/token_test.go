@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maxeth/go-bank-app/auth"
+	"github.com/maxeth/go-bank-app/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTokenKeysAPI(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	_, err := rand.Read(seed)
+	require.NoError(t, err)
+
+	conf := config.Config{
+		TokenMaker:          config.TokenMakerJWT,
+		TokenAsymmetric:     true,
+		TokenKeys:           "2021-07:" + base64.StdEncoding.EncodeToString(seed),
+		TokenCurrentKeyID:   "2021-07",
+		AccessTokenDuration: time.Minute,
+	}
+	server, err := NewServer(conf, nil)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/token-keys", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var keys []auth.PublicKey
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &keys))
+	require.Len(t, keys, 1)
+	require.Equal(t, "2021-07", keys[0].ID)
+	require.Equal(t, "EdDSA", keys[0].Algorithm)
+
+	// the published key is enough to verify tokens issued by the server
+	publicKey, err := base64.StdEncoding.DecodeString(keys[0].Key)
+	require.NoError(t, err)
+
+	verifier, err := auth.NewJWTMakerFromKeys(auth.KeySet{Keys: []auth.Key{{ID: keys[0].ID, PublicKey: publicKey}}})
+	require.NoError(t, err)
+
+	token, err := server.tokenMaker.CreateToken("alice", time.Minute)
+	require.NoError(t, err)
+
+	payload, err := verifier.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, "alice", payload.Username)
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+var (
+	errInvalidSecondFactor = errors.New("invalid two-factor code")
+	errTOTPNotEnabled      = errors.New("two-factor authentication is not enabled")
+	errSecondFactorLocked  = errors.New("too many invalid two-factor codes, try again later")
+)
+
+type enrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// uri, usually shown as a qr code
+}
+
+// enrollTOTP creates a new secret for the logged in user. two-factor authentication is only enabled
+// once the user proved to have set up the secret by confirming it with a valid code
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	user, err := server.repository.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// replaces an unconfirmed enrollment, but never an enabled one
+	_, err = server.repository.UpsertPendingTOTP(ctx, db.UpsertPendingTOTPParams{
+		Username: user.Username,
+		Secret:   secret,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("two-factor authentication is already enabled")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret: secret,
+		URI:    auth.TOTPURI(server.config.TOTPIssuer, user.Email, secret),
+	})
+}
+
+type totpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type confirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"` // only shown once, each of them can replace a totp code a single time
+}
+
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var req totpCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	totp, err := server.repository.GetUserTOTP(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(db.ErrTOTPNotPending))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(db.ErrTOTPNotPending))
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidSecondFactor))
+		return
+	}
+
+	codes, err := auth.NewRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		if hashes[i], err = auth.HashPassword(code); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	_, err = server.repository.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:           authPayload.Username,
+		Step:               step,
+		RecoveryCodeHashes: hashes,
+		Audit:              auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		if err == db.ErrTOTPNotPending {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTOTPResponse{RecoveryCodes: codes})
+}
+
+// disableTOTP turns off two-factor authentication. it requires a current code or a recovery code,
+// so a stolen access token alone isnt enough
+func (server *Server) disableTOTP(ctx *gin.Context) {
+	var req totpCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	if !server.checkSecondFactor(ctx, authPayload.Username, req.Code, true) {
+		return
+	}
+
+	err := server.repository.DisableTOTPTx(ctx, db.DisableTOTPTxParams{
+		Username: authPayload.Username,
+		Audit:    auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+type loginTwoFactorRequest struct {
+	TwoFactorToken string `json:"twoFactorToken" binding:"required"`
+	Code           string `json:"code" binding:"required"` // totp code or recovery code
+}
+
+// loginTwoFactor is the second step of a login for users with two-factor authentication
+func (server *Server) loginTwoFactor(ctx *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload, err := server.tokenMaker.VerifyToken(req.TwoFactorToken)
+	if err != nil || payload.Scope != auth.ScopeTwoFactor {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(auth.ErrInvalidToken))
+		return
+	}
+
+	if !server.checkSecondFactor(ctx, payload.Username, req.Code, true) {
+		if auditErr := server.recordLoginFailure(ctx, payload.Username, "wrong second factor"); auditErr != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(auditErr))
+		}
+		return
+	}
+
+	user, err := server.repository.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.completeLogin(ctx, user)
+}
+
+// checkSecondFactor validates a totp code of the user, and a recovery code as well if allowRecovery is set.
+// accepted codes are being used up. if it returns false, the error response has already been written
+func (server *Server) checkSecondFactor(ctx *gin.Context, username, code string, allowRecovery bool) bool {
+	err := server.verifySecondFactor(ctx, username, code, allowRecovery)
+	switch err {
+	case nil:
+		return true
+	case errInvalidSecondFactor:
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	case errTOTPNotEnabled:
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+	case errSecondFactorLocked:
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+	return false
+}
+
+func (server *Server) verifySecondFactor(ctx *gin.Context, username, code string, allowRecovery bool) error {
+	totp, err := server.repository.GetUserTOTP(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errTOTPNotEnabled
+		}
+		return err
+	}
+	if !totp.ConfirmedAt.Valid {
+		return errTOTPNotEnabled
+	}
+
+	// every attempt is counted before the code is checked, so parallel requests cant guess past the limit
+	_, err = server.repository.ClaimSecondFactorAttempt(ctx, db.ClaimSecondFactorAttemptParams{
+		Username:     username,
+		MaxFailures:  auth.MaxSecondFactorFailures,
+		LockedBefore: time.Now().Add(-auth.SecondFactorLockout),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errSecondFactorLocked
+		}
+		return err
+	}
+
+	if step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		// fails if a code of this step has been accepted before
+		_, err = server.repository.UseTOTPStep(ctx, db.UseTOTPStepParams{Username: username, LastUsedStep: step})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errInvalidSecondFactor
+			}
+			return err
+		}
+		return server.repository.ResetSecondFactorAttempts(ctx, username)
+	}
+
+	// anything else than a recovery code would only cost a bcrypt comparison per stored code
+	if !allowRecovery || !auth.IsRecoveryCode(code) {
+		return errInvalidSecondFactor
+	}
+
+	codes, err := server.repository.ListUnusedRecoveryCodes(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	code = auth.NormalizeRecoveryCode(code)
+	for _, rc := range codes {
+		if auth.CheckPassword(rc.CodeHash, code) != nil {
+			continue
+		}
+
+		if _, err = server.repository.UseRecoveryCode(ctx, rc.ID); err != nil {
+			if err == sql.ErrNoRows {
+				// used by a concurrent request
+				return errInvalidSecondFactor
+			}
+			return err
+		}
+		if err = server.repository.ResetSecondFactorAttempts(ctx, username); err != nil {
+			return err
+		}
+
+		return server.recordAuditEvent(ctx, username, db.AuditActionRecoveryCodeUsed, db.AuditResourceUser, username, nil, gin.H{"remaining": len(codes) - 1})
+	}
+
+	return errInvalidSecondFactor
+}
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// returns an enabled totp enrollment of the user together with its secret
+func randomUserTOTP(t *testing.T, username string) db.UserTotp {
+	secret, err := auth.NewTOTPSecret()
+	require.NoError(t, err)
+
+	return db.UserTotp{
+		Username:    username,
+		Secret:      secret,
+		ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	return totpCodeAt(t, secret, time.Now())
+}
+
+func totpCodeAt(t *testing.T, secret string, at time.Time) string {
+	code, err := auth.TOTPCode(secret, at)
+	require.NoError(t, err)
+	return code
+}
+
+// expectSecondFactorAttempt expects one counted attempt of the user. locked fails it like for a user who is locked
+// out, an attempt with unexpected arguments fails with an error and so with a 500
+func expectSecondFactorAttempt(repo *mockdb.MockRepository, username string, locked bool) {
+	repo.EXPECT().
+		ClaimSecondFactorAttempt(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.ClaimSecondFactorAttemptParams) (db.UserTotp, error) {
+			lockedBefore := time.Now().Add(-auth.SecondFactorLockout)
+			if arg.Username != username || arg.MaxFailures != auth.MaxSecondFactorFailures || arg.LockedBefore.After(lockedBefore) {
+				return db.UserTotp{}, fmt.Errorf("unexpected second factor attempt %+v", arg)
+			}
+			if locked {
+				return db.UserTotp{}, sql.ErrNoRows
+			}
+			return db.UserTotp{Username: username, FailedAttempts: 1}, nil
+		})
+}
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					UpsertPendingTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpsertPendingTOTPParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserTotp{Username: arg.Username, Secret: arg.Secret}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp enrollTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.Secret)
+				require.True(t, strings.HasPrefix(resp.URI, "otpauth://totp/go-bank-app:"))
+				require.Contains(t, resp.URI, "secret="+resp.Secret)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().UpsertPendingTOTP(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodPost, "/users/me/totp", nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	pending := randomUserTOTP(t, user.Username)
+	pending.ConfirmedAt = sql.NullTime{}
+	enabled := randomUserTOTP(t, user.Username)
+
+	// the step is taken from when the code was generated, the request can fall into the next one
+	var codeTime time.Time
+
+	testCases := []struct {
+		name          string
+		code          func(t *testing.T) string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(t *testing.T) string {
+				codeTime = time.Now()
+				return totpCodeAt(t, pending.Secret, codeTime)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(pending, nil)
+				repo.EXPECT().
+					EnableTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.EnableTOTPTxParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, auth.TOTPStep(codeTime), arg.Step)
+						require.Len(t, arg.RecoveryCodeHashes, auth.RecoveryCodeCount)
+						return enabled, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp confirmTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp.RecoveryCodes, auth.RecoveryCodeCount)
+			},
+		},
+		{
+			name: "WrongCode",
+			code: func(t *testing.T) string { return currentTOTPCode(t, enabled.Secret) },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(pending, nil)
+				repo.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			code: func(t *testing.T) string { return currentTOTPCode(t, enabled.Secret) },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(enabled, nil)
+				repo.EXPECT().EnableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			code: func(t *testing.T) string { return "123456" },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"code": tc.code(t)})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/me/totp/confirm", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginTwoFactorAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	totp := randomUserTOTP(t, user.Username)
+
+	recoveryCode := "abcde-fghij"
+	recoveryHash, err := auth.HashPassword(recoveryCode)
+	require.NoError(t, err)
+
+	var codeTime time.Time
+
+	testCases := []struct {
+		name          string
+		code          func(t *testing.T) string
+		scope         string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(t *testing.T) string {
+				codeTime = time.Now()
+				return totpCodeAt(t, totp.Secret, codeTime)
+			},
+			scope: auth.ScopeTwoFactor,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, user.Username, false)
+				repo.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UseTOTPStepParams) (db.UserTotp, error) {
+						if arg != (db.UseTOTPStepParams{Username: user.Username, LastUsedStep: auth.TOTPStep(codeTime)}) {
+							return db.UserTotp{}, fmt.Errorf("unexpected step %+v", arg)
+						}
+						return totp, nil
+					})
+				repo.EXPECT().ResetSecondFactorAttempts(gomock.Any(), gomock.Eq(user.Username)).Times(1)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginSucceeded)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.AccessToken)
+			},
+		},
+		{
+			name:  "ReplayedCode",
+			code:  func(t *testing.T) string { return currentTOTPCode(t, totp.Secret) },
+			scope: auth.ScopeTwoFactor,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, user.Username, false)
+				repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				repo.EXPECT().ResetSecondFactorAttempts(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).Times(1)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			// a wrong totp code isnt compared against the recovery codes
+			name:  "WrongCode",
+			code:  func(t *testing.T) string { return totpCodeAt(t, totp.Secret, time.Now().Add(-time.Hour)) },
+			scope: auth.ScopeTwoFactor,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, user.Username, false)
+				repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().ListUnusedRecoveryCodes(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).Times(1)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "LockedOut",
+			code:  func(t *testing.T) string { return currentTOTPCode(t, totp.Secret) },
+			scope: auth.ScopeTwoFactor,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, user.Username, true)
+				repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).Times(1)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			name:  "RecoveryCode",
+			code:  func(t *testing.T) string { return "ABCDE FGHIJ" },
+			scope: auth.ScopeTwoFactor,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, user.Username, false)
+				repo.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RecoveryCode{{ID: 7, Username: user.Username, CodeHash: recoveryHash}}, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Eq(int64(7))).Times(1).Return(db.RecoveryCode{ID: 7}, nil)
+				repo.EXPECT().ResetSecondFactorAttempts(gomock.Any(), gomock.Eq(user.Username)).Times(1)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionRecoveryCodeUsed)).Times(1)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginSucceeded)).Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "AccessTokenInsteadOfChallenge",
+			code:  func(t *testing.T) string { return currentTOTPCode(t, totp.Secret) },
+			scope: auth.ScopeAccess,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			token, err := server.tokenMaker.CreateScopedToken(user.Username, tc.scope, time.Minute)
+			require.NoError(t, err)
+
+			data, err := json.Marshal(gin.H{"twoFactorToken": token, "code": tc.code(t)})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login/2fa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestTwoFactorTokenIsNoAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	token, err := server.tokenMaker.CreateScopedToken("user", auth.ScopeTwoFactor, time.Minute)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/accounts?page=1&limit=5", nil)
+	require.NoError(t, err)
+	req.Header.Set(authHeaderKey, authTypeBearer+" "+token)
+
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestDisableTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	totp := randomUserTOTP(t, user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(totp, nil)
+	expectSecondFactorAttempt(repo, user.Username, false)
+	repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(totp, nil)
+	repo.EXPECT().ResetSecondFactorAttempts(gomock.Any(), gomock.Eq(user.Username)).Times(1)
+	repo.EXPECT().
+		DisableTOTPTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.DisableTOTPTxParams) error {
+			require.Equal(t, user.Username, arg.Username)
+			return nil
+		})
+	allowAnyToken(repo)
+
+	server := newTestServer(t, repo)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{"code": currentTOTPCode(t, totp.Secret)})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodDelete, "/users/me/totp", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
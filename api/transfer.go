@@ -1,82 +1,430 @@
 package api
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/maxeth/go-bank-app/auth"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
 )
 
 type createTransferRequest struct {
-	FromID   int64  `json:"fromAccountID" binding:"required,min=1"`
-	ToID     int64  `json:"toAccountID" binding:"required,min=1"`
-	Amount   int64  `json:"amount" binding:"required,min=0"`      // 100 is 1.00[currency], so 1 would be 1 cent in case the currency is divisable
-	Currency string `json:"currency" binding:"required,currency"` // currency validation method is implemented in api/validation/currency.go
+	FromAccount   string `json:"fromAccount" binding:"required,accountnumber"` // public account numbers, see api/validator.go
+	ToAccount     string `json:"toAccount" binding:"omitempty,accountnumber"`  // either toAccount or beneficiaryID is required
+	BeneficiaryID int64  `json:"beneficiaryID" binding:"omitempty,min=1"`      // a beneficiary of the caller, see api/beneficiary.go
+	Amount        int64  `json:"amount" binding:"required,min=0"`              // 100 is 1.00[currency], so 1 would be 1 cent in case the currency is divisable
+	Currency      string `json:"currency" binding:"required,currency"`         // currency validation method is implemented in api/validation/currency.go
+	TOTPCode      string `json:"totpCode"`                                     // required for amounts above the configured step-up amount
+	QuoteToken    string `json:"quoteToken"`                                   // executes a quote of POST /transfers/quote, see quoteTransfer
+	// optional details, the description is shown to both parties and the reference is meant for invoice numbers etc.
+	Description string          `json:"description" binding:"omitempty,max=140,freetext"`
+	Reference   string          `json:"reference" binding:"omitempty,max=35,reference"`
+	Metadata    json.RawMessage `json:"metadata"` // flat object with string values, see validateTransferMetadata
+}
+
+const (
+	maxMetadataKeys        = 20
+	maxMetadataKeyLength   = 40
+	maxMetadataValueLength = 200
+	maxMetadataSize        = 1024 // bytes of the encoded object, the column allows twice as much
+)
+
+// validateTransferMetadata checks that the metadata is a small flat object of strings, so it can be stored and
+// displayed without any further checks. a missing or null field counts as empty
+func validateTransferMetadata(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if len(raw) > maxMetadataSize {
+		return nil, fmt.Errorf("metadata must not be larger than %d bytes", maxMetadataSize)
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, errors.New("metadata must be an object with string values")
+	}
+	if len(fields) > maxMetadataKeys {
+		return nil, fmt.Errorf("metadata must not have more than %d keys", maxMetadataKeys)
+	}
+	for key, value := range fields {
+		if key == "" || utf8.RuneCountInString(key) > maxMetadataKeyLength || !isPrintable(key) {
+			return nil, fmt.Errorf("invalid metadata key %q", key)
+		}
+		if utf8.RuneCountInString(value) > maxMetadataValueLength || !isPrintable(value) {
+			return nil, fmt.Errorf("invalid metadata value for key %q", key)
+		}
+	}
+
+	// store the object re-encoded, so duplicate keys and odd whitespace dont end up in the database
+	return json.Marshal(fields)
+}
+
+// transferResponse only contains what the sender is allowed to see, the receivers balance and the internal ids stay hidden
+type transferResponse struct {
+	ID          uuid.UUID       `json:"id"` // public id of the transfer, the transferID of its events
+	FromAccount string          `json:"fromAccount"`
+	ToAccount   string          `json:"toAccount"`
+	Amount      int64           `json:"amount"`
+	Currency    string          `json:"currency"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Description string          `json:"description"`
+	Reference   string          `json:"reference"`
+	Metadata    json.RawMessage `json:"metadata"`
+	Fee         int64           `json:"fee"`               // charged to the sender on top of the amount
+	Balance     *int64          `json:"balance,omitempty"` // balance of the sending account after the transfer, only set when creating one
 }
 
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req createTransferRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	if err := bindStrictJSON(ctx, &req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
 	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
 
+	arg, accFrom, accTo, ok := server.prepareTransfer(ctx, req, authPayload.Username)
+	if !ok {
+		return
+	}
+
+	// large transfers need a fresh second factor, a stolen access token alone isnt enough to move them
+	if server.requiresTOTP(req.Amount) {
+		if req.TOTPCode == "" {
+			err := fmt.Errorf("transfers above %d require a totp code", server.config.TOTPStepUpAmount)
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		if !server.checkSecondFactor(ctx, authPayload.Username, req.TOTPCode, false) {
+			return
+		}
+	}
+
+	if req.QuoteToken != "" {
+		if !server.applyTransferQuote(ctx, req.QuoteToken, authPayload.Username, accFrom, accTo, &arg) {
+			return
+		}
+	}
+	arg.Audit = auditMeta(ctx, authPayload.Username)
+
+	// execute transfer transcation repository method
+	trf, err := server.repository.TransferTx(ctx, arg)
+	if err != nil {
+		switch err {
+		case db.ErrInsufficientFunds, db.ErrAccountClosed:
+			// the amount alone was covered but the fee wasnt, or an account was closed since it was checked
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		case db.ErrQuoteChanged:
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" && arg.QuoteID != "" {
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("the quote has already been executed")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transferResponse{
+		ID:          trf.Transfer.PublicID,
+		FromAccount: trf.FromAccount.Number,
+		ToAccount:   trf.ToAccount.Number,
+		Amount:      trf.Transfer.Amount,
+		Currency:    trf.FromAccount.Currency,
+		CreatedAt:   trf.Transfer.CreatedAt,
+		Description: trf.Transfer.Description,
+		Reference:   trf.Transfer.Reference,
+		Metadata:    trf.Transfer.Metadata,
+		Fee:         trf.Transfer.Fee,
+		Balance:     &trf.FromAccount.Balance,
+	})
+}
+
+// prepareTransfer runs every check of a transfer that doesnt need the second factor and returns the parameters
+// of the transfer and both accounts. it writes the error response itself if a check fails
+func (server *Server) prepareTransfer(ctx *gin.Context, req createTransferRequest, username string) (db.TransferTxParams, db.Account, db.Account, bool) {
+	if (req.ToAccount == "") == (req.BeneficiaryID == 0) {
+		err := errors.New("either toAccount or beneficiaryID is required")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+	}
+	metadata, err := validateTransferMetadata(req.Metadata)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+	}
+
 	// validate that sender and receiver use the same currencies. Optionally add some conversion later on
 	// check whether sender account sends the right currency and whether he has enough balance to perform the transfer
-	isValidFrom, accFrom := server.checkValidAccount(ctx, req.FromID, req.Currency)
+	isValidFrom, accFrom := server.checkValidAccount(ctx, req.FromAccount, req.Currency)
 	if !isValidFrom {
-		return
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
 	}
-	if accFrom.Owner != authPayload.Username {
-		// sender isnt the owner of the account he is trying to send money from
-		err := errors.New("not authorized to make this tansfer")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
-		return
+	// only owners and co-owners can send money, viewers can just look at the account
+	if _, ok := server.authorizeAccount(ctx, accFrom, username, spendingRoles...); !ok {
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
 	}
 	if accFrom.Balance-req.Amount < 0 {
 		err := errors.New("sender does not have enough balance to perform this transfer")
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+	}
+
+	toNumber := req.ToAccount
+	if req.BeneficiaryID != 0 {
+		// only the callers own beneficiaries can be used, the account behind it goes through the same checks
+		beneficiary, ok := server.getOwnBeneficiary(ctx, req.BeneficiaryID, username)
+		if !ok {
+			return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+		}
+		toNumber = beneficiary.AccountNumber
 	}
 
 	// if receiver has different currency, cancel
-	isValidTo, accTo := server.checkValidAccount(ctx, req.ToID, req.Currency)
+	isValidTo, accTo := server.checkValidAccount(ctx, toNumber, req.Currency)
+	if !isValidTo {
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+	}
 	// ensure sender isnt the same acc as receiver
-	if accTo.Owner == authPayload.Username {
-		err := errors.New("cannot make transfer to your own account")
+	if accTo.ID == accFrom.ID {
+		err := errors.New("cannot make transfer to the same account")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.TransferTxParams{}, db.Account{}, db.Account{}, false
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: accFrom.ID,
+		ToAccountID:   accTo.ID,
+		Amount:        req.Amount,
+		Description:   req.Description,
+		Reference:     req.Reference,
+		Metadata:      metadata,
+	}
+	return arg, accFrom, accTo, true
+}
+
+func (server *Server) requiresTOTP(amount int64) bool {
+	return server.config.TOTPStepUpAmount > 0 && amount > server.config.TOTPStepUpAmount
+}
+
+// transferQuote are the terms of a quoted transfer, they are carried by the quote token so nothing has to be
+// stored until the quote is executed. clients can read some tokens, so the accounts are referred to by their numbers
+type transferQuote struct {
+	FromAccount string          `json:"fromAccount"`
+	ToAccount   string          `json:"toAccount"`
+	Amount      int64           `json:"amount"`
+	Description string          `json:"description"`
+	Reference   string          `json:"reference"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"` // omitted without metadata, so it decodes to nil again
+	Fee         int64           `json:"fee"`
+}
+
+type transferQuoteResponse struct {
+	QuoteToken   string          `json:"quoteToken"` // pass along with the same transfer to POST /transfers to execute it
+	ExpiresAt    time.Time       `json:"expiresAt"`
+	FromAccount  string          `json:"fromAccount"`
+	ToAccount    string          `json:"toAccount"`
+	Amount       int64           `json:"amount"`
+	Fee          int64           `json:"fee"`
+	Total        int64           `json:"total"` // amount plus fee, what the sender is charged
+	Currency     string          `json:"currency"`
+	Description  string          `json:"description"`
+	Reference    string          `json:"reference"`
+	Metadata     json.RawMessage `json:"metadata"`
+	FeeWaived    bool            `json:"feeWaived"`    // covered by the free transfers of the month
+	FreeLeft     int64           `json:"freeLeft"`     // free transfers left after this one
+	TOTPRequired bool            `json:"totpRequired"` // executing the quote needs a totp code
+}
+
+// quoteTransfer runs all checks of createTransfer for the same request without moving any money and returns the
+// fee together with a short-lived quote token. POST /transfers executes the quote if the token is passed along
+// with the same transfer, and fails if the terms changed in the meantime
+func (server *Server) quoteTransfer(ctx *gin.Context) {
+	var req createTransferRequest
+	if err := bindStrictJSON(ctx, &req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
-	if !isValidTo {
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	arg, accFrom, accTo, ok := server.prepareTransfer(ctx, req, authPayload.Username)
+	if !ok {
 		return
 	}
 
-	arg := db.TransferTxParams{FromAccountID: req.FromID, ToAccountID: req.ToID, Amount: req.Amount}
+	fee, err := server.repository.QuoteTransferFee(ctx, accFrom, req.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if accFrom.Balance < req.Amount+fee.Amount {
+		ctx.JSON(http.StatusBadRequest, errorResponse(db.ErrInsufficientFunds))
+		return
+	}
 
-	// execute transfer transcation repository method
-	trf, err := server.repository.TransferTx(ctx, arg)
+	quote := transferQuote{
+		FromAccount: accFrom.Number,
+		ToAccount:   accTo.Number,
+		Amount:      arg.Amount,
+		Description: arg.Description,
+		Reference:   arg.Reference,
+		Metadata:    arg.Metadata,
+		Fee:         fee.Amount,
+	}
+	expiresAt := time.Now().Add(server.config.TransferQuoteDuration)
+	token, err := server.tokenMaker.CreateDataToken(authPayload.Username, auth.ScopeTransferQuote, quote, server.config.TransferQuoteDuration)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, trf)
+	ctx.JSON(http.StatusOK, transferQuoteResponse{
+		QuoteToken:   token,
+		ExpiresAt:    expiresAt,
+		FromAccount:  accFrom.Number,
+		ToAccount:    accTo.Number,
+		Amount:       req.Amount,
+		Fee:          fee.Amount,
+		Total:        req.Amount + fee.Amount,
+		Currency:     accFrom.Currency,
+		Description:  arg.Description,
+		Reference:    arg.Reference,
+		Metadata:     arg.Metadata,
+		FeeWaived:    fee.Waived,
+		FreeLeft:     fee.FreeLeft,
+		TOTPRequired: server.requiresTOTP(req.Amount),
+	})
 }
 
-// function checks whether the passed account id has the passed currency as primary currency set, and returns the account
-func (server *Server) checkValidAccount(ctx *gin.Context, id int64, curr string) (bool, db.Account) {
-	acc, err := server.repository.GetAccount(ctx, id)
+// applyTransferQuote checks that the quote token was issued to the caller for exactly this transfer and binds the
+// transfer to the quote, so it is only executed with the quoted fee and only once. from and to are the accounts of
+// the transfer as they were looked up for this request
+func (server *Server) applyTransferQuote(ctx *gin.Context, token string, username string, from db.Account, to db.Account, arg *db.TransferTxParams) bool {
+	payload, err := server.tokenMaker.VerifyToken(token)
+	if err == nil && (payload.Scope != auth.ScopeTransferQuote || payload.Username != username) {
+		err = auth.ErrInvalidToken
+	}
+	var quote transferQuote
+	if err == nil && json.Unmarshal(payload.Data, &quote) != nil {
+		err = auth.ErrInvalidToken
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid quote token: %w", err)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return false
+	}
+
+	// metadata is compared in its normalized form, see validateTransferMetadata
+	if quote.FromAccount != from.Number ||
+		quote.ToAccount != to.Number ||
+		quote.Amount != arg.Amount ||
+		quote.Description != arg.Description ||
+		quote.Reference != arg.Reference ||
+		!bytes.Equal(quote.Metadata, arg.Metadata) {
+		err := errors.New("the transfer does not match the quote")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return false
+	}
+
+	arg.QuoteID = payload.ID.String()
+	arg.QuotedFee = quote.Fee
+	return true
+}
+
+type listAccountTransfersRequest struct {
+	PageID    int32  `form:"page" binding:"required,min=1"`
+	Limit     int32  `form:"limit" binding:"required,min=5,max=50"`
+	Search    string `form:"search" binding:"omitempty,max=140,freetext"` // matches parts of the description or reference
+	Reference string `form:"reference" binding:"omitempty,max=35,reference"`
+}
+
+// listAccountTransfers returns the incoming and outgoing transfers of an account, newest first. every member can see them
+func (server *Server) listAccountTransfers(ctx *gin.Context) {
+	var req listAccountTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	acc, ok := server.loadAccount(ctx)
+	if !ok {
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if _, ok := server.authorizeAccount(ctx, acc, authPayload.Username); !ok {
+		return
+	}
+
+	arg := db.ListAccountTransfersParams{
+		AccountID: acc.ID,
+		Limit:     req.Limit,
+		Offset:    (req.PageID - 1) * req.Limit,
+	}
+	if req.Search != "" {
+		arg.Search = sql.NullString{String: req.Search, Valid: true}
+	}
+	if req.Reference != "" {
+		arg.Reference = sql.NullString{String: req.Reference, Valid: true}
+	}
+
+	transfers, err := server.repository.ListAccountTransfers(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]transferResponse, 0, len(transfers))
+	for _, trf := range transfers {
+		resp = append(resp, transferResponse{
+			ID:          trf.PublicID,
+			FromAccount: trf.FromAccountNumber,
+			ToAccount:   trf.ToAccountNumber,
+			Amount:      trf.Amount,
+			Currency:    trf.Currency,
+			CreatedAt:   trf.CreatedAt,
+			Description: trf.Description,
+			Reference:   trf.Reference,
+			Metadata:    trf.Metadata,
+			Fee:         trf.Fee,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// function checks whether the passed account number has the passed currency as primary currency set, and returns the account
+func (server *Server) checkValidAccount(ctx *gin.Context, number string, curr string) (bool, db.Account) {
+	acc, err := server.repository.GetAccountByNumber(ctx, number)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, errorResponse(err))
 		return false, db.Account{}
 	}
+	// the fee revenue and interest expense accounts of the bank are only posted to by the bank itself
+	if acc.Owner == db.SystemUsername {
+		err = fmt.Errorf("account [%s] does not exist", acc.Number)
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return false, db.Account{}
+	}
+	if acc.ClosedAt.Valid {
+		err = fmt.Errorf("account [%s] is closed", acc.Number)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return false, db.Account{}
+	}
 	if acc.Currency != curr {
-		err = fmt.Errorf("invalid currency for account [%d]: expected %s received %s", acc.ID, acc.Currency, curr)
+		err = fmt.Errorf("invalid currency for account [%s]: expected %s received %s", acc.Number, acc.Currency, curr)
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return false, db.Account{}
 	}
@@ -0,0 +1,374 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+// upper limit of transfers per batch, bigger payrolls have to be split up
+const maxBatchItems = 1000
+
+type transferBatchItemRequest struct {
+	ToAccount   string `json:"toAccount" binding:"required,accountnumber"`
+	Amount      int64  `json:"amount" binding:"required,min=1"`
+	Description string `json:"description" binding:"omitempty,max=140,freetext"`
+	Reference   string `json:"reference" binding:"omitempty,max=35,reference"`
+}
+
+// createTransferBatchRequest is either sent as json, or as multipart form with the items in a csv file, see
+// parseBatchCSV. the items are validated line by line, so every invalid line can be reported at once
+type createTransferBatchRequest struct {
+	FromAccount string                     `json:"fromAccount" form:"fromAccount" binding:"required,accountnumber"`
+	Currency    string                     `json:"currency" form:"currency" binding:"required,currency"`
+	Atomic      bool                       `json:"atomic" form:"atomic"`     // execute either all items or none
+	TOTPCode    string                     `json:"totpCode" form:"totpCode"` // required if the total is above the step-up amount
+	Items       []transferBatchItemRequest `json:"items" form:"-"`
+}
+
+// batchLineError describes why a line of a batch was rejected, lines start at 1. in csv files line 1 is the first
+// line after the header
+type batchLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type transferBatchItemResponse struct {
+	Line        int32  `json:"line"`
+	ToAccount   string `json:"toAccount"`
+	Amount      int64  `json:"amount"`
+	Fee         int64  `json:"fee"`
+	Description string `json:"description"`
+	Reference   string `json:"reference"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+type transferBatchResponse struct {
+	ID          int64                       `json:"id"`
+	FromAccount string                      `json:"fromAccount"`
+	Currency    string                      `json:"currency"`
+	Atomic      bool                        `json:"atomic"`
+	Status      string                      `json:"status"`
+	ItemCount   int32                       `json:"itemCount"`
+	Succeeded   int32                       `json:"succeeded"`
+	Failed      int32                       `json:"failed"`
+	TotalAmount int64                       `json:"totalAmount"` // sum of the executed transfers
+	TotalFee    int64                       `json:"totalFee"`
+	CreatedBy   string                      `json:"createdBy"`
+	CreatedAt   time.Time                   `json:"createdAt"`
+	Items       []transferBatchItemResponse `json:"items"`
+}
+
+func newTransferBatchResponse(batch db.TransferBatch, from db.Account, items []transferBatchItemResponse) transferBatchResponse {
+	return transferBatchResponse{
+		ID:          batch.ID,
+		FromAccount: from.Number,
+		Currency:    from.Currency,
+		Atomic:      batch.Atomic,
+		Status:      batch.Status,
+		ItemCount:   batch.ItemCount,
+		Succeeded:   batch.Succeeded,
+		Failed:      batch.Failed,
+		TotalAmount: batch.TotalAmount,
+		TotalFee:    batch.TotalFee,
+		CreatedBy:   batch.CreatedBy,
+		CreatedAt:   batch.CreatedAt,
+		Items:       items,
+	}
+}
+
+// createTransferBatch sends many transfers from one account, e.g. a payroll. every line is validated before anything
+// is executed and the request is rejected with all invalid lines if there are any
+func (server *Server) createTransferBatch(ctx *gin.Context) {
+	var req createTransferBatchRequest
+	var err error
+	if ctx.ContentType() == binding.MIMEMultipartPOSTForm {
+		err = ctx.ShouldBindWith(&req, binding.FormMultipart)
+		if err == nil {
+			req.Items, err = server.readBatchCSV(ctx)
+		}
+	} else {
+		err = bindStrictJSON(ctx, &req)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchItems {
+		err := fmt.Errorf("a batch needs between 1 and %d items", maxBatchItems)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	isValidFrom, accFrom := server.checkValidAccount(ctx, req.FromAccount, req.Currency)
+	if !isValidFrom {
+		return
+	}
+	if _, ok := server.authorizeAccount(ctx, accFrom, authPayload.Username, spendingRoles...); !ok {
+		return
+	}
+
+	items, total, lineErrors, err := server.validateBatchItems(ctx, accFrom, req.Items)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if len(lineErrors) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "batch contains invalid lines", "lines": lineErrors})
+		return
+	}
+	// an atomic batch that cant be covered would fail anyway. fees are only known while executing
+	if req.Atomic && total > accFrom.Balance {
+		err := errors.New("sender does not have enough balance to perform this batch")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the step-up applies to the total, otherwise a large payment could just be split up
+	if server.requiresTOTP(total) {
+		if req.TOTPCode == "" {
+			err := fmt.Errorf("batches above %d require a totp code", server.config.TOTPStepUpAmount)
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		if !server.checkSecondFactor(ctx, authPayload.Username, req.TOTPCode, false) {
+			return
+		}
+	}
+
+	result, err := server.repository.TransferBatchTx(ctx, db.TransferBatchTxParams{
+		FromAccountID: accFrom.ID,
+		CreatedBy:     authPayload.Username,
+		Atomic:        req.Atomic,
+		Items:         items,
+		Audit:         auditMeta(ctx, authPayload.Username),
+	})
+	if err != nil {
+		if err == db.ErrAccountClosed {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]transferBatchItemResponse, 0, len(result.Items))
+	for i, item := range result.Items {
+		resp = append(resp, transferBatchItemResponse{
+			Line:        item.Line,
+			ToAccount:   req.Items[i].ToAccount,
+			Amount:      item.Amount,
+			Fee:         item.Fee,
+			Description: item.Description,
+			Reference:   item.Reference,
+			Status:      item.Status,
+			Error:       item.Error,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, newTransferBatchResponse(result.Batch, accFrom, resp))
+}
+
+// validateBatchItems checks every line of a batch like createTransfer checks a single transfer and returns the items
+// to execute together with their total. the returned error is only set if the checks themselves failed
+func (server *Server) validateBatchItems(ctx *gin.Context, from db.Account, lines []transferBatchItemRequest) ([]db.TransferBatchItemParams, int64, []batchLineError, error) {
+	items := make([]db.TransferBatchItemParams, 0, len(lines))
+	lineErrors := []batchLineError{}
+	receivers := make(map[string]db.Account)
+	var total int64
+
+	for i, line := range lines {
+		fail := func(err error) {
+			lineErrors = append(lineErrors, batchLineError{Line: i + 1, Error: err.Error()})
+		}
+
+		if err := binding.Validator.ValidateStruct(line); err != nil {
+			fail(err)
+			continue
+		}
+
+		to, ok := receivers[line.ToAccount]
+		if !ok {
+			var err error
+			to, err = server.repository.GetAccountByNumber(ctx, line.ToAccount)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, 0, nil, err
+			}
+			receivers[line.ToAccount] = to
+		}
+
+		switch {
+		case to.ID == 0, to.Owner == db.SystemUsername:
+			fail(fmt.Errorf("account [%s] does not exist", line.ToAccount))
+		case to.ID == from.ID:
+			fail(errors.New("cannot make transfer to the same account"))
+		case to.ClosedAt.Valid:
+			fail(fmt.Errorf("account [%s] is closed", to.Number))
+		case to.Currency != from.Currency:
+			fail(fmt.Errorf("invalid currency for account [%s]: expected %s received %s", to.Number, to.Currency, from.Currency))
+		case line.Amount > math.MaxInt64-total:
+			// a wrapped total would slip past the balance check of atomic batches and the totp step-up
+			fail(errors.New("the amounts of the batch add up to more than the largest possible amount"))
+		default:
+			items = append(items, db.TransferBatchItemParams{
+				ToAccountID: to.ID,
+				Amount:      line.Amount,
+				Description: line.Description,
+				Reference:   line.Reference,
+			})
+			total += line.Amount
+		}
+	}
+
+	return items, total, lineErrors, nil
+}
+
+// readBatchCSV reads the items of a batch from the uploaded file "file". the first line is a header naming the
+// columns toAccount and amount, and optionally description and reference, in any order
+func (server *Server) readBatchCSV(ctx *gin.Context) ([]transferBatchItemRequest, error) {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseBatchCSV(file)
+}
+
+func parseBatchCSV(r io.Reader) ([]transferBatchItemRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"toAccount", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv is missing the %s column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	items := []transferBatchItemRequest{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read csv line %d: %w", line, err)
+		}
+		if len(items) == maxBatchItems {
+			return nil, fmt.Errorf("a batch needs between 1 and %d items", maxBatchItems)
+		}
+
+		amount, err := strconv.ParseInt(field(record, "amount"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount in csv line %d", line)
+		}
+		items = append(items, transferBatchItemRequest{
+			ToAccount:   field(record, "toAccount"),
+			Amount:      amount,
+			Description: field(record, "description"),
+			Reference:   field(record, "reference"),
+		})
+	}
+
+	return items, nil
+}
+
+type transferBatchRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getTransferBatch returns a batch and the status of all its items. every member of the sending account can see it
+func (server *Server) getTransferBatch(ctx *gin.Context) {
+	var req transferBatchRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// users that arent members of the sending account get the same answer as for a batch that doesnt exist,
+	// otherwise the ids of other users batches could be probed
+	notFound := fmt.Errorf("transfer batch [%d] does not exist", req.ID)
+
+	batch, err := server.repository.GetTransferBatch(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(notFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	_, err = server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: batch.FromAccountID, Username: authPayload.Username})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(notFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	from, err := server.repository.GetAccount(ctx, batch.FromAccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	items, err := server.repository.ListTransferBatchItems(ctx, batch.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]transferBatchItemResponse, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, transferBatchItemResponse{
+			Line:        item.Line,
+			ToAccount:   item.ToAccountNumber,
+			Amount:      item.Amount,
+			Fee:         item.Fee,
+			Description: item.Description,
+			Reference:   item.Reference,
+			Status:      item.Status,
+			Error:       item.Error,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, newTransferBatchResponse(batch, from, resp))
+}
@@ -0,0 +1,441 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTransferBatchAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
+
+	from := generateRandomAccount(user.Username)
+	toA := generateRandomAccount("receiver-a")
+	toB := generateRandomAccount("receiver-b")
+	cad := generateRandomAccount("receiver-c")
+	system := generateRandomAccount(db.SystemUsername)
+	from.ID, toA.ID, toB.ID, cad.ID, system.ID = 1, 2, 3, 4, 5
+	from.Currency, toA.Currency, toB.Currency, cad.Currency, system.Currency = "USD", "USD", "USD", "CAD", "USD"
+	from.Balance = 1000
+	requestID := "batch-test-request"
+
+	jsonBody := func(atomic bool, items ...gin.H) func(t *testing.T) (io.Reader, string) {
+		return func(t *testing.T) (io.Reader, string) {
+			data, err := json.Marshal(gin.H{"fromAccount": from.Number, "currency": "USD", "atomic": atomic, "items": items})
+			require.NoError(t, err)
+			return bytes.NewReader(data), "application/json"
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          func(t *testing.T) (io.Reader, string)
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			body: jsonBody(false,
+				gin.H{"toAccount": toA.Number, "amount": 100, "reference": "SALARY-01"},
+				gin.H{"toAccount": toB.Number, "amount": 200},
+			),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				expectAccountLookup(repo, toA)
+				expectAccountLookup(repo, toB)
+
+				arg := db.TransferBatchTxParams{
+					FromAccountID: from.ID,
+					CreatedBy:     user.Username,
+					Items: []db.TransferBatchItemParams{
+						{ToAccountID: toA.ID, Amount: 100, Reference: "SALARY-01"},
+						{ToAccountID: toB.ID, Amount: 200},
+					},
+					Audit: db.AuditMeta{Actor: user.Username, RequestID: requestID},
+				}
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferBatchTxResult{
+					Batch: db.TransferBatch{ID: 7, FromAccountID: from.ID, Status: db.TransferBatchPartiallyFailed, ItemCount: 2, Succeeded: 1, Failed: 1, TotalAmount: 100},
+					Items: []db.TransferBatchItem{
+						{BatchID: 7, Line: 1, ToAccountID: toA.ID, Amount: 100, Reference: "SALARY-01", Status: db.TransferBatchItemSucceeded},
+						{BatchID: 7, Line: 2, ToAccountID: toB.ID, Amount: 200, Status: db.TransferBatchItemFailed, Error: db.ErrInsufficientFunds.Error()},
+					},
+				}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got transferBatchResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, int64(7), got.ID)
+				require.Equal(t, from.Number, got.FromAccount)
+				require.Equal(t, db.TransferBatchPartiallyFailed, got.Status)
+				require.Len(t, got.Items, 2)
+				require.Equal(t, toA.Number, got.Items[0].ToAccount)
+				require.Equal(t, db.TransferBatchItemFailed, got.Items[1].Status)
+				require.NotEmpty(t, got.Items[1].Error)
+			},
+		},
+		{
+			name:     "CSV",
+			username: user.Username,
+			body: func(t *testing.T) (io.Reader, string) {
+				var buf bytes.Buffer
+				w := multipart.NewWriter(&buf)
+				require.NoError(t, w.WriteField("fromAccount", from.Number))
+				require.NoError(t, w.WriteField("currency", "USD"))
+				require.NoError(t, w.WriteField("atomic", "true"))
+				file, err := w.CreateFormFile("file", "payroll.csv")
+				require.NoError(t, err)
+				fmt.Fprintf(file, "amount,toAccount,description\n100,%s,Salary March\n200,%s,\"Salary, March\"\n", toA.Number, toB.Number)
+				require.NoError(t, w.Close())
+				return &buf, w.FormDataContentType()
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				expectAccountLookup(repo, toA)
+				expectAccountLookup(repo, toB)
+
+				arg := db.TransferBatchTxParams{
+					FromAccountID: from.ID,
+					CreatedBy:     user.Username,
+					Atomic:        true,
+					Items: []db.TransferBatchItemParams{
+						{ToAccountID: toA.ID, Amount: 100, Description: "Salary March"},
+						{ToAccountID: toB.ID, Amount: 200, Description: "Salary, March"},
+					},
+					Audit: db.AuditMeta{Actor: user.Username, RequestID: requestID},
+				}
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.TransferBatchTxResult{
+					Batch: db.TransferBatch{ID: 8, Atomic: true, Status: db.TransferBatchCompleted},
+					Items: []db.TransferBatchItem{{Line: 1}, {Line: 2}},
+				}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidLines",
+			username: user.Username,
+			body: jsonBody(false,
+				gin.H{"toAccount": toA.Number, "amount": 100},
+				gin.H{"toAccount": toA.Number, "amount": 0},
+				gin.H{"toAccount": cad.Number, "amount": 100},
+				gin.H{"toAccount": from.Number, "amount": 100},
+				gin.H{"toAccount": system.Number, "amount": 100},
+			),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				expectAccountLookup(repo, toA)
+				expectAccountLookup(repo, cad)
+				expectAccountLookup(repo, from)
+				expectAccountLookup(repo, system)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+				var got struct {
+					Lines []batchLineError `json:"lines"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Len(t, got.Lines, 4)
+				for i, line := range got.Lines {
+					require.Equal(t, i+2, line.Line)
+				}
+			},
+		},
+		{
+			name:     "TotalOverflow",
+			username: user.Username,
+			body: jsonBody(false,
+				gin.H{"toAccount": toA.Number, "amount": 100},
+				gin.H{"toAccount": toB.Number, "amount": int64(math.MaxInt64)},
+			),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				expectAccountLookup(repo, toA)
+				expectAccountLookup(repo, toB)
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+				var got struct {
+					Lines []batchLineError `json:"lines"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Len(t, got.Lines, 1)
+				require.Equal(t, 2, got.Lines[0].Line)
+			},
+		},
+		{
+			name:     "AtomicNotCovered",
+			username: user.Username,
+			body: jsonBody(true,
+				gin.H{"toAccount": toA.Number, "amount": 600},
+				gin.H{"toAccount": toB.Number, "amount": 600},
+			),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				expectAccountLookup(repo, toA)
+				expectAccountLookup(repo, toB)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NoItems",
+			username: user.Username,
+			body:     jsonBody(false),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "Viewer",
+			username: viewer.Username,
+			body:     jsonBody(false, gin.H{"toAccount": toA.Number, "amount": 100}),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, from)
+				expectAccountMember(repo, from, viewer.Username, db.AccountRoleViewer)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "UnknownItemField",
+			username: user.Username,
+			body:     jsonBody(false, gin.H{"toAccount": toA.Number, "amount": 100, "amout": 100}),
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			body, contentType := tc.body(t)
+			req, err := http.NewRequest(http.MethodPost, "/transfers/batch", body)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set(requestIDHeader, requestID)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestParseBatchCSV(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		check func(t *testing.T, items []transferBatchItemRequest, err error)
+	}{
+		{
+			name:  "OK",
+			input: "toAccount, amount, reference\nDE1, 100, INV-1\nDE2, 250,\n",
+			check: func(t *testing.T, items []transferBatchItemRequest, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []transferBatchItemRequest{
+					{ToAccount: "DE1", Amount: 100, Reference: "INV-1"},
+					{ToAccount: "DE2", Amount: 250},
+				}, items)
+			},
+		},
+		{
+			name:  "MissingColumn",
+			input: "toAccount,description\nDE1,x\n",
+			check: func(t *testing.T, items []transferBatchItemRequest, err error) {
+				require.EqualError(t, err, "csv is missing the amount column")
+			},
+		},
+		{
+			name:  "InvalidAmount",
+			input: "toAccount,amount\nDE1,100\nDE2,1.50\n",
+			check: func(t *testing.T, items []transferBatchItemRequest, err error) {
+				require.EqualError(t, err, "invalid amount in csv line 2")
+			},
+		},
+		{
+			name:  "FieldCount",
+			input: "toAccount,amount\nDE1,100,extra\n",
+			check: func(t *testing.T, items []transferBatchItemRequest, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name:  "Empty",
+			input: "",
+			check: func(t *testing.T, items []transferBatchItemRequest, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := parseBatchCSV(strings.NewReader(tc.input))
+			tc.check(t, items, err)
+		})
+	}
+}
+
+func TestGetTransferBatchAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+
+	from := generateRandomAccount(user.Username)
+	batch := db.TransferBatch{ID: 5, FromAccountID: from.ID, CreatedBy: user.Username, Status: db.TransferBatchCompleted, ItemCount: 1, Succeeded: 1, TotalAmount: 100}
+	items := []db.ListTransferBatchItemsRow{
+		{BatchID: batch.ID, Line: 1, Amount: 100, Status: db.TransferBatchItemSucceeded, TransferID: sql.NullInt64{Int64: 9, Valid: true}, ToAccountNumber: "receiver"},
+	}
+	notFoundBody := `{"error": "transfer batch [5] does not exist"}`
+
+	testCases := []struct {
+		name          string
+		username      string
+		id            int64
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			id:       batch.ID,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(from.ID)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleViewer)
+				repo.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(items, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got transferBatchResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, db.TransferBatchCompleted, got.Status)
+				require.Equal(t, []transferBatchItemResponse{
+					{Line: 1, ToAccount: "receiver", Amount: 100, Status: db.TransferBatchItemSucceeded},
+				}, got.Items)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			id:       batch.ID,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(db.TransferBatch{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.JSONEq(t, notFoundBody, recorder.Body.String())
+			},
+		},
+		{
+			// looks exactly like a batch that doesnt exist
+			name:     "NotMember",
+			username: other.Username,
+			id:       batch.ID,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: from.ID, Username: other.Username})).
+					Times(1).
+					Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.JSONEq(t, notFoundBody, recorder.Body.String())
+			},
+		},
+		{
+			name:     "InvalidID",
+			username: user.Username,
+			id:       0,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetTransferBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/transfers/batch/%d", tc.id), nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/maxeth/go-bank-app/auth"
 	mockdb "github.com/maxeth/go-bank-app/db/mock"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
@@ -28,11 +31,15 @@ func TestCreateTransfer(t *testing.T) {
 	accC := generateRandomAccount(userC.Username)
 
 	transferAmount := int64(10)
+	requestID := "transfer-test-request"
+	publicID := uuid.New()
 
 	accA.Currency = "USD"
 	accB.Currency = "USD"
 	accC.Currency = "CAD"
 
+	beneficiary := db.Beneficiary{ID: 3, Owner: userA.Username, Name: "b", AccountNumber: accB.Number, Currency: accB.Currency}
+
 	testCases := []struct {
 		name          string
 		body          gin.H
@@ -43,26 +50,257 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "OK",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        transferAmount,
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"description": "Pizza 🍕",
+				"reference":   "INV-2024/42",
+				"metadata":    gin.H{"category": "food"},
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				// create a token witht he randomly created users username so that the requests are not being rejected
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
-				// the GetAccount method is beind called inside the handler when checking whether both accounts have the same currency / enough balance
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				// the GetAccountByNumber method is beind called inside the handler when checking whether both accounts have the same currency / enough balance
 				// for reference: /api/transfer.go
-				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(accA.ID)).Times(1).Return(accA, nil)
-				repo.EXPECT().GetAccount(gomock.Any(), gomock.Eq(accB.ID)).Times(1).Return(accB, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(accB, nil)
 
 				// the TransferTx method is expected be called with these parameters internally
 				args := db.TransferTxParams{
 					FromAccountID: accA.ID,
 					ToAccountID:   accB.ID,
 					Amount:        transferAmount,
+					Description:   "Pizza 🍕",
+					Reference:     "INV-2024/42",
+					Metadata:      json.RawMessage(`{"category":"food"}`),
+					Audit:         db.AuditMeta{Actor: userA.Username, RequestID: requestID},
+				}
+				from := accA
+				from.Balance -= transferAmount + 2
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Eq(args)).Times(1).Return(db.TransferTxResult{
+					Transfer: db.Transfer{
+						ID:            1,
+						PublicID:      publicID,
+						FromAccountID: accA.ID,
+						ToAccountID:   accB.ID,
+						Amount:        transferAmount,
+						Description:   args.Description,
+						Reference:     args.Reference,
+						Metadata:      args.Metadata,
+						Fee:           2,
+					},
+					Fee:         db.TransferFee{RuleID: 1, Amount: 2},
+					FromAccount: from,
+					ToAccount:   accB,
+				}, nil)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+
+				// neither internal ids nor the receivers balance end up in the response
+				var got map[string]interface{}
+				require.NoError(t, json.Unmarshal(resRec.Body.Bytes(), &got))
+				require.Equal(t, map[string]interface{}{
+					"id":          publicID.String(),
+					"fromAccount": accA.Number,
+					"toAccount":   accB.Number,
+					"amount":      float64(transferAmount),
+					"currency":    accA.Currency,
+					"createdAt":   "0001-01-01T00:00:00Z",
+					"description": "Pizza 🍕",
+					"reference":   "INV-2024/42",
+					"metadata":    map[string]interface{}{"category": "food"},
+					"fee":         float64(2),
+					"balance":     float64(accA.Balance - transferAmount - 2),
+				}, got)
+			},
+		},
+		{
+			name: "InvalidAccountNumber",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   typoAccountNumber(accB.Number),
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "UnknownField",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"referense":   "INV-2024/42",
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+				require.Contains(t, resRec.Body.String(), "referense")
+			},
+		},
+		{
+			name: "DescriptionTooLong",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"description": strings.Repeat("a", 141),
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "DescriptionControlCharacters",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"description": "line\nbreak",
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "InvalidReference",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"reference":   "INV#42",
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "NestedMetadata",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"metadata":    gin.H{"order": gin.H{"id": "42"}},
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "MetadataNotAnObject",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"metadata":    []string{"food"},
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "MetadataTooLarge",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+				"metadata":    gin.H{"note": strings.Repeat("a", 1100)},
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "ByBeneficiary",
+			body: gin.H{
+				"fromAccount":   accA.Number,
+				"beneficiaryID": beneficiary.ID,
+				"amount":        transferAmount,
+				"currency":      accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(beneficiary, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(accB, nil)
+
+				args := db.TransferTxParams{
+					FromAccountID: accA.ID,
+					ToAccountID:   accB.ID,
+					Amount:        transferAmount,
+					Audit:         db.AuditMeta{Actor: userA.Username, RequestID: requestID},
 				}
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Eq(args)).Times(1)
 			},
@@ -71,24 +309,68 @@ func TestCreateTransfer(t *testing.T) {
 			},
 		},
 		{
-			name: "SenderNotFound",
+			name: "OtherUsersBeneficiary",
+			body: gin.H{
+				"fromAccount":   accA.Number,
+				"beneficiaryID": beneficiary.ID,
+				"amount":        transferAmount,
+				"currency":      accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				foreign := beneficiary
+				foreign.Owner = userC.Username
+
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetBeneficiary(gomock.Any(), gomock.Eq(beneficiary.ID)).Times(1).Return(foreign, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name: "AccountAndBeneficiary",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
+				"fromAccount":   accA.Number,
+				"toAccount":     accB.Number,
+				"beneficiaryID": beneficiary.ID,
 				"amount":        transferAmount,
 				"currency":      accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "SenderNotFound",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(db.Account{}, sql.ErrNoRows)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(0)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -100,22 +382,23 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "ReceiverNotFound",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        transferAmount,
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(1).Return(db.Account{}, sql.ErrNoRows)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -127,21 +410,21 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "InvalidCurrency",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        transferAmount,
-				"currency":      "ABCDEFG",
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    "ABCDEFG",
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(0)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(0)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -154,22 +437,23 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "ReceiverCurrencyMismatch",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accC.ID,
-				"amount":        transferAmount,
-				"currency":      "USD",
+				"fromAccount": accA.Number,
+				"toAccount":   accC.Number,
+				"amount":      transferAmount,
+				"currency":    "USD",
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accC.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accC.Number)).
 					Times(1).
 					Return(accC, nil)
 
@@ -182,22 +466,22 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "SenderCurrencyMismatch",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accC.ID,
-				"amount":        transferAmount,
-				"currency":      "CAD",
+				"fromAccount": accA.Number,
+				"toAccount":   accC.Number,
+				"amount":      transferAmount,
+				"currency":    "CAD",
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accC.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accC.Number)).
 					Times(0)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -208,21 +492,21 @@ func TestCreateTransfer(t *testing.T) {
 		}, {
 			name: "SendingNegativeAmount",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        int64(-transferAmount), // sending 1 more than the account actually has
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      int64(-transferAmount), // sending 1 more than the account actually has
+				"currency":    accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(0)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(0)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -234,22 +518,23 @@ func TestCreateTransfer(t *testing.T) {
 		{
 			name: "SendingTooMuch",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        accA.Balance + int64(1), // sending 1 more than the account actually has
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      accA.Balance + int64(1), // sending 1 more than the account actually has
+				"currency":    accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(0)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -260,22 +545,23 @@ func TestCreateTransfer(t *testing.T) {
 		}, {
 			name: "SendingEverything",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accB.ID,
-				"amount":        accA.Balance, // sending 1 more than the account actually has
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      accA.Balance, // sending 1 more than the account actually has
+				"currency":    accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accB.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).
 					Times(1).Return(accB, nil)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
@@ -284,25 +570,70 @@ func TestCreateTransfer(t *testing.T) {
 				require.Equal(t, http.StatusOK, resRec.Code)
 			},
 		},
+		{
+			name: "FeeNotCovered",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      accA.Balance,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(accB, nil)
+				// the amount alone is covered, the fee evaluated inside the transaction isnt
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "ClosedDuringTransfer",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(accB, nil)
+				// the receiver was closed after it was checked, the transaction finds out once it locked it
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrAccountClosed)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
 		{
 			name: "SendingToSameAccount",
 			body: gin.H{
-				"fromAccountID": accA.ID,
-				"toAccountID":   accA.ID,
-				"amount":        accA.Balance, // sending 1 more than the account actually has
-				"currency":      accA.Currency,
+				"fromAccount": accA.Number,
+				"toAccount":   accA.Number,
+				"amount":      accA.Balance, // sending 1 more than the account actually has
+				"currency":    accA.Currency,
 			},
 			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
 				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
 			},
 			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).
 					Return(accA, nil)
 
 				repo.EXPECT().
-					GetAccount(gomock.Any(), gomock.Eq(accA.ID)).
+					GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).
 					Times(1).Return(accA, nil)
 
 				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
@@ -311,6 +642,122 @@ func TestCreateTransfer(t *testing.T) {
 				require.Equal(t, http.StatusBadRequest, resRec.Code)
 			},
 		},
+		{
+			name: "NotMember",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userB.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: accA.ID, Username: userB.Username})).
+					Times(1).
+					Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name: "Viewer",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userB.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				expectAccountMember(repo, accA, userB.Username, db.AccountRoleViewer)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, resRec.Code)
+			},
+		},
+		{
+			name: "CoOwner",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accC.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userB.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				receiver := accC
+				receiver.Currency = accA.Currency
+
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accC.Number)).Times(1).Return(receiver, nil)
+				expectAccountMember(repo, accA, userB.Username, db.AccountRoleCoOwner)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name: "ClosedReceiver",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				closed := accB
+				closed.ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(closed, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, resRec.Code)
+			},
+		},
+		{
+			name: "SystemReceiver",
+			body: gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      transferAmount,
+				"currency":    accA.Currency,
+			},
+			setupAuth: func(t *testing.T, req *http.Request, tm auth.TokenMaker) {
+				addAuthToHeader(t, req, tm, time.Minute, authTypeBearer, userA.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+				// e.g. the fee revenue account of the currency
+				system := accB
+				system.Owner = db.SystemUsername
+
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).Times(1).Return(accA, nil)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).Times(1).Return(system, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, resRec.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -322,6 +769,7 @@ func TestCreateTransfer(t *testing.T) {
 
 			repo := mockdb.NewMockRepository(ctrl)
 			tc.buildStubs(repo)
+			allowAnyToken(repo)
 
 			server := newTestServer(t, repo)
 			recorder := httptest.NewRecorder()
@@ -332,6 +780,7 @@ func TestCreateTransfer(t *testing.T) {
 
 			req, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
 			require.NoError(t, err)
+			req.Header.Set(requestIDHeader, requestID)
 
 			tc.setupAuth(t, req, server.tokenMaker)
 			server.router.ServeHTTP(recorder, req)
@@ -340,3 +789,613 @@ func TestCreateTransfer(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateTransferStepUp(t *testing.T) {
+	userA, _ := randomUser(t)
+	userB, _ := randomUser(t)
+
+	accA := generateRandomAccount(userA.Username)
+	accB := generateRandomAccount(userB.Username)
+	accA.Currency, accB.Currency = "USD", "USD"
+	accA.ID, accB.ID = 1, 2
+	accA.Balance = 1000
+
+	totp := randomUserTOTP(t, userA.Username)
+	threshold := int64(100)
+
+	testCases := []struct {
+		name          string
+		amount        int64
+		code          func(t *testing.T) string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(resRec *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "BelowThreshold",
+			amount: threshold,
+			code:   func(t *testing.T) string { return "" },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name:   "MissingCode",
+			amount: threshold + 1,
+			code:   func(t *testing.T) string { return "" },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+		{
+			name:   "ValidCode",
+			amount: threshold + 1,
+			code:   func(t *testing.T) string { return currentTOTPCode(t, totp.Secret) },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(userA.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, userA.Username, false)
+				repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(totp, nil)
+				repo.EXPECT().ResetSecondFactorAttempts(gomock.Any(), gomock.Eq(userA.Username)).Times(1)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, resRec.Code)
+			},
+		},
+		{
+			name:   "TwoFactorNotEnabled",
+			amount: threshold + 1,
+			code:   func(t *testing.T) string { return "123456" },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(userA.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, resRec.Code)
+			},
+		},
+		{
+			name:   "RecoveryCodeNotAccepted",
+			amount: threshold + 1,
+			code:   func(t *testing.T) string { return "abcde-fghij" },
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(userA.Username)).Times(1).Return(totp, nil)
+				expectSecondFactorAttempt(repo, userA.Username, false)
+				repo.EXPECT().ListUnusedRecoveryCodes(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(resRec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, resRec.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accA.Number)).AnyTimes().Return(accA, nil)
+			repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(accB.Number)).AnyTimes().Return(accB, nil)
+			expectAccountMember(repo, accA, userA.Username, db.AccountRoleOwner)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			server.config.TOTPStepUpAmount = threshold
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"fromAccount": accA.Number,
+				"toAccount":   accB.Number,
+				"amount":      tc.amount,
+				"currency":    "USD",
+				"totpCode":    tc.code(t),
+			})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, userA.Username)
+			server.router.ServeHTTP(recorder, req)
+
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListAccountTransfersAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	acc := generateRandomAccount(user.Username)
+	counterparty := generateRandomAccount(other.Username)
+
+	rows := []db.ListAccountTransfersRow{
+		{
+			ID:                2,
+			PublicID:          uuid.New(),
+			Amount:            10,
+			Description:       "rent",
+			Reference:         "INV-42",
+			Metadata:          json.RawMessage(`{}`),
+			FromAccountNumber: counterparty.Number,
+			ToAccountNumber:   acc.Number,
+			Currency:          acc.Currency,
+		},
+		{
+			ID:                1,
+			PublicID:          uuid.New(),
+			Amount:            20,
+			Description:       "pizza",
+			Metadata:          json.RawMessage(`{"category":"food"}`),
+			FromAccountNumber: acc.Number,
+			ToAccountNumber:   counterparty.Number,
+			Currency:          acc.Currency,
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		query         string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			query:    "page=1&limit=5",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleViewer)
+				arg := db.ListAccountTransfersParams{AccountID: acc.ID, Limit: 5, Offset: 0}
+				repo.EXPECT().ListAccountTransfers(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rows, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got []map[string]interface{}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Len(t, got, 2)
+				require.Equal(t, counterparty.Number, got[0]["fromAccount"])
+				require.Equal(t, "INV-42", got[0]["reference"])
+				require.Equal(t, map[string]interface{}{"category": "food"}, got[1]["metadata"])
+				// balances and internal ids of either side are never part of the history
+				require.NotContains(t, got[0], "balance")
+				require.Equal(t, rows[0].PublicID.String(), got[0]["id"])
+				require.NotContains(t, got[0], "fromAccountID")
+			},
+		},
+		{
+			name:     "Search",
+			username: user.Username,
+			query:    "page=2&limit=5&search=Pizza&reference=INV-42",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				arg := db.ListAccountTransfersParams{
+					AccountID: acc.ID,
+					Search:    sql.NullString{String: "Pizza", Valid: true},
+					Reference: sql.NullString{String: "INV-42", Valid: true},
+					Limit:     5,
+					Offset:    5,
+				}
+				repo.EXPECT().ListAccountTransfers(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rows[1:], nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidReference",
+			username: user.Username,
+			query:    "page=1&limit=5&reference=INV%2342",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().ListAccountTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NotMember",
+			username: other.Username,
+			query:    "page=1&limit=5",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				repo.EXPECT().
+					GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: other.Username})).
+					Times(1).
+					Return(db.AccountMember{}, sql.ErrNoRows)
+				repo.EXPECT().ListAccountTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%s/transfers?%s", acc.Number, tc.query)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestQuoteTransferAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
+	acc := generateRandomAccount(user.Username)
+	acc.Currency = "USD"
+	acc.Balance = 1010
+	to := generateRandomAccount("receiver")
+	to.Currency = "USD"
+
+	body := gin.H{"fromAccount": acc.Number, "toAccount": to.Number, "amount": 1000, "currency": acc.Currency, "reference": "INV-1"}
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountLookup(repo, to)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Eq(acc), gomock.Eq(int64(1000))).Times(1).Return(db.TransferFee{RuleID: 1, Amount: 10}, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got transferQuoteResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.NotEmpty(t, got.QuoteToken)
+				require.WithinDuration(t, time.Now().Add(time.Minute), got.ExpiresAt, time.Second)
+				require.Equal(t, acc.Number, got.FromAccount)
+				require.Equal(t, to.Number, got.ToAccount)
+				require.Equal(t, int64(10), got.Fee)
+				require.Equal(t, int64(1010), got.Total)
+				require.Equal(t, "INV-1", got.Reference)
+				require.False(t, got.TOTPRequired)
+
+				// the token carries the terms and cant be used to authenticate
+				payload, err := server.tokenMaker.VerifyToken(got.QuoteToken)
+				require.NoError(t, err)
+				require.Equal(t, auth.ScopeTransferQuote, payload.Scope)
+				require.Equal(t, user.Username, payload.Username)
+
+				var quote transferQuote
+				require.NoError(t, json.Unmarshal(payload.Data, &quote))
+				require.Equal(t, transferQuote{FromAccount: acc.Number, ToAccount: to.Number, Amount: 1000, Reference: "INV-1", Fee: 10}, quote)
+				require.NotContains(t, string(payload.Data), "ID")
+			},
+		},
+		{
+			name:     "Waived",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountLookup(repo, to)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleCoOwner)
+				repo.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(db.TransferFee{RuleID: 1, Waived: true, FreeLeft: 2}, nil)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got transferQuoteResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Zero(t, got.Fee)
+				require.Equal(t, int64(1000), got.Total)
+				require.True(t, got.FeeWaived)
+				require.Equal(t, int64(2), got.FreeLeft)
+			},
+		},
+		{
+			name:     "FeeNotCovered",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountLookup(repo, to)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(db.TransferFee{RuleID: 1, Amount: 11}, nil)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.NotContains(t, recorder.Body.String(), "quoteToken")
+			},
+		},
+		{
+			name:     "Viewer",
+			username: viewer.Username,
+			body:     body,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				expectAccountLookup(repo, acc)
+				expectAccountMember(repo, acc, viewer.Username, db.AccountRoleViewer)
+				repo.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "ReceiverCurrencyMismatch",
+			username: user.Username,
+			body:     body,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				cad := to
+				cad.Currency = "CAD"
+				expectAccountLookup(repo, acc)
+				expectAccountLookup(repo, cad)
+				expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "MissingReceiver",
+			username: user.Username,
+			body:     gin.H{"fromAccount": acc.Number, "amount": 1000, "currency": acc.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/transfers/quote", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, server, recorder)
+		})
+	}
+}
+
+func TestExecuteTransferQuote(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	acc := generateRandomAccount(user.Username)
+	acc.Currency = "USD"
+	acc.Balance = 5000
+	to := generateRandomAccount("receiver")
+	to.Currency = "USD"
+
+	quote := transferQuote{FromAccount: acc.Number, ToAccount: to.Number, Amount: 1000, Fee: 10}
+	body := gin.H{"fromAccount": acc.Number, "toAccount": to.Number, "amount": 1000, "currency": acc.Currency}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		createToken   func(t *testing.T, tm auth.TokenMaker) string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, quote, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.TransferTxParams) (db.TransferTxResult, error) {
+						require.NotEmpty(t, arg.QuoteID)
+						require.Equal(t, quote.Fee, arg.QuotedFee)
+						require.Equal(t, quote.Amount, arg.Amount)
+						return db.TransferTxResult{
+							Transfer:    db.Transfer{Amount: arg.Amount, Fee: arg.QuotedFee},
+							FromAccount: acc,
+							ToAccount:   to,
+						}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "TermsChanged",
+			body: gin.H{"fromAccount": acc.Number, "toAccount": to.Number, "amount": 1001, "currency": acc.Currency},
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, quote, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OtherReceiver",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				other := quote
+				other.ToAccount = generateRandomAccount("receiver").Number
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, other, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OtherUser",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(other.Username, auth.ScopeTransferQuote, quote, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AccessToken",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateToken(user.Username, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Expired",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, quote, -time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "FeeChanged",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, quote, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrQuoteChanged)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyExecuted",
+			body: body,
+			createToken: func(t *testing.T, tm auth.TokenMaker) string {
+				token, err := tm.CreateDataToken(user.Username, auth.ScopeTransferQuote, quote, time.Minute)
+				require.NoError(t, err)
+				return token
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, &pq.Error{Code: "23505"})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			expectAccountLookup(repo, acc)
+			expectAccountLookup(repo, to)
+			expectAccountMember(repo, acc, user.Username, db.AccountRoleOwner)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			body := gin.H{"quoteToken": tc.createToken(t, server.tokenMaker)}
+			for k, v := range tc.body {
+				body[k] = v
+			}
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
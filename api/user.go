@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
@@ -13,28 +14,45 @@ import (
 
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"` // the rules are configurable, see Server.passwordPolicy
 	FullName string `json:"fullname" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 }
 
 type userResponse struct {
+	Username          string     `json:"username"`
+	FullName          string     `json:"fullName"`
+	Email             string     `json:"email"`
+	PasswordChangedAt time.Time  `json:"passwordChangedAt"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	EmailVerifiedAt   *time.Time `json:"emailVerifiedAt"` // null until the email has been verified
+}
+
+// auditUser is what the audit log keeps of a user. the log is append-only and cant be cleaned up when the user
+// is deleted, so the name and email stay out of it
+type auditUser struct {
 	Username          string    `json:"username"`
-	FullName          string    `json:"fullName"`
-	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"passwordChangedAt"`
 	CreatedAt         time.Time `json:"createdAt"`
 }
 
+func newAuditUser(user db.User) auditUser {
+	return auditUser{Username: user.Username, PasswordChangedAt: user.PasswordChangedAt, CreatedAt: user.CreatedAt}
+}
+
 // takes an entire user struct and returns only certain, insensitive information in form of a  userResponse struct
 func newUserResponse(user db.User) userResponse {
-	return userResponse{
+	resp := userResponse{
 		Username:          user.Username,
 		Email:             user.Email,
 		FullName:          user.FullName,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
+	if user.EmailVerifiedAt.Valid {
+		resp.EmailVerifiedAt = &user.EmailVerifiedAt.Time
+	}
+	return resp
 }
 
 func (server *Server) createUser(ctx *gin.Context) {
@@ -44,9 +62,9 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	hashedPw, err := auth.HashPassword(req.Password)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	hashedPw, ok := server.hashNewPassword(ctx, req.Password, req.Username)
+	if !ok {
+		return
 	}
 
 	arg := db.CreateUserParams{Username: req.Username, HashedPassword: hashedPw, FullName: req.FullName, Email: req.Email}
@@ -69,6 +87,19 @@ func (server *Server) createUser(ctx *gin.Context) {
 
 	// only return insensitive info
 	resp := newUserResponse(user)
+
+	err = server.recordAuditEvent(ctx, user.Username, db.AuditActionUserCreated, db.AuditResourceUser, user.Username, nil, newAuditUser(user))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the user exists at this point, failing the request would only make the client retry with a taken username.
+	// a new token can be requested through the resend route instead
+	if err := server.sendEmailVerification(ctx, user); err != nil {
+		log.Printf("cannot send verification email to %s: %v", user.Username, err)
+	}
+
 	ctx.JSON(http.StatusOK, resp)
 }
 
@@ -92,6 +123,10 @@ func (server *Server) loginUser(ctx *gin.Context) {
 	user, err := server.repository.GetUser(ctx, req.Username)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			if auditErr := server.recordLoginFailure(ctx, req.Username, "unknown user"); auditErr != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(auditErr))
+				return
+			}
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
 		} else {
 			ctx.JSON(http.StatusInternalServerError, err)
@@ -102,12 +137,43 @@ func (server *Server) loginUser(ctx *gin.Context) {
 
 	err = auth.CheckPassword(user.HashedPassword, req.Password)
 	if err != nil {
+		if auditErr := server.recordLoginFailure(ctx, req.Username, "wrong password"); auditErr != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(auditErr))
+			return
+		}
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
+	// users with two-factor authentication get a token that only allows completing the login with a code
+	totp, err := server.repository.GetUserTOTP(ctx, user.Username)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		token, err := server.tokenMaker.CreateScopedToken(user.Username, auth.ScopeTwoFactor, server.config.TwoFactorTokenDuration)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, twoFactorChallengeResponse{TwoFactorRequired: true, TwoFactorToken: token})
+		return
+	}
+
+	server.completeLogin(ctx, user)
+}
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	TwoFactorToken    string `json:"twoFactorToken"` // has to be sent to /users/login/2fa together with a code
+}
+
+// completeLogin issues an access token once every required factor has been checked
+func (server *Server) completeLogin(ctx *gin.Context, user db.User) {
 	accessToken, err := server.tokenMaker.CreateToken(
-		req.Username,
+		user.Username,
 		server.config.AccessTokenDuration,
 	)
 	if err != nil {
@@ -115,6 +181,12 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	err = server.recordAuditEvent(ctx, user.Username, db.AuditActionLoginSucceeded, db.AuditResourceUser, user.Username, nil, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	resp := loginUserResponse{
 		User:        newUserResponse(user),
 		AccessToken: accessToken,
@@ -122,3 +194,8 @@ func (server *Server) loginUser(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, resp)
 }
+
+// the attempted username is recorded as actor, since there is no authenticated caller for a failed login
+func (server *Server) recordLoginFailure(ctx *gin.Context, username, reason string) error {
+	return server.recordAuditEvent(ctx, username, db.AuditActionLoginFailed, db.AuditResourceUser, username, nil, gin.H{"reason": reason})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -88,8 +90,50 @@ func TestCreateUserAPI(t *testing.T) {
 					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)). // simple equal check wouldnt work since hashed pw is always different
 					Times(1).
 					Return(user, nil)
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionUserCreated)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.AppendAuditEventParams) (db.AuditEvent, error) {
+						requireNoPersonalData(t, arg, user)
+						return db.AuditEvent{}, nil
+					})
+				repo.EXPECT().
+					CreateEmailVerificationToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateEmailVerificationTokenParams) (db.EmailVerificationToken, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Email, arg.Email)
+						return db.EmailVerificationToken{TokenHash: arg.TokenHash, Username: arg.Username, Email: arg.Email, ExpiresAt: arg.ExpiresAt}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireResponseBodyMatch(t, recorder.Body, user)
+			},
+		},
+		{
+			name: "VerificationEmailFails",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"fullName": user.FullName,
+				"email":    user.Email,
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionUserCreated)).
+					Times(1)
+				repo.EXPECT().
+					CreateEmailVerificationToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.EmailVerificationToken{}, sql.ErrConnDone)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				// the user has been created, a new token can be requested later
 				require.Equal(t, http.StatusOK, recorder.Code)
 				requireResponseBodyMatch(t, recorder.Body, user)
 			},
@@ -233,9 +277,46 @@ func TestLoginUserAPI(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				repo.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginSucceeded)).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "TwoFactorRequired",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				repo.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+				// the login only succeeds after the second step
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp map[string]interface{}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, true, resp["twoFactorRequired"])
+				require.NotEmpty(t, resp["twoFactorToken"])
+				require.NotContains(t, resp, "accessToken")
 			},
 		},
 		{
@@ -249,6 +330,10 @@ func TestLoginUserAPI(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				// failed logins are being recorded as well
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).
+					Times(1)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
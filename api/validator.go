@@ -1,7 +1,16 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
 	library "github.com/maxeth/go-bank-app/library"
 )
 
@@ -16,3 +25,82 @@ var validCurrency validator.Func = func(fl validator.FieldLevel) bool {
 	// field is not a string so it cannot be a supported currency
 	return false
 }
+
+var validAccountNumber validator.Func = func(fl validator.FieldLevel) bool {
+	if val, ok := fl.Field().Interface().(string); ok {
+		// checks the format and the mod-97 check digits, so typos are caught before the account is looked up
+		return library.IsValidAccountNumber(val)
+	}
+
+	return false
+}
+
+var validFreeText validator.Func = func(fl validator.FieldLevel) bool {
+	val, ok := fl.Field().Interface().(string)
+
+	// any printable character is fine, control characters like newlines would break statements and exports
+	return ok && utf8.ValidString(val) && isPrintable(val)
+}
+
+var validReference validator.Func = func(fl validator.FieldLevel) bool {
+	val, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	// the character set allowed in SEPA remittance references, so references can be passed on to other banks unchanged
+	for _, r := range val {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("/-?:().,'+ ", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+var validEventType validator.Func = func(fl validator.FieldLevel) bool {
+	val, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	for _, eventType := range db.EventTypes {
+		if val == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bindStrictJSON binds like ShouldBindJSON, but rejects fields the request doesnt know. requests that move money use
+// it, so a misspelled optional field like "referense" fails instead of silently sending the transfer without it
+func bindStrictJSON(ctx *gin.Context, obj interface{}) error {
+	if ctx.Request.Body == nil {
+		return errors.New("request body required")
+	}
+
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	// a second value after the object would be ignored otherwise
+	if decoder.More() {
+		return errors.New("request body must be a single json object")
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// format of the dates in API_DEPRECATIONS, API_UNVERSIONED_DEPRECATED and API_UNVERSIONED_SUNSET
+const deprecationDateFormat = "2006-01-02"
+
+type route struct {
+	method   string
+	path     string
+	handlers []gin.HandlerFunc
+}
+
+// routes collects the routes of an api version before they are mounted, it mirrors the parts of gin.IRoutes
+// the server uses
+type routes struct {
+	list       *[]route
+	middleware []gin.HandlerFunc
+}
+
+func newRoutes() routes {
+	return routes{list: &[]route{}}
+}
+
+// Use returns a view of the routes that runs the given middleware in front of every route added through it
+func (r routes) Use(middleware ...gin.HandlerFunc) routes {
+	r.middleware = append(append([]gin.HandlerFunc{}, r.middleware...), middleware...)
+	return r
+}
+
+func (r routes) handle(method string, path string, handlers []gin.HandlerFunc) {
+	chain := append(append([]gin.HandlerFunc{}, r.middleware...), handlers...)
+	*r.list = append(*r.list, route{method: method, path: path, handlers: chain})
+}
+
+func (r routes) GET(path string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, path, handlers)
+}
+
+func (r routes) POST(path string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, path, handlers)
+}
+
+func (r routes) PUT(path string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, path, handlers)
+}
+
+func (r routes) PATCH(path string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPatch, path, handlers)
+}
+
+func (r routes) DELETE(path string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, path, handlers)
+}
+
+// apiVersion is mounted under /<name>. a version only lists the routes that changed compared to the previous one,
+// every other route of the previous version is served under the new prefix as well
+type apiVersion struct {
+	name   string
+	routes routes
+}
+
+// deprecation is announced to clients through the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+type deprecation struct {
+	at     time.Time
+	sunset time.Time // zero if no removal date was announced yet
+}
+
+// parseDeprecations parses a "METHOD /path deprecated-date [sunset-date],..." list, e.g.
+// "GET /v1/accounts 2026-11-01 2027-05-01". paths are the patterns the routes were registered with
+func parseDeprecations(spec string) (map[string]deprecation, error) {
+	deprecations := map[string]deprecation{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("invalid deprecation %q, expected METHOD /path deprecated-date [sunset-date]", strings.TrimSpace(entry))
+		}
+
+		var d deprecation
+		var err error
+		d.at, err = time.Parse(deprecationDateFormat, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid deprecation date of %s %s", fields[0], fields[1])
+		}
+		if len(fields) == 4 {
+			d.sunset, err = time.Parse(deprecationDateFormat, fields[3])
+			if err != nil || !d.sunset.After(d.at) {
+				return nil, fmt.Errorf("sunset date of %s %s must be a date after the deprecation date", fields[0], fields[1])
+			}
+		}
+
+		deprecations[routeKey(strings.ToUpper(fields[0]), fields[1])] = d
+	}
+
+	return deprecations, nil
+}
+
+func routeKey(method string, path string) string {
+	return method + " " + path
+}
+
+// mountVersions mounts every version under its prefix, versions without own routes are left out. the routes of
+// the first version are also served without prefix for clients from before the versioning, those always count as
+// deprecated with the unversioned dates. deprecations are keyed by the full path, e.g. "GET /v1/accounts"
+func mountVersions(router gin.IRoutes, versions []apiVersion, deprecations map[string]deprecation, unversioned deprecation) error {
+	unknown := map[string]bool{}
+	for key := range deprecations {
+		unknown[key] = true
+	}
+
+	// the routes of the version being mounted, a route keeps its position from the version that introduced it
+	var served []route
+	index := map[string]int{}
+	first := true
+
+	for i, version := range versions {
+		if len(*version.routes.list) == 0 {
+			continue
+		}
+
+		for _, r := range *version.routes.list {
+			key := routeKey(r.method, r.path)
+			if j, ok := index[key]; ok {
+				served[j] = r
+				continue
+			}
+			index[key] = len(served)
+			served = append(served, r)
+		}
+
+		prefix := "/" + version.name
+		successors := nextVersionReplacing(versions[i+1:])
+		for _, r := range served {
+			key := routeKey(r.method, prefix+r.path)
+			delete(unknown, key)
+
+			handlers := r.handlers
+			d, deprecated := deprecations[key]
+			successor := successors[routeKey(r.method, r.path)]
+			if deprecated || successor != "" {
+				handlers = append([]gin.HandlerFunc{deprecationMiddleware(d, prefix, successor)}, handlers...)
+			}
+			router.Handle(r.method, prefix+r.path, handlers...)
+		}
+
+		if first {
+			for _, r := range served {
+				router.Handle(r.method, r.path, append([]gin.HandlerFunc{deprecationMiddleware(unversioned, "", prefix)}, r.handlers...)...)
+			}
+			first = false
+		}
+	}
+
+	for key := range unknown {
+		return fmt.Errorf("cannot deprecate %s, no such route", key)
+	}
+	return nil
+}
+
+// nextVersionReplacing returns the name of the closest later version that replaces each route
+func nextVersionReplacing(later []apiVersion) map[string]string {
+	next := map[string]string{}
+	for i := len(later) - 1; i >= 0; i-- {
+		for _, r := range *later[i].routes.list {
+			next[routeKey(r.method, r.path)] = "/" + later[i].name
+		}
+	}
+	return next
+}
+
+// deprecationMiddleware adds the deprecation headers. if a later version replaces the route, the Link header points
+// clients to it by swapping the prefix of the requested path
+func deprecationMiddleware(d deprecation, prefix string, successor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		if !d.at.IsZero() {
+			header.Set("Deprecation", fmt.Sprintf("@%d", d.at.Unix()))
+		}
+		if !d.sunset.IsZero() {
+			header.Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != "" {
+			path := successor + strings.TrimPrefix(ctx.Request.URL.Path, prefix)
+			header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, path))
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		deprecations          string
+		unversionedDeprecated string
+		unversionedSunset     string
+		path                  string
+		checkResponse         func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "V1",
+			path: "/v1/users/me",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Empty(t, rec.Header().Get("Deprecation"))
+				require.Empty(t, rec.Header().Get("Sunset"))
+				require.Empty(t, rec.Header().Get("Link"))
+			},
+		},
+		{
+			name:                  "Unversioned",
+			unversionedDeprecated: "2026-10-19",
+			path:                  "/users/me",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rec.Code)
+				require.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+				require.Empty(t, rec.Header().Get("Sunset"))
+				require.Equal(t, `</v1/users/me>; rel="successor-version"`, rec.Header().Get("Link"))
+			},
+		},
+		{
+			name:              "UnversionedSunset",
+			unversionedSunset: "2027-04-30",
+			path:              "/users/me",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+			},
+		},
+		{
+			name:         "DeprecatedV1Route",
+			deprecations: "GET /v1/users/me 2026-11-01 2027-05-01, delete /v1/users/me 2026-11-01",
+			path:         "/v1/users/me",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Equal(t, "@1793491200", rec.Header().Get("Deprecation"))
+				require.Equal(t, "Sat, 01 May 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+				require.Empty(t, rec.Header().Get("Link"))
+			},
+		},
+		{
+			name:         "OtherRouteDeprecated",
+			deprecations: "PATCH /v1/users/me 2026-11-01",
+			path:         "/v1/users/me",
+			checkResponse: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				require.Empty(t, rec.Header().Get("Deprecation"))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockRepository(ctrl))
+			server.config.APIDeprecations = tc.deprecations
+			server.config.APIUnversionedDeprecated = tc.unversionedDeprecated
+			server.config.APIUnversionedSunset = tc.unversionedSunset
+			require.NoError(t, server.applyRoutes())
+
+			rec := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(rec, req)
+			tc.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestApplyRoutesUnknownDeprecation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockRepository(ctrl))
+
+	// routes are deprecated per version, the unversioned ones have their own sunset
+	for _, spec := range []string{"GET /v1/user/me 2026-11-01", "GET /users/me 2026-11-01", "GET /v2/users/me 2026-11-01"} {
+		server.config.APIDeprecations = spec
+		require.Error(t, server.applyRoutes(), spec)
+	}
+}
+
+func TestMountVersions(t *testing.T) {
+	handler := func(name string) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, name)
+		}
+	}
+
+	v1 := newRoutes()
+	v1.GET("/accounts", handler("v1 list"))
+	v1.GET("/accounts/:number", handler("v1 get"))
+
+	v2 := newRoutes()
+	v2.GET("/accounts/:number", handler("v2 get"))
+
+	versions := []apiVersion{
+		{name: "v1", routes: v1},
+		{name: "v2", routes: v2},
+		{name: "v3", routes: newRoutes()},
+	}
+
+	router := gin.New()
+	deprecations := map[string]deprecation{
+		"GET /v1/accounts/:number": {at: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	require.NoError(t, mountVersions(router, versions, deprecations, deprecation{}))
+
+	testCases := []struct {
+		path      string
+		code      int
+		body      string
+		successor string
+	}{
+		{path: "/v1/accounts", code: http.StatusOK, body: "v1 list"},
+		{path: "/v1/accounts/DE01", code: http.StatusOK, body: "v1 get", successor: "/v2/accounts/DE01"},
+		{path: "/v2/accounts", code: http.StatusOK, body: "v1 list"},
+		{path: "/v2/accounts/DE01", code: http.StatusOK, body: "v2 get"},
+		{path: "/accounts/DE01", code: http.StatusOK, body: "v1 get", successor: "/v1/accounts/DE01"},
+		// versions without own routes arent mounted
+		{path: "/v3/accounts", code: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+		require.NoError(t, err)
+
+		router.ServeHTTP(rec, req)
+		require.Equal(t, tc.code, rec.Code, tc.path)
+		if tc.code != http.StatusOK {
+			continue
+		}
+		require.Equal(t, tc.body, rec.Body.String(), tc.path)
+
+		link := ""
+		if tc.successor != "" {
+			link = fmt.Sprintf(`<%s>; rel="successor-version"`, tc.successor)
+		}
+		require.Equal(t, link, rec.Header().Get("Link"), tc.path)
+	}
+}
+
+func TestParseDeprecations(t *testing.T) {
+	deprecations, err := parseDeprecations(" get /v1/accounts 2026-11-01 2027-05-01,, POST /v1/transfers 2026-12-01 ")
+	require.NoError(t, err)
+	require.Equal(t, map[string]deprecation{
+		"GET /v1/accounts": {
+			at:     time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			sunset: time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"POST /v1/transfers": {at: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)},
+	}, deprecations)
+
+	deprecations, err = parseDeprecations("")
+	require.NoError(t, err)
+	require.Empty(t, deprecations)
+
+	for _, spec := range []string{
+		"GET /v1/accounts",
+		"GET /v1/accounts 01.11.2026",
+		"GET /v1/accounts 2026-11-01 2026-10-01",
+		"GET /v1/accounts 2026-11-01 2027-05-01 2028-01-01",
+	} {
+		_, err := parseDeprecations(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/webhook"
+)
+
+// every user can register this many webhooks
+const maxWebhookSubscriptions = 10
+
+// prefix of webhook secrets, so they can be recognized in configuration files and secret scanners
+const webhookSecretPrefix = "whsec_"
+
+// resolves the hosts of webhook urls, tests replace it so they dont depend on dns
+var lookupWebhookHost = net.DefaultResolver.LookupIPAddr
+
+type webhookResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newWebhookResponse(sub db.WebhookSubscription) webhookResponse {
+	return webhookResponse{
+		ID:         sub.ID,
+		URL:        sub.Url,
+		EventTypes: sub.EventTypes,
+		CreatedAt:  sub.CreatedAt,
+	}
+}
+
+type createWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url,max=2000"`
+	EventTypes []string `json:"eventTypes" binding:"required,min=1,unique,dive,eventtype"`
+}
+
+type createWebhookResponse struct {
+	webhookResponse
+	Secret string `json:"secret"` // only returned once, deliveries are signed with it
+}
+
+// createWebhook subscribes the caller to events of all accounts they are a member of. the secret the deliveries
+// are signed with is only part of this response
+func (server *Server) createWebhook(ctx *gin.Context) {
+	var req createWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := server.checkWebhookURL(ctx, req.URL); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	count, err := server.repository.CountWebhookSubscriptions(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if count >= maxWebhookSubscriptions {
+		err := fmt.Errorf("cannot register more than %d webhooks", maxWebhookSubscriptions)
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	// the hash is of no use, the secret has to be stored in plain text to sign the deliveries
+	token, _, err := auth.NewSecretToken()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	sub, err := server.repository.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Username:   authPayload.Username,
+		Url:        req.URL,
+		Secret:     webhookSecretPrefix + token,
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the audit log gets the response, which doesnt contain the secret
+	resp := newWebhookResponse(sub)
+	err = server.recordAuditEvent(ctx, authPayload.Username, db.AuditActionWebhookCreated, db.AuditResourceWebhook, strconv.FormatInt(sub.ID, 10), nil, resp)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createWebhookResponse{webhookResponse: resp, Secret: sub.Secret})
+}
+
+// checkWebhookURL only accepts http(s) urls. production requires https, so deliveries cant be read on the way, and
+// hosts that resolve to public addresses. the worker checks the address again for every delivery, this only turns
+// down urls that can never be delivered to
+func (server *Server) checkWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute http(s) url")
+	}
+
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && !server.config.IsProduction():
+	case u.Scheme == "http":
+		return errors.New("url must use https")
+	default:
+		return errors.New("url must be an absolute http(s) url")
+	}
+
+	if !server.config.IsProduction() {
+		return nil
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !webhook.IsPublicIP(ip) {
+			return errors.New("url must point to a public address")
+		}
+		return nil
+	}
+
+	addrs, err := lookupWebhookHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("cannot resolve the host %s", host)
+	}
+	for _, addr := range addrs {
+		if !webhook.IsPublicIP(addr.IP) {
+			return errors.New("url must point to a public address")
+		}
+	}
+	return nil
+}
+
+func (server *Server) listWebhooks(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+
+	subs, err := server.repository.ListWebhookSubscriptions(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]webhookResponse, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, newWebhookResponse(sub))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type webhookRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// loadWebhook binds the webhook id from the uri and returns the subscription if it belongs to the caller.
+// the error response has already been sent when false is returned
+func (server *Server) loadWebhook(ctx *gin.Context) (db.WebhookSubscription, bool) {
+	var req webhookRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.WebhookSubscription{}, false
+	}
+
+	sub, err := server.repository.GetWebhookSubscription(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return db.WebhookSubscription{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.WebhookSubscription{}, false
+	}
+
+	authPayload := ctx.MustGet(authPayloadKey).(*auth.Payload)
+	if sub.Username != authPayload.Username {
+		err := fmt.Errorf("not authorized to access webhook [%d]", req.ID)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return db.WebhookSubscription{}, false
+	}
+
+	return sub, true
+}
+
+func (server *Server) getWebhook(ctx *gin.Context) {
+	sub, ok := server.loadWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newWebhookResponse(sub))
+}
+
+// deleteWebhook removes the subscription together with its delivery log, pending deliveries are dropped
+func (server *Server) deleteWebhook(ctx *gin.Context) {
+	sub, ok := server.loadWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	if err := server.repository.DeleteWebhookSubscription(ctx, sub.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err := server.recordAuditEvent(ctx, sub.Username, db.AuditActionWebhookDeleted, db.AuditResourceWebhook, strconv.FormatInt(sub.ID, 10), newWebhookResponse(sub), nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type webhookDeliveryResponse struct {
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"eventID"` // id of the event in the delivered body
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt"` // only set while the delivery is pending
+	LastStatusCode *int32     `json:"lastStatusCode"`
+	LastError      string     `json:"lastError"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+}
+
+func newWebhookDeliveryResponse(delivery db.ListWebhookDeliveriesRow) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:        delivery.ID,
+		EventID:   delivery.OutboxID,
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.Status == db.WebhookDeliveryPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+
+	return resp
+}
+
+type listWebhookDeliveriesRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending succeeded dead"`
+	PageID int32  `form:"page" binding:"required,min=1"`
+	Limit  int32  `form:"limit" binding:"required,min=5,max=100"`
+}
+
+// listWebhookDeliveries is the delivery log of a webhook, newest first. status=dead lists the dead letters
+func (server *Server) listWebhookDeliveries(ctx *gin.Context) {
+	var req listWebhookDeliveriesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	sub, ok := server.loadWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	deliveries, err := server.repository.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		SubscriptionID: sub.ID,
+		Status:         sql.NullString{String: req.Status, Valid: req.Status != ""},
+		LimitCount:     req.Limit,
+		OffsetCount:    (req.PageID - 1) * req.Limit,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(delivery))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type webhookDeliveryRequest struct {
+	ID         int64 `uri:"id" binding:"required,min=1"`
+	DeliveryID int64 `uri:"deliveryID" binding:"required,min=1"`
+}
+
+// retryWebhookDelivery puts a dead delivery back into the queue, e.g. after the receiver was fixed
+func (server *Server) retryWebhookDelivery(ctx *gin.Context) {
+	var req webhookDeliveryRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	sub, ok := server.loadWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	delivery, err := server.repository.GetWebhookDelivery(ctx, req.DeliveryID)
+	if err == sql.ErrNoRows || (err == nil && delivery.SubscriptionID != sub.ID) {
+		err := fmt.Errorf("webhook [%d] has no delivery [%d]", sub.ID, req.DeliveryID)
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.repository.RetryWebhookDelivery(ctx, delivery.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// only dead deliveries can be retried, pending ones are retried anyway
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("only dead deliveries can be retried")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/maxeth/go-bank-app/config"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	library "github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhookAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	events := []string{db.EventTransferReceived, db.EventAccountClosed}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"url": "https://example.com/hooks", "eventTypes": events},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CountWebhookSubscriptions(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(int64(0), nil)
+				repo.EXPECT().
+					CreateWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "https://example.com/hooks", arg.Url)
+						require.Equal(t, events, arg.EventTypes)
+						require.True(t, strings.HasPrefix(arg.Secret, webhookSecretPrefix))
+						return db.WebhookSubscription{ID: 1, Username: arg.Username, Url: arg.Url, Secret: arg.Secret, EventTypes: arg.EventTypes}, nil
+					})
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionWebhookCreated)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got createWebhookResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, "https://example.com/hooks", got.URL)
+				require.Equal(t, events, got.EventTypes)
+				require.True(t, strings.HasPrefix(got.Secret, webhookSecretPrefix))
+			},
+		},
+		{
+			name: "UnknownEventType",
+			body: gin.H{"url": "https://example.com/hooks", "eventTypes": []string{"account.frozen"}},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NoEventTypes",
+			body: gin.H{"url": "https://example.com/hooks", "eventTypes": []string{}},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidScheme",
+			body: gin.H{"url": "ftp://example.com/hooks", "eventTypes": events},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TooManyWebhooks",
+			body: gin.H{"url": "https://example.com/hooks", "eventTypes": events},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CountWebhookSubscriptions(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(int64(maxWebhookSubscriptions), nil)
+				repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	hosts := map[string][]string{
+		"example.com":       {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		"internal.example":  {"10.0.0.5"},
+		"rebinding.example": {"93.184.216.34", "127.0.0.1"},
+	}
+	lookup := lookupWebhookHost
+	defer func() { lookupWebhookHost = lookup }()
+	lookupWebhookHost = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		var addrs []net.IPAddr
+		for _, ip := range hosts[host] {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return addrs, nil
+	}
+
+	ctx := context.Background()
+	server := &Server{}
+	require.NoError(t, server.checkWebhookURL(ctx, "https://example.com/hooks"))
+	require.NoError(t, server.checkWebhookURL(ctx, "http://localhost:9000/hooks"))
+	require.NoError(t, server.checkWebhookURL(ctx, "http://10.0.0.5/hooks"))
+	require.Error(t, server.checkWebhookURL(ctx, "/hooks"))
+	require.Error(t, server.checkWebhookURL(ctx, "mailto:someone@example.com"))
+
+	server.config.Environment = config.EnvProduction
+	require.NoError(t, server.checkWebhookURL(ctx, "https://example.com/hooks"))
+	require.NoError(t, server.checkWebhookURL(ctx, "https://93.184.216.34:8443/hooks"))
+	require.Error(t, server.checkWebhookURL(ctx, "http://example.com/hooks"))
+
+	for _, rawURL := range []string{
+		"https://127.0.0.1/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/hooks",
+		"https://[::ffff:10.0.0.5]/hooks",
+		"https://internal.example/hooks",
+		"https://rebinding.example/hooks",
+		"https://unknown.example/hooks",
+	} {
+		require.Error(t, server.checkWebhookURL(ctx, rawURL), rawURL)
+	}
+}
+
+func TestDeleteWebhookAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	sub := randomWebhook(user.Username)
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(sub, nil)
+				repo.EXPECT().DeleteWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionWebhookDeleted)).Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:     "NotOwner",
+			username: other.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(sub, nil)
+				repo.EXPECT().DeleteWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(db.WebhookSubscription{}, sql.ErrNoRows)
+				repo.EXPECT().DeleteWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/webhooks/%d", sub.ID)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, tc.username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListWebhookDeliveriesAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	sub := randomWebhook(user.Username)
+
+	dead := db.ListWebhookDeliveriesRow{
+		ID:             2,
+		SubscriptionID: sub.ID,
+		OutboxID:       20,
+		Status:         db.WebhookDeliveryDead,
+		Attempts:       8,
+		LastStatusCode: sql.NullInt32{Int32: http.StatusServiceUnavailable, Valid: true},
+		LastError:      "receiver answered with 503",
+		EventType:      db.EventTransferReceived,
+	}
+	delivered := db.ListWebhookDeliveriesRow{
+		ID:             1,
+		SubscriptionID: sub.ID,
+		OutboxID:       10,
+		Status:         db.WebhookDeliverySucceeded,
+		Attempts:       1,
+		LastStatusCode: sql.NullInt32{Int32: http.StatusOK, Valid: true},
+		DeliveredAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		EventType:      db.EventTransferReceived,
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "page=1&limit=10",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.ListWebhookDeliveriesParams{SubscriptionID: sub.ID, LimitCount: 10, OffsetCount: 0}
+				repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(sub, nil)
+				repo.EXPECT().ListWebhookDeliveries(gomock.Any(), gomock.Eq(arg)).Times(1).Return([]db.ListWebhookDeliveriesRow{dead, delivered}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got []webhookDeliveryResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Len(t, got, 2)
+				require.Equal(t, dead.OutboxID, got[0].EventID)
+				require.Equal(t, db.WebhookDeliveryDead, got[0].Status)
+				require.Equal(t, int32(http.StatusServiceUnavailable), *got[0].LastStatusCode)
+				require.Nil(t, got[0].DeliveredAt)
+				require.Nil(t, got[0].NextAttemptAt)
+				require.NotNil(t, got[1].DeliveredAt)
+			},
+		},
+		{
+			name:  "DeadLetters",
+			query: "page=2&limit=5&status=dead",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.ListWebhookDeliveriesParams{
+					SubscriptionID: sub.ID,
+					Status:         sql.NullString{String: db.WebhookDeliveryDead, Valid: true},
+					LimitCount:     5,
+					OffsetCount:    5,
+				}
+				repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(sub, nil)
+				repo.EXPECT().ListWebhookDeliveries(gomock.Any(), gomock.Eq(arg)).Times(1).Return([]db.ListWebhookDeliveriesRow{dead}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidStatus",
+			query: "page=1&limit=10&status=lost",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListWebhookDeliveries(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/webhooks/%d/deliveries?%s", sub.ID, tc.query)
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestRetryWebhookDeliveryAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	sub := randomWebhook(user.Username)
+	delivery := db.WebhookDelivery{ID: 7, SubscriptionID: sub.ID, OutboxID: 70, Status: db.WebhookDeliveryDead, Attempts: 8}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				retried := delivery
+				retried.Status = db.WebhookDeliveryPending
+				retried.Attempts = 0
+
+				repo.EXPECT().GetWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).Times(1).Return(delivery, nil)
+				repo.EXPECT().RetryWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).Times(1).Return(retried, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "NotDead",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				pending := delivery
+				pending.Status = db.WebhookDeliveryPending
+
+				repo.EXPECT().GetWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).Times(1).Return(pending, nil)
+				repo.EXPECT().RetryWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).Times(1).Return(db.WebhookDelivery{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "OtherWebhook",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				foreign := delivery
+				foreign.SubscriptionID = sub.ID + 1
+
+				repo.EXPECT().GetWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).Times(1).Return(foreign, nil)
+				repo.EXPECT().RetryWebhookDelivery(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Eq(sub.ID)).Times(1).Return(sub, nil)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/webhooks/%d/deliveries/%d/retry", sub.ID, delivery.ID)
+			req, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthToHeader(t, req, server.tokenMaker, time.Minute, authTypeBearer, user.Username)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func randomWebhook(username string) db.WebhookSubscription {
+	return db.WebhookSubscription{
+		ID:         library.RandomInt(1, 1000),
+		Username:   username,
+		Url:        "https://example.com/" + library.RandomString(8),
+		Secret:     webhookSecretPrefix + library.RandomString(32),
+		EventTypes: []string{db.EventTransferReceived},
+		CreatedAt:  time.Now(),
+	}
+}
@@ -10,40 +10,106 @@ import (
 
 const minKeyLen = 32
 
+// symmetric keys create HS256 tokens, asymmetric keys create EdDSA (ed25519) tokens
 type JWTMaker struct {
-	secretKey string
+	keys       KeySet
+	asymmetric bool
 }
 
 //
 func NewJWTMaker(secretKey string) (TokenMaker, error) {
-	if len(secretKey) < minKeyLen {
-		return nil, fmt.Errorf("invalid key size. key must be at least %d characters", minKeyLen)
+	return NewJWTMakerFromKeys(KeySet{Keys: []Key{{Secret: []byte(secretKey)}}})
+}
+
+// creates a jwt token-manager that supports several keys, see KeySet
+func NewJWTMakerFromKeys(keys KeySet) (TokenMaker, error) {
+	keys, asymmetric, err := keys.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if !asymmetric {
+		for _, key := range keys.Keys {
+			if len(key.Secret) < minKeyLen {
+				return nil, fmt.Errorf("invalid key size. key must be at least %d characters", minKeyLen)
+			}
+		}
 	}
-	return &JWTMaker{secretKey}, nil
+
+	return &JWTMaker{keys: keys, asymmetric: asymmetric}, nil
 }
 
 func (jm *JWTMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, duration)
+	return jm.CreateScopedToken(username, ScopeAccess, duration)
+}
+
+func (jm *JWTMaker) CreateScopedToken(username string, scope string, duration time.Duration) (string, error) {
+	return jm.CreateDataToken(username, scope, nil, duration)
+}
+
+func (jm *JWTMaker) CreateDataToken(username string, scope string, data interface{}, duration time.Duration) (string, error) {
+	key, err := jm.keys.current()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := newScopedPayload(username, scope, data, duration)
 	if err != nil {
 		return "", err
 	}
+
+	if jm.asymmetric {
+		token := jwt.NewWithClaims(SigningMethodEdDSA, payload)
+		setKeyID(token, key.ID)
+		return token.SignedString(key.PrivateKey)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload) // token struct
-	return token.SignedString([]byte(jm.secretKey))             // signs token struct, turns it into a string
+	setKeyID(token, key.ID)
+	return token.SignedString(key.Secret) // signs token struct, turns it into a string
+}
+
+// the kid header tells which key to verify the token with. tokens of a key without id have no kid header
+func setKeyID(token *jwt.Token, id string) {
+	if id != "" {
+		token.Header["kid"] = id
+	}
 }
 
 // check if input token is valid and return the payload if it is, or an error if it isnt
 func (jm *JWTMaker) VerifyToken(token string) (*Payload, error) {
 
 	keyFunc := func(jwtToken *jwt.Token) (interface{}, error) {
-		// ensure that the signing method specificed in the token is in fact the one we use (HS256 which is an instance of HMAC)
-		_, ok := jwtToken.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
+		// ensure that the signing method specificed in the token is in fact the one we use (HS256 which is an instance of HMAC, or EdDSA)
+		// otherwise an attacker could e.g. sign a token with HMAC and our public key as secret
+		if jm.asymmetric {
+			if jwtToken.Method != SigningMethodEdDSA {
+				return nil, ErrInvalidToken
+			}
+		} else if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
 			// a token with a false signing method was passed
 			return nil, ErrInvalidToken
 		}
 
+		keyID := ""
+		if kid, ok := jwtToken.Header["kid"]; ok {
+			keyID, ok = kid.(string)
+			if !ok {
+				return nil, ErrInvalidToken
+			}
+		}
+
+		key, ok := jm.keys.lookup(keyID)
+		if !ok {
+			// unknown or already retired key
+			return nil, ErrInvalidToken
+		}
+
 		// return key for validating
-		return []byte(jm.secretKey), nil
+		if jm.asymmetric {
+			return key.PublicKey, nil
+		}
+		return key.Secret, nil
 	}
 
 	// ParseWithClaims method docs:
@@ -68,3 +134,11 @@ func (jm *JWTMaker) VerifyToken(token string) (*Payload, error) {
 
 	return payload, nil
 }
+
+// returns the keys other services need to verify EdDSA tokens. symmetric keys are never shared
+func (jm *JWTMaker) PublicKeys() []PublicKey {
+	if !jm.asymmetric {
+		return []PublicKey{}
+	}
+	return jm.keys.publicKeys(SigningMethodEdDSA.Alg())
+}
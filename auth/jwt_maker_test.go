@@ -66,3 +66,21 @@ func TestInvalidJWT(t *testing.T) {
 	require.EqualError(t, err, ErrInvalidToken.Error())
 	require.Nil(t, payload)
 }
+
+func TestEdDSAJWTRejectsHMACWithPublicKey(t *testing.T) {
+	public, private := randomEd25519Key(t)
+	maker, err := NewJWTMakerFromKeys(KeySet{Keys: []Key{{PrivateKey: private}}})
+	require.NoError(t, err)
+
+	payload, err := NewPayload(library.RandomString(20), time.Minute)
+	require.NoError(t, err)
+
+	// the public key is not secret, so an HS256 token signed with it must never be accepted
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	signedToken, err := jwtToken.SignedString([]byte(public))
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(signedToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
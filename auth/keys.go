@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNoSigningKey = errors.New("no signing key configured, tokens can only be verified")
+
+// Key is a single key for creating or verifying tokens. symmetric keys only have a secret. asymmetric keys have
+// an ed25519 private key for signing and a public key for verifying, keys with only a public key can never sign
+type Key struct {
+	ID         string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// KeySet holds every key a token maker accepts. new tokens are always created with the current key, while
+// the other keys keep verifying tokens issued before a rotation until they are retired by removing them from the set.
+// a set without a current key can only verify tokens, e.g. in internal services that only know our public keys
+type KeySet struct {
+	CurrentID string
+	Keys      []Key
+}
+
+// PublicKey is the shareable half of an asymmetric key
+type PublicKey struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	Key       string `json:"key"` // standard base64 encoding of the raw ed25519 public key
+}
+
+// ParseKeySet parses a key list in the form "id1:value1,id2:value2". symmetric values are used as they are,
+// asymmetric values have to be the standard base64 encoding of a 32 byte ed25519 seed
+func ParseKeySet(spec string, currentID string, asymmetric bool) (KeySet, error) {
+	keys := KeySet{CurrentID: currentID}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return KeySet{}, fmt.Errorf("invalid key entry, expected id:value")
+		}
+
+		key := Key{ID: parts[0]}
+		if asymmetric {
+			seed, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return KeySet{}, fmt.Errorf("key %s must be a base64 encoded %d byte ed25519 seed", key.ID, ed25519.SeedSize)
+			}
+			key.PrivateKey = ed25519.NewKeyFromSeed(seed)
+		} else {
+			key.Secret = []byte(parts[1])
+		}
+
+		keys.Keys = append(keys.Keys, key)
+	}
+
+	if len(keys.Keys) == 0 {
+		return KeySet{}, errors.New("key list is empty")
+	}
+
+	return keys, nil
+}
+
+// normalize checks the set for consistency and derives missing public keys from the private keys
+func (ks KeySet) normalize() (KeySet, bool, error) {
+	if len(ks.Keys) == 0 {
+		return KeySet{}, false, errors.New("at least one key is required")
+	}
+
+	out := KeySet{CurrentID: ks.CurrentID}
+	asymmetric := len(ks.Keys[0].Secret) == 0
+	seen := map[string]bool{}
+	hasCurrent := false
+
+	for _, key := range ks.Keys {
+		if seen[key.ID] {
+			return KeySet{}, false, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		if (len(key.Secret) == 0) != asymmetric {
+			return KeySet{}, false, errors.New("symmetric and asymmetric keys cannot be mixed")
+		}
+
+		if asymmetric {
+			if key.PrivateKey != nil {
+				if len(key.PrivateKey) != ed25519.PrivateKeySize {
+					return KeySet{}, false, fmt.Errorf("invalid private key size for key %q", key.ID)
+				}
+				key.PublicKey = key.PrivateKey.Public().(ed25519.PublicKey)
+			}
+			if len(key.PublicKey) != ed25519.PublicKeySize {
+				return KeySet{}, false, fmt.Errorf("invalid public key size for key %q", key.ID)
+			}
+		}
+
+		if key.ID == ks.CurrentID {
+			if asymmetric && key.PrivateKey == nil {
+				return KeySet{}, false, fmt.Errorf("current key %q has no private key", key.ID)
+			}
+			hasCurrent = true
+		}
+
+		out.Keys = append(out.Keys, key)
+	}
+
+	// an empty current id without a matching key means the set is verify-only, a non-empty one is most likely a typo
+	if !hasCurrent && ks.CurrentID != "" {
+		return KeySet{}, false, fmt.Errorf("current key %q is not part of the key set", ks.CurrentID)
+	}
+
+	return out, asymmetric, nil
+}
+
+// current returns the key that signs new tokens
+func (ks KeySet) current() (Key, error) {
+	key, ok := ks.lookup(ks.CurrentID)
+	if !ok || (len(key.Secret) == 0 && key.PrivateKey == nil) {
+		return Key{}, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+func (ks KeySet) lookup(id string) (Key, bool) {
+	for _, key := range ks.Keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+func (ks KeySet) publicKeys(algorithm string) []PublicKey {
+	keys := []PublicKey{}
+	for _, key := range ks.Keys {
+		if key.PublicKey == nil {
+			continue
+		}
+		keys = append(keys, PublicKey{
+			ID:        key.ID,
+			Algorithm: algorithm,
+			Key:       base64.StdEncoding.EncodeToString(key.PublicKey),
+		})
+	}
+	return keys
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeySet(t *testing.T) {
+	keys, err := ParseKeySet("a:"+library.RandomString(32)+", b:"+library.RandomString(32), "b", false)
+	require.NoError(t, err)
+	require.Equal(t, "b", keys.CurrentID)
+	require.Len(t, keys.Keys, 2)
+	require.Equal(t, "a", keys.Keys[0].ID)
+	require.Len(t, keys.Keys[0].Secret, 32)
+
+	seed := make([]byte, ed25519.SeedSize)
+	_, err = rand.Read(seed)
+	require.NoError(t, err)
+
+	keys, err = ParseKeySet("a:"+base64.StdEncoding.EncodeToString(seed), "a", true)
+	require.NoError(t, err)
+	require.Equal(t, ed25519.NewKeyFromSeed(seed), keys.Keys[0].PrivateKey)
+
+	_, err = ParseKeySet("a:not-a-seed", "a", true)
+	require.Error(t, err)
+
+	_, err = ParseKeySet("missing-separator", "", false)
+	require.Error(t, err)
+
+	_, err = ParseKeySet("", "", false)
+	require.Error(t, err)
+}
+
+func TestInvalidKeySets(t *testing.T) {
+	_, private := randomEd25519Key(t)
+
+	testCases := []struct {
+		name string
+		keys KeySet
+	}{
+		{
+			name: "Empty",
+			keys: KeySet{},
+		},
+		{
+			name: "DuplicateID",
+			keys: KeySet{CurrentID: "a", Keys: []Key{{ID: "a", Secret: []byte(library.RandomString(32))}, {ID: "a", Secret: []byte(library.RandomString(32))}}},
+		},
+		{
+			name: "UnknownCurrentKey",
+			keys: KeySet{CurrentID: "b", Keys: []Key{{ID: "a", Secret: []byte(library.RandomString(32))}}},
+		},
+		{
+			name: "MixedKeyTypes",
+			keys: KeySet{CurrentID: "a", Keys: []Key{{ID: "a", Secret: []byte(library.RandomString(32))}, {ID: "b", PrivateKey: private}}},
+		},
+		{
+			name: "CurrentKeyWithoutPrivateKey",
+			keys: KeySet{CurrentID: "a", Keys: []Key{{ID: "a", PublicKey: private.Public().(ed25519.PublicKey)}}},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewPasetoMakerFromKeys(tc.keys)
+			require.Error(t, err)
+			_, err = NewJWTMakerFromKeys(tc.keys)
+			require.Error(t, err)
+		})
+	}
+}
+
+// runs the same rotation scenario against every maker type
+func TestKeyRotation(t *testing.T) {
+	oldPublic, oldPrivate := randomEd25519Key(t)
+	newPublic, newPrivate := randomEd25519Key(t)
+	oldSecret := []byte(library.RandomString(32))
+	newSecret := []byte(library.RandomString(32))
+
+	keySet := func(current string, keys ...Key) KeySet { return KeySet{CurrentID: current, Keys: keys} }
+
+	testCases := []struct {
+		name     string
+		newMaker func(keys KeySet) (TokenMaker, error)
+		before   KeySet // only the old key
+		rotated  KeySet // new key signs, old key still verifies
+		retired  KeySet // old key removed
+		verifier KeySet // public keys only
+	}{
+		{
+			name:     "PasetoLocal",
+			newMaker: NewPasetoMakerFromKeys,
+			before:   keySet("old", Key{ID: "old", Secret: oldSecret}),
+			rotated:  keySet("new", Key{ID: "old", Secret: oldSecret}, Key{ID: "new", Secret: newSecret}),
+			retired:  keySet("new", Key{ID: "new", Secret: newSecret}),
+		},
+		{
+			name:     "JWTHMAC",
+			newMaker: NewJWTMakerFromKeys,
+			before:   keySet("old", Key{ID: "old", Secret: oldSecret}),
+			rotated:  keySet("new", Key{ID: "old", Secret: oldSecret}, Key{ID: "new", Secret: newSecret}),
+			retired:  keySet("new", Key{ID: "new", Secret: newSecret}),
+		},
+		{
+			name:     "PasetoPublic",
+			newMaker: NewPasetoMakerFromKeys,
+			before:   keySet("old", Key{ID: "old", PrivateKey: oldPrivate}),
+			rotated:  keySet("new", Key{ID: "old", PrivateKey: oldPrivate}, Key{ID: "new", PrivateKey: newPrivate}),
+			retired:  keySet("new", Key{ID: "new", PrivateKey: newPrivate}),
+			verifier: keySet("", Key{ID: "old", PublicKey: oldPublic}, Key{ID: "new", PublicKey: newPublic}),
+		},
+		{
+			name:     "JWTEdDSA",
+			newMaker: NewJWTMakerFromKeys,
+			before:   keySet("old", Key{ID: "old", PrivateKey: oldPrivate}),
+			rotated:  keySet("new", Key{ID: "old", PrivateKey: oldPrivate}, Key{ID: "new", PrivateKey: newPrivate}),
+			retired:  keySet("new", Key{ID: "new", PrivateKey: newPrivate}),
+			verifier: keySet("", Key{ID: "old", PublicKey: oldPublic}, Key{ID: "new", PublicKey: newPublic}),
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			before, err := tc.newMaker(tc.before)
+			require.NoError(t, err)
+			rotated, err := tc.newMaker(tc.rotated)
+			require.NoError(t, err)
+			retired, err := tc.newMaker(tc.retired)
+			require.NoError(t, err)
+
+			oldToken, err := before.CreateToken("alice", time.Minute)
+			require.NoError(t, err)
+			newToken, err := rotated.CreateToken("bob", time.Minute)
+			require.NoError(t, err)
+
+			// tokens of the previous key keep working until the key is retired
+			payload, err := rotated.VerifyToken(oldToken)
+			require.NoError(t, err)
+			require.Equal(t, "alice", payload.Username)
+
+			_, err = retired.VerifyToken(oldToken)
+			require.Error(t, err)
+
+			payload, err = retired.VerifyToken(newToken)
+			require.NoError(t, err)
+			require.Equal(t, "bob", payload.Username)
+
+			// the old maker doesnt know the new key
+			_, err = before.VerifyToken(newToken)
+			require.Error(t, err)
+
+			if len(tc.verifier.Keys) == 0 {
+				require.Empty(t, rotated.PublicKeys())
+				return
+			}
+
+			// other services only need the public keys to verify tokens, but can never create one
+			verifier, err := tc.newMaker(tc.verifier)
+			require.NoError(t, err)
+
+			payload, err = verifier.VerifyToken(newToken)
+			require.NoError(t, err)
+			require.Equal(t, "bob", payload.Username)
+
+			_, err = verifier.CreateToken("mallory", time.Minute)
+			require.EqualError(t, err, ErrNoSigningKey.Error())
+
+			require.ElementsMatch(t, verifier.PublicKeys(), rotated.PublicKeys())
+			require.Len(t, rotated.PublicKeys(), 2)
+		})
+	}
+}
+
+func randomEd25519Key(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return public, private
+}
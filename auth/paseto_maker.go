@@ -8,41 +8,100 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
-// use paseto
+// use paseto. symmetric keys create encrypted v2.local tokens, asymmetric keys create signed v2.public tokens
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto     *paseto.V2
+	keys       KeySet
+	asymmetric bool
+}
+
+// the footer of a paseto token is authenticated but not encrypted, so it tells which key to decrypt/verify with
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
 }
 
 // creates a new paseto token-manager struct that implements the TokenMaker interface
 func NewPasetoMaker(symmetricKey string) (TokenMaker, error) {
-	if len(symmetricKey) != chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("invalid key size. requires key of length %d for the chachapoly algorithm", chacha20poly1305.KeySize)
+	return NewPasetoMakerFromKeys(KeySet{Keys: []Key{{Secret: []byte(symmetricKey)}}})
+}
+
+// creates a paseto token-manager that supports several keys, see KeySet
+func NewPasetoMakerFromKeys(keys KeySet) (TokenMaker, error) {
+	keys, asymmetric, err := keys.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if !asymmetric {
+		for _, key := range keys.Keys {
+			if len(key.Secret) != chacha20poly1305.KeySize {
+				return nil, fmt.Errorf("invalid key size. requires key of length %d for the chachapoly algorithm", chacha20poly1305.KeySize)
+			}
+		}
 	}
 
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+		paseto:     paseto.NewV2(),
+		keys:       keys,
+		asymmetric: asymmetric,
 	}
 
 	return maker, nil
 }
 
-// creates a new payload including the username, encrypts it and returns the token as a string
+// creates a new payload including the username, encrypts (or signs) it and returns the token as a string
 func (pm *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, duration)
+	return pm.CreateScopedToken(username, ScopeAccess, duration)
+}
+
+func (pm *PasetoMaker) CreateScopedToken(username string, scope string, duration time.Duration) (string, error) {
+	return pm.CreateDataToken(username, scope, nil, duration)
+}
+
+func (pm *PasetoMaker) CreateDataToken(username string, scope string, data interface{}, duration time.Duration) (string, error) {
+	key, err := pm.keys.current()
 	if err != nil {
 		return "", err
 	}
 
-	return pm.paseto.Encrypt(pm.symmetricKey, payload, nil)
+	payload, err := newScopedPayload(username, scope, data, duration)
+	if err != nil {
+		return "", err
+	}
+
+	// tokens of a key without id have no footer, exactly like the tokens issued before keys had ids
+	var footer interface{}
+	if key.ID != "" {
+		footer = pasetoFooter{KeyID: key.ID}
+	}
+
+	if pm.asymmetric {
+		return pm.paseto.Sign(key.PrivateKey, payload, footer)
+	}
+	return pm.paseto.Encrypt(key.Secret, payload, footer)
 }
 
 // verifies the token by trying to decrypt it. if successfull, returns the payload of the token, otherwise an error
 func (pm *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+	var footer pasetoFooter
+	if err := paseto.ParseFooter(token, &footer); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := pm.keys.lookup(footer.KeyID)
+	if !ok {
+		// unknown or already retired key
+		return nil, ErrInvalidToken
+	}
+
 	payload := &Payload{}
 
-	err := pm.paseto.Decrypt(token, pm.symmetricKey, payload, nil)
+	var err error
+	if pm.asymmetric {
+		err = pm.paseto.Verify(token, key.PublicKey, payload, nil)
+	} else {
+		err = pm.paseto.Decrypt(token, key.Secret, payload, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -55,3 +114,11 @@ func (pm *PasetoMaker) VerifyToken(token string) (*Payload, error) {
 
 	return payload, nil
 }
+
+// returns the keys other services need to verify v2.public tokens. symmetric keys are never shared
+func (pm *PasetoMaker) PublicKeys() []PublicKey {
+	if !pm.asymmetric {
+		return []PublicKey{}
+	}
+	return pm.keys.publicKeys("v2.public")
+}
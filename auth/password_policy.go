@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string // checked into the repository, so the blocklist doesnt depend on any file at runtime
+
+var commonPasswords = parseBlocklist(commonPasswordList)
+
+// bcrypt silently ignores everything after the 72nd byte
+const maxPasswordBytes = 72
+
+// PasswordPolicy describes the rules new passwords have to follow. the zero value only enforces the blocklist and the bcrypt limit
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// PasswordPolicyError lists every rule a password violates
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, ", ")
+}
+
+// Validate checks a new password against the policy. the username is needed to reject passwords that contain it
+func (p PasswordPolicy) Validate(password, username string) error {
+	perr := &PasswordPolicyError{}
+	fail := func(format string, args ...interface{}) {
+		perr.Violations = append(perr.Violations, fmt.Sprintf(format, args...))
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		fail("must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		fail("must not be longer than %d bytes", maxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		fail("must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		fail("must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		fail("must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		fail("must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if commonPasswords[lowered] {
+		fail("is too common")
+	}
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		fail("must not contain the username")
+	}
+
+	if len(perr.Violations) > 0 {
+		return perr
+	}
+	return nil
+}
+
+// IsPolicyError reports whether err was caused by a password that violates the policy, as opposed to an internal error
+func IsPolicyError(err error) bool {
+	var perr *PasswordPolicyError
+	return errors.As(err, &perr)
+}
+
+func parseBlocklist(list string) map[string]bool {
+	blocked := map[string]bool{}
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocked[strings.ToLower(line)] = true
+	}
+
+	return blocked
+}
@@ -28,3 +28,53 @@ func TestPassword(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEqual(t, hashedPw, hashedPw2)
 }
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	testCases := []struct {
+		name     string
+		password string
+		username string
+		policy   PasswordPolicy
+		valid    bool
+	}{
+		{name: "OK", password: "Correct-Horse-42", username: "alice", policy: policy, valid: true},
+		{name: "TooShort", password: "Sh0rt-pw", username: "alice", policy: policy},
+		{name: "NoUpper", password: "correct-horse-42", username: "alice", policy: policy},
+		{name: "NoLower", password: "CORRECT-HORSE-42", username: "alice", policy: policy},
+		{name: "NoDigit", password: "Correct-Horse-xx", username: "alice", policy: policy},
+		{name: "NoSymbol", password: "CorrectHorse42", username: "alice", policy: policy},
+		{name: "ContainsUsername", password: "Alice-Horse-42", username: "alice", policy: policy},
+		{name: "TooLongForBcrypt", password: "Aa1-" + library.RandomString(maxPasswordBytes), username: "alice", policy: policy},
+		{name: "CommonPassword", password: "password123", username: "alice", policy: PasswordPolicy{MinLength: 8}},
+		{name: "CommonPasswordIgnoresCase", password: "PassWord123", username: "alice", policy: PasswordPolicy{MinLength: 8}},
+		{name: "ZeroPolicy", password: library.RandomString(6), username: "alice", valid: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate(tc.password, tc.username)
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.True(t, IsPolicyError(err))
+		})
+	}
+}
+
+func TestSecretToken(t *testing.T) {
+	token, hash, err := NewSecretToken()
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.Equal(t, hash, HashSecretToken(token))
+	require.NotEqual(t, token, hash)
+
+	other, _, err := NewSecretToken()
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -12,11 +13,20 @@ var (
 	ErrInvalidToken = errors.New("token is invalid")
 )
 
+// scopes restrict what a token can be used for. access tokens have no scope
+const (
+	ScopeAccess        = ""
+	ScopeTwoFactor     = "2fa"            // only allows completing a login with a second factor
+	ScopeTransferQuote = "transfer-quote" // carries the terms of a quoted transfer, cant be used to authenticate
+)
+
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	IssuedAt  time.Time `json:"issuedAt"`
-	ExpiredAt time.Time `json:"expiredAt"`
+	ID        uuid.UUID       `json:"id"`
+	Username  string          `json:"username"`
+	IssuedAt  time.Time       `json:"issuedAt"`
+	ExpiredAt time.Time       `json:"expiredAt"`
+	Scope     string          `json:"scope,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"` // scope specific claims, e.g. the terms of a transfer quote
 }
 
 func NewPayload(username string, duration time.Duration) (*Payload, error) {
@@ -35,6 +45,24 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	return payload, nil
 }
 
+// newScopedPayload creates the payload of a scoped token, data is stored as json if it isnt nil
+func newScopedPayload(username string, scope string, data interface{}, duration time.Duration) (*Payload, error) {
+	payload, err := NewPayload(username, duration)
+	if err != nil {
+		return nil, err
+	}
+	payload.Scope = scope
+
+	if data != nil {
+		payload.Data, err = json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
 // checks whether payload is valid aka., whether token isn't expired
 func (pl *Payload) Valid() error {
 	if time.Now().After(pl.ExpiredAt) {
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedToken(t *testing.T) {
+	paseto, err := NewPasetoMaker(library.RandomString(32))
+	require.NoError(t, err)
+	jwt, err := NewJWTMaker(library.RandomString(32))
+	require.NoError(t, err)
+
+	for _, maker := range []TokenMaker{paseto, jwt} {
+		token, err := maker.CreateScopedToken("user", ScopeTwoFactor, time.Minute)
+		require.NoError(t, err)
+
+		payload, err := maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, ScopeTwoFactor, payload.Scope)
+
+		// plain access tokens have no scope
+		token, err = maker.CreateToken("user", time.Minute)
+		require.NoError(t, err)
+
+		payload, err = maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, ScopeAccess, payload.Scope)
+	}
+}
+
+func TestDataToken(t *testing.T) {
+	paseto, err := NewPasetoMaker(library.RandomString(32))
+	require.NoError(t, err)
+	jwt, err := NewJWTMaker(library.RandomString(32))
+	require.NoError(t, err)
+
+	type terms struct {
+		Amount int64 `json:"amount"`
+	}
+
+	for _, maker := range []TokenMaker{paseto, jwt} {
+		token, err := maker.CreateDataToken("user", ScopeTransferQuote, terms{Amount: 42}, time.Minute)
+		require.NoError(t, err)
+
+		payload, err := maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, ScopeTransferQuote, payload.Scope)
+
+		var got terms
+		require.NoError(t, json.Unmarshal(payload.Data, &got))
+		require.Equal(t, int64(42), got.Amount)
+
+		// tokens without data dont carry the claim at all
+		token, err = maker.CreateScopedToken("user", ScopeTwoFactor, time.Minute)
+		require.NoError(t, err)
+
+		payload, err = maker.VerifyToken(token)
+		require.NoError(t, err)
+		require.Empty(t, payload.Data)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes created when enabling two-factor authentication
+const RecoveryCodeCount = 10
+
+// 10 base32 characters carry 50 bits, enough since every code can only be used once and is hashed with bcrypt
+const recoveryCodeBytes = 10 * 5 / 8
+
+const recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+var recoveryCodeEncoding = base32.NewEncoding(recoveryCodeAlphabet).WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes creates n random codes like "abcde-fghij". store them with HashPassword
+// and compare them with CheckPassword after normalizing the input with NormalizeRecoveryCode
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := recoveryCodeEncoding.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode brings user input into the form the codes were hashed in
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// IsRecoveryCode reports whether the input looks like a recovery code, only those are compared against the
+// stored hashes
+func IsRecoveryCode(code string) bool {
+	code = NormalizeRecoveryCode(code)
+	if len(code) != 11 || code[5] != '-' {
+		return false
+	}
+
+	for _, c := range code[:5] + code[6:] {
+		if !strings.ContainsRune(recoveryCodeAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
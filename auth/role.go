@@ -0,0 +1,7 @@
+package auth
+
+// roles a user can have. every user that signs up is a depositor, admins have to be promoted manually
+const (
+	RoleDepositor = "depositor"
+	RoleAdmin     = "admin"
+)
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// length of the random part of secret tokens, 256 bits cannot be guessed
+const secretTokenBytes = 32
+
+// NewSecretToken creates a random url-safe token for single-use links like password resets.
+// only the hash should be stored, so a leaked database doesnt leak usable tokens
+func NewSecretToken() (token string, hash string, err error) {
+	b := make([]byte, secretTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashSecretToken(token), nil
+}
+
+// HashSecretToken returns the value that is stored for a token created by NewSecretToken.
+// the token has enough entropy, so unlike passwords it doesnt need a slow salted hash
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
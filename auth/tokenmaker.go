@@ -5,6 +5,12 @@ import "time"
 type TokenMaker interface {
 	// create a token
 	CreateToken(username string, duration time.Duration) (string, error)
+	// create a token that can only be used for the given scope, see ScopeTwoFactor
+	CreateScopedToken(username string, scope string, duration time.Duration) (string, error)
+	// create a scoped token that carries additional data, it ends up json encoded in Payload.Data
+	CreateDataToken(username string, scope string, data interface{}, duration time.Duration) (string, error)
 	// check if input token is valid and return its payload if so
 	VerifyToken(token string) (*Payload, error)
+	// keys other services can use to verify our tokens, empty for symmetric keys
+	PublicKeys() []PublicKey
 }
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the parameters every common authenticator app expects, see RFC 6238
+const (
+	TOTPPeriod  = 30 * time.Second
+	TOTPDigits  = 6
+	totpSkew    = 1  // accepted steps before and after the current one, to tolerate clock drift and slow typing
+	totpKeySize = 20 // 160 bits, the size recommended for HMAC-SHA1 in RFC 4226
+)
+
+// after this many failed second factor attempts in a row, users can only try one code per SecondFactorLockout
+// until a code is accepted
+const (
+	MaxSecondFactorFailures = 5
+	SecondFactorLockout     = 15 * time.Minute
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret creates a random base32 encoded secret, the format authenticator apps expect
+func NewTOTPSecret() (string, error) {
+	key := make([]byte, totpKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(key), nil
+}
+
+// TOTPStep returns the number of the time step t falls into
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the code for the time step t falls into
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(TOTPStep(t)), TOTPDigits), nil
+}
+
+// ValidateTOTP checks a code against the steps around t and returns the step it belongs to.
+// callers have to remember the step and reject codes of the same or earlier steps, otherwise codes can be replayed
+func ValidateTOTP(secret, code string, t time.Time) (step int64, ok bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for s := current - totpSkew; s <= current+totpSkew; s++ {
+		expected := hotp(key, uint64(s), TOTPDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// uri authenticator apps import, usually shown as a qr code
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// hotp implements RFC 4226: an HMAC-SHA1 over the counter, dynamically truncated to the given number of digits
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// apps show secrets in groups and some users type them in lowercase
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return base32NoPadding.DecodeString(strings.TrimRight(secret, "="))
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the sha1 test vectors of RFC 6238 appendix B, truncated to our 6 digits
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := base32NoPadding.EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range testCases {
+		code, err := TOTPCode(secret, time.Unix(tc.unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, tc.code, code, "unix time %d", tc.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTP(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now), step)
+
+	// the previous code is still accepted to tolerate clock drift, older ones arent
+	prev, err := TOTPCode(secret, now.Add(-TOTPPeriod))
+	require.NoError(t, err)
+	step, ok = ValidateTOTP(secret, prev, now)
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now)-1, step)
+
+	old, err := TOTPCode(secret, now.Add(-3*TOTPPeriod))
+	require.NoError(t, err)
+	_, ok = ValidateTOTP(secret, old, now)
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	require.False(t, ok)
+
+	// apps show the secret grouped and in lowercase
+	_, ok = ValidateTOTP(strings.ToLower(secret[:4]+" "+secret[4:]), code, now)
+	require.True(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("go bank", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	require.Equal(t, "otpauth://totp/go%20bank:alice@example.com?algorithm=SHA1&digits=6&issuer=go+bank&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		require.Len(t, code, 11)
+		require.Equal(t, code, NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))))
+		require.True(t, IsRecoveryCode(strings.ToUpper(code)))
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestIsRecoveryCode(t *testing.T) {
+	require.True(t, IsRecoveryCode("abcde-fghij"))
+	require.True(t, IsRecoveryCode(" ABCDE FGHIJ "))
+	require.True(t, IsRecoveryCode("abcdefghij"))
+
+	for _, code := range []string{"123456", "abcde-fghi", "abcde-fghij1", "abcd0-fghij", ""} {
+		require.False(t, IsRecoveryCode(code), code)
+	}
+}
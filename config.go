@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/maxeth/go-bank-app/config"
+)
+
+const configUsage = "usage: config print [--redacted]"
+
+// runConfig executes the config subcommand. it prints the effective configuration after all layers have been applied,
+// followed by validation errors if there are any
+func runConfig(path string, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	redacted := false
+	for _, arg := range args[1:] {
+		if arg != "--redacted" {
+			return errors.New(configUsage)
+		}
+		redacted = true
+	}
+
+	conf, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	values := conf.Values()
+	if redacted {
+		values = conf.Redacted()
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s=%s\n", key, values[key])
+	}
+
+	if err := conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	return nil
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
-	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -14,31 +18,539 @@ const (
 	Driver = "postgres"
 )
 
+// environment profiles. prod enforces stricter rules in Validate
+const (
+	EnvDevelopment = "dev"
+	EnvTest        = "test"
+	EnvProduction  = "prod"
+)
+
+// token makers that can be selected through TOKEN_MAKER
+const (
+	TokenMakerPaseto = "paseto"
+	TokenMakerJWT    = "jwt"
+)
+
+// notifiers that can be selected through NOTIFIER
+const (
+	NotifierLog  = "log"
+	NotifierFile = "file"
+	NotifierSMTP = "smtp"
+)
+
+// publishers the outbox relay can use, selected through OUTBOX_PUBLISHER
+const (
+	OutboxPublisherStdout = "stdout"
+	OutboxPublisherFile   = "file"
+)
+
+// the symmetric key from the app.env file that is checked into the repository. it must never be used in production
+const developmentTokenKey = "zka4pozka4poC4EJVLNxwMC4EJVLNxwM"
+
+// suffix of environment variables that point to a file containing the actual value, e.g. TOKEN_SYMMETRIC_KEY_FILE=/run/secrets/token_key
+const secretFileSuffix = "_FILE"
+
+// fields tagged with redact:"true" are being masked by Redacted
 type Config struct {
-	Environment         string
-	DBString            string        `mapstructure:"DB_STRING"`
+	Environment         string        `mapstructure:"ENVIRONMENT"`
+	DBString            string        `mapstructure:"DB_STRING" redact:"true"`
 	DBDriver            string        `mapstructure:"DB_DRIVER"`
 	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSummetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	GRPCServerAddress   string        `mapstructure:"GRPC_SERVER_ADDRESS"` // the grpc api, see package gapi
+	TokenSummetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY" redact:"true"`
 	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	TokenMaker          string        `mapstructure:"TOKEN_MAKER"`              // paseto or jwt
+	TokenAsymmetric     bool          `mapstructure:"TOKEN_ASYMMETRIC"`         // sign tokens with ed25519 keys instead of a shared secret
+	TokenKeys           string        `mapstructure:"TOKEN_KEYS" redact:"true"` // "id:key,id:key" list replacing TOKEN_SYMMETRIC_KEY, see auth.ParseKeySet
+	TokenCurrentKeyID   string        `mapstructure:"TOKEN_CURRENT_KEY_ID"`     // the key of TOKEN_KEYS that signs new tokens
+	MigrateOnStart      bool          `mapstructure:"MIGRATE_ON_START"`         // apply pending db migrations before starting the server
+
+	// connection pool settings, see database/sql.DB
+	DBMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+	DBConnectAttempts int           `mapstructure:"DB_CONNECT_ATTEMPTS"` // how often the startup ping is tried before giving up
+	DBConnectBackoff  time.Duration `mapstructure:"DB_CONNECT_BACKOFF"`  // wait time after the first failed ping, doubled after every further attempt
+
+	// rules for new passwords, see auth.PasswordPolicy
+	PasswordMinLength     int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+
+	PasswordResetTokenDuration time.Duration `mapstructure:"PASSWORD_RESET_TOKEN_DURATION"` // how long a reset token can be redeemed
+	Notifier                   string        `mapstructure:"NOTIFIER"`                      // log, file or smtp
+	NotifierFile               string        `mapstructure:"NOTIFIER_FILE"`                 // path the file notifier appends messages to
+
+	// mail server of the smtp notifier, see notify.SMTPConfig
+	SMTPAddress  string `mapstructure:"SMTP_ADDRESS"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD" redact:"true"`
+	SMTPFrom     string `mapstructure:"SMTP_FROM"`
+
+	RequireVerifiedEmail           bool          `mapstructure:"REQUIRE_VERIFIED_EMAIL"`            // users have to verify their email before creating accounts or transfers
+	EmailVerificationTokenDuration time.Duration `mapstructure:"EMAIL_VERIFICATION_TOKEN_DURATION"` // how long a verification token can be redeemed
+
+	TOTPIssuer             string        `mapstructure:"TOTP_ISSUER"`               // name authenticator apps show next to the code
+	TOTPStepUpAmount       int64         `mapstructure:"TOTP_STEP_UP_AMOUNT"`       // transfers above this amount need a fresh totp code, 0 disables the step-up
+	TwoFactorTokenDuration time.Duration `mapstructure:"TWO_FACTOR_TOKEN_DURATION"` // how long the second login step can be completed after the password was checked
+
+	AccountInvitationDuration time.Duration `mapstructure:"ACCOUNT_INVITATION_DURATION"` // how long an invitation to a joint account can be accepted
+
+	TransferQuoteDuration time.Duration `mapstructure:"TRANSFER_QUOTE_DURATION"` // how long a transfer quote can be executed
+
+	// delivery of webhooks, see webhook.Config
+	WebhookMaxAttempts  int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`  // deliveries are dead-lettered after this many failed attempts
+	WebhookRetryBackoff time.Duration `mapstructure:"WEBHOOK_RETRY_BACKOFF"` // wait time after the first failed attempt, doubled after every further attempt
+	WebhookTimeout      time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`       // how long a receiver can take to answer
+	WebhookPollInterval time.Duration `mapstructure:"WEBHOOK_POLL_INTERVAL"` // how often the outbox is checked for due deliveries
+
+	OutboxPublisher    string        `mapstructure:"OUTBOX_PUBLISHER"`     // stdout or file
+	OutboxFile         string        `mapstructure:"OUTBOX_FILE"`          // path the file publisher appends events to
+	OutboxPollInterval time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL"` // how often the relay checks for new events
+
+	APIDeprecations          string `mapstructure:"API_DEPRECATIONS"`           // "METHOD /v1/path deprecated-date [sunset-date],..." list of routes answering with deprecation headers
+	APIUnversionedDeprecated string `mapstructure:"API_UNVERSIONED_DEPRECATED"` // date the routes without /v1 prefix were replaced by /v1
+	APIUnversionedSunset     string `mapstructure:"API_UNVERSIONED_SUNSET"`     // date after which the routes without /v1 prefix may be removed
+
+	// cross-origin requests from browser apps, disabled without allowed origins
+	CORSAllowedOrigins   string        `mapstructure:"CORS_ALLOWED_ORIGINS"`   // comma separated origins like https://app.example.com, or * for any
+	CORSAllowedMethods   string        `mapstructure:"CORS_ALLOWED_METHODS"`   // comma separated
+	CORSAllowedHeaders   string        `mapstructure:"CORS_ALLOWED_HEADERS"`   // comma separated request headers browsers may send
+	CORSAllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"` // let browsers send cookies and authorization headers along
+	CORSMaxAge           time.Duration `mapstructure:"CORS_MAX_AGE"`           // how long browsers may cache a preflight response
+
+	HSTSMaxAge  time.Duration `mapstructure:"HSTS_MAX_AGE"`  // browsers only use https for this long after a response, 0 disables the header
+	MaxBodySize int64         `mapstructure:"MAX_BODY_SIZE"` // largest request body in bytes
+
+	// https for the http api, plain http without a certificate. the files are reloaded when they change
+	TLSCertFile           string `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile            string `mapstructure:"TLS_KEY_FILE"`
+	TLSClientCAFile       string `mapstructure:"TLS_CLIENT_CA_FILE"`       // verifies client certificates of internal services
+	TLSClientAuthRequired bool   `mapstructure:"TLS_CLIENT_AUTH_REQUIRED"` // reject connections without a client certificate
+	TLSClientIdentities   string `mapstructure:"TLS_CLIENT_IDENTITIES"`    // "common-name=service,..." list of known client certificates
 }
 
-func New(path string) (config Config, err error) {
+// defaults are the lowest configuration layer. keys without a sensible default still need an entry,
+// otherwise viper wouldnt pick them up from the environment
+var defaults = map[string]interface{}{
+	"ENVIRONMENT":           EnvDevelopment,
+	"DB_STRING":             "",
+	"DB_DRIVER":             Driver,
+	"SERVER_ADDRESS":        "0.0.0.0:8080",
+	"GRPC_SERVER_ADDRESS":   "0.0.0.0:9090",
+	"TOKEN_SYMMETRIC_KEY":   "",
+	"ACCESS_TOKEN_DURATION": 15 * time.Minute,
+	"TOKEN_MAKER":           TokenMakerPaseto,
+	"TOKEN_ASYMMETRIC":      false,
+	"TOKEN_KEYS":            "",
+	"TOKEN_CURRENT_KEY_ID":  "",
+	"MIGRATE_ON_START":      false,
+	"DB_MAX_OPEN_CONNS":     25,
+	"DB_MAX_IDLE_CONNS":     25,
+	"DB_CONN_MAX_LIFETIME":  30 * time.Minute,
+	"DB_CONN_MAX_IDLE_TIME": 5 * time.Minute,
+	"DB_CONNECT_ATTEMPTS":   5,
+	"DB_CONNECT_BACKOFF":    500 * time.Millisecond,
+
+	"PASSWORD_MIN_LENGTH":           8,
+	"PASSWORD_REQUIRE_UPPER":        false,
+	"PASSWORD_REQUIRE_LOWER":        false,
+	"PASSWORD_REQUIRE_DIGIT":        false,
+	"PASSWORD_REQUIRE_SYMBOL":       false,
+	"PASSWORD_RESET_TOKEN_DURATION": 30 * time.Minute,
+	"NOTIFIER":                      NotifierLog,
+	"NOTIFIER_FILE":                 "",
+
+	"SMTP_ADDRESS":                      "",
+	"SMTP_USERNAME":                     "",
+	"SMTP_PASSWORD":                     "",
+	"SMTP_FROM":                         "",
+	"REQUIRE_VERIFIED_EMAIL":            false,
+	"EMAIL_VERIFICATION_TOKEN_DURATION": 24 * time.Hour,
+
+	"TOTP_ISSUER":               "go-bank-app",
+	"TOTP_STEP_UP_AMOUNT":       0,
+	"TWO_FACTOR_TOKEN_DURATION": 5 * time.Minute,
+
+	"ACCOUNT_INVITATION_DURATION": 7 * 24 * time.Hour,
+
+	"TRANSFER_QUOTE_DURATION": 2 * time.Minute,
+
+	"WEBHOOK_MAX_ATTEMPTS":  8,
+	"WEBHOOK_RETRY_BACKOFF": 30 * time.Second,
+	"WEBHOOK_TIMEOUT":       10 * time.Second,
+	"WEBHOOK_POLL_INTERVAL": 5 * time.Second,
 
-	dir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	fmt.Println(dir)
+	"OUTBOX_PUBLISHER":     OutboxPublisherStdout,
+	"OUTBOX_FILE":          "",
+	"OUTBOX_POLL_INTERVAL": time.Second,
 
-	viper.AddConfigPath(path) // this refers to the path of the file/directory that calls this function, not the path of this config.go function
-	viper.SetConfigName("app")
-	viper.SetConfigType("env")
+	"API_DEPRECATIONS":           "",
+	"API_UNVERSIONED_DEPRECATED": "2026-10-19",
+	"API_UNVERSIONED_SUNSET":     "",
 
-	viper.AutomaticEnv()
-	err = viper.ReadInConfig()
+	"CORS_ALLOWED_ORIGINS":   "",
+	"CORS_ALLOWED_METHODS":   "GET,POST,PUT,PATCH,DELETE",
+	"CORS_ALLOWED_HEADERS":   "Authorization,Content-Type,X-Request-ID,Last-Event-ID",
+	"CORS_ALLOW_CREDENTIALS": false,
+	"CORS_MAX_AGE":           10 * time.Minute,
+	"HSTS_MAX_AGE":           365 * 24 * time.Hour,
+	"MAX_BODY_SIZE":          1 << 20,
+
+	"TLS_CERT_FILE":            "",
+	"TLS_KEY_FILE":             "",
+	"TLS_CLIENT_CA_FILE":       "",
+	"TLS_CLIENT_AUTH_REQUIRED": false,
+	"TLS_CLIENT_IDENTITIES":    "",
+}
+
+// New loads the configuration and validates it
+func New(path string) (Config, error) {
+	config, err := Load(path)
 	if err != nil {
 		return Config{}, err
 	}
 
-	err = viper.Unmarshal(&config)
+	if err = config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// Load reads the configuration without validating it. later layers override earlier ones:
+// defaults, the app.env file inside path (optional), environment variables, files referenced by *_FILE variables
+func Load(path string) (config Config, err error) {
+	v := viper.New()
+
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	v.AddConfigPath(path) // this refers to the path of the file/directory that calls this function, not the path of this config.go function
+	v.SetConfigName("app")
+	v.SetConfigType("env")
+
+	err = v.ReadInConfig()
+	if err != nil {
+		// the file is optional, e.g. containers are configured through the environment only
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return Config{}, err
+		}
+	}
+
+	v.AutomaticEnv()
+
+	for _, key := range Keys() {
+		secretPath := os.Getenv(key + secretFileSuffix)
+		if secretPath == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(secretPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot read %s%s: %w", key, secretFileSuffix, err)
+		}
+		// editors and secret managers usually add a trailing newline
+		v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+
+	err = v.Unmarshal(&config)
 
 	return
 }
+
+// Keys returns the names of all configuration keys in alphabetical order
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("mapstructure"))
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+type FieldError struct {
+	Key     string
+	Message string
+}
+
+// ValidationError contains every invalid field, so all of them can be fixed at once
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = fmt.Sprintf("%s: %s", f.Key, f.Message)
+	}
+
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks every field and returns a *ValidationError listing all problems
+func (config Config) Validate() error {
+	verr := &ValidationError{}
+	fail := func(key, format string, args ...interface{}) {
+		verr.Fields = append(verr.Fields, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch config.Environment {
+	case EnvDevelopment, EnvTest, EnvProduction:
+	default:
+		fail("ENVIRONMENT", "must be one of %s, %s, %s", EnvDevelopment, EnvTest, EnvProduction)
+	}
+
+	if config.DBDriver == "" {
+		fail("DB_DRIVER", "is required")
+	}
+	if config.DBString == "" {
+		fail("DB_STRING", "is required")
+	}
+	if _, _, err := net.SplitHostPort(config.ServerAddress); err != nil {
+		fail("SERVER_ADDRESS", "must be host:port")
+	}
+	if _, _, err := net.SplitHostPort(config.GRPCServerAddress); err != nil {
+		fail("GRPC_SERVER_ADDRESS", "must be host:port")
+	} else if config.GRPCServerAddress == config.ServerAddress {
+		fail("GRPC_SERVER_ADDRESS", "must not be the same as SERVER_ADDRESS")
+	}
+	if config.TokenMaker != TokenMakerPaseto && config.TokenMaker != TokenMakerJWT {
+		fail("TOKEN_MAKER", "must be %s or %s", TokenMakerPaseto, TokenMakerJWT)
+	}
+	if config.TokenKeys == "" {
+		// a single symmetric key without id. paseto needs exactly 32 bytes, HS256 at least 32
+		if config.TokenAsymmetric {
+			fail("TOKEN_KEYS", "is required for asymmetric tokens")
+		} else if config.TokenMaker == TokenMakerJWT {
+			if len(config.TokenSummetricKey) < 32 {
+				fail("TOKEN_SYMMETRIC_KEY", "must be at least 32 characters")
+			}
+		} else if len(config.TokenSummetricKey) != 32 {
+			fail("TOKEN_SYMMETRIC_KEY", "must be exactly 32 characters")
+		}
+	} else if !hasKeyID(config.TokenKeys, config.TokenCurrentKeyID) {
+		fail("TOKEN_CURRENT_KEY_ID", "must be the id of one of the TOKEN_KEYS")
+	}
+	if config.AccessTokenDuration <= 0 {
+		fail("ACCESS_TOKEN_DURATION", "must be positive")
+	}
+
+	if config.DBMaxOpenConns < 0 {
+		fail("DB_MAX_OPEN_CONNS", "must not be negative")
+	}
+	if config.DBMaxIdleConns < 0 {
+		fail("DB_MAX_IDLE_CONNS", "must not be negative")
+	}
+	if config.DBMaxOpenConns > 0 && config.DBMaxIdleConns > config.DBMaxOpenConns {
+		fail("DB_MAX_IDLE_CONNS", "must not exceed DB_MAX_OPEN_CONNS")
+	}
+	if config.DBConnMaxLifetime < 0 {
+		fail("DB_CONN_MAX_LIFETIME", "must not be negative")
+	}
+	if config.DBConnMaxIdleTime < 0 {
+		fail("DB_CONN_MAX_IDLE_TIME", "must not be negative")
+	}
+	if config.DBConnectAttempts < 1 {
+		fail("DB_CONNECT_ATTEMPTS", "must be at least 1")
+	}
+	if config.DBConnectBackoff < 0 {
+		fail("DB_CONNECT_BACKOFF", "must not be negative")
+	}
+
+	// bcrypt ignores everything after 72 bytes, a longer minimum could never be met safely
+	if config.PasswordMinLength < 6 || config.PasswordMinLength > 72 {
+		fail("PASSWORD_MIN_LENGTH", "must be between 6 and 72")
+	}
+	if config.PasswordResetTokenDuration <= 0 {
+		fail("PASSWORD_RESET_TOKEN_DURATION", "must be positive")
+	}
+	switch config.Notifier {
+	case NotifierLog:
+	case NotifierFile:
+		if config.NotifierFile == "" {
+			fail("NOTIFIER_FILE", "is required for the %s notifier", NotifierFile)
+		}
+	case NotifierSMTP:
+		if _, _, err := net.SplitHostPort(config.SMTPAddress); err != nil {
+			fail("SMTP_ADDRESS", "must be host:port for the %s notifier", NotifierSMTP)
+		}
+		if config.SMTPFrom == "" {
+			fail("SMTP_FROM", "is required for the %s notifier", NotifierSMTP)
+		}
+	default:
+		fail("NOTIFIER", "must be one of %s, %s, %s", NotifierLog, NotifierFile, NotifierSMTP)
+	}
+	if config.EmailVerificationTokenDuration <= 0 {
+		fail("EMAIL_VERIFICATION_TOKEN_DURATION", "must be positive")
+	}
+	if config.TOTPIssuer == "" || strings.Contains(config.TOTPIssuer, ":") {
+		fail("TOTP_ISSUER", "is required and must not contain a colon")
+	}
+	if config.TOTPStepUpAmount < 0 {
+		fail("TOTP_STEP_UP_AMOUNT", "must not be negative")
+	}
+	if config.TwoFactorTokenDuration <= 0 {
+		fail("TWO_FACTOR_TOKEN_DURATION", "must be positive")
+	}
+	if config.AccountInvitationDuration <= 0 {
+		fail("ACCOUNT_INVITATION_DURATION", "must be positive")
+	}
+	if config.TransferQuoteDuration <= 0 {
+		fail("TRANSFER_QUOTE_DURATION", "must be positive")
+	}
+	if config.WebhookMaxAttempts < 1 {
+		fail("WEBHOOK_MAX_ATTEMPTS", "must be at least 1")
+	}
+	if config.WebhookRetryBackoff <= 0 {
+		fail("WEBHOOK_RETRY_BACKOFF", "must be positive")
+	}
+	if config.WebhookTimeout <= 0 {
+		fail("WEBHOOK_TIMEOUT", "must be positive")
+	}
+	if config.WebhookPollInterval <= 0 {
+		fail("WEBHOOK_POLL_INTERVAL", "must be positive")
+	}
+	switch config.OutboxPublisher {
+	case OutboxPublisherStdout:
+	case OutboxPublisherFile:
+		if config.OutboxFile == "" {
+			fail("OUTBOX_FILE", "is required for the %s publisher", OutboxPublisherFile)
+		}
+	default:
+		fail("OUTBOX_PUBLISHER", "must be one of %s, %s", OutboxPublisherStdout, OutboxPublisherFile)
+	}
+	if config.OutboxPollInterval <= 0 {
+		fail("OUTBOX_POLL_INTERVAL", "must be positive")
+	}
+	// the entries of API_DEPRECATIONS are checked against the routes when the server starts
+	unversionedDeprecated, err := time.Parse("2006-01-02", config.APIUnversionedDeprecated)
+	if err != nil {
+		fail("API_UNVERSIONED_DEPRECATED", "must be a date like 2006-01-02")
+	}
+	if config.APIUnversionedSunset != "" {
+		sunset, err := time.Parse("2006-01-02", config.APIUnversionedSunset)
+		if err != nil {
+			fail("API_UNVERSIONED_SUNSET", "must be a date like 2006-01-02")
+		} else if !unversionedDeprecated.IsZero() && !sunset.After(unversionedDeprecated) {
+			fail("API_UNVERSIONED_SUNSET", "must be after API_UNVERSIONED_DEPRECATED")
+		}
+	}
+	for _, origin := range strings.Split(config.CORSAllowedOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			// browsers refuse credentials for a wildcard origin
+			if config.CORSAllowCredentials {
+				fail("CORS_ALLOWED_ORIGINS", "must list the origins instead of * when CORS_ALLOW_CREDENTIALS is set")
+			}
+			continue
+		}
+		// an origin is scheme, host and port, browsers never send a path
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			fail("CORS_ALLOWED_ORIGINS", "%q is not an origin like https://app.example.com", origin)
+		}
+	}
+	if config.CORSMaxAge < 0 {
+		fail("CORS_MAX_AGE", "must not be negative")
+	}
+	if config.HSTSMaxAge < 0 {
+		fail("HSTS_MAX_AGE", "must not be negative")
+	}
+	if config.MaxBodySize <= 0 {
+		fail("MAX_BODY_SIZE", "must be positive")
+	}
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		fail("TLS_KEY_FILE", "must be set together with TLS_CERT_FILE")
+	}
+	if config.TLSClientCAFile != "" && config.TLSCertFile == "" {
+		fail("TLS_CLIENT_CA_FILE", "requires TLS_CERT_FILE")
+	}
+	if config.TLSClientCAFile == "" {
+		if config.TLSClientAuthRequired {
+			fail("TLS_CLIENT_AUTH_REQUIRED", "requires TLS_CLIENT_CA_FILE")
+		}
+		if config.TLSClientIdentities != "" {
+			fail("TLS_CLIENT_IDENTITIES", "requires TLS_CLIENT_CA_FILE")
+		}
+	}
+
+	if config.Environment == EnvProduction {
+		if config.TokenKeys == "" && config.TokenSummetricKey == developmentTokenKey {
+			fail("TOKEN_SYMMETRIC_KEY", "must not be the development key in %s", EnvProduction)
+		}
+		if strings.Contains(config.DBString, "sslmode=disable") {
+			fail("DB_STRING", "must not disable ssl in %s", EnvProduction)
+		}
+		// the other notifiers write reset and verification tokens to places other people can read
+		if config.Notifier != NotifierSMTP {
+			fail("NOTIFIER", "must be %s in %s", NotifierSMTP, EnvProduction)
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// reports whether the "id:key,id:key" list contains the given id
+func hasKeyID(keys string, id string) bool {
+	for _, entry := range strings.Split(keys, ",") {
+		if strings.SplitN(strings.TrimSpace(entry), ":", 2)[0] == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns all configuration values by key, with secrets masked. connection strings keep everything but the password
+func (config Config) Redacted() map[string]string {
+	return config.values(true)
+}
+
+// Values returns all configuration values by key, including secrets
+func (config Config) Values() map[string]string {
+	return config.values(false)
+}
+
+func (config Config) values(redact bool) map[string]string {
+	values := map[string]string{}
+
+	v := reflect.ValueOf(config)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := fmt.Sprint(v.Field(i).Interface())
+
+		if redact && field.Tag.Get("redact") == "true" && value != "" {
+			value = redactValue(value)
+		}
+
+		values[field.Tag.Get("mapstructure")] = value
+	}
+
+	return values
+}
+
+func redactValue(value string) string {
+	u, err := url.Parse(value)
+	if err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	return "xxxxx"
+}
+
+// IsProduction reports whether the production profile is active
+func (config Config) IsProduction() bool {
+	return config.Environment == EnvProduction
+}
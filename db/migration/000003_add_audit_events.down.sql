@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS "audit_events_no_truncate" ON "audit_events";
+
+DROP TRIGGER IF EXISTS "audit_events_append_only" ON "audit_events";
+
+DROP FUNCTION IF EXISTS audit_events_append_only();
+
+DROP TABLE IF EXISTS "audit_events";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,13 @@
+CREATE TABLE "password_reset_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "password_reset_tokens" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "password_reset_tokens" ("username");
+
+COMMENT ON COLUMN "password_reset_tokens"."token_hash" IS 'sha256 of the token, the token itself is only ever sent to the user';
@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+
+CREATE TABLE "email_verification_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "email_verification_tokens" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "email_verification_tokens" ("username");
+
+COMMENT ON COLUMN "users"."email_verified_at" IS 'null until the user proved to own the email';
+
+COMMENT ON COLUMN "email_verification_tokens"."email" IS 'the address the token was sent to, the token is worthless once the user changed it';
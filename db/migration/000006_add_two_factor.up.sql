@@ -0,0 +1,25 @@
+CREATE TABLE "user_totp" (
+  "username" varchar PRIMARY KEY,
+  "secret" varchar NOT NULL,
+  "confirmed_at" timestamptz,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "user_totp" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "recovery_codes" ("username");
+
+COMMENT ON COLUMN "user_totp"."confirmed_at" IS 'null while the enrollment hasnt been confirmed with a valid code';
+
+COMMENT ON COLUMN "user_totp"."last_used_step" IS 'time step of the last accepted code, codes of this or earlier steps cannot be replayed';
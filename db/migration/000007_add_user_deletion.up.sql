@@ -0,0 +1,7 @@
+ALTER TABLE "accounts" ADD COLUMN "closed_at" timestamptz;
+
+ALTER TABLE "users" ADD COLUMN "deleted_at" timestamptz;
+
+COMMENT ON COLUMN "accounts"."closed_at" IS 'closed accounts keep their entries and transfers but cannot move money anymore';
+
+COMMENT ON COLUMN "users"."deleted_at" IS 'deleted users keep their username for the ledger, everything else is anonymized';
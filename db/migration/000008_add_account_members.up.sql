@@ -0,0 +1,47 @@
+CREATE TABLE "account_members" (
+  "account_id" bigint NOT NULL,
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "username")
+);
+
+CREATE TABLE "account_invitations" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "inviter" varchar NOT NULL,
+  "invitee" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "accepted_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "account_members" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "account_members" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "account_members" ADD CONSTRAINT "account_members_role_check" CHECK ("role" IN ('owner', 'co-owner', 'viewer'));
+
+ALTER TABLE "account_invitations" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "account_invitations" ADD FOREIGN KEY ("inviter") REFERENCES "users" ("username");
+
+ALTER TABLE "account_invitations" ADD FOREIGN KEY ("invitee") REFERENCES "users" ("username");
+
+ALTER TABLE "account_invitations" ADD CONSTRAINT "account_invitations_role_check" CHECK ("role" IN ('co-owner', 'viewer'));
+
+CREATE INDEX ON "account_members" ("username");
+
+CREATE INDEX ON "account_invitations" ("invitee");
+
+-- a user can only have one pending invitation per account
+CREATE UNIQUE INDEX ON "account_invitations" ("account_id", "invitee") WHERE "accepted_at" IS NULL;
+
+-- every existing account gets its owner as first member
+INSERT INTO "account_members" ("account_id", "username", "role", "created_at")
+SELECT "id", "owner", 'owner', "created_at" FROM "accounts";
+
+COMMENT ON COLUMN "account_members"."role" IS 'owner and co-owner can move money, viewer can only read. accounts.owner always has the owner role';
+
+COMMENT ON COLUMN "account_invitations"."accepted_at" IS 'null while the invitation is pending';
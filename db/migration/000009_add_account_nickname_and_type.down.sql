@@ -0,0 +1,9 @@
+-- fails if a user opened several accounts in the same currency in the meantime,
+-- those have to be merged or closed by hand before migrating down
+ALTER TABLE "accounts" ADD CONSTRAINT "owner_currency_key" UNIQUE ("owner", "currency");
+
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_type_check";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "type";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "nickname";
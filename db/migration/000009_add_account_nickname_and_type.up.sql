@@ -0,0 +1,11 @@
+ALTER TABLE "accounts" ADD COLUMN "nickname" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "accounts" ADD COLUMN "type" varchar NOT NULL DEFAULT 'checking';
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_type_check" CHECK ("type" IN ('checking', 'savings'));
+
+-- users can have several accounts in the same currency now. the index on owner from the init schema
+-- still covers lookups by owner, so nothing depends on the constraint's index anymore
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "owner_currency_key";
+
+COMMENT ON COLUMN "accounts"."nickname" IS 'user defined name to tell accounts in the same currency apart';
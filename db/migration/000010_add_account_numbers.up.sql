@@ -0,0 +1,15 @@
+ALTER TABLE "accounts" ADD COLUMN "number" varchar;
+
+-- backfill existing accounts with a random 16 digit body and mod-97 check digits, the same format
+-- library.NewAccountNumber generates. the prefix GO is rearranged to the digits 1624 for the checksum
+UPDATE "accounts" SET "number" = 'GO' || lpad((98 - mod(("b"."body" || '162400')::numeric, 97))::text, 2, '0') || "b"."body"
+FROM (
+  SELECT "id", lpad(floor(random() * 1e16)::bigint::text, 16, '0') AS "body" FROM "accounts"
+) AS "b"
+WHERE "accounts"."id" = "b"."id";
+
+ALTER TABLE "accounts" ALTER COLUMN "number" SET NOT NULL;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_number_key" UNIQUE ("number");
+
+COMMENT ON COLUMN "accounts"."number" IS 'public account number, the serial id is never exposed through the api';
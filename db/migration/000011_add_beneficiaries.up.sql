@@ -0,0 +1,20 @@
+CREATE TABLE "beneficiaries" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "account_number" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "note" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "beneficiaries" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "beneficiaries" ADD FOREIGN KEY ("account_number") REFERENCES "accounts" ("number");
+
+-- every account can only be saved once per user
+ALTER TABLE "beneficiaries" ADD CONSTRAINT "beneficiaries_owner_account_number_key" UNIQUE ("owner", "account_number");
+
+COMMENT ON COLUMN "beneficiaries"."owner" IS 'user who saved the beneficiary, not the owner of the account';
+
+COMMENT ON COLUMN "beneficiaries"."name" IS 'name the user gave the beneficiary, it is not checked against the account owner';
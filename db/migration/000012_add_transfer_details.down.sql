@@ -0,0 +1,11 @@
+ALTER TABLE "transfers" DROP CONSTRAINT IF EXISTS "transfers_metadata_size";
+
+ALTER TABLE "transfers" DROP CONSTRAINT IF EXISTS "transfers_reference_length";
+
+ALTER TABLE "transfers" DROP CONSTRAINT IF EXISTS "transfers_description_length";
+
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "metadata";
+
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "reference";
+
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "description";
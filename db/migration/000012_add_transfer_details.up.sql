@@ -0,0 +1,18 @@
+ALTER TABLE "transfers" ADD COLUMN "description" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "transfers" ADD COLUMN "reference" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "transfers" ADD COLUMN "metadata" jsonb NOT NULL DEFAULT '{}';
+
+-- the api validates these as well, the constraints keep other writers within the same bounds
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_description_length" CHECK (char_length("description") <= 140);
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_reference_length" CHECK (char_length("reference") <= 35);
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_metadata_size" CHECK (jsonb_typeof("metadata") = 'object' AND octet_length("metadata"::text) <= 2048);
+
+COMMENT ON COLUMN "transfers"."description" IS 'free text shown to both parties';
+
+COMMENT ON COLUMN "transfers"."reference" IS 'external reference, e.g. an invoice number';
+
+COMMENT ON COLUMN "transfers"."metadata" IS 'flat json object with string values set by the api client';
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS "interest_capitalizations";
+
+DROP TABLE IF EXISTS "interest_accruals";
+
+DROP TABLE IF EXISTS "interest_rates";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "interest_rate_bps";
+
+DROP INDEX IF EXISTS "accounts_interest_expense_currency_key";
+
+-- interest expense accounts and the system user stay, since entries and transfers reference them once interest
+-- was paid. the old type check only applies to new rows for that reason
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_type_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_type_check" CHECK ("type" IN ('checking', 'savings')) NOT VALID;
@@ -0,0 +1,61 @@
+-- interest is paid from one expense account per currency, owned by a user that cannot log in. the username
+-- isnt alphanumeric, so nobody can sign up with it
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email")
+VALUES ('_system', '', 'System', '_system')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_type_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_type_check" CHECK ("type" IN ('checking', 'savings', 'interest-expense'));
+
+CREATE UNIQUE INDEX "accounts_interest_expense_currency_key" ON "accounts" ("currency") WHERE "type" = 'interest-expense';
+
+ALTER TABLE "accounts" ADD COLUMN "interest_rate_bps" integer;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_interest_rate_bps_check" CHECK ("interest_rate_bps" >= 0);
+
+CREATE TABLE "interest_rates" (
+  "account_type" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "annual_rate_bps" integer NOT NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_type", "currency")
+);
+
+ALTER TABLE "interest_rates" ADD CONSTRAINT "interest_rates_annual_rate_bps_check" CHECK ("annual_rate_bps" >= 0);
+
+CREATE TABLE "interest_accruals" (
+  "account_id" bigint NOT NULL,
+  "accrual_date" date NOT NULL,
+  "balance" bigint NOT NULL,
+  "annual_rate_bps" integer NOT NULL,
+  "days_in_year" integer NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "accrual_date")
+);
+
+ALTER TABLE "interest_accruals" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+CREATE INDEX ON "interest_accruals" ("accrual_date");
+
+CREATE TABLE "interest_capitalizations" (
+  "account_id" bigint NOT NULL,
+  "period" date NOT NULL,
+  "amount" bigint NOT NULL,
+  "carry" varchar NOT NULL,
+  "transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "period")
+);
+
+ALTER TABLE "interest_capitalizations" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "interest_capitalizations" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+COMMENT ON COLUMN "accounts"."interest_rate_bps" IS 'annual interest rate in basis points, overrides the rate of the account type and currency when set';
+
+COMMENT ON COLUMN "interest_accruals"."balance" IS 'balance the interest of the day was calculated on, the interest itself is balance * annual_rate_bps / 10000 / days_in_year';
+
+COMMENT ON COLUMN "interest_capitalizations"."period" IS 'first day of the month the interest was accrued in';
+
+COMMENT ON COLUMN "interest_capitalizations"."carry" IS 'fraction of a minor unit that was left over, as exact rational number. it is added to the next period';
@@ -0,0 +1,10 @@
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "fee";
+
+DROP TABLE IF EXISTS "fee_rules";
+
+DROP INDEX IF EXISTS "accounts_fee_revenue_currency_key";
+
+-- fee revenue accounts stay once fees were posted to them, see 000013_add_interest.down.sql
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_type_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_type_check" CHECK ("type" IN ('checking', 'savings', 'interest-expense')) NOT VALID;
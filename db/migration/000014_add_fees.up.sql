@@ -0,0 +1,36 @@
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_type_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_type_check" CHECK ("type" IN ('checking', 'savings', 'interest-expense', 'fee-revenue'));
+
+CREATE UNIQUE INDEX "accounts_fee_revenue_currency_key" ON "accounts" ("currency") WHERE "type" = 'fee-revenue';
+
+CREATE TABLE "fee_rules" (
+  "id" bigserial PRIMARY KEY,
+  "currency" varchar NOT NULL,
+  "account_type" varchar NOT NULL DEFAULT '',
+  "flat_amount" bigint NOT NULL DEFAULT 0,
+  "percentage_bps" integer NOT NULL DEFAULT 0,
+  "min_amount" bigint NOT NULL DEFAULT 0,
+  "max_amount" bigint,
+  "free_per_month" integer NOT NULL DEFAULT 0,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- one rule per currency and account type, the rule with an empty type applies to all other types
+ALTER TABLE "fee_rules" ADD CONSTRAINT "fee_rules_currency_account_type_key" UNIQUE ("currency", "account_type");
+
+ALTER TABLE "fee_rules" ADD CONSTRAINT "fee_rules_amounts_check" CHECK (
+  "flat_amount" >= 0 AND "min_amount" >= 0 AND ("max_amount" IS NULL OR "max_amount" >= "min_amount")
+);
+
+ALTER TABLE "fee_rules" ADD CONSTRAINT "fee_rules_percentage_bps_check" CHECK ("percentage_bps" BETWEEN 0 AND 10000);
+
+ALTER TABLE "fee_rules" ADD CONSTRAINT "fee_rules_free_per_month_check" CHECK ("free_per_month" >= 0);
+
+ALTER TABLE "transfers" ADD COLUMN "fee" bigint NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN "fee_rules"."account_type" IS 'type of the sending account, empty for all types without a rule of their own';
+
+COMMENT ON COLUMN "fee_rules"."free_per_month" IS 'amount of outgoing transfers per account and calendar month (UTC) that are free of charge';
+
+COMMENT ON COLUMN "transfers"."fee" IS 'charged to the sender on top of the amount and posted to the fee revenue account of the currency';
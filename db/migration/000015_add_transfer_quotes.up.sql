@@ -0,0 +1,3 @@
+ALTER TABLE "transfers" ADD COLUMN "quote_id" varchar UNIQUE;
+
+COMMENT ON COLUMN "transfers"."quote_id" IS 'id of the quote the transfer was executed from, every quote can only be executed once';
@@ -0,0 +1,50 @@
+CREATE TABLE "transfer_batches" (
+  "id" bigserial PRIMARY KEY,
+  "from_account_id" bigint NOT NULL,
+  "created_by" varchar NOT NULL,
+  "atomic" boolean NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'processing',
+  "item_count" integer NOT NULL,
+  "succeeded" integer NOT NULL DEFAULT 0,
+  "failed" integer NOT NULL DEFAULT 0,
+  "total_amount" bigint NOT NULL DEFAULT 0,
+  "total_fee" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "completed_at" timestamptz
+);
+
+ALTER TABLE "transfer_batches" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfer_batches" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("username");
+
+ALTER TABLE "transfer_batches" ADD CONSTRAINT "transfer_batches_status_check" CHECK ("status" IN ('processing', 'completed', 'partially-failed', 'failed'));
+
+CREATE INDEX ON "transfer_batches" ("from_account_id");
+
+CREATE TABLE "transfer_batch_items" (
+  "batch_id" bigint NOT NULL,
+  "line" integer NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "description" varchar NOT NULL DEFAULT '',
+  "reference" varchar NOT NULL DEFAULT '',
+  "status" varchar NOT NULL,
+  "error" varchar NOT NULL DEFAULT '',
+  "transfer_id" bigint,
+  "fee" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("batch_id", "line")
+);
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("batch_id") REFERENCES "transfer_batches" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD CONSTRAINT "transfer_batch_items_status_check" CHECK ("status" IN ('succeeded', 'failed', 'skipped'));
+
+COMMENT ON COLUMN "transfer_batches"."atomic" IS 'either all items are executed or none, otherwise every item is executed on its own';
+
+COMMENT ON COLUMN "transfer_batch_items"."line" IS 'position of the item in the request, starting at 1';
+
+COMMENT ON COLUMN "transfer_batch_items"."status" IS 'skipped items were not executed because another item of an atomic batch failed';
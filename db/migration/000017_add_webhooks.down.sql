@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "webhook_deliveries";
+
+DROP TABLE IF EXISTS "webhook_subscriptions";
+
+DROP TABLE IF EXISTS "outbox";
@@ -0,0 +1,54 @@
+CREATE TABLE "outbox" (
+  "id" bigserial PRIMARY KEY,
+  "event_type" varchar NOT NULL,
+  "account_id" bigint NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "outbox" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+CREATE INDEX ON "outbox" ("account_id", "id");
+
+CREATE TABLE "webhook_subscriptions" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "url" varchar NOT NULL,
+  "secret" varchar NOT NULL,
+  "event_types" varchar[] NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "webhook_subscriptions" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "webhook_subscriptions" ("username");
+
+CREATE TABLE "webhook_deliveries" (
+  "id" bigserial PRIMARY KEY,
+  "subscription_id" bigint NOT NULL,
+  "outbox_id" bigint NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+  "last_status_code" integer,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "delivered_at" timestamptz,
+  UNIQUE ("subscription_id", "outbox_id")
+);
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("outbox_id") REFERENCES "outbox" ("id");
+
+ALTER TABLE "webhook_deliveries" ADD CONSTRAINT "webhook_deliveries_status_check" CHECK ("status" IN ('pending', 'succeeded', 'dead'));
+
+CREATE INDEX ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "outbox"."account_id" IS 'the account the event happened to, events of the same account are published in id order';
+
+COMMENT ON COLUMN "webhook_subscriptions"."secret" IS 'key of the HMAC-SHA256 signature, it has to be stored in plain text to sign the deliveries';
+
+COMMENT ON COLUMN "webhook_deliveries"."status" IS 'dead deliveries failed too often and are only retried on request';
+
+COMMENT ON COLUMN "webhook_deliveries"."next_attempt_at" IS 'moved ahead while a worker sends the delivery, so no other worker picks it up at the same time';
@@ -0,0 +1,7 @@
+CREATE TABLE "outbox_consumers" (
+  "name" varchar PRIMARY KEY,
+  "last_event_id" bigint NOT NULL DEFAULT 0,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "outbox_consumers"."last_event_id" IS 'id of the last outbox event the consumer has published, the relay continues after it';
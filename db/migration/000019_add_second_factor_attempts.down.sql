@@ -0,0 +1,3 @@
+ALTER TABLE "user_totp" DROP COLUMN IF EXISTS "last_failed_at";
+
+ALTER TABLE "user_totp" DROP COLUMN IF EXISTS "failed_attempts";
@@ -0,0 +1,7 @@
+ALTER TABLE "user_totp" ADD COLUMN "failed_attempts" int NOT NULL DEFAULT 0;
+
+ALTER TABLE "user_totp" ADD COLUMN "last_failed_at" timestamptz;
+
+COMMENT ON COLUMN "user_totp"."failed_attempts" IS 'second factor attempts since the last accepted code, every attempt is counted before the code is checked';
+
+COMMENT ON COLUMN "user_totp"."last_failed_at" IS 'time of the last counted attempt, once too many failed the user is locked out for a while after it';
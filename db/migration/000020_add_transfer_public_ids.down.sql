@@ -0,0 +1 @@
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "public_id";
@@ -0,0 +1,10 @@
+ALTER TABLE "transfers" ADD COLUMN "public_id" uuid;
+
+-- postgres 12 has no gen_random_uuid without pgcrypto, the random md5 is just as unguessable for existing transfers
+UPDATE "transfers" SET "public_id" = md5(random()::text || "id"::text)::uuid;
+
+ALTER TABLE "transfers" ALTER COLUMN "public_id" SET NOT NULL;
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_public_id_key" UNIQUE ("public_id");
+
+COMMENT ON COLUMN "transfers"."public_id" IS 'random id of the transfer that is shown outside the database instead of the sequential id';
@@ -0,0 +1,282 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed *.sql
+var files embed.FS // the migration files are compiled into the binary so it can migrate the database without the migrate CLI
+
+// arbitrary key for the postgres advisory lock that makes sure only one process migrates at a time
+const lockKey = 6371024
+
+// NilVersion is reported when no migration has been applied yet
+const NilVersion = -1
+
+var (
+	ErrDirty     = errors.New("database is dirty, fix the failed migration manually and use force to set the version")
+	ErrNoChange  = errors.New("no change")
+	fileNameExpr = regexp.MustCompile(`^([0-9]+)_(.*)\.(up|down)\.sql$`)
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator applies the embedded migrations. it uses the same schema_migrations table as the migrate CLI
+// (https://github.com/golang-migrate/migrate), so both can be used on the same database interchangeably
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration // sorted by version
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load reads all up/down file pairs of fsys and returns them sorted by version
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		match := fileNameExpr.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", name, err)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("conflicting names for migration version %d: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations, all of them if n is 0
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		applied := 0
+		for _, mig := range m.migrations {
+			if mig.Version <= current {
+				continue
+			}
+			if n > 0 && applied == n {
+				break
+			}
+
+			if err := m.run(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up failed: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+
+		if applied == 0 {
+			return ErrNoChange
+		}
+		return nil
+	})
+}
+
+// Down reverts up to n applied migrations, all of them if n is 0
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		reverted := 0
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version > current {
+				continue
+			}
+			if n > 0 && reverted == n {
+				break
+			}
+
+			// after reverting, the database is at the version of the previous migration
+			prev := int64(NilVersion)
+			if i > 0 {
+				prev = m.migrations[i-1].Version
+			}
+
+			if err := m.run(ctx, conn, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s down failed: %w", mig.Version, mig.Name, err)
+			}
+			reverted++
+		}
+
+		if reverted == 0 {
+			return ErrNoChange
+		}
+		return nil
+	})
+}
+
+// Version returns the currently applied version, NilVersion if nothing has been applied yet
+func (m *Migrator) Version(ctx context.Context) (version int64, dirty bool, err error) {
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err = readVersion(ctx, conn)
+		return err
+	})
+	return
+}
+
+// Force sets the version without running any migration and clears the dirty flag.
+// it is meant to recover after a migration failed halfway through
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := writeVersion(ctx, tx, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// run executes a migration and stores the resulting version in the same transaction,
+// so a failing migration leaves the database at its previous version instead of marking it dirty
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, query string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx error: %v, rollback error: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if err := writeVersion(ctx, tx, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// cleanVersion returns the current version or ErrDirty if a previous migration failed
+func (m *Migrator) cleanVersion(ctx context.Context, conn *sql.Conn) (int64, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, ErrDirty
+	}
+	if version != NilVersion && !m.known(version) {
+		// the database has been migrated by a newer binary, dont touch it
+		return 0, fmt.Errorf("database version %d has no migration file", version)
+	}
+	return version, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock runs fn on a single connection that holds the migration advisory lock. other replicas
+// that try to migrate at the same time block until the lock is released
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func readVersion(ctx context.Context, conn *sql.Conn) (version int64, dirty bool, err error) {
+	err = conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return NilVersion, false, nil
+	}
+	return
+}
+
+// the migrate CLI keeps exactly one row in schema_migrations and has no row at all for NilVersion
+func writeVersion(ctx context.Context, tx *sql.Tx, version int64) error {
+	if _, err := tx.ExecContext(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return err
+	}
+	if version == NilVersion {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version)
+	return err
+}
@@ -0,0 +1,74 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		require.NotEmpty(t, m.Up, "migration %d has no up file", m.Version)
+		require.NotEmpty(t, m.Down, "migration %d has no down file", m.Version)
+		if i > 0 {
+			require.Greater(t, m.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+		check func(t *testing.T, migrations []Migration, err error)
+	}{
+		{
+			name: "SortsByVersion",
+			files: fstest.MapFS{
+				"000010_b.up.sql":   {Data: []byte("up b")},
+				"000010_b.down.sql": {Data: []byte("down b")},
+				"000002_a.up.sql":   {Data: []byte("up a")},
+				"000002_a.down.sql": {Data: []byte("down a")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.NoError(t, err)
+				require.Len(t, migrations, 2)
+				require.Equal(t, Migration{Version: 2, Name: "a", Up: "up a", Down: "down a"}, migrations[0])
+				require.Equal(t, Migration{Version: 10, Name: "b", Up: "up b", Down: "down b"}, migrations[1])
+			},
+		},
+		{
+			name: "InvalidFileName",
+			files: fstest.MapFS{
+				"init.sql": {Data: []byte("")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name: "ConflictingNames",
+			files: fstest.MapFS{
+				"000001_a.up.sql":   {Data: []byte("")},
+				"000001_b.down.sql": {Data: []byte("")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := load(tc.files)
+			tc.check(t, migrations, err)
+		})
+	}
+}
@@ -7,6 +7,7 @@ package mockdb
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
@@ -35,213 +36,1663 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AcceptAccountInvitation mocks base method.
+func (m *MockRepository) AcceptAccountInvitation(arg0 context.Context, arg1 db.AcceptAccountInvitationParams) (db.AccountInvitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptAccountInvitation", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountInvitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptAccountInvitation indicates an expected call of AcceptAccountInvitation.
+func (mr *MockRepositoryMockRecorder) AcceptAccountInvitation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptAccountInvitation", reflect.TypeOf((*MockRepository)(nil).AcceptAccountInvitation), arg0, arg1)
+}
+
+// AcceptAccountInvitationTx mocks base method.
+func (m *MockRepository) AcceptAccountInvitationTx(arg0 context.Context, arg1 db.AcceptAccountInvitationTxParams) (db.AccountMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptAccountInvitationTx", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptAccountInvitationTx indicates an expected call of AcceptAccountInvitationTx.
+func (mr *MockRepositoryMockRecorder) AcceptAccountInvitationTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptAccountInvitationTx", reflect.TypeOf((*MockRepository)(nil).AcceptAccountInvitationTx), arg0, arg1)
+}
+
+// AccrueInterest mocks base method.
+func (m *MockRepository) AccrueInterest(arg0 context.Context, arg1 db.AccrueInterestParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrueInterest", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrueInterest indicates an expected call of AccrueInterest.
+func (mr *MockRepositoryMockRecorder) AccrueInterest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrueInterest", reflect.TypeOf((*MockRepository)(nil).AccrueInterest), arg0, arg1)
+}
+
 // AddAccountBalance mocks base method.
 func (m *MockRepository) AddAccountBalance(arg0 context.Context, arg1 db.AddAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddAccountBalance", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "AddAccountBalance", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddAccountBalance indicates an expected call of AddAccountBalance.
+func (mr *MockRepositoryMockRecorder) AddAccountBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockRepository)(nil).AddAccountBalance), arg0, arg1)
+}
+
+// AnonymizeUser mocks base method.
+func (m *MockRepository) AnonymizeUser(arg0 context.Context, arg1 db.AnonymizeUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockRepositoryMockRecorder) AnonymizeUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockRepository)(nil).AnonymizeUser), arg0, arg1)
+}
+
+// AppendAuditEvent mocks base method.
+func (m *MockRepository) AppendAuditEvent(arg0 context.Context, arg1 db.AppendAuditEventParams) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAuditEvent", arg0, arg1)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendAuditEvent indicates an expected call of AppendAuditEvent.
+func (mr *MockRepositoryMockRecorder) AppendAuditEvent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAuditEvent", reflect.TypeOf((*MockRepository)(nil).AppendAuditEvent), arg0, arg1)
+}
+
+// CapitalizeInterestTx mocks base method.
+func (m *MockRepository) CapitalizeInterestTx(arg0 context.Context, arg1 db.CapitalizeInterestTxParams) (db.CapitalizeInterestTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CapitalizeInterestTx", arg0, arg1)
+	ret0, _ := ret[0].(db.CapitalizeInterestTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CapitalizeInterestTx indicates an expected call of CapitalizeInterestTx.
+func (mr *MockRepositoryMockRecorder) CapitalizeInterestTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CapitalizeInterestTx", reflect.TypeOf((*MockRepository)(nil).CapitalizeInterestTx), arg0, arg1)
+}
+
+// ChangePasswordTx mocks base method.
+func (m *MockRepository) ChangePasswordTx(arg0 context.Context, arg1 db.ChangePasswordTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePasswordTx indicates an expected call of ChangePasswordTx.
+func (mr *MockRepositoryMockRecorder) ChangePasswordTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordTx", reflect.TypeOf((*MockRepository)(nil).ChangePasswordTx), arg0, arg1)
+}
+
+// ClaimSecondFactorAttempt mocks base method.
+func (m *MockRepository) ClaimSecondFactorAttempt(arg0 context.Context, arg1 db.ClaimSecondFactorAttemptParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimSecondFactorAttempt", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimSecondFactorAttempt indicates an expected call of ClaimSecondFactorAttempt.
+func (mr *MockRepositoryMockRecorder) ClaimSecondFactorAttempt(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimSecondFactorAttempt", reflect.TypeOf((*MockRepository)(nil).ClaimSecondFactorAttempt), arg0, arg1)
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepository) ClaimWebhookDeliveries(arg0 context.Context, arg1 db.ClaimWebhookDeliveriesParams) ([]db.ClaimWebhookDeliveriesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]db.ClaimWebhookDeliveriesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ClaimWebhookDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ClaimWebhookDeliveries), arg0, arg1)
+}
+
+// CloseAccount mocks base method.
+func (m *MockRepository) CloseAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseAccount indicates an expected call of CloseAccount.
+func (mr *MockRepositoryMockRecorder) CloseAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseAccount", reflect.TypeOf((*MockRepository)(nil).CloseAccount), arg0, arg1)
+}
+
+// CloseAccountTx mocks base method.
+func (m *MockRepository) CloseAccountTx(arg0 context.Context, arg1 db.CloseAccountTxParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseAccountTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseAccountTx indicates an expected call of CloseAccountTx.
+func (mr *MockRepositoryMockRecorder) CloseAccountTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseAccountTx", reflect.TypeOf((*MockRepository)(nil).CloseAccountTx), arg0, arg1)
+}
+
+// CompleteTransferBatch mocks base method.
+func (m *MockRepository) CompleteTransferBatch(arg0 context.Context, arg1 db.CompleteTransferBatchParams) (db.TransferBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteTransferBatch", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteTransferBatch indicates an expected call of CompleteTransferBatch.
+func (mr *MockRepositoryMockRecorder) CompleteTransferBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteTransferBatch", reflect.TypeOf((*MockRepository)(nil).CompleteTransferBatch), arg0, arg1)
+}
+
+// ConfirmUserTOTP mocks base method.
+func (m *MockRepository) ConfirmUserTOTP(arg0 context.Context, arg1 db.ConfirmUserTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmUserTOTP indicates an expected call of ConfirmUserTOTP.
+func (mr *MockRepositoryMockRecorder) ConfirmUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUserTOTP", reflect.TypeOf((*MockRepository)(nil).ConfirmUserTOTP), arg0, arg1)
+}
+
+// CountAccountTransfersSince mocks base method.
+func (m *MockRepository) CountAccountTransfersSince(arg0 context.Context, arg1 db.CountAccountTransfersSinceParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountAccountTransfersSince", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountAccountTransfersSince indicates an expected call of CountAccountTransfersSince.
+func (mr *MockRepositoryMockRecorder) CountAccountTransfersSince(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAccountTransfersSince", reflect.TypeOf((*MockRepository)(nil).CountAccountTransfersSince), arg0, arg1)
+}
+
+// CountOpenAccounts mocks base method.
+func (m *MockRepository) CountOpenAccounts(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOpenAccounts", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOpenAccounts indicates an expected call of CountOpenAccounts.
+func (mr *MockRepositoryMockRecorder) CountOpenAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOpenAccounts", reflect.TypeOf((*MockRepository)(nil).CountOpenAccounts), arg0, arg1)
+}
+
+// CountWebhookSubscriptions mocks base method.
+func (m *MockRepository) CountWebhookSubscriptions(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWebhookSubscriptions", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountWebhookSubscriptions indicates an expected call of CountWebhookSubscriptions.
+func (mr *MockRepositoryMockRecorder) CountWebhookSubscriptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWebhookSubscriptions", reflect.TypeOf((*MockRepository)(nil).CountWebhookSubscriptions), arg0, arg1)
+}
+
+// CreateAccount mocks base method.
+func (m *MockRepository) CreateAccount(arg0 context.Context, arg1 db.CreateAccountParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccount indicates an expected call of CreateAccount.
+func (mr *MockRepositoryMockRecorder) CreateAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockRepository)(nil).CreateAccount), arg0, arg1)
+}
+
+// CreateAccountInvitation mocks base method.
+func (m *MockRepository) CreateAccountInvitation(arg0 context.Context, arg1 db.CreateAccountInvitationParams) (db.AccountInvitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccountInvitation", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountInvitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccountInvitation indicates an expected call of CreateAccountInvitation.
+func (mr *MockRepositoryMockRecorder) CreateAccountInvitation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccountInvitation", reflect.TypeOf((*MockRepository)(nil).CreateAccountInvitation), arg0, arg1)
+}
+
+// CreateAccountMember mocks base method.
+func (m *MockRepository) CreateAccountMember(arg0 context.Context, arg1 db.CreateAccountMemberParams) (db.AccountMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccountMember", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccountMember indicates an expected call of CreateAccountMember.
+func (mr *MockRepositoryMockRecorder) CreateAccountMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccountMember", reflect.TypeOf((*MockRepository)(nil).CreateAccountMember), arg0, arg1)
+}
+
+// CreateAccountTx mocks base method.
+func (m *MockRepository) CreateAccountTx(arg0 context.Context, arg1 db.CreateAccountParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccountTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccountTx indicates an expected call of CreateAccountTx.
+func (mr *MockRepositoryMockRecorder) CreateAccountTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccountTx", reflect.TypeOf((*MockRepository)(nil).CreateAccountTx), arg0, arg1)
+}
+
+// CreateAuditEvent mocks base method.
+func (m *MockRepository) CreateAuditEvent(arg0 context.Context, arg1 db.CreateAuditEventParams) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditEvent", arg0, arg1)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuditEvent indicates an expected call of CreateAuditEvent.
+func (mr *MockRepositoryMockRecorder) CreateAuditEvent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditEvent", reflect.TypeOf((*MockRepository)(nil).CreateAuditEvent), arg0, arg1)
+}
+
+// CreateBeneficiary mocks base method.
+func (m *MockRepository) CreateBeneficiary(arg0 context.Context, arg1 db.CreateBeneficiaryParams) (db.Beneficiary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBeneficiary", arg0, arg1)
+	ret0, _ := ret[0].(db.Beneficiary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBeneficiary indicates an expected call of CreateBeneficiary.
+func (mr *MockRepositoryMockRecorder) CreateBeneficiary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBeneficiary", reflect.TypeOf((*MockRepository)(nil).CreateBeneficiary), arg0, arg1)
+}
+
+// CreateEmailVerificationToken mocks base method.
+func (m *MockRepository) CreateEmailVerificationToken(arg0 context.Context, arg1 db.CreateEmailVerificationTokenParams) (db.EmailVerificationToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEmailVerificationToken", arg0, arg1)
+	ret0, _ := ret[0].(db.EmailVerificationToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEmailVerificationToken indicates an expected call of CreateEmailVerificationToken.
+func (mr *MockRepositoryMockRecorder) CreateEmailVerificationToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEmailVerificationToken", reflect.TypeOf((*MockRepository)(nil).CreateEmailVerificationToken), arg0, arg1)
+}
+
+// CreateEntry mocks base method.
+func (m *MockRepository) CreateEntry(arg0 context.Context, arg1 db.CreateEntryParams) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEntry", arg0, arg1)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEntry indicates an expected call of CreateEntry.
+func (mr *MockRepositoryMockRecorder) CreateEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockRepository)(nil).CreateEntry), arg0, arg1)
+}
+
+// CreateInterestCapitalization mocks base method.
+func (m *MockRepository) CreateInterestCapitalization(arg0 context.Context, arg1 db.CreateInterestCapitalizationParams) (db.InterestCapitalization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInterestCapitalization", arg0, arg1)
+	ret0, _ := ret[0].(db.InterestCapitalization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateInterestCapitalization indicates an expected call of CreateInterestCapitalization.
+func (mr *MockRepositoryMockRecorder) CreateInterestCapitalization(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInterestCapitalization", reflect.TypeOf((*MockRepository)(nil).CreateInterestCapitalization), arg0, arg1)
+}
+
+// CreateOutboxEvent mocks base method.
+func (m *MockRepository) CreateOutboxEvent(arg0 context.Context, arg1 db.CreateOutboxEventParams) (db.Outbox, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOutboxEvent", arg0, arg1)
+	ret0, _ := ret[0].(db.Outbox)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOutboxEvent indicates an expected call of CreateOutboxEvent.
+func (mr *MockRepositoryMockRecorder) CreateOutboxEvent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOutboxEvent", reflect.TypeOf((*MockRepository)(nil).CreateOutboxEvent), arg0, arg1)
+}
+
+// CreatePasswordResetToken mocks base method.
+func (m *MockRepository) CreatePasswordResetToken(arg0 context.Context, arg1 db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) CreatePasswordResetToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).CreatePasswordResetToken), arg0, arg1)
+}
+
+// CreateRecoveryCode mocks base method.
+func (m *MockRepository) CreateRecoveryCode(arg0 context.Context, arg1 db.CreateRecoveryCodeParams) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRecoveryCode indicates an expected call of CreateRecoveryCode.
+func (mr *MockRepositoryMockRecorder) CreateRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCode", reflect.TypeOf((*MockRepository)(nil).CreateRecoveryCode), arg0, arg1)
+}
+
+// CreateTransfer mocks base method.
+func (m *MockRepository) CreateTransfer(arg0 context.Context, arg1 db.CreateTransferParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockRepositoryMockRecorder) CreateTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockRepository)(nil).CreateTransfer), arg0, arg1)
+}
+
+// CreateTransferBatch mocks base method.
+func (m *MockRepository) CreateTransferBatch(arg0 context.Context, arg1 db.CreateTransferBatchParams) (db.TransferBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransferBatch", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransferBatch indicates an expected call of CreateTransferBatch.
+func (mr *MockRepositoryMockRecorder) CreateTransferBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferBatch", reflect.TypeOf((*MockRepository)(nil).CreateTransferBatch), arg0, arg1)
+}
+
+// CreateTransferBatchItem mocks base method.
+func (m *MockRepository) CreateTransferBatchItem(arg0 context.Context, arg1 db.CreateTransferBatchItemParams) (db.TransferBatchItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransferBatchItem", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferBatchItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransferBatchItem indicates an expected call of CreateTransferBatchItem.
+func (mr *MockRepositoryMockRecorder) CreateTransferBatchItem(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferBatchItem", reflect.TypeOf((*MockRepository)(nil).CreateTransferBatchItem), arg0, arg1)
+}
+
+// CreateUser mocks base method.
+func (m *MockRepository) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockRepositoryMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), arg0, arg1)
+}
+
+// CreateWebhookDeliveries mocks base method.
+func (m *MockRepository) CreateWebhookDeliveries(arg0 context.Context, arg1 db.CreateWebhookDeliveriesParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhookDeliveries indicates an expected call of CreateWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) CreateWebhookDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).CreateWebhookDeliveries), arg0, arg1)
+}
+
+// CreateWebhookSubscription mocks base method.
+func (m *MockRepository) CreateWebhookSubscription(arg0 context.Context, arg1 db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", arg0, arg1)
+	ret0, _ := ret[0].(db.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockRepositoryMockRecorder) CreateWebhookSubscription(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockRepository)(nil).CreateWebhookSubscription), arg0, arg1)
+}
+
+// DeleteAccount mocks base method.
+func (m *MockRepository) DeleteAccount(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockRepositoryMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockRepository)(nil).DeleteAccount), arg0, arg1)
+}
+
+// DeleteAccountInvitation mocks base method.
+func (m *MockRepository) DeleteAccountInvitation(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccountInvitation", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccountInvitation indicates an expected call of DeleteAccountInvitation.
+func (mr *MockRepositoryMockRecorder) DeleteAccountInvitation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccountInvitation", reflect.TypeOf((*MockRepository)(nil).DeleteAccountInvitation), arg0, arg1)
+}
+
+// DeleteAccountMember mocks base method.
+func (m *MockRepository) DeleteAccountMember(arg0 context.Context, arg1 db.DeleteAccountMemberParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccountMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccountMember indicates an expected call of DeleteAccountMember.
+func (mr *MockRepositoryMockRecorder) DeleteAccountMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccountMember", reflect.TypeOf((*MockRepository)(nil).DeleteAccountMember), arg0, arg1)
+}
+
+// DeleteBeneficiary mocks base method.
+func (m *MockRepository) DeleteBeneficiary(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBeneficiary", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBeneficiary indicates an expected call of DeleteBeneficiary.
+func (mr *MockRepositoryMockRecorder) DeleteBeneficiary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBeneficiary", reflect.TypeOf((*MockRepository)(nil).DeleteBeneficiary), arg0, arg1)
+}
+
+// DeleteEmailVerificationTokens mocks base method.
+func (m *MockRepository) DeleteEmailVerificationTokens(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEmailVerificationTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEmailVerificationTokens indicates an expected call of DeleteEmailVerificationTokens.
+func (mr *MockRepositoryMockRecorder) DeleteEmailVerificationTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEmailVerificationTokens", reflect.TypeOf((*MockRepository)(nil).DeleteEmailVerificationTokens), arg0, arg1)
+}
+
+// DeleteFeeRule mocks base method.
+func (m *MockRepository) DeleteFeeRule(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFeeRule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFeeRule indicates an expected call of DeleteFeeRule.
+func (mr *MockRepositoryMockRecorder) DeleteFeeRule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFeeRule", reflect.TypeOf((*MockRepository)(nil).DeleteFeeRule), arg0, arg1)
+}
+
+// DeleteRecoveryCodes mocks base method.
+func (m *MockRepository) DeleteRecoveryCodes(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCodes", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRecoveryCodes indicates an expected call of DeleteRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) DeleteRecoveryCodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).DeleteRecoveryCodes), arg0, arg1)
+}
+
+// DeleteUserAccountInvitations mocks base method.
+func (m *MockRepository) DeleteUserAccountInvitations(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserAccountInvitations", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserAccountInvitations indicates an expected call of DeleteUserAccountInvitations.
+func (mr *MockRepositoryMockRecorder) DeleteUserAccountInvitations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserAccountInvitations", reflect.TypeOf((*MockRepository)(nil).DeleteUserAccountInvitations), arg0, arg1)
+}
+
+// DeleteUserAccountMemberships mocks base method.
+func (m *MockRepository) DeleteUserAccountMemberships(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserAccountMemberships", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserAccountMemberships indicates an expected call of DeleteUserAccountMemberships.
+func (mr *MockRepositoryMockRecorder) DeleteUserAccountMemberships(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserAccountMemberships", reflect.TypeOf((*MockRepository)(nil).DeleteUserAccountMemberships), arg0, arg1)
+}
+
+// DeleteUserBeneficiaries mocks base method.
+func (m *MockRepository) DeleteUserBeneficiaries(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserBeneficiaries", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserBeneficiaries indicates an expected call of DeleteUserBeneficiaries.
+func (mr *MockRepositoryMockRecorder) DeleteUserBeneficiaries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserBeneficiaries", reflect.TypeOf((*MockRepository)(nil).DeleteUserBeneficiaries), arg0, arg1)
+}
+
+// DeleteUserTOTP mocks base method.
+func (m *MockRepository) DeleteUserTOTP(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserTOTP indicates an expected call of DeleteUserTOTP.
+func (mr *MockRepositoryMockRecorder) DeleteUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTOTP", reflect.TypeOf((*MockRepository)(nil).DeleteUserTOTP), arg0, arg1)
+}
+
+// DeleteUserTx mocks base method.
+func (m *MockRepository) DeleteUserTx(arg0 context.Context, arg1 db.DeleteUserTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserTx indicates an expected call of DeleteUserTx.
+func (mr *MockRepositoryMockRecorder) DeleteUserTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserTx", reflect.TypeOf((*MockRepository)(nil).DeleteUserTx), arg0, arg1)
+}
+
+// DeleteUserWebhookSubscriptions mocks base method.
+func (m *MockRepository) DeleteUserWebhookSubscriptions(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserWebhookSubscriptions", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserWebhookSubscriptions indicates an expected call of DeleteUserWebhookSubscriptions.
+func (mr *MockRepositoryMockRecorder) DeleteUserWebhookSubscriptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserWebhookSubscriptions", reflect.TypeOf((*MockRepository)(nil).DeleteUserWebhookSubscriptions), arg0, arg1)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockRepository) DeleteWebhookSubscription(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockRepositoryMockRecorder) DeleteWebhookSubscription(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockRepository)(nil).DeleteWebhookSubscription), arg0, arg1)
+}
+
+// DisableTOTPTx mocks base method.
+func (m *MockRepository) DisableTOTPTx(arg0 context.Context, arg1 db.DisableTOTPTxParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTPTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTPTx indicates an expected call of DisableTOTPTx.
+func (mr *MockRepositoryMockRecorder) DisableTOTPTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTPTx", reflect.TypeOf((*MockRepository)(nil).DisableTOTPTx), arg0, arg1)
+}
+
+// EnableTOTPTx mocks base method.
+func (m *MockRepository) EnableTOTPTx(arg0 context.Context, arg1 db.EnableTOTPTxParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTPTx", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTOTPTx indicates an expected call of EnableTOTPTx.
+func (mr *MockRepositoryMockRecorder) EnableTOTPTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTPTx", reflect.TypeOf((*MockRepository)(nil).EnableTOTPTx), arg0, arg1)
+}
+
+// GetAccount mocks base method.
+func (m *MockRepository) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockRepositoryMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepository)(nil).GetAccount), arg0, arg1)
+}
+
+// GetAccountByNumber mocks base method.
+func (m *MockRepository) GetAccountByNumber(arg0 context.Context, arg1 string) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountByNumber", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountByNumber indicates an expected call of GetAccountByNumber.
+func (mr *MockRepositoryMockRecorder) GetAccountByNumber(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountByNumber", reflect.TypeOf((*MockRepository)(nil).GetAccountByNumber), arg0, arg1)
+}
+
+// GetAccountForUpdate mocks base method.
+func (m *MockRepository) GetAccountForUpdate(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
+func (mr *MockRepositoryMockRecorder) GetAccountForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockRepository)(nil).GetAccountForUpdate), arg0, arg1)
+}
+
+// GetAccountInvitation mocks base method.
+func (m *MockRepository) GetAccountInvitation(arg0 context.Context, arg1 int64) (db.AccountInvitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountInvitation", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountInvitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountInvitation indicates an expected call of GetAccountInvitation.
+func (mr *MockRepositoryMockRecorder) GetAccountInvitation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountInvitation", reflect.TypeOf((*MockRepository)(nil).GetAccountInvitation), arg0, arg1)
+}
+
+// GetAccountMember mocks base method.
+func (m *MockRepository) GetAccountMember(arg0 context.Context, arg1 db.GetAccountMemberParams) (db.AccountMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountMember", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountMember indicates an expected call of GetAccountMember.
+func (mr *MockRepositoryMockRecorder) GetAccountMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountMember", reflect.TypeOf((*MockRepository)(nil).GetAccountMember), arg0, arg1)
+}
+
+// GetApplicableFeeRule mocks base method.
+func (m *MockRepository) GetApplicableFeeRule(arg0 context.Context, arg1 db.GetApplicableFeeRuleParams) (db.FeeRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApplicableFeeRule", arg0, arg1)
+	ret0, _ := ret[0].(db.FeeRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApplicableFeeRule indicates an expected call of GetApplicableFeeRule.
+func (mr *MockRepositoryMockRecorder) GetApplicableFeeRule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApplicableFeeRule", reflect.TypeOf((*MockRepository)(nil).GetApplicableFeeRule), arg0, arg1)
+}
+
+// GetBeneficiary mocks base method.
+func (m *MockRepository) GetBeneficiary(arg0 context.Context, arg1 int64) (db.Beneficiary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBeneficiary", arg0, arg1)
+	ret0, _ := ret[0].(db.Beneficiary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBeneficiary indicates an expected call of GetBeneficiary.
+func (mr *MockRepositoryMockRecorder) GetBeneficiary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBeneficiary", reflect.TypeOf((*MockRepository)(nil).GetBeneficiary), arg0, arg1)
+}
+
+// GetEntry mocks base method.
+func (m *MockRepository) GetEntry(arg0 context.Context, arg1 int64) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEntry", arg0, arg1)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEntry indicates an expected call of GetEntry.
+func (mr *MockRepositoryMockRecorder) GetEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockRepository)(nil).GetEntry), arg0, arg1)
+}
+
+// GetFeeRule mocks base method.
+func (m *MockRepository) GetFeeRule(arg0 context.Context, arg1 int64) (db.FeeRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeRule", arg0, arg1)
+	ret0, _ := ret[0].(db.FeeRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeRule indicates an expected call of GetFeeRule.
+func (mr *MockRepositoryMockRecorder) GetFeeRule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeRule", reflect.TypeOf((*MockRepository)(nil).GetFeeRule), arg0, arg1)
+}
+
+// GetFeeRuleByScope mocks base method.
+func (m *MockRepository) GetFeeRuleByScope(arg0 context.Context, arg1 db.GetFeeRuleByScopeParams) (db.FeeRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeRuleByScope", arg0, arg1)
+	ret0, _ := ret[0].(db.FeeRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeRuleByScope indicates an expected call of GetFeeRuleByScope.
+func (mr *MockRepositoryMockRecorder) GetFeeRuleByScope(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeRuleByScope", reflect.TypeOf((*MockRepository)(nil).GetFeeRuleByScope), arg0, arg1)
+}
+
+// GetInterestRate mocks base method.
+func (m *MockRepository) GetInterestRate(arg0 context.Context, arg1 db.GetInterestRateParams) (db.InterestRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestRate", arg0, arg1)
+	ret0, _ := ret[0].(db.InterestRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestRate indicates an expected call of GetInterestRate.
+func (mr *MockRepositoryMockRecorder) GetInterestRate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestRate", reflect.TypeOf((*MockRepository)(nil).GetInterestRate), arg0, arg1)
+}
+
+// GetLastAccountOutboxEventID mocks base method.
+func (m *MockRepository) GetLastAccountOutboxEventID(arg0 context.Context, arg1 int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastAccountOutboxEventID", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastAccountOutboxEventID indicates an expected call of GetLastAccountOutboxEventID.
+func (mr *MockRepositoryMockRecorder) GetLastAccountOutboxEventID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastAccountOutboxEventID", reflect.TypeOf((*MockRepository)(nil).GetLastAccountOutboxEventID), arg0, arg1)
+}
+
+// GetLastAuditEvent mocks base method.
+func (m *MockRepository) GetLastAuditEvent(arg0 context.Context) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastAuditEvent", arg0)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastAuditEvent indicates an expected call of GetLastAuditEvent.
+func (mr *MockRepositoryMockRecorder) GetLastAuditEvent(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastAuditEvent", reflect.TypeOf((*MockRepository)(nil).GetLastAuditEvent), arg0)
+}
+
+// GetLastInterestCapitalization mocks base method.
+func (m *MockRepository) GetLastInterestCapitalization(arg0 context.Context, arg1 db.GetLastInterestCapitalizationParams) (db.InterestCapitalization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastInterestCapitalization", arg0, arg1)
+	ret0, _ := ret[0].(db.InterestCapitalization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastInterestCapitalization indicates an expected call of GetLastInterestCapitalization.
+func (mr *MockRepositoryMockRecorder) GetLastInterestCapitalization(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastInterestCapitalization", reflect.TypeOf((*MockRepository)(nil).GetLastInterestCapitalization), arg0, arg1)
+}
+
+// GetOutboxConsumerOffset mocks base method.
+func (m *MockRepository) GetOutboxConsumerOffset(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOutboxConsumerOffset", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOutboxConsumerOffset indicates an expected call of GetOutboxConsumerOffset.
+func (mr *MockRepositoryMockRecorder) GetOutboxConsumerOffset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutboxConsumerOffset", reflect.TypeOf((*MockRepository)(nil).GetOutboxConsumerOffset), arg0, arg1)
+}
+
+// GetSystemAccount mocks base method.
+func (m *MockRepository) GetSystemAccount(arg0 context.Context, arg1 db.GetSystemAccountParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSystemAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSystemAccount indicates an expected call of GetSystemAccount.
+func (mr *MockRepositoryMockRecorder) GetSystemAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSystemAccount", reflect.TypeOf((*MockRepository)(nil).GetSystemAccount), arg0, arg1)
+}
+
+// GetTransfer mocks base method.
+func (m *MockRepository) GetTransfer(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfer indicates an expected call of GetTransfer.
+func (mr *MockRepositoryMockRecorder) GetTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockRepository)(nil).GetTransfer), arg0, arg1)
+}
+
+// GetTransferBatch mocks base method.
+func (m *MockRepository) GetTransferBatch(arg0 context.Context, arg1 int64) (db.TransferBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferBatch", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferBatch indicates an expected call of GetTransferBatch.
+func (mr *MockRepositoryMockRecorder) GetTransferBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferBatch", reflect.TypeOf((*MockRepository)(nil).GetTransferBatch), arg0, arg1)
+}
+
+// GetUser mocks base method.
+func (m *MockRepository) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockRepositoryMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), arg0, arg1)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockRepository) GetUserByEmail(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockRepositoryMockRecorder) GetUserByEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), arg0, arg1)
+}
+
+// GetUserForUpdate mocks base method.
+func (m *MockRepository) GetUserForUpdate(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserForUpdate indicates an expected call of GetUserForUpdate.
+func (mr *MockRepositoryMockRecorder) GetUserForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserForUpdate", reflect.TypeOf((*MockRepository)(nil).GetUserForUpdate), arg0, arg1)
+}
+
+// GetUserPasswordChangedAt mocks base method.
+func (m *MockRepository) GetUserPasswordChangedAt(arg0 context.Context, arg1 string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserPasswordChangedAt", arg0, arg1)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserPasswordChangedAt indicates an expected call of GetUserPasswordChangedAt.
+func (mr *MockRepositoryMockRecorder) GetUserPasswordChangedAt(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPasswordChangedAt", reflect.TypeOf((*MockRepository)(nil).GetUserPasswordChangedAt), arg0, arg1)
+}
+
+// GetUserTOTP mocks base method.
+func (m *MockRepository) GetUserTOTP(arg0 context.Context, arg1 string) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTOTP indicates an expected call of GetUserTOTP.
+func (mr *MockRepositoryMockRecorder) GetUserTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTOTP", reflect.TypeOf((*MockRepository)(nil).GetUserTOTP), arg0, arg1)
+}
+
+// GetWebhookDelivery mocks base method.
+func (m *MockRepository) GetWebhookDelivery(arg0 context.Context, arg1 int64) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDelivery indicates an expected call of GetWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) GetWebhookDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).GetWebhookDelivery), arg0, arg1)
+}
+
+// GetWebhookSubscription mocks base method.
+func (m *MockRepository) GetWebhookSubscription(arg0 context.Context, arg1 int64) (db.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSubscription", arg0, arg1)
+	ret0, _ := ret[0].(db.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSubscription indicates an expected call of GetWebhookSubscription.
+func (mr *MockRepositoryMockRecorder) GetWebhookSubscription(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSubscription", reflect.TypeOf((*MockRepository)(nil).GetWebhookSubscription), arg0, arg1)
+}
+
+// InvalidatePasswordResetTokens mocks base method.
+func (m *MockRepository) InvalidatePasswordResetTokens(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidatePasswordResetTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidatePasswordResetTokens indicates an expected call of InvalidatePasswordResetTokens.
+func (mr *MockRepositoryMockRecorder) InvalidatePasswordResetTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePasswordResetTokens", reflect.TypeOf((*MockRepository)(nil).InvalidatePasswordResetTokens), arg0, arg1)
+}
+
+// ListAccountMembers mocks base method.
+func (m *MockRepository) ListAccountMembers(arg0 context.Context, arg1 int64) ([]db.AccountMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountMembers", arg0, arg1)
+	ret0, _ := ret[0].([]db.AccountMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountMembers indicates an expected call of ListAccountMembers.
+func (mr *MockRepositoryMockRecorder) ListAccountMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountMembers", reflect.TypeOf((*MockRepository)(nil).ListAccountMembers), arg0, arg1)
+}
+
+// ListAccountOutboxEvents mocks base method.
+func (m *MockRepository) ListAccountOutboxEvents(arg0 context.Context, arg1 db.ListAccountOutboxEventsParams) ([]db.Outbox, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountOutboxEvents", arg0, arg1)
+	ret0, _ := ret[0].([]db.Outbox)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountOutboxEvents indicates an expected call of ListAccountOutboxEvents.
+func (mr *MockRepositoryMockRecorder) ListAccountOutboxEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountOutboxEvents", reflect.TypeOf((*MockRepository)(nil).ListAccountOutboxEvents), arg0, arg1)
+}
+
+// ListAccountTransfers mocks base method.
+func (m *MockRepository) ListAccountTransfers(arg0 context.Context, arg1 db.ListAccountTransfersParams) ([]db.ListAccountTransfersRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListAccountTransfersRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountTransfers indicates an expected call of ListAccountTransfers.
+func (mr *MockRepositoryMockRecorder) ListAccountTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountTransfers", reflect.TypeOf((*MockRepository)(nil).ListAccountTransfers), arg0, arg1)
+}
+
+// ListAccounts mocks base method.
+func (m *MockRepository) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccounts indicates an expected call of ListAccounts.
+func (mr *MockRepositoryMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockRepository)(nil).ListAccounts), arg0, arg1)
+}
+
+// ListAuditEvents mocks base method.
+func (m *MockRepository) ListAuditEvents(arg0 context.Context, arg1 db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEvents", arg0, arg1)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEvents indicates an expected call of ListAuditEvents.
+func (mr *MockRepositoryMockRecorder) ListAuditEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEvents", reflect.TypeOf((*MockRepository)(nil).ListAuditEvents), arg0, arg1)
+}
+
+// ListAuditEventsAfter mocks base method.
+func (m *MockRepository) ListAuditEventsAfter(arg0 context.Context, arg1 db.ListAuditEventsAfterParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEventsAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEventsAfter indicates an expected call of ListAuditEventsAfter.
+func (mr *MockRepositoryMockRecorder) ListAuditEventsAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEventsAfter", reflect.TypeOf((*MockRepository)(nil).ListAuditEventsAfter), arg0, arg1)
+}
+
+// ListBeneficiaries mocks base method.
+func (m *MockRepository) ListBeneficiaries(arg0 context.Context, arg1 db.ListBeneficiariesParams) ([]db.Beneficiary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBeneficiaries", arg0, arg1)
+	ret0, _ := ret[0].([]db.Beneficiary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBeneficiaries indicates an expected call of ListBeneficiaries.
+func (mr *MockRepositoryMockRecorder) ListBeneficiaries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBeneficiaries", reflect.TypeOf((*MockRepository)(nil).ListBeneficiaries), arg0, arg1)
+}
+
+// ListEntries mocks base method.
+func (m *MockRepository) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockRepositoryMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockRepository)(nil).ListEntries), arg0, arg1)
+}
+
+// ListFeeRules mocks base method.
+func (m *MockRepository) ListFeeRules(arg0 context.Context) ([]db.FeeRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFeeRules", arg0)
+	ret0, _ := ret[0].([]db.FeeRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFeeRules indicates an expected call of ListFeeRules.
+func (mr *MockRepositoryMockRecorder) ListFeeRules(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFeeRules", reflect.TypeOf((*MockRepository)(nil).ListFeeRules), arg0)
+}
+
+// ListInterestAccruals mocks base method.
+func (m *MockRepository) ListInterestAccruals(arg0 context.Context, arg1 db.ListInterestAccrualsParams) ([]db.InterestAccrual, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestAccruals", arg0, arg1)
+	ret0, _ := ret[0].([]db.InterestAccrual)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestAccruals indicates an expected call of ListInterestAccruals.
+func (mr *MockRepositoryMockRecorder) ListInterestAccruals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestAccruals", reflect.TypeOf((*MockRepository)(nil).ListInterestAccruals), arg0, arg1)
+}
+
+// ListInterestRates mocks base method.
+func (m *MockRepository) ListInterestRates(arg0 context.Context) ([]db.InterestRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestRates", arg0)
+	ret0, _ := ret[0].([]db.InterestRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestRates indicates an expected call of ListInterestRates.
+func (mr *MockRepositoryMockRecorder) ListInterestRates(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestRates", reflect.TypeOf((*MockRepository)(nil).ListInterestRates), arg0)
+}
+
+// ListMemberAccounts mocks base method.
+func (m *MockRepository) ListMemberAccounts(arg0 context.Context, arg1 db.ListMemberAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMemberAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMemberAccounts indicates an expected call of ListMemberAccounts.
+func (mr *MockRepositoryMockRecorder) ListMemberAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMemberAccounts", reflect.TypeOf((*MockRepository)(nil).ListMemberAccounts), arg0, arg1)
+}
+
+// ListOutboxEventsAfter mocks base method.
+func (m *MockRepository) ListOutboxEventsAfter(arg0 context.Context, arg1 db.ListOutboxEventsAfterParams) ([]db.ListOutboxEventsAfterRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOutboxEventsAfter", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListOutboxEventsAfterRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOutboxEventsAfter indicates an expected call of ListOutboxEventsAfter.
+func (mr *MockRepositoryMockRecorder) ListOutboxEventsAfter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOutboxEventsAfter", reflect.TypeOf((*MockRepository)(nil).ListOutboxEventsAfter), arg0, arg1)
+}
+
+// ListOwnerAccounts mocks base method.
+func (m *MockRepository) ListOwnerAccounts(arg0 context.Context, arg1 string) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOwnerAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOwnerAccounts indicates an expected call of ListOwnerAccounts.
+func (mr *MockRepositoryMockRecorder) ListOwnerAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOwnerAccounts", reflect.TypeOf((*MockRepository)(nil).ListOwnerAccounts), arg0, arg1)
+}
+
+// ListPendingAccountInvitations mocks base method.
+func (m *MockRepository) ListPendingAccountInvitations(arg0 context.Context, arg1 string) ([]db.ListPendingAccountInvitationsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingAccountInvitations", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListPendingAccountInvitationsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingAccountInvitations indicates an expected call of ListPendingAccountInvitations.
+func (mr *MockRepositoryMockRecorder) ListPendingAccountInvitations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingAccountInvitations", reflect.TypeOf((*MockRepository)(nil).ListPendingAccountInvitations), arg0, arg1)
+}
+
+// ListPendingInterestCapitalizations mocks base method.
+func (m *MockRepository) ListPendingInterestCapitalizations(arg0 context.Context, arg1 time.Time) ([]db.ListPendingInterestCapitalizationsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingInterestCapitalizations", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListPendingInterestCapitalizationsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingInterestCapitalizations indicates an expected call of ListPendingInterestCapitalizations.
+func (mr *MockRepositoryMockRecorder) ListPendingInterestCapitalizations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingInterestCapitalizations", reflect.TypeOf((*MockRepository)(nil).ListPendingInterestCapitalizations), arg0, arg1)
+}
+
+// ListTransferBatchItems mocks base method.
+func (m *MockRepository) ListTransferBatchItems(arg0 context.Context, arg1 int64) ([]db.ListTransferBatchItemsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferBatchItems", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListTransferBatchItemsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferBatchItems indicates an expected call of ListTransferBatchItems.
+func (mr *MockRepositoryMockRecorder) ListTransferBatchItems(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferBatchItems", reflect.TypeOf((*MockRepository)(nil).ListTransferBatchItems), arg0, arg1)
+}
+
+// ListTransfers mocks base method.
+func (m *MockRepository) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfers indicates an expected call of ListTransfers.
+func (mr *MockRepositoryMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockRepository)(nil).ListTransfers), arg0, arg1)
+}
+
+// ListUnusedRecoveryCodes mocks base method.
+func (m *MockRepository) ListUnusedRecoveryCodes(arg0 context.Context, arg1 string) ([]db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnusedRecoveryCodes", arg0, arg1)
+	ret0, _ := ret[0].([]db.RecoveryCode)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddAccountBalance indicates an expected call of AddAccountBalance.
-func (mr *MockRepositoryMockRecorder) AddAccountBalance(arg0, arg1 interface{}) *gomock.Call {
+// ListUnusedRecoveryCodes indicates an expected call of ListUnusedRecoveryCodes.
+func (mr *MockRepositoryMockRecorder) ListUnusedRecoveryCodes(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockRepository)(nil).AddAccountBalance), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnusedRecoveryCodes", reflect.TypeOf((*MockRepository)(nil).ListUnusedRecoveryCodes), arg0, arg1)
 }
 
-// CreateAccount mocks base method.
-func (m *MockRepository) CreateAccount(arg0 context.Context, arg1 db.CreateAccountParams) (db.Account, error) {
+// ListWebhookDeliveries mocks base method.
+func (m *MockRepository) ListWebhookDeliveries(arg0 context.Context, arg1 db.ListWebhookDeliveriesParams) ([]db.ListWebhookDeliveriesRow, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateAccount", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListWebhookDeliveriesRow)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateAccount indicates an expected call of CreateAccount.
-func (mr *MockRepositoryMockRecorder) CreateAccount(arg0, arg1 interface{}) *gomock.Call {
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ListWebhookDeliveries(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockRepository)(nil).CreateAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ListWebhookDeliveries), arg0, arg1)
 }
 
-// CreateEntry mocks base method.
-func (m *MockRepository) CreateEntry(arg0 context.Context, arg1 db.CreateEntryParams) (db.Entry, error) {
+// ListWebhookSubscriptions mocks base method.
+func (m *MockRepository) ListWebhookSubscriptions(arg0 context.Context, arg1 string) ([]db.WebhookSubscription, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateEntry", arg0, arg1)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "ListWebhookSubscriptions", arg0, arg1)
+	ret0, _ := ret[0].([]db.WebhookSubscription)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateEntry indicates an expected call of CreateEntry.
-func (mr *MockRepositoryMockRecorder) CreateEntry(arg0, arg1 interface{}) *gomock.Call {
+// ListWebhookSubscriptions indicates an expected call of ListWebhookSubscriptions.
+func (mr *MockRepositoryMockRecorder) ListWebhookSubscriptions(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockRepository)(nil).CreateEntry), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookSubscriptions", reflect.TypeOf((*MockRepository)(nil).ListWebhookSubscriptions), arg0, arg1)
 }
 
-// CreateTransfer mocks base method.
-func (m *MockRepository) CreateTransfer(arg0 context.Context, arg1 db.CreateTransferParams) (db.Transfer, error) {
+// LockAccounts mocks base method.
+func (m *MockRepository) LockAccounts(arg0 context.Context, arg1 []int64) ([]db.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransfer", arg0, arg1)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "LockAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateTransfer indicates an expected call of CreateTransfer.
-func (mr *MockRepositoryMockRecorder) CreateTransfer(arg0, arg1 interface{}) *gomock.Call {
+// LockAccounts indicates an expected call of LockAccounts.
+func (mr *MockRepositoryMockRecorder) LockAccounts(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockRepository)(nil).CreateTransfer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAccounts", reflect.TypeOf((*MockRepository)(nil).LockAccounts), arg0, arg1)
 }
 
-// CreateUser mocks base method.
-func (m *MockRepository) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
+// LockAuditChain mocks base method.
+func (m *MockRepository) LockAuditChain(arg0 context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
-	ret0, _ := ret[0].(db.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "LockAuditChain", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockRepositoryMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call {
+// LockAuditChain indicates an expected call of LockAuditChain.
+func (mr *MockRepositoryMockRecorder) LockAuditChain(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAuditChain", reflect.TypeOf((*MockRepository)(nil).LockAuditChain), arg0)
 }
 
-// DeleteAccount mocks base method.
-func (m *MockRepository) DeleteAccount(arg0 context.Context, arg1 int64) error {
+// LockOutboxConsumer mocks base method.
+func (m *MockRepository) LockOutboxConsumer(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
+	ret := m.ctrl.Call(m, "LockOutboxConsumer", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockRepositoryMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+// LockOutboxConsumer indicates an expected call of LockOutboxConsumer.
+func (mr *MockRepositoryMockRecorder) LockOutboxConsumer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockRepository)(nil).DeleteAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockOutboxConsumer", reflect.TypeOf((*MockRepository)(nil).LockOutboxConsumer), arg0, arg1)
 }
 
-// GetAccount mocks base method.
-func (m *MockRepository) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+// MarkUserEmailVerified mocks base method.
+func (m *MockRepository) MarkUserEmailVerified(arg0 context.Context, arg1 db.MarkUserEmailVerifiedParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "MarkUserEmailVerified", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccount indicates an expected call of GetAccount.
-func (mr *MockRepositoryMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+// MarkUserEmailVerified indicates an expected call of MarkUserEmailVerified.
+func (mr *MockRepositoryMockRecorder) MarkUserEmailVerified(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepository)(nil).GetAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkUserEmailVerified", reflect.TypeOf((*MockRepository)(nil).MarkUserEmailVerified), arg0, arg1)
 }
 
-// GetAccountForUpdate mocks base method.
-func (m *MockRepository) GetAccountForUpdate(arg0 context.Context, arg1 int64) (db.Account, error) {
+// NotifyOutboxEvent mocks base method.
+func (m *MockRepository) NotifyOutboxEvent(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountForUpdate", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "NotifyOutboxEvent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
-func (mr *MockRepositoryMockRecorder) GetAccountForUpdate(arg0, arg1 interface{}) *gomock.Call {
+// NotifyOutboxEvent indicates an expected call of NotifyOutboxEvent.
+func (mr *MockRepositoryMockRecorder) NotifyOutboxEvent(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockRepository)(nil).GetAccountForUpdate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyOutboxEvent", reflect.TypeOf((*MockRepository)(nil).NotifyOutboxEvent), arg0, arg1)
 }
 
-// GetEntry mocks base method.
-func (m *MockRepository) GetEntry(arg0 context.Context, arg1 int64) (db.Entry, error) {
+// OutboxConsumerTx mocks base method.
+func (m *MockRepository) OutboxConsumerTx(arg0 context.Context, arg1 string, arg2 func(db.Querier) error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEntry", arg0, arg1)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "OutboxConsumerTx", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OutboxConsumerTx indicates an expected call of OutboxConsumerTx.
+func (mr *MockRepositoryMockRecorder) OutboxConsumerTx(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OutboxConsumerTx", reflect.TypeOf((*MockRepository)(nil).OutboxConsumerTx), arg0, arg1, arg2)
+}
+
+// QuoteTransferFee mocks base method.
+func (m *MockRepository) QuoteTransferFee(arg0 context.Context, arg1 db.Account, arg2 int64) (db.TransferFee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuoteTransferFee", arg0, arg1, arg2)
+	ret0, _ := ret[0].(db.TransferFee)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetEntry indicates an expected call of GetEntry.
-func (mr *MockRepositoryMockRecorder) GetEntry(arg0, arg1 interface{}) *gomock.Call {
+// QuoteTransferFee indicates an expected call of QuoteTransferFee.
+func (mr *MockRepositoryMockRecorder) QuoteTransferFee(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockRepository)(nil).GetEntry), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuoteTransferFee", reflect.TypeOf((*MockRepository)(nil).QuoteTransferFee), arg0, arg1, arg2)
 }
 
-// GetTransfer mocks base method.
-func (m *MockRepository) GetTransfer(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+// RecordWebhookDeliveryAttempt mocks base method.
+func (m *MockRepository) RecordWebhookDeliveryAttempt(arg0 context.Context, arg1 db.RecordWebhookDeliveryAttemptParams) (db.WebhookDelivery, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransfer", arg0, arg1)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "RecordWebhookDeliveryAttempt", arg0, arg1)
+	ret0, _ := ret[0].(db.WebhookDelivery)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransfer indicates an expected call of GetTransfer.
-func (mr *MockRepositoryMockRecorder) GetTransfer(arg0, arg1 interface{}) *gomock.Call {
+// RecordWebhookDeliveryAttempt indicates an expected call of RecordWebhookDeliveryAttempt.
+func (mr *MockRepositoryMockRecorder) RecordWebhookDeliveryAttempt(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockRepository)(nil).GetTransfer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookDeliveryAttempt", reflect.TypeOf((*MockRepository)(nil).RecordWebhookDeliveryAttempt), arg0, arg1)
 }
 
-// GetUser mocks base method.
-func (m *MockRepository) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
+// ResetPasswordTx mocks base method.
+func (m *MockRepository) ResetPasswordTx(arg0 context.Context, arg1 db.ResetPasswordTxParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret := m.ctrl.Call(m, "ResetPasswordTx", arg0, arg1)
 	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockRepositoryMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockRepositoryMockRecorder) ResetPasswordTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockRepository)(nil).ResetPasswordTx), arg0, arg1)
 }
 
-// ListAccounts mocks base method.
-func (m *MockRepository) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+// ResetSecondFactorAttempts mocks base method.
+func (m *MockRepository) ResetSecondFactorAttempts(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
-	ret0, _ := ret[0].([]db.Account)
+	ret := m.ctrl.Call(m, "ResetSecondFactorAttempts", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetSecondFactorAttempts indicates an expected call of ResetSecondFactorAttempts.
+func (mr *MockRepositoryMockRecorder) ResetSecondFactorAttempts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetSecondFactorAttempts", reflect.TypeOf((*MockRepository)(nil).ResetSecondFactorAttempts), arg0, arg1)
+}
+
+// RetryWebhookDelivery mocks base method.
+func (m *MockRepository) RetryWebhookDelivery(arg0 context.Context, arg1 int64) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(db.WebhookDelivery)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListAccounts indicates an expected call of ListAccounts.
-func (mr *MockRepositoryMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+// RetryWebhookDelivery indicates an expected call of RetryWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) RetryWebhookDelivery(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockRepository)(nil).ListAccounts), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).RetryWebhookDelivery), arg0, arg1)
 }
 
-// ListEntries mocks base method.
-func (m *MockRepository) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+// SetOutboxConsumerOffset mocks base method.
+func (m *MockRepository) SetOutboxConsumerOffset(arg0 context.Context, arg1 db.SetOutboxConsumerOffsetParams) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
-	ret0, _ := ret[0].([]db.Entry)
+	ret := m.ctrl.Call(m, "SetOutboxConsumerOffset", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOutboxConsumerOffset indicates an expected call of SetOutboxConsumerOffset.
+func (mr *MockRepositoryMockRecorder) SetOutboxConsumerOffset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOutboxConsumerOffset", reflect.TypeOf((*MockRepository)(nil).SetOutboxConsumerOffset), arg0, arg1)
+}
+
+// TransferAccountOwnershipTx mocks base method.
+func (m *MockRepository) TransferAccountOwnershipTx(arg0 context.Context, arg1 db.TransferAccountOwnershipTxParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferAccountOwnershipTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListEntries indicates an expected call of ListEntries.
-func (mr *MockRepositoryMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+// TransferAccountOwnershipTx indicates an expected call of TransferAccountOwnershipTx.
+func (mr *MockRepositoryMockRecorder) TransferAccountOwnershipTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockRepository)(nil).ListEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferAccountOwnershipTx", reflect.TypeOf((*MockRepository)(nil).TransferAccountOwnershipTx), arg0, arg1)
 }
 
-// ListTransfers mocks base method.
-func (m *MockRepository) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+// TransferBatchTx mocks base method.
+func (m *MockRepository) TransferBatchTx(arg0 context.Context, arg1 db.TransferBatchTxParams) (db.TransferBatchTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "TransferBatchTx", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferBatchTxResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListTransfers indicates an expected call of ListTransfers.
-func (mr *MockRepositoryMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+// TransferBatchTx indicates an expected call of TransferBatchTx.
+func (mr *MockRepositoryMockRecorder) TransferBatchTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockRepository)(nil).ListTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferBatchTx", reflect.TypeOf((*MockRepository)(nil).TransferBatchTx), arg0, arg1)
 }
 
 // TransferTx mocks base method.
@@ -274,6 +1725,51 @@ func (mr *MockRepositoryMockRecorder) UpdateAccountBalance(arg0, arg1 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountBalance", reflect.TypeOf((*MockRepository)(nil).UpdateAccountBalance), arg0, arg1)
 }
 
+// UpdateAccountInterestRate mocks base method.
+func (m *MockRepository) UpdateAccountInterestRate(arg0 context.Context, arg1 db.UpdateAccountInterestRateParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountInterestRate", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountInterestRate indicates an expected call of UpdateAccountInterestRate.
+func (mr *MockRepositoryMockRecorder) UpdateAccountInterestRate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountInterestRate", reflect.TypeOf((*MockRepository)(nil).UpdateAccountInterestRate), arg0, arg1)
+}
+
+// UpdateAccountMemberRole mocks base method.
+func (m *MockRepository) UpdateAccountMemberRole(arg0 context.Context, arg1 db.UpdateAccountMemberRoleParams) (db.AccountMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountMemberRole", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountMemberRole indicates an expected call of UpdateAccountMemberRole.
+func (mr *MockRepositoryMockRecorder) UpdateAccountMemberRole(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountMemberRole", reflect.TypeOf((*MockRepository)(nil).UpdateAccountMemberRole), arg0, arg1)
+}
+
+// UpdateAccountNickname mocks base method.
+func (m *MockRepository) UpdateAccountNickname(arg0 context.Context, arg1 db.UpdateAccountNicknameParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountNickname", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountNickname indicates an expected call of UpdateAccountNickname.
+func (mr *MockRepositoryMockRecorder) UpdateAccountNickname(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountNickname", reflect.TypeOf((*MockRepository)(nil).UpdateAccountNickname), arg0, arg1)
+}
+
 // UpdateAccountOwner mocks base method.
 func (m *MockRepository) UpdateAccountOwner(arg0 context.Context, arg1 db.UpdateAccountOwnerParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -288,3 +1784,183 @@ func (mr *MockRepositoryMockRecorder) UpdateAccountOwner(arg0, arg1 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountOwner", reflect.TypeOf((*MockRepository)(nil).UpdateAccountOwner), arg0, arg1)
 }
+
+// UpdateBeneficiary mocks base method.
+func (m *MockRepository) UpdateBeneficiary(arg0 context.Context, arg1 db.UpdateBeneficiaryParams) (db.Beneficiary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBeneficiary", arg0, arg1)
+	ret0, _ := ret[0].(db.Beneficiary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateBeneficiary indicates an expected call of UpdateBeneficiary.
+func (mr *MockRepositoryMockRecorder) UpdateBeneficiary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBeneficiary", reflect.TypeOf((*MockRepository)(nil).UpdateBeneficiary), arg0, arg1)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockRepository) UpdateUserPassword(arg0 context.Context, arg1 db.UpdateUserPasswordParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockRepositoryMockRecorder) UpdateUserPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockRepository)(nil).UpdateUserPassword), arg0, arg1)
+}
+
+// UpdateUserProfile mocks base method.
+func (m *MockRepository) UpdateUserProfile(arg0 context.Context, arg1 db.UpdateUserProfileParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserProfile", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserProfile indicates an expected call of UpdateUserProfile.
+func (mr *MockRepositoryMockRecorder) UpdateUserProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserProfile", reflect.TypeOf((*MockRepository)(nil).UpdateUserProfile), arg0, arg1)
+}
+
+// UpsertFeeRule mocks base method.
+func (m *MockRepository) UpsertFeeRule(arg0 context.Context, arg1 db.UpsertFeeRuleParams) (db.FeeRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertFeeRule", arg0, arg1)
+	ret0, _ := ret[0].(db.FeeRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertFeeRule indicates an expected call of UpsertFeeRule.
+func (mr *MockRepositoryMockRecorder) UpsertFeeRule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertFeeRule", reflect.TypeOf((*MockRepository)(nil).UpsertFeeRule), arg0, arg1)
+}
+
+// UpsertInterestRate mocks base method.
+func (m *MockRepository) UpsertInterestRate(arg0 context.Context, arg1 db.UpsertInterestRateParams) (db.InterestRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertInterestRate", arg0, arg1)
+	ret0, _ := ret[0].(db.InterestRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertInterestRate indicates an expected call of UpsertInterestRate.
+func (mr *MockRepositoryMockRecorder) UpsertInterestRate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertInterestRate", reflect.TypeOf((*MockRepository)(nil).UpsertInterestRate), arg0, arg1)
+}
+
+// UpsertPendingTOTP mocks base method.
+func (m *MockRepository) UpsertPendingTOTP(arg0 context.Context, arg1 db.UpsertPendingTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertPendingTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertPendingTOTP indicates an expected call of UpsertPendingTOTP.
+func (mr *MockRepositoryMockRecorder) UpsertPendingTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertPendingTOTP", reflect.TypeOf((*MockRepository)(nil).UpsertPendingTOTP), arg0, arg1)
+}
+
+// UseEmailVerificationToken mocks base method.
+func (m *MockRepository) UseEmailVerificationToken(arg0 context.Context, arg1 string) (db.EmailVerificationToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseEmailVerificationToken", arg0, arg1)
+	ret0, _ := ret[0].(db.EmailVerificationToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseEmailVerificationToken indicates an expected call of UseEmailVerificationToken.
+func (mr *MockRepositoryMockRecorder) UseEmailVerificationToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseEmailVerificationToken", reflect.TypeOf((*MockRepository)(nil).UseEmailVerificationToken), arg0, arg1)
+}
+
+// UsePasswordResetToken mocks base method.
+func (m *MockRepository) UsePasswordResetToken(arg0 context.Context, arg1 string) (db.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordResetToken", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordResetToken indicates an expected call of UsePasswordResetToken.
+func (mr *MockRepositoryMockRecorder) UsePasswordResetToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordResetToken", reflect.TypeOf((*MockRepository)(nil).UsePasswordResetToken), arg0, arg1)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepository) UseRecoveryCode(arg0 context.Context, arg1 int64) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryMockRecorder) UseRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepository)(nil).UseRecoveryCode), arg0, arg1)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockRepository) UseTOTPStep(arg0 context.Context, arg1 db.UseTOTPStepParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", arg0, arg1)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockRepositoryMockRecorder) UseTOTPStep(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockRepository)(nil).UseTOTPStep), arg0, arg1)
+}
+
+// VerifyAuditChain mocks base method.
+func (m *MockRepository) VerifyAuditChain(arg0 context.Context) (db.AuditChainReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditChain", arg0)
+	ret0, _ := ret[0].(db.AuditChainReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditChain indicates an expected call of VerifyAuditChain.
+func (mr *MockRepositoryMockRecorder) VerifyAuditChain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditChain", reflect.TypeOf((*MockRepository)(nil).VerifyAuditChain), arg0)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockRepository) VerifyEmailTx(arg0 context.Context, arg1 db.VerifyEmailTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockRepositoryMockRecorder) VerifyEmailTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockRepository)(nil).VerifyEmailTx), arg0, arg1)
+}
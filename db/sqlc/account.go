@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+// types of accounts users can open, they only differ in how they are presented and which interest rate applies
+const (
+	AccountTypeChecking = "checking"
+	AccountTypeSavings  = "savings"
+)
+
+// types of the system accounts of the bank, there is one of each per currency
+const (
+	AccountTypeInterestExpense = "interest-expense" // interest is paid from it
+	AccountTypeFeeRevenue      = "fee-revenue"      // transfer fees are posted to it
+)
+
+// ErrAccountClosed is returned when an account of a transfer was closed before the transfer locked it
+var ErrAccountClosed = errors.New("account is closed")
+
+// SystemUsername owns the internal accounts of the bank. it isnt alphanumeric, so nobody can sign up with it
+const SystemUsername = "_system"
+
+// systemAccount returns the system account of the given type in the currency, it is opened when it is needed for the
+// first time. the balance of an interest expense account goes negative by the total amount of interest paid
+func systemAccount(ctx context.Context, q *Queries, accountType, currency string) (Account, error) {
+	acc, err := q.GetSystemAccount(ctx, GetSystemAccountParams{Type: accountType, Currency: currency})
+	if err != sql.ErrNoRows {
+		return acc, err
+	}
+
+	number, err := library.NewAccountNumber()
+	if err != nil {
+		return acc, err
+	}
+
+	// concurrent transactions in a new currency could both end up here, the unique index on the type and currency
+	// makes one of them fail and roll back instead of opening a second account
+	return q.CreateAccount(ctx, CreateAccountParams{
+		Owner:    SystemUsername,
+		Balance:  0,
+		Currency: currency,
+		Nickname: strings.ReplaceAll(accountType, "-", " "),
+		Type:     accountType,
+		Number:   number,
+	})
+}
+
+type CloseAccountTxParams struct {
+	AccountID int64     `json:"accountID"`
+	Audit     AuditMeta `json:"audit"`
+}
+
+// CloseAccountTx closes an open account with a balance of 0 and writes the account.closed event. it returns
+// sql.ErrNoRows if the account is already closed or not empty anymore
+func (repo *SQLRepository) CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (Account, error) {
+	var acc Account
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		before, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		acc, err = q.CloseAccount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionAccountClosed,
+			ResourceType: AuditResourceAccount,
+			ResourceID:   strconv.FormatInt(acc.ID, 10),
+			Before:       before,
+			After:        acc,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = appendOutboxEvent(ctx, q, EventAccountClosed, acc.ID, AccountEvent{
+			Number:   acc.Number,
+			Currency: acc.Currency,
+			Type:     acc.Type,
+			ClosedAt: acc.ClosedAt.Time,
+		})
+		return err
+	})
+
+	return acc, err
+}
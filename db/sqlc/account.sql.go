@@ -5,13 +5,16 @@ package db
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
 `
 
 type AddAccountBalanceParams struct {
@@ -28,28 +31,83 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
+	)
+	return i, err
+}
+
+const closeAccount = `-- name: CloseAccount :one
+UPDATE accounts
+SET closed_at = now()
+WHERE id = $1 AND closed_at IS NULL AND balance = 0
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
+`
+
+func (q *Queries) CloseAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, closeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
 
+const countOpenAccounts = `-- name: CountOpenAccounts :one
+SELECT count(*) FROM accounts
+WHERE owner = $1 AND closed_at IS NULL
+`
+
+func (q *Queries) CountOpenAccounts(ctx context.Context, owner string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOpenAccounts, owner)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createAccount = `-- name: CreateAccount :one
 INSERT INTO accounts (
 	owner,
 	balance,
-	currency
+	currency,
+	nickname,
+	type,
+	number
 ) VALUES (
-	$1, $2, $3
-) RETURNING id, owner, balance, currency, created_at
+	$1, $2, $3, $4, $5, $6
+) RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
 `
 
 type CreateAccountParams struct {
 	Owner    string `json:"owner"`
 	Balance  int64  `json:"balance"`
 	Currency string `json:"currency"`
+	Nickname string `json:"nickname"`
+	Type     string `json:"type"`
+	Number   string `json:"number"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
-	row := q.db.QueryRowContext(ctx, createAccount, arg.Owner, arg.Balance, arg.Currency)
+	row := q.db.QueryRowContext(ctx, createAccount,
+		arg.Owner,
+		arg.Balance,
+		arg.Currency,
+		arg.Nickname,
+		arg.Type,
+		arg.Number,
+	)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -57,6 +115,11 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
@@ -72,7 +135,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -85,12 +148,40 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
+	)
+	return i, err
+}
+
+const getAccountByNumber = `-- name: GetAccountByNumber :one
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
+WHERE number = $1 LIMIT 1
+`
+
+func (q *Queries) GetAccountByNumber(ctx context.Context, number string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountByNumber, number)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -104,12 +195,45 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
+	)
+	return i, err
+}
+
+const getSystemAccount = `-- name: GetSystemAccount :one
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
+WHERE owner = '_system' AND type = $1 AND currency = $2 LIMIT 1
+`
+
+type GetSystemAccountParams struct {
+	Type     string `json:"type"`
+	Currency string `json:"currency"`
+}
+
+func (q *Queries) GetSystemAccount(ctx context.Context, arg GetSystemAccountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getSystemAccount, arg.Type, arg.Currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
 WHERE owner = $1
 ORDER BY id
 LIMIT $2
@@ -137,6 +261,151 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.ClosedAt,
+			&i.Nickname,
+			&i.Type,
+			&i.Number,
+			&i.InterestRateBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMemberAccounts = `-- name: ListMemberAccounts :many
+SELECT accounts.id, accounts.owner, accounts.balance, accounts.currency, accounts.created_at, accounts.closed_at, accounts.nickname, accounts.type, accounts.number, accounts.interest_rate_bps FROM accounts
+JOIN account_members ON account_members.account_id = accounts.id
+WHERE account_members.username = $1
+  AND ($2::varchar IS NULL OR accounts.currency = $2)
+  AND ($3::varchar IS NULL OR accounts.type = $3)
+ORDER BY accounts.id
+LIMIT $4
+OFFSET $5
+`
+
+type ListMemberAccountsParams struct {
+	Username string         `json:"username"`
+	Currency sql.NullString `json:"currency"`
+	Type     sql.NullString `json:"type"`
+	Limit    int32          `json:"limit"`
+	Offset   int32          `json:"offset"`
+}
+
+func (q *Queries) ListMemberAccounts(ctx context.Context, arg ListMemberAccountsParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listMemberAccounts,
+		arg.Username,
+		arg.Currency,
+		arg.Type,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.ClosedAt,
+			&i.Nickname,
+			&i.Type,
+			&i.Number,
+			&i.InterestRateBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOwnerAccounts = `-- name: ListOwnerAccounts :many
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
+WHERE owner = $1
+ORDER BY id
+`
+
+func (q *Queries) ListOwnerAccounts(ctx context.Context, owner string) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listOwnerAccounts, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.ClosedAt,
+			&i.Nickname,
+			&i.Type,
+			&i.Number,
+			&i.InterestRateBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAccounts = `-- name: LockAccounts :many
+SELECT id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps FROM accounts
+WHERE id = ANY($1::bigint[])
+ORDER BY id DESC
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) LockAccounts(ctx context.Context, ids []int64) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, lockAccounts, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.ClosedAt,
+			&i.Nickname,
+			&i.Type,
+			&i.Number,
+			&i.InterestRateBps,
 		); err != nil {
 			return nil, err
 		}
@@ -155,7 +424,7 @@ const updateAccountBalance = `-- name: UpdateAccountBalance :one
 UPDATE accounts 
 SET balance = $2
 WHERE  id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
 `
 
 type UpdateAccountBalanceParams struct {
@@ -172,6 +441,71 @@ func (q *Queries) UpdateAccountBalance(ctx context.Context, arg UpdateAccountBal
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
+	)
+	return i, err
+}
+
+const updateAccountInterestRate = `-- name: UpdateAccountInterestRate :one
+UPDATE accounts
+SET interest_rate_bps = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
+`
+
+type UpdateAccountInterestRateParams struct {
+	ID              int64         `json:"id"`
+	InterestRateBps sql.NullInt32 `json:"interestRateBps"`
+}
+
+func (q *Queries) UpdateAccountInterestRate(ctx context.Context, arg UpdateAccountInterestRateParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountInterestRate, arg.ID, arg.InterestRateBps)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
+	)
+	return i, err
+}
+
+const updateAccountNickname = `-- name: UpdateAccountNickname :one
+UPDATE accounts
+SET nickname = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
+`
+
+type UpdateAccountNicknameParams struct {
+	ID       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+}
+
+func (q *Queries) UpdateAccountNickname(ctx context.Context, arg UpdateAccountNicknameParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountNickname, arg.ID, arg.Nickname)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
@@ -180,7 +514,7 @@ const updateAccountOwner = `-- name: UpdateAccountOwner :one
 UPDATE accounts 
 SET owner = $2
 WHERE  id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, closed_at, nickname, type, number, interest_rate_bps
 `
 
 type UpdateAccountOwnerParams struct {
@@ -197,6 +531,11 @@ func (q *Queries) UpdateAccountOwner(ctx context.Context, arg UpdateAccountOwner
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.ClosedAt,
+		&i.Nickname,
+		&i.Type,
+		&i.Number,
+		&i.InterestRateBps,
 	)
 	return i, err
 }
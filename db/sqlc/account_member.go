@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+// roles a member of an account can have
+const (
+	AccountRoleOwner   = "owner"
+	AccountRoleCoOwner = "co-owner"
+	AccountRoleViewer  = "viewer"
+)
+
+// ErrInvalidInvitation is returned for invitations that dont exist, arent addressed to the caller, have expired or have already been accepted
+var ErrInvalidInvitation = errors.New("invitation is invalid or has expired")
+
+// ErrNotAccountMember is returned when ownership should be transferred to a user that isnt a member of the account
+var ErrNotAccountMember = errors.New("user is not a member of the account")
+
+// CreateAccountTx creates an account and makes its owner the first member. a public account number is generated
+// unless the caller already set one
+func (repo *SQLRepository) CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	var acc Account
+
+	if arg.Number == "" {
+		// 16 random digits make a collision practically impossible, should one still happen the unique
+		// constraint rejects the insert instead of handing out a number twice
+		number, err := library.NewAccountNumber()
+		if err != nil {
+			return acc, err
+		}
+		arg.Number = number
+	}
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		var err error
+		acc, err = q.CreateAccount(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateAccountMember(ctx, CreateAccountMemberParams{
+			AccountID: acc.ID,
+			Username:  acc.Owner,
+			Role:      AccountRoleOwner,
+		})
+		return err
+	})
+
+	return acc, err
+}
+
+type AcceptAccountInvitationTxParams struct {
+	InvitationID int64     `json:"invitationID"`
+	Invitee      string    `json:"invitee"`
+	Audit        AuditMeta `json:"audit"`
+}
+
+// AcceptAccountInvitationTx redeems a pending invitation and adds the invitee as member with the invited role
+func (repo *SQLRepository) AcceptAccountInvitationTx(ctx context.Context, arg AcceptAccountInvitationTxParams) (AccountMember, error) {
+	var member AccountMember
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		invitation, err := q.AcceptAccountInvitation(ctx, AcceptAccountInvitationParams{ID: arg.InvitationID, Invitee: arg.Invitee})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidInvitation
+			}
+			return err
+		}
+
+		member, err = q.CreateAccountMember(ctx, CreateAccountMemberParams{
+			AccountID: invitation.AccountID,
+			Username:  invitation.Invitee,
+			Role:      invitation.Role,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionAccountMemberJoined,
+			ResourceType: AuditResourceAccount,
+			ResourceID:   strconv.FormatInt(member.AccountID, 10),
+			After:        member,
+		})
+		return err
+	})
+
+	return member, err
+}
+
+type TransferAccountOwnershipTxParams struct {
+	AccountID int64     `json:"accountID"`
+	NewOwner  string    `json:"newOwner"`
+	Audit     AuditMeta `json:"audit"`
+}
+
+// TransferAccountOwnershipTx hands an account over to another member. the previous owner stays a co-owner,
+// the new owner can remove them afterwards
+func (repo *SQLRepository) TransferAccountOwnershipTx(ctx context.Context, arg TransferAccountOwnershipTxParams) (Account, error) {
+	var acc Account
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		before, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.GetAccountMember(ctx, GetAccountMemberParams{AccountID: before.ID, Username: arg.NewOwner})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotAccountMember
+			}
+			return err
+		}
+
+		acc, err = q.UpdateAccountOwner(ctx, UpdateAccountOwnerParams{ID: before.ID, Owner: arg.NewOwner})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.UpdateAccountMemberRole(ctx, UpdateAccountMemberRoleParams{AccountID: acc.ID, Username: before.Owner, Role: AccountRoleCoOwner})
+		if err != nil {
+			return err
+		}
+		_, err = q.UpdateAccountMemberRole(ctx, UpdateAccountMemberRoleParams{AccountID: acc.ID, Username: acc.Owner, Role: AccountRoleOwner})
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionAccountOwnershipTransferred,
+			ResourceType: AuditResourceAccount,
+			ResourceID:   strconv.FormatInt(acc.ID, 10),
+			Before:       before,
+			After:        acc,
+		})
+		return err
+	})
+
+	return acc, err
+}
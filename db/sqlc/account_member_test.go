@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestCreateAccountTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+
+	acc, err := repo.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: library.RandomCurrency(), Type: AccountTypeChecking})
+	require.NoError(t, err)
+
+	member, err := testQueries.GetAccountMember(context.Background(), GetAccountMemberParams{AccountID: acc.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, AccountRoleOwner, member.Role)
+
+	accs, err := testQueries.ListMemberAccounts(context.Background(), ListMemberAccountsParams{Username: user.Username, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, accs, 1)
+	require.Equal(t, acc.ID, accs[0].ID)
+}
+
+func TestAcceptAccountInvitationTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomMemberAccount(t)
+	invitee := createRandomUser(t)
+
+	invitation := createRandomInvitation(t, acc, invitee, time.Hour)
+
+	// only the invitee can accept
+	_, err := repo.AcceptAccountInvitationTx(context.Background(), AcceptAccountInvitationTxParams{InvitationID: invitation.ID, Invitee: acc.Owner})
+	require.ErrorIs(t, err, ErrInvalidInvitation)
+
+	member, err := repo.AcceptAccountInvitationTx(context.Background(), AcceptAccountInvitationTxParams{InvitationID: invitation.ID, Invitee: invitee.Username})
+	require.NoError(t, err)
+	require.Equal(t, AccountRoleViewer, member.Role)
+
+	// invitations are single use
+	_, err = repo.AcceptAccountInvitationTx(context.Background(), AcceptAccountInvitationTxParams{InvitationID: invitation.ID, Invitee: invitee.Username})
+	require.ErrorIs(t, err, ErrInvalidInvitation)
+}
+
+func TestAcceptAccountInvitationTxExpired(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomMemberAccount(t)
+	invitee := createRandomUser(t)
+
+	invitation := createRandomInvitation(t, acc, invitee, -time.Minute)
+
+	_, err := repo.AcceptAccountInvitationTx(context.Background(), AcceptAccountInvitationTxParams{InvitationID: invitation.ID, Invitee: invitee.Username})
+	require.ErrorIs(t, err, ErrInvalidInvitation)
+}
+
+func TestListPendingAccountInvitations(t *testing.T) {
+	acc := createRandomMemberAccount(t)
+	invitee := createRandomUser(t)
+
+	createRandomInvitation(t, acc, invitee, -time.Minute)
+	pending := createRandomInvitation(t, createRandomMemberAccount(t), invitee, time.Hour)
+
+	invitations, err := testQueries.ListPendingAccountInvitations(context.Background(), invitee.Username)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	require.Equal(t, pending.ID, invitations[0].ID)
+	require.NotEmpty(t, invitations[0].AccountNumber)
+}
+
+func TestTransferAccountOwnershipTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomMemberAccount(t)
+	newOwner := createRandomUser(t)
+
+	_, err := repo.TransferAccountOwnershipTx(context.Background(), TransferAccountOwnershipTxParams{AccountID: acc.ID, NewOwner: newOwner.Username})
+	require.ErrorIs(t, err, ErrNotAccountMember)
+
+	_, err = testQueries.CreateAccountMember(context.Background(), CreateAccountMemberParams{AccountID: acc.ID, Username: newOwner.Username, Role: AccountRoleCoOwner})
+	require.NoError(t, err)
+
+	transferred, err := repo.TransferAccountOwnershipTx(context.Background(), TransferAccountOwnershipTxParams{AccountID: acc.ID, NewOwner: newOwner.Username})
+	require.NoError(t, err)
+	require.Equal(t, newOwner.Username, transferred.Owner)
+
+	members, err := testQueries.ListAccountMembers(context.Background(), acc.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	roles := map[string]string{}
+	for _, m := range members {
+		roles[m.Username] = m.Role
+	}
+	require.Equal(t, AccountRoleOwner, roles[newOwner.Username])
+	require.Equal(t, AccountRoleCoOwner, roles[acc.Owner])
+}
+
+func TestListMemberAccountsFilters(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+
+	// several accounts in the same currency are allowed
+	for _, typ := range []string{AccountTypeChecking, AccountTypeSavings, AccountTypeSavings} {
+		_, err := repo.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: "EUR", Type: typ, Nickname: typ})
+		require.NoError(t, err)
+	}
+	_, err := repo.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: "USD", Type: AccountTypeSavings})
+	require.NoError(t, err)
+
+	list := func(currency, typ string) []Account {
+		accs, err := testQueries.ListMemberAccounts(context.Background(), ListMemberAccountsParams{
+			Username: user.Username,
+			Currency: sql.NullString{String: currency, Valid: currency != ""},
+			Type:     sql.NullString{String: typ, Valid: typ != ""},
+			Limit:    10,
+		})
+		require.NoError(t, err)
+		return accs
+	}
+
+	require.Len(t, list("", ""), 4)
+	require.Len(t, list("EUR", ""), 3)
+	require.Len(t, list("", AccountTypeSavings), 3)
+	require.Len(t, list("EUR", AccountTypeSavings), 2)
+	require.Empty(t, list("CAD", ""))
+}
+
+func createRandomMemberAccount(t *testing.T) Account {
+	user := createRandomUser(t)
+
+	acc, err := NewRepository(testDB).CreateAccountTx(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  library.RandomBalance(),
+		Currency: library.RandomCurrency(),
+		Type:     AccountTypeChecking,
+	})
+	require.NoError(t, err)
+	require.True(t, library.IsValidAccountNumber(acc.Number))
+
+	return acc
+}
+
+func createRandomInvitation(t *testing.T, acc Account, invitee User, validFor time.Duration) AccountInvitation {
+	invitation, err := testQueries.CreateAccountInvitation(context.Background(), CreateAccountInvitationParams{
+		AccountID: acc.ID,
+		Inviter:   acc.Owner,
+		Invitee:   invitee.Username,
+		Role:      AccountRoleViewer,
+		ExpiresAt: time.Now().Add(validFor),
+	})
+	require.NoError(t, err)
+
+	return invitation
+}
@@ -82,19 +82,56 @@ func TestListAccounts(t *testing.T) {
 		require.Equal(t, lastAcc.Owner, acc.Owner)
 	}
 }
+func TestCloseAccount(t *testing.T) {
+	acc := createRandomAccount(t)
+
+	// accounts with money on them cant be closed
+	_, err := testQueries.CloseAccount(context.Background(), acc.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: acc.ID, Balance: 0})
+	require.NoError(t, err)
+
+	closed, err := testQueries.CloseAccount(context.Background(), acc.ID)
+	require.NoError(t, err)
+	require.True(t, closed.ClosedAt.Valid)
+
+	open, err := testQueries.CountOpenAccounts(context.Background(), acc.Owner)
+	require.NoError(t, err)
+	require.Zero(t, open)
+}
+
+func TestGetAccountByNumber(t *testing.T) {
+	acc := createRandomAccount(t)
+
+	res, err := testQueries.GetAccountByNumber(context.Background(), acc.Number)
+	require.NoError(t, err)
+	require.Equal(t, acc, res)
+
+	_, err = testQueries.GetAccountByNumber(context.Background(), "GO00"+acc.Number[4:])
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
 func createRandomAccount(t *testing.T) Account {
 	user := createRandomUser(t)
 
+	number, err := library.NewAccountNumber()
+	require.NoError(t, err)
+
 	arg := CreateAccountParams{
 		Owner:    user.Username,
 		Balance:  library.RandomBalance(),
 		Currency: library.RandomCurrency(),
+		Nickname: library.RandomOwner(),
+		Type:     AccountTypeChecking,
+		Number:   number,
 	}
 
 	acc, err := testQueries.CreateAccount(context.Background(), arg)
 	require.Nil(t, err)
 
 	require.Equal(t, arg.Owner, acc.Owner)
+	require.Equal(t, arg.Number, acc.Number)
 	require.Equal(t, arg.Balance, acc.Balance)
 	require.Equal(t, arg.Currency, acc.Currency)
 
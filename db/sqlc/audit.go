@@ -0,0 +1,279 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// actions that are being recorded in the audit log
+const (
+	AuditActionUserCreated     = "user.created"
+	AuditActionLoginSucceeded  = "user.login_succeeded"
+	AuditActionLoginFailed     = "user.login_failed"
+	AuditActionAccountCreated  = "account.created"
+	AuditActionTransferCreated = "transfer.created"
+
+	AuditActionPasswordChanged        = "user.password_changed"
+	AuditActionPasswordResetRequested = "user.password_reset_requested"
+	AuditActionPasswordReset          = "user.password_reset"
+	AuditActionEmailVerified          = "user.email_verified"
+	AuditActionTOTPEnabled            = "user.totp_enabled"
+	AuditActionTOTPDisabled           = "user.totp_disabled"
+	AuditActionRecoveryCodeUsed       = "user.recovery_code_used"
+	AuditActionUserUpdated            = "user.updated"
+	AuditActionUserDeleted            = "user.deleted"
+	AuditActionAccountClosed          = "account.closed"
+
+	AuditActionAccountMemberInvited        = "account.member_invited"
+	AuditActionAccountMemberJoined         = "account.member_joined"
+	AuditActionAccountMemberRemoved        = "account.member_removed"
+	AuditActionAccountOwnershipTransferred = "account.ownership_transferred"
+
+	AuditActionBeneficiaryCreated = "beneficiary.created"
+	AuditActionBeneficiaryUpdated = "beneficiary.updated"
+	AuditActionBeneficiaryDeleted = "beneficiary.deleted"
+
+	AuditActionInterestRateChanged        = "interest.rate_changed"
+	AuditActionAccountInterestRateChanged = "account.interest_rate_changed"
+	AuditActionInterestCapitalized        = "interest.capitalized"
+
+	AuditActionFeeRuleChanged = "fee.rule_changed"
+	AuditActionFeeRuleDeleted = "fee.rule_deleted"
+
+	AuditActionTransferBatchCreated = "transfer.batch_created"
+
+	AuditActionWebhookCreated = "webhook.created"
+	AuditActionWebhookDeleted = "webhook.deleted"
+)
+
+// resource types an audit event can refer to
+const (
+	AuditResourceUser          = "user"
+	AuditResourceAccount       = "account"
+	AuditResourceTransfer      = "transfer"
+	AuditResourceBeneficiary   = "beneficiary"
+	AuditResourceInterestRate  = "interest_rate"
+	AuditResourceFeeRule       = "fee_rule"
+	AuditResourceTransferBatch = "transfer_batch"
+	AuditResourceWebhook       = "webhook"
+)
+
+// events without an authenticated caller are being recorded as done by this actor
+const auditSystemActor = "system"
+
+// how many rows are being loaded at once when walking the hash chain
+const auditVerifyBatchSize = 500
+
+// AuditMeta describes who triggered an audited change and where the request came from
+type AuditMeta struct {
+	Actor     string `json:"actor"`
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+	RequestID string `json:"requestID"`
+}
+
+type AppendAuditEventParams struct {
+	Meta         AuditMeta   `json:"meta"`
+	Action       string      `json:"action"`
+	ResourceType string      `json:"resourceType"`
+	ResourceID   string      `json:"resourceID"`
+	Before       interface{} `json:"before"` // state of the resource before the change, marshalled to json. nil if it didnt exist
+	After        interface{} `json:"after"`  // state of the resource after the change, marshalled to json
+}
+
+type AuditChainReport struct {
+	Checked    int64 `json:"checked"`
+	Valid      bool  `json:"valid"`
+	BrokenAtID int64 `json:"brokenAtID,omitempty"` // id of the first row whose hash doesnt match its contents or predecessor
+}
+
+// AppendAuditEvent appends a single event to the audit log in its own transaction
+func (repo *SQLRepository) AppendAuditEvent(ctx context.Context, arg AppendAuditEventParams) (AuditEvent, error) {
+	var event AuditEvent
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		var err error
+		event, err = appendAuditEvent(ctx, q, arg)
+		return err
+	})
+
+	return event, err
+}
+
+// appendAuditEvent links a new event to the last one in the chain. it has to be called with a transaction-bound
+// Queries so the row is only persisted if the audited change itself gets commited
+func appendAuditEvent(ctx context.Context, q *Queries, arg AppendAuditEventParams) (AuditEvent, error) {
+	before, err := canonicalJSON(arg.Before)
+	if err != nil {
+		return AuditEvent{}, fmt.Errorf("cannot encode audit before-state: %w", err)
+	}
+	after, err := canonicalJSON(arg.After)
+	if err != nil {
+		return AuditEvent{}, fmt.Errorf("cannot encode audit after-state: %w", err)
+	}
+
+	actor := arg.Meta.Actor
+	if actor == "" {
+		actor = auditSystemActor
+	}
+
+	// the advisory lock is held until the transaction ends, so no two events can ever claim the same predecessor.
+	// it is always taken after any row locks of the audited change, which keeps the lock order consistent
+	if err = q.LockAuditChain(ctx); err != nil {
+		return AuditEvent{}, err
+	}
+
+	prevHash := ""
+	last, err := q.GetLastAuditEvent(ctx)
+	if err == nil {
+		prevHash = last.Hash
+	} else if err != sql.ErrNoRows {
+		return AuditEvent{}, err
+	}
+
+	params := CreateAuditEventParams{
+		Actor:        actor,
+		Action:       arg.Action,
+		ResourceType: arg.ResourceType,
+		ResourceID:   arg.ResourceID,
+		IpAddress:    arg.Meta.IPAddress,
+		UserAgent:    arg.Meta.UserAgent,
+		RequestID:    arg.Meta.RequestID,
+		Before:       before,
+		After:        after,
+		PrevHash:     prevHash,
+		// postgres only stores microseconds, truncate now so the hash can be recomputed from the stored row
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	params.Hash = hashAuditEvent(params)
+
+	return q.CreateAuditEvent(ctx, params)
+}
+
+// VerifyAuditChain walks the whole audit log and recomputes every hash. the chain is valid if every row
+// references the hash of its predecessor and its own hash still matches its contents
+func (repo *SQLRepository) VerifyAuditChain(ctx context.Context) (AuditChainReport, error) {
+	report := AuditChainReport{Valid: true}
+	prevHash := ""
+	var lastID int64
+
+	for {
+		events, err := repo.ListAuditEventsAfter(ctx, ListAuditEventsAfterParams{ID: lastID, Limit: auditVerifyBatchSize})
+		if err != nil {
+			return AuditChainReport{}, err
+		}
+
+		for _, event := range events {
+			report.Checked++
+
+			hash, err := recomputeAuditHash(event)
+			if err != nil {
+				return AuditChainReport{}, err
+			}
+			if event.PrevHash != prevHash || event.Hash != hash {
+				report.Valid = false
+				report.BrokenAtID = event.ID
+				return report, nil
+			}
+
+			prevHash = event.Hash
+			lastID = event.ID
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			return report, nil
+		}
+	}
+}
+
+// recomputeAuditHash computes the hash of a stored event. jsonb doesnt preserve key order or whitespace,
+// so the before/after states have to be canonicalized again before hashing
+func recomputeAuditHash(event AuditEvent) (string, error) {
+	before, err := canonicalJSON(event.Before)
+	if err != nil {
+		return "", err
+	}
+	after, err := canonicalJSON(event.After)
+	if err != nil {
+		return "", err
+	}
+
+	return hashAuditEvent(CreateAuditEventParams{
+		Actor:        event.Actor,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		IpAddress:    event.IpAddress,
+		UserAgent:    event.UserAgent,
+		RequestID:    event.RequestID,
+		Before:       before,
+		After:        after,
+		PrevHash:     event.PrevHash,
+		CreatedAt:    event.CreatedAt,
+	}), nil
+}
+
+// hashAuditEvent returns the hex encoded sha256 of all fields of the event, including the hash of its predecessor.
+// every field is length-prefixed so that moving bytes from one field into the next changes the hash
+func hashAuditEvent(arg CreateAuditEventParams) string {
+	fields := []string{
+		arg.PrevHash,
+		arg.Actor,
+		arg.Action,
+		arg.ResourceType,
+		arg.ResourceID,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.RequestID,
+		string(arg.Before),
+		string(arg.After),
+		arg.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	h := sha256.New()
+	for _, f := range fields {
+		h.Write([]byte(strconv.Itoa(len(f))))
+		h.Write([]byte{':'})
+		h.Write([]byte(f))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalJSON marshals v into compact json with sorted object keys. raw json input is being re-encoded as well
+func canonicalJSON(v interface{}) (json.RawMessage, error) {
+	var raw []byte
+	switch val := v.(type) {
+	case json.RawMessage:
+		raw = val
+	case []byte:
+		raw = val
+	default:
+		var err error
+		raw, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(raw) == 0 {
+		return json.RawMessage("null"), nil
+	}
+
+	// decoding into an interface{} and encoding again sorts map keys. UseNumber keeps large int64 amounts exact
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(generic)
+}
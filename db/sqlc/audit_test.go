@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestAppendAuditEvent(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	first := appendRandomAuditEvent(t, repo)
+	second := appendRandomAuditEvent(t, repo)
+
+	// every event references the hash of the event before it
+	require.Equal(t, first.Hash, second.PrevHash)
+	require.NotEqual(t, first.Hash, second.Hash)
+}
+
+func TestAuditEventsAreAppendOnly(t *testing.T) {
+	event := appendRandomAuditEvent(t, NewRepository(testDB))
+
+	_, err := testDB.Exec("UPDATE audit_events SET actor = $1 WHERE id = $2", library.RandomOwner(), event.ID)
+	require.Error(t, err)
+
+	_, err = testDB.Exec("DELETE FROM audit_events WHERE id = $1", event.ID)
+	require.Error(t, err)
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	repo := NewRepository(testDB)
+	for i := 0; i < 3; i++ {
+		appendRandomAuditEvent(t, repo)
+	}
+
+	report, err := repo.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Valid)
+	require.GreaterOrEqual(t, report.Checked, int64(3))
+	require.Zero(t, report.BrokenAtID)
+}
+
+func TestTransferTxIsAudited(t *testing.T) {
+	repo := NewRepository(testDB)
+	accA := createRandomAccount(t)
+	accB := createRandomAccount(t)
+
+	meta := AuditMeta{Actor: accA.Owner, IPAddress: "127.0.0.1", RequestID: library.RandomString(16)}
+	result, err := repo.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: accA.ID,
+		ToAccountID:   accB.ID,
+		Amount:        10,
+		Audit:         meta,
+	})
+	require.NoError(t, err)
+
+	events, err := repo.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Action:      AuditActionTransferCreated,
+		ResourceID:  strconv.FormatInt(result.Transfer.ID, 10),
+		CreatedTo:   result.Transfer.CreatedAt.AddDate(0, 0, 1),
+		LimitCount:  5,
+		OffsetCount: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, meta.Actor, events[0].Actor)
+	require.Equal(t, meta.RequestID, events[0].RequestID)
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	// jsonb reorders keys and adds whitespace, both versions have to result in the same hash input
+	a, err := canonicalJSON(json.RawMessage(`{"b": 1, "a": {"d": 9007199254740993, "c": "x"}}`))
+	require.NoError(t, err)
+	b, err := canonicalJSON(map[string]interface{}{"a": map[string]interface{}{"c": "x", "d": int64(9007199254740993)}, "b": 1})
+	require.NoError(t, err)
+
+	require.Equal(t, string(a), string(b))
+	require.Equal(t, `{"a":{"c":"x","d":9007199254740993},"b":1}`, string(a))
+
+	null, err := canonicalJSON(nil)
+	require.NoError(t, err)
+	require.Equal(t, "null", string(null))
+}
+
+func appendRandomAuditEvent(t *testing.T, repo Repository) AuditEvent {
+	user := createRandomUser(t)
+
+	arg := AppendAuditEventParams{
+		Meta:         AuditMeta{Actor: user.Username, IPAddress: "127.0.0.1", UserAgent: "test", RequestID: library.RandomString(16)},
+		Action:       AuditActionUserCreated,
+		ResourceType: AuditResourceUser,
+		ResourceID:   user.Username,
+		After:        map[string]string{"username": user.Username, "email": user.Email},
+	}
+
+	event, err := repo.AppendAuditEvent(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, event.ID)
+	require.Equal(t, arg.Meta.Actor, event.Actor)
+	require.Equal(t, arg.Action, event.Action)
+	require.Equal(t, arg.Meta.RequestID, event.RequestID)
+	require.Len(t, event.Hash, 64)
+
+	// the stored row has to hash to the same value as the one computed before inserting it
+	hash, err := recomputeAuditHash(event)
+	require.NoError(t, err)
+	require.Equal(t, event.Hash, hash)
+
+	return event
+}
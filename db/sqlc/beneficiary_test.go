@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestCreateBeneficiary(t *testing.T) {
+	beneficiary := createRandomBeneficiary(t, createRandomUser(t))
+
+	// the same account cant be saved twice by a user
+	_, err := testQueries.CreateBeneficiary(context.Background(), CreateBeneficiaryParams{
+		Owner:         beneficiary.Owner,
+		Name:          library.RandomOwner(),
+		AccountNumber: beneficiary.AccountNumber,
+		Currency:      beneficiary.Currency,
+	})
+	require.Error(t, err)
+	require.Equal(t, "unique_violation", err.(*pq.Error).Code.Name())
+}
+
+func TestListBeneficiaries(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomBeneficiary(t, user)
+	}
+	createRandomBeneficiary(t, createRandomUser(t))
+
+	beneficiaries, err := testQueries.ListBeneficiaries(context.Background(), ListBeneficiariesParams{Owner: user.Username, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, beneficiaries, 3)
+	for _, b := range beneficiaries {
+		require.Equal(t, user.Username, b.Owner)
+	}
+}
+
+func TestUpdateBeneficiary(t *testing.T) {
+	beneficiary := createRandomBeneficiary(t, createRandomUser(t))
+
+	updated, err := testQueries.UpdateBeneficiary(context.Background(), UpdateBeneficiaryParams{
+		ID:   beneficiary.ID,
+		Note: sql.NullString{String: "rent", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, beneficiary.Name, updated.Name)
+	require.Equal(t, "rent", updated.Note)
+	require.Equal(t, beneficiary.AccountNumber, updated.AccountNumber)
+}
+
+func TestDeleteBeneficiary(t *testing.T) {
+	beneficiary := createRandomBeneficiary(t, createRandomUser(t))
+
+	err := testQueries.DeleteBeneficiary(context.Background(), beneficiary.ID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetBeneficiary(context.Background(), beneficiary.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func createRandomBeneficiary(t *testing.T, owner User) Beneficiary {
+	acc := createRandomAccount(t)
+
+	arg := CreateBeneficiaryParams{
+		Owner:         owner.Username,
+		Name:          library.RandomOwner(),
+		AccountNumber: acc.Number,
+		Currency:      acc.Currency,
+		Note:          library.RandomString(12),
+	}
+
+	beneficiary, err := testQueries.CreateBeneficiary(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, beneficiary.ID)
+	require.Equal(t, arg.Owner, beneficiary.Owner)
+	require.Equal(t, arg.Name, beneficiary.Name)
+	require.Equal(t, arg.AccountNumber, beneficiary.AccountNumber)
+	require.Equal(t, arg.Currency, beneficiary.Currency)
+	require.Equal(t, arg.Note, beneficiary.Note)
+
+	got, err := testQueries.GetBeneficiary(context.Background(), beneficiary.ID)
+	require.NoError(t, err)
+	require.Equal(t, beneficiary, got)
+
+	return beneficiary
+}
@@ -1,30 +1,51 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	config "github.com/maxeth/go-bank-app/config"
 )
 
-type TestDB struct {
-	DB *sql.DB
-}
-
-var (
-	testDB *sql.DB
+const (
+	pingTimeout = 5 * time.Second
+	maxBackoff  = 30 * time.Second
 )
 
-func GetOrCreate(c config.Config) (*TestDB, error) {
-	if testDB == nil || testDB.Ping() != nil {
-		// not connected yet, either testDB var is nil or Ping() returned an error
-		var err error
-		testDB, err = sql.Open(c.DBDriver, c.DBString)
-		if err != nil {
-			return nil, err
-		}
-		return &TestDB{DB: testDB}, nil
+// Open creates a new connection pool configured from c and makes sure the database is reachable.
+// the startup ping is being retried with exponential backoff, e.g. while the db container is still booting.
+// every caller gets its own independent pool and is responsible for closing it
+func Open(c config.Config) (*sql.DB, error) {
+	conn, err := sql.Open(c.DBDriver, c.DBString)
+	if err != nil {
+		return nil, err
 	}
 
-	// already have a connection
-	return &TestDB{DB: testDB}, nil
+	conn.SetMaxOpenConns(c.DBMaxOpenConns)
+	conn.SetMaxIdleConns(c.DBMaxIdleConns)
+	conn.SetConnMaxLifetime(c.DBConnMaxLifetime)
+	conn.SetConnMaxIdleTime(c.DBConnMaxIdleTime)
+
+	backoff := c.DBConnectBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = conn.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+
+		if attempt >= c.DBConnectAttempts {
+			conn.Close()
+			return nil, fmt.Errorf("cannot reach db after %d attempts: %w", attempt, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
 }
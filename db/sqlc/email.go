@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvalidVerificationToken is returned for verification tokens that dont exist, have expired, have already been used
+// or were sent to an address the user doesnt have anymore
+var ErrInvalidVerificationToken = errors.New("email verification token is invalid or has expired")
+
+type VerifyEmailTxParams struct {
+	TokenHash string    `json:"tokenHash"`
+	Audit     AuditMeta `json:"audit"` // the actor defaults to the owner of the token, verifying doesnt require being logged in
+}
+
+// VerifyEmailTx redeems a verification token and marks the email it was sent to as verified
+func (repo *SQLRepository) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (User, error) {
+	var user User
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		token, err := q.UseEmailVerificationToken(ctx, arg.TokenHash)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+
+		// only matches while the user still has the address the token was sent to
+		user, err = q.MarkUserEmailVerified(ctx, MarkUserEmailVerifiedParams{
+			Username: token.Username,
+			Email:    token.Email,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+
+		meta := arg.Audit
+		if meta.Actor == "" {
+			meta.Actor = user.Username
+		}
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         meta,
+			Action:       AuditActionEmailVerified,
+			ResourceType: AuditResourceUser,
+			ResourceID:   user.Username,
+			After:        emailVerification{Email: user.Email, EmailVerifiedAt: user.EmailVerifiedAt.Time},
+		})
+		return err
+	})
+
+	return user, err
+}
+
+type emailVerification struct {
+	Email           string    `json:"email"`
+	EmailVerifiedAt time.Time `json:"emailVerifiedAt"`
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestVerifyEmailTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	require.False(t, user.EmailVerifiedAt.Valid)
+
+	token := createRandomVerificationToken(t, user, user.Email, time.Hour)
+
+	verified, err := repo.VerifyEmailTx(context.Background(), VerifyEmailTxParams{TokenHash: token.TokenHash})
+	require.NoError(t, err)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+
+	// single use
+	_, err = repo.VerifyEmailTx(context.Background(), VerifyEmailTxParams{TokenHash: token.TokenHash})
+	require.ErrorIs(t, err, ErrInvalidVerificationToken)
+}
+
+func TestVerifyEmailTxOutdatedAddress(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+
+	// the token was sent to an address the user doesnt have anymore
+	token := createRandomVerificationToken(t, user, library.RandomString(10)+"@example.com", time.Hour)
+
+	_, err := repo.VerifyEmailTx(context.Background(), VerifyEmailTxParams{TokenHash: token.TokenHash})
+	require.ErrorIs(t, err, ErrInvalidVerificationToken)
+}
+
+func TestVerifyEmailTxExpired(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	token := createRandomVerificationToken(t, user, user.Email, -time.Minute)
+
+	_, err := repo.VerifyEmailTx(context.Background(), VerifyEmailTxParams{TokenHash: token.TokenHash})
+	require.ErrorIs(t, err, ErrInvalidVerificationToken)
+}
+
+func createRandomVerificationToken(t *testing.T, user User, email string, validFor time.Duration) EmailVerificationToken {
+	token, err := testQueries.CreateEmailVerificationToken(context.Background(), CreateEmailVerificationTokenParams{
+		TokenHash: library.RandomString(64),
+		Username:  user.Username,
+		Email:     email,
+		ExpiresAt: time.Now().Add(validFor),
+	})
+	require.NoError(t, err)
+
+	return token
+}
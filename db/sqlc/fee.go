@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"time"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+// ErrInsufficientFunds is returned when the sender cant cover the amount and the fee of a transfer
+var ErrInsufficientFunds = errors.New("sender does not have enough balance to cover the amount and the fee")
+
+// ErrQuoteChanged is returned when the fee of a quoted transfer changed since the quote was made
+var ErrQuoteChanged = errors.New("the fee of the transfer changed since it was quoted")
+
+// TransferFee describes the fee charged for a transfer
+type TransferFee struct {
+	RuleID       int64 `json:"ruleID"` // 0 if no rule applies to the sending account
+	Amount       int64 `json:"amount"`
+	Waived       bool  `json:"waived"`       // the transfer is covered by the free allowance of the month
+	FreeLeft     int64 `json:"freeLeft"`     // free transfers left this month after this one
+	Entry        Entry `json:"entry"`        // charged to the sender, empty without fee
+	RevenueEntry Entry `json:"revenueEntry"` // posted to the fee revenue account, empty without fee
+}
+
+// Calculate returns the fee of a transfer of the given amount: the flat part plus the percentage, rounded half up
+// to whole minor units and kept between the minimum and maximum of the rule
+func (rule FeeRule) Calculate(amount int64) int64 {
+	// amount * bps can overflow for big amounts, the division result itself always fits
+	pct := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(amount), big.NewInt(int64(rule.PercentageBps))),
+		big.NewInt(library.BasisPointsPerUnit),
+	)
+	whole, rest := library.SplitMinorUnits(pct)
+	if rest.Cmp(big.NewRat(1, 2)) >= 0 {
+		whole++
+	}
+
+	fee := rule.FlatAmount + whole
+	if fee < rule.MinAmount {
+		fee = rule.MinAmount
+	}
+	if rule.MaxAmount.Valid && fee > rule.MaxAmount.Int64 {
+		fee = rule.MaxAmount.Int64
+	}
+
+	return fee
+}
+
+// QuoteTransferFee returns the fee a transfer from the account would be charged right now, without charging it.
+// the fee charged by TransferTx can still differ if another transfer uses up the free allowance in the meantime
+func (repo *SQLRepository) QuoteTransferFee(ctx context.Context, from Account, amount int64) (TransferFee, error) {
+	return evaluateTransferFee(ctx, repo.Queries, from, amount, time.Now())
+}
+
+// evaluateTransferFee picks the rule for the currency and type of the sending account, a rule for the exact type
+// wins over the rule for all types, and applies the free allowance of the calendar month (UTC)
+func evaluateTransferFee(ctx context.Context, q *Queries, from Account, amount int64, now time.Time) (TransferFee, error) {
+	var fee TransferFee
+
+	rule, err := q.GetApplicableFeeRule(ctx, GetApplicableFeeRuleParams{Currency: from.Currency, AccountType: from.Type})
+	if err == sql.ErrNoRows {
+		return fee, nil
+	}
+	if err != nil {
+		return fee, err
+	}
+	fee.RuleID = rule.ID
+
+	now = now.UTC()
+	sent, err := q.CountAccountTransfersSince(ctx, CountAccountTransfersSinceParams{
+		FromAccountID: from.ID,
+		CreatedAt:     time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		return fee, err
+	}
+
+	if sent < int64(rule.FreePerMonth) {
+		fee.Waived = true
+		fee.FreeLeft = int64(rule.FreePerMonth) - sent - 1
+		return fee, nil
+	}
+
+	fee.Amount = rule.Calculate(amount)
+	return fee, nil
+}
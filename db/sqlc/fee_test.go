@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	library "github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeRuleCalculate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rule   FeeRule
+		amount int64
+		fee    int64
+	}{
+		{name: "Flat", rule: FeeRule{FlatAmount: 25}, amount: 1000, fee: 25},
+		{name: "Percentage", rule: FeeRule{PercentageBps: 150}, amount: 1000, fee: 15},
+		{name: "RoundsHalfUp", rule: FeeRule{PercentageBps: 50}, amount: 101, fee: 1},
+		{name: "RoundsDown", rule: FeeRule{PercentageBps: 50}, amount: 99, fee: 0},
+		{name: "FlatAndPercentage", rule: FeeRule{FlatAmount: 10, PercentageBps: 100}, amount: 1000, fee: 20},
+		{name: "Min", rule: FeeRule{PercentageBps: 100, MinAmount: 30}, amount: 1000, fee: 30},
+		{name: "Max", rule: FeeRule{PercentageBps: 100, MaxAmount: sql.NullInt64{Int64: 50, Valid: true}}, amount: 100000, fee: 50},
+		{name: "NoOverflow", rule: FeeRule{PercentageBps: 10000}, amount: 1 << 62, fee: 1 << 62},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.fee, tc.rule.Calculate(tc.amount))
+		})
+	}
+}
+
+func createRandomSavingsAccount(t *testing.T, currency string) Account {
+	user := createRandomUser(t)
+
+	number, err := library.NewAccountNumber()
+	require.NoError(t, err)
+
+	acc, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  1000000,
+		Currency: currency,
+		Type:     AccountTypeSavings,
+		Number:   number,
+	})
+	require.NoError(t, err)
+
+	return acc
+}
+
+func TestTransferTxFee(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	// only savings accounts are charged, the accounts of the other tests stay free
+	rule, err := testQueries.UpsertFeeRule(context.Background(), UpsertFeeRuleParams{
+		Currency:      "EUR",
+		AccountType:   AccountTypeSavings,
+		FlatAmount:    10,
+		PercentageBps: 100,
+		MaxAmount:     sql.NullInt64{Int64: 50, Valid: true},
+		FreePerMonth:  1,
+	})
+	require.NoError(t, err)
+	defer testQueries.DeleteFeeRule(context.Background(), rule.ID)
+
+	from := createRandomSavingsAccount(t, "EUR")
+	to := createRandomAccount(t)
+
+	quote, err := repo.QuoteTransferFee(context.Background(), from, 1000)
+	require.NoError(t, err)
+	require.Equal(t, TransferFee{RuleID: rule.ID, Waived: true}, quote)
+
+	// the first transfer of the month is free
+	result, err := repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 1000})
+	require.NoError(t, err)
+	require.Equal(t, rule.ID, result.Fee.RuleID)
+	require.True(t, result.Fee.Waived)
+	require.Zero(t, result.Fee.Amount)
+	require.Zero(t, result.Transfer.Fee)
+	require.Equal(t, from.Balance-1000, result.FromAccount.Balance)
+
+	// the second one is charged 10 + 1% on top of the amount
+	result, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 1000})
+	require.NoError(t, err)
+	require.False(t, result.Fee.Waived)
+	require.Equal(t, int64(20), result.Fee.Amount)
+	require.Equal(t, int64(20), result.Transfer.Fee)
+	require.Equal(t, from.Balance-2000-20, result.FromAccount.Balance)
+	require.Equal(t, to.Balance+2000, result.ToAccount.Balance)
+	require.Equal(t, int64(-1000), result.FromEntry.Amount)
+	require.Equal(t, int64(-20), result.Fee.Entry.Amount)
+	require.Equal(t, from.ID, result.Fee.Entry.AccountID)
+	require.Equal(t, int64(20), result.Fee.RevenueEntry.Amount)
+
+	revenue, err := testQueries.GetAccount(context.Background(), result.Fee.RevenueEntry.AccountID)
+	require.NoError(t, err)
+	require.Equal(t, AccountTypeFeeRevenue, revenue.Type)
+	require.Equal(t, SystemUsername, revenue.Owner)
+	require.Equal(t, "EUR", revenue.Currency)
+
+	// big transfers are capped at the maximum
+	result, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 100000})
+	require.NoError(t, err)
+	require.Equal(t, int64(50), result.Fee.Amount)
+	balance := result.FromAccount.Balance
+
+	// the balance covers the amount but not the fee, nothing is written
+	_, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: balance})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	from, err = testQueries.GetAccount(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Equal(t, balance, from.Balance)
+}
+
+func TestTransferTxQuote(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	from := createRandomAccount(t)
+	to := createRandomAccount(t)
+	quoteID := uuid.New().String()
+
+	// the fee changed since the quote was made, nothing is written
+	_, err := repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10, QuoteID: quoteID, QuotedFee: 5})
+	require.ErrorIs(t, err, ErrQuoteChanged)
+
+	result, err := repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10, QuoteID: quoteID})
+	require.NoError(t, err)
+	require.Equal(t, sql.NullString{String: quoteID, Valid: true}, result.Transfer.QuoteID)
+	require.Equal(t, from.Balance-10, result.FromAccount.Balance)
+
+	// every quote can only be executed once
+	_, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10, QuoteID: quoteID})
+	require.Error(t, err)
+
+	from, err = testQueries.GetAccount(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.FromAccount.Balance, from.Balance)
+}
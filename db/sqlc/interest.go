@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+// ErrInvalidInterestPeriod is returned when a capitalization period doesnt start on the first day of a month
+var ErrInvalidInterestPeriod = errors.New("interest period has to start on the first day of a month")
+
+type CapitalizeInterestTxParams struct {
+	AccountID int64     `json:"accountID"`
+	Period    time.Time `json:"period"` // first day of the month whose accruals are being paid out
+	Audit     AuditMeta `json:"audit"`
+}
+
+type CapitalizeInterestTxResult struct {
+	Capitalization InterestCapitalization `json:"capitalization"`
+	Transfer       TransferTxResult       `json:"transfer"` // empty if less than one minor unit was accrued
+}
+
+// CapitalizeInterestTx pays out the interest an account accrued during a month. the exact sum of all daily accruals
+// plus the carry of the previous month is split into whole minor units, which are transferred from the interest
+// expense account of the currency, and the fraction that is carried into the next month.
+// every account and period can only be capitalized once, a second call fails on the primary key and changes nothing.
+// accounts that were closed before the end of the month forfeit their interest, it would reopen them otherwise
+func (repo *SQLRepository) CapitalizeInterestTx(ctx context.Context, arg CapitalizeInterestTxParams) (CapitalizeInterestTxResult, error) {
+	var result CapitalizeInterestTxResult
+
+	if arg.Period.Day() != 1 {
+		return result, ErrInvalidInterestPeriod
+	}
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		// locked for the whole transaction, so the account cant be closed between checking it and paying out
+		acc, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		accruals, err := q.ListInterestAccruals(ctx, ListInterestAccrualsParams{
+			AccountID:   arg.AccountID,
+			PeriodStart: arg.Period,
+			PeriodEnd:   arg.Period.AddDate(0, 1, 0),
+		})
+		if err != nil {
+			return err
+		}
+
+		total := new(big.Rat)
+		last, err := q.GetLastInterestCapitalization(ctx, GetLastInterestCapitalizationParams{AccountID: arg.AccountID, Period: arg.Period})
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			if _, ok := total.SetString(last.Carry); !ok {
+				return fmt.Errorf("invalid interest carry %q of account [%d]", last.Carry, arg.AccountID)
+			}
+		}
+
+		for _, accrual := range accruals {
+			total.Add(total, library.DailyInterest(accrual.Balance, accrual.AnnualRateBps, int(accrual.DaysInYear)))
+		}
+
+		amount, carry := library.SplitMinorUnits(total)
+		if acc.ClosedAt.Valid {
+			amount, carry = 0, new(big.Rat)
+		}
+
+		capitalization := CreateInterestCapitalizationParams{
+			AccountID: arg.AccountID,
+			Period:    arg.Period,
+			Amount:    amount,
+			Carry:     carry.RatString(),
+		}
+
+		if amount > 0 {
+			expense, err := systemAccount(ctx, q, AccountTypeInterestExpense, acc.Currency)
+			if err != nil {
+				return err
+			}
+
+			result.Transfer, err = postTransfer(ctx, q, TransferTxParams{
+				FromAccountID: expense.ID,
+				ToAccountID:   acc.ID,
+				Amount:        amount,
+				Description:   "Interest " + arg.Period.Format("January 2006"),
+				Reference:     "INTEREST " + arg.Period.Format("2006-01"),
+			}, TransferFee{})
+			if err != nil {
+				return err
+			}
+			capitalization.TransferID = sql.NullInt64{Int64: result.Transfer.Transfer.ID, Valid: true}
+		}
+
+		result.Capitalization, err = q.CreateInterestCapitalization(ctx, capitalization)
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionInterestCapitalized,
+			ResourceType: AuditResourceAccount,
+			ResourceID:   strconv.FormatInt(acc.ID, 10),
+			Before:       acc,
+			After:        result,
+		})
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"testing"
+	"time"
+
+	library "github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+// accrues interest for the given day and returns the accruals of the account in the month of the day
+func accrueInterestDay(t *testing.T, acc Account, day time.Time) []InterestAccrual {
+	_, err := testQueries.AccrueInterest(context.Background(), AccrueInterestParams{
+		AccrualDate: day,
+		DaysInYear:  int32(library.DaysInYear(day.Year())),
+	})
+	require.NoError(t, err)
+
+	period := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	accruals, err := testQueries.ListInterestAccruals(context.Background(), ListInterestAccrualsParams{
+		AccountID:   acc.ID,
+		PeriodStart: period,
+		PeriodEnd:   period.AddDate(0, 1, 0),
+	})
+	require.NoError(t, err)
+
+	return accruals
+}
+
+func TestUpsertInterestRate(t *testing.T) {
+	// a made up account type, so the rate doesnt apply to accounts of other tests
+	arg := UpsertInterestRateParams{AccountType: library.RandomString(8), Currency: library.RandomCurrency(), AnnualRateBps: 150}
+
+	rate, err := testQueries.UpsertInterestRate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.AnnualRateBps, rate.AnnualRateBps)
+
+	arg.AnnualRateBps = 300
+	_, err = testQueries.UpsertInterestRate(context.Background(), arg)
+	require.NoError(t, err)
+
+	rate, err = testQueries.GetInterestRate(context.Background(), GetInterestRateParams{AccountType: arg.AccountType, Currency: arg.Currency})
+	require.NoError(t, err)
+	require.Equal(t, int32(300), rate.AnnualRateBps)
+}
+
+func TestAccrueAndCapitalizeInterest(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomAccount(t)
+
+	acc, err := testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: acc.ID, Balance: 1000000})
+	require.NoError(t, err)
+	acc, err = testQueries.UpdateAccountInterestRate(context.Background(), UpdateAccountInterestRateParams{
+		ID:              acc.ID,
+		InterestRateBps: sql.NullInt32{Int32: 333, Valid: true},
+	})
+	require.NoError(t, err)
+
+	// accrue the first three days of next month, running every day twice
+	now := time.Now().UTC()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	var accruals []InterestAccrual
+	for i := 0; i < 3; i++ {
+		accrueInterestDay(t, acc, period.AddDate(0, 0, i))
+		accruals = accrueInterestDay(t, acc, period.AddDate(0, 0, i))
+	}
+	require.Len(t, accruals, 3)
+
+	expected := new(big.Rat)
+	for _, accrual := range accruals {
+		require.Equal(t, acc.Balance, accrual.Balance)
+		require.Equal(t, int32(333), accrual.AnnualRateBps)
+		expected.Add(expected, library.DailyInterest(acc.Balance, 333, library.DaysInYear(period.Year())))
+	}
+	amount, carry := library.SplitMinorUnits(expected)
+	require.Positive(t, amount)
+
+	pending, err := testQueries.ListPendingInterestCapitalizations(context.Background(), period.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.Contains(t, pendingAccounts(pending, period), acc.ID)
+
+	result, err := repo.CapitalizeInterestTx(context.Background(), CapitalizeInterestTxParams{AccountID: acc.ID, Period: period})
+	require.NoError(t, err)
+	require.Equal(t, amount, result.Capitalization.Amount)
+	require.Equal(t, carry.RatString(), result.Capitalization.Carry)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Capitalization.TransferID.Int64)
+
+	// paid from the system account of the currency
+	require.Equal(t, acc.Balance+amount, result.Transfer.ToAccount.Balance)
+	require.Equal(t, AccountTypeInterestExpense, result.Transfer.FromAccount.Type)
+	require.Equal(t, SystemUsername, result.Transfer.FromAccount.Owner)
+	require.Equal(t, acc.Currency, result.Transfer.FromAccount.Currency)
+	require.Equal(t, -amount, result.Transfer.FromEntry.Amount)
+	require.Equal(t, amount, result.Transfer.ToEntry.Amount)
+
+	// a month can only be paid once
+	_, err = repo.CapitalizeInterestTx(context.Background(), CapitalizeInterestTxParams{AccountID: acc.ID, Period: period})
+	require.Error(t, err)
+
+	acc, err = testQueries.GetAccount(context.Background(), acc.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer.ToAccount.Balance, acc.Balance)
+
+	pending, err = testQueries.ListPendingInterestCapitalizations(context.Background(), period.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.NotContains(t, pendingAccounts(pending, period), acc.ID)
+
+	// a paid month doesnt accrue anymore, the interest would never be paid out
+	accruals = accrueInterestDay(t, acc, period.AddDate(0, 0, 3))
+	require.Len(t, accruals, 3)
+
+	// the carry of the first month is paid with the next one
+	next := period.AddDate(0, 1, 0)
+	accruals = accrueInterestDay(t, acc, next)
+	require.Len(t, accruals, 1)
+
+	expected = new(big.Rat).Add(carry, library.DailyInterest(acc.Balance, 333, library.DaysInYear(next.Year())))
+	amount, carry = library.SplitMinorUnits(expected)
+
+	result, err = repo.CapitalizeInterestTx(context.Background(), CapitalizeInterestTxParams{AccountID: acc.ID, Period: next})
+	require.NoError(t, err)
+	require.Equal(t, amount, result.Capitalization.Amount)
+	require.Equal(t, carry.RatString(), result.Capitalization.Carry)
+}
+
+func TestAccrueInterestPastBalance(t *testing.T) {
+	acc := createRandomAccount(t)
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+
+	// opened before the day being caught up
+	_, err := testDB.Exec("UPDATE accounts SET created_at = $1 WHERE id = $2", yesterday.AddDate(0, 0, -7), acc.ID)
+	require.NoError(t, err)
+
+	acc, err = testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: acc.ID, Balance: 1000000})
+	require.NoError(t, err)
+	_, err = testQueries.UpdateAccountInterestRate(context.Background(), UpdateAccountInterestRateParams{
+		ID:              acc.ID,
+		InterestRateBps: sql.NullInt32{Int32: 250, Valid: true},
+	})
+	require.NoError(t, err)
+
+	// money that arrived today doesnt count for a catch-up run of yesterday
+	_, err = testQueries.CreateEntry(context.Background(), CreateEntryParams{AccountID: acc.ID, Amount: 500000})
+	require.NoError(t, err)
+	acc, err = testQueries.AddAccountBalance(context.Background(), AddAccountBalanceParams{ID: acc.ID, Amount: 500000})
+	require.NoError(t, err)
+
+	accruals := accrueInterestDay(t, acc, yesterday)
+	require.NotEmpty(t, accruals)
+	require.Equal(t, yesterday, accruals[len(accruals)-1].AccrualDate.UTC())
+	require.Equal(t, int64(1000000), accruals[len(accruals)-1].Balance)
+}
+
+func TestCapitalizeInterestInvalidPeriod(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomAccount(t)
+
+	_, err := repo.CapitalizeInterestTx(context.Background(), CapitalizeInterestTxParams{
+		AccountID: acc.ID,
+		Period:    time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC),
+	})
+	require.ErrorIs(t, err, ErrInvalidInterestPeriod)
+}
+
+func pendingAccounts(pending []ListPendingInterestCapitalizationsRow, period time.Time) []int64 {
+	var ids []int64
+	for _, p := range pending {
+		if p.Period.Equal(period) {
+			ids = append(ids, p.AccountID)
+		}
+	}
+	return ids
+}
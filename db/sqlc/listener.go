@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	config "github.com/maxeth/go-bank-app/config"
+)
+
+// channel the NotifyOutboxEvent query notifies with the account id of the event
+const outboxChannel = "outbox_events"
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	// idle connections are pinged regularly, otherwise a dead connection would go unnoticed until the next event
+	listenerPingInterval = 90 * time.Second
+)
+
+// ListenOutbox calls notify with the account id of every outbox event once the transaction that wrote it has been
+// commited, until the context is cancelled. it uses a dedicated connection outside of the pool. notifications sent
+// while the connection was lost are gone, so after every reconnect notify is called with 0 and listeners have to
+// check all their accounts
+func ListenOutbox(ctx context.Context, c config.Config, notify func(accountID int64)) error {
+	listener := pq.NewListener(c.DBString, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("outbox listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(outboxChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				notify(0)
+				continue
+			}
+			accountID, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				log.Printf("outbox listener: invalid notification %q", n.Extra)
+				continue
+			}
+			notify(accountID)
+		case <-time.After(listenerPingInterval):
+			if err := listener.Ping(); err != nil {
+				log.Printf("outbox listener: %v", err)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 
@@ -10,6 +11,7 @@ import (
 
 var (
 	testQueries *Queries
+	testDB      *sql.DB
 )
 
 func TestMain(m *testing.M) {
@@ -19,12 +21,12 @@ func TestMain(m *testing.M) {
 		panic("couldn't load config env file")
 	}
 
-	dbConn, err := GetOrCreate(c)
+	testDB, err = Open(c)
 	if err != nil {
 		panic("Cannot test because db connection fails")
 	}
 
-	testQueries = New(dbConn.DB)
+	testQueries = New(testDB)
 
 	os.Exit(m.Run())
 }
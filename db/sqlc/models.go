@@ -3,7 +3,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -12,6 +16,74 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"createdAt"`
+	// closed accounts keep their entries and transfers but cannot move money anymore
+	ClosedAt sql.NullTime `json:"closedAt"`
+	// user defined name to tell accounts in the same currency apart
+	Nickname string `json:"nickname"`
+	Type     string `json:"type"`
+	// public account number, the serial id is never exposed through the api
+	Number string `json:"number"`
+	// annual interest rate in basis points, overrides the rate of the account type and currency when set
+	InterestRateBps sql.NullInt32 `json:"interestRateBps"`
+}
+
+type AccountInvitation struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"accountID"`
+	Inviter   string    `json:"inviter"`
+	Invitee   string    `json:"invitee"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// null while the invitation is pending
+	AcceptedAt sql.NullTime `json:"acceptedAt"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
+
+type AccountMember struct {
+	AccountID int64  `json:"accountID"`
+	Username  string `json:"username"`
+	// owner and co-owner can move money, viewer can only read. accounts.owner always has the owner role
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AuditEvent struct {
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resourceType"`
+	ResourceID   string          `json:"resourceID"`
+	IpAddress    string          `json:"ipAddress"`
+	UserAgent    string          `json:"userAgent"`
+	RequestID    string          `json:"requestID"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	PrevHash     string          `json:"prevHash"`
+	// sha256 over prev_hash and the row contents
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Beneficiary struct {
+	ID int64 `json:"id"`
+	// user who saved the beneficiary, not the owner of the account
+	Owner string `json:"owner"`
+	// name the user gave the beneficiary, it is not checked against the account owner
+	Name          string    `json:"name"`
+	AccountNumber string    `json:"accountNumber"`
+	Currency      string    `json:"currency"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type EmailVerificationToken struct {
+	TokenHash string `json:"tokenHash"`
+	Username  string `json:"username"`
+	// the address the token was sent to, the token is worthless once the user changed it
+	Email     string       `json:"email"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	UsedAt    sql.NullTime `json:"usedAt"`
+	CreatedAt time.Time    `json:"createdAt"`
 }
 
 type Entry struct {
@@ -22,6 +94,81 @@ type Entry struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type FeeRule struct {
+	ID       int64  `json:"id"`
+	Currency string `json:"currency"`
+	// type of the sending account, empty for all types without a rule of their own
+	AccountType   string        `json:"accountType"`
+	FlatAmount    int64         `json:"flatAmount"`
+	PercentageBps int32         `json:"percentageBps"`
+	MinAmount     int64         `json:"minAmount"`
+	MaxAmount     sql.NullInt64 `json:"maxAmount"`
+	// amount of outgoing transfers per account and calendar month (UTC) that are free of charge
+	FreePerMonth int32     `json:"freePerMonth"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+type InterestAccrual struct {
+	AccountID   int64     `json:"accountID"`
+	AccrualDate time.Time `json:"accrualDate"`
+	// balance the interest of the day was calculated on, the interest itself is balance * annual_rate_bps / 10000 / days_in_year
+	Balance       int64     `json:"balance"`
+	AnnualRateBps int32     `json:"annualRateBps"`
+	DaysInYear    int32     `json:"daysInYear"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type InterestCapitalization struct {
+	AccountID int64 `json:"accountID"`
+	// first day of the month the interest was accrued in
+	Period time.Time `json:"period"`
+	Amount int64     `json:"amount"`
+	// fraction of a minor unit that was left over, as exact rational number. it is added to the next period
+	Carry      string        `json:"carry"`
+	TransferID sql.NullInt64 `json:"transferID"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+type InterestRate struct {
+	AccountType   string    `json:"accountType"`
+	Currency      string    `json:"currency"`
+	AnnualRateBps int32     `json:"annualRateBps"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type Outbox struct {
+	ID        int64  `json:"id"`
+	EventType string `json:"eventType"`
+	// the account the event happened to, events of the same account are published in id order
+	AccountID int64           `json:"accountID"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type OutboxConsumer struct {
+	Name string `json:"name"`
+	// id of the last outbox event the consumer has published, the relay continues after it
+	LastEventID int64     `json:"lastEventID"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type PasswordResetToken struct {
+	// sha256 of the token, the token itself is only ever sent to the user
+	TokenHash string       `json:"tokenHash"`
+	Username  string       `json:"username"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	UsedAt    sql.NullTime `json:"usedAt"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type RecoveryCode struct {
+	ID        int64        `json:"id"`
+	Username  string       `json:"username"`
+	CodeHash  string       `json:"codeHash"`
+	UsedAt    sql.NullTime `json:"usedAt"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"fromAccountID"`
@@ -29,6 +176,49 @@ type Transfer struct {
 	// Amount must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"createdAt"`
+	// free text shown to both parties
+	Description string `json:"description"`
+	// external reference, e.g. an invoice number
+	Reference string `json:"reference"`
+	// flat json object with string values set by the api client
+	Metadata json.RawMessage `json:"metadata"`
+	// charged to the sender on top of the amount and posted to the fee revenue account of the currency
+	Fee int64 `json:"fee"`
+	// id of the quote the transfer was executed from, every quote can only be executed once
+	QuoteID sql.NullString `json:"quoteID"`
+	// random id of the transfer that is shown outside the database instead of the sequential id
+	PublicID uuid.UUID `json:"publicID"`
+}
+
+type TransferBatch struct {
+	ID            int64  `json:"id"`
+	FromAccountID int64  `json:"fromAccountID"`
+	CreatedBy     string `json:"createdBy"`
+	// either all items are executed or none, otherwise every item is executed on its own
+	Atomic      bool         `json:"atomic"`
+	Status      string       `json:"status"`
+	ItemCount   int32        `json:"itemCount"`
+	Succeeded   int32        `json:"succeeded"`
+	Failed      int32        `json:"failed"`
+	TotalAmount int64        `json:"totalAmount"`
+	TotalFee    int64        `json:"totalFee"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	CompletedAt sql.NullTime `json:"completedAt"`
+}
+
+type TransferBatchItem struct {
+	BatchID int64 `json:"batchID"`
+	// position of the item in the request, starting at 1
+	Line        int32  `json:"line"`
+	ToAccountID int64  `json:"toAccountID"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	Reference   string `json:"reference"`
+	// skipped items were not executed because another item of an atomic batch failed
+	Status     string        `json:"status"`
+	Error      string        `json:"error"`
+	TransferID sql.NullInt64 `json:"transferID"`
+	Fee        int64         `json:"fee"`
 }
 
 type User struct {
@@ -38,4 +228,48 @@ type User struct {
 	Email             string    `json:"email"`
 	CreatedAt         time.Time `json:"createdAt"`
 	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+	Role              string    `json:"role"`
+	// null until the user proved to own the email
+	EmailVerifiedAt sql.NullTime `json:"emailVerifiedAt"`
+	// deleted users keep their username for the ledger, everything else is anonymized
+	DeletedAt sql.NullTime `json:"deletedAt"`
+}
+
+type UserTotp struct {
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+	// null while the enrollment hasnt been confirmed with a valid code
+	ConfirmedAt sql.NullTime `json:"confirmedAt"`
+	// time step of the last accepted code, codes of this or earlier steps cannot be replayed
+	LastUsedStep int64     `json:"lastUsedStep"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// second factor attempts since the last accepted code, every attempt is counted before the code is checked
+	FailedAttempts int32 `json:"failedAttempts"`
+	// time of the last counted attempt, once too many failed the user is locked out for a while after it
+	LastFailedAt sql.NullTime `json:"lastFailedAt"`
+}
+
+type WebhookDelivery struct {
+	ID             int64 `json:"id"`
+	SubscriptionID int64 `json:"subscriptionID"`
+	OutboxID       int64 `json:"outboxID"`
+	// dead deliveries failed too often and are only retried on request
+	Status   string `json:"status"`
+	Attempts int32  `json:"attempts"`
+	// moved ahead while a worker sends the delivery, so no other worker picks it up at the same time
+	NextAttemptAt  time.Time     `json:"nextAttemptAt"`
+	LastStatusCode sql.NullInt32 `json:"lastStatusCode"`
+	LastError      string        `json:"lastError"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	DeliveredAt    sql.NullTime  `json:"deliveredAt"`
+}
+
+type WebhookSubscription struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Url      string `json:"url"`
+	// key of the HMAC-SHA256 signature, it has to be stored in plain text to sign the deliveries
+	Secret     string    `json:"secret"`
+	EventTypes []string  `json:"eventTypes"`
+	CreatedAt  time.Time `json:"createdAt"`
 }
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// types of the events that are written to the outbox
+const (
+	EventTransferCreated  = "transfer.created"  // money left the account
+	EventTransferReceived = "transfer.received" // money arrived on the account
+	EventAccountClosed    = "account.closed"
+)
+
+// EventTypes lists every event type, webhooks can subscribe to any of them
+var EventTypes = []string{EventTransferCreated, EventTransferReceived, EventAccountClosed}
+
+// TransferEvent is the payload of transfer.created and transfer.received events. accounts are referred to by
+// their public numbers and the transfer by its random public id, like everywhere else outside the database
+type TransferEvent struct {
+	TransferID  uuid.UUID       `json:"transferID"`
+	FromAccount string          `json:"fromAccount"`
+	ToAccount   string          `json:"toAccount"`
+	Amount      int64           `json:"amount"`
+	Fee         int64           `json:"fee"` // paid by the sender on top of the amount
+	Currency    string          `json:"currency"`
+	Description string          `json:"description"`
+	Reference   string          `json:"reference"`
+	Metadata    json.RawMessage `json:"metadata"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Balance     int64           `json:"balance"` // balance of the account the event belongs to after the transfer
+}
+
+// AccountEvent is the payload of account.closed events
+type AccountEvent struct {
+	Number   string    `json:"number"`
+	Currency string    `json:"currency"`
+	Type     string    `json:"type"`
+	ClosedAt time.Time `json:"closedAt"`
+}
+
+// appendOutboxEvent writes an event about the account to the outbox and queues it for the webhooks of the
+// account members. like appendAuditEvent it has to be called with a transaction-bound Queries, so the event
+// only exists if the change itself gets commited
+func appendOutboxEvent(ctx context.Context, q *Queries, eventType string, accountID int64, payload interface{}) (Outbox, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Outbox{}, fmt.Errorf("cannot encode %s event: %w", eventType, err)
+	}
+
+	// the relay continues after the last id it has published, so ids have to be assigned in commit order. holding
+	// the audit chain lock until the transaction ends guarantees that, transactions that write events take it for
+	// their audit event anyway and using a single lock rules out deadlocks between the two
+	if err = q.LockAuditChain(ctx); err != nil {
+		return Outbox{}, err
+	}
+
+	event, err := q.CreateOutboxEvent(ctx, CreateOutboxEventParams{
+		EventType: eventType,
+		AccountID: accountID,
+		Payload:   data,
+	})
+	if err != nil {
+		return Outbox{}, err
+	}
+
+	// postgres holds notifications back until the transaction commits, listeners never see uncommited events
+	if err = q.NotifyOutboxEvent(ctx, accountID); err != nil {
+		return Outbox{}, err
+	}
+
+	_, err = q.CreateWebhookDeliveries(ctx, CreateWebhookDeliveriesParams{
+		OutboxID:  event.ID,
+		AccountID: accountID,
+		EventType: eventType,
+	})
+	return event, err
+}
+
+// appendTransferEvents writes the transfer.created event of the sender and the transfer.received event of the receiver
+func appendTransferEvents(ctx context.Context, q *Queries, result TransferTxResult) error {
+	event := TransferEvent{
+		TransferID:  result.Transfer.PublicID,
+		FromAccount: result.FromAccount.Number,
+		ToAccount:   result.ToAccount.Number,
+		Amount:      result.Transfer.Amount,
+		Fee:         result.Transfer.Fee,
+		Currency:    result.FromAccount.Currency,
+		Description: result.Transfer.Description,
+		Reference:   result.Transfer.Reference,
+		Metadata:    result.Transfer.Metadata,
+		CreatedAt:   result.Transfer.CreatedAt,
+	}
+
+	sent := event
+	sent.Balance = result.FromAccount.Balance
+	if _, err := appendOutboxEvent(ctx, q, EventTransferCreated, result.FromAccount.ID, sent); err != nil {
+		return err
+	}
+
+	received := event
+	received.Balance = result.ToAccount.Balance
+	_, err := appendOutboxEvent(ctx, q, EventTransferReceived, result.ToAccount.ID, received)
+	return err
+}
+
+// OutboxConsumerTx runs fn in a transaction that holds the lock of the consumer until it ends. relays of the same
+// consumer run one after another this way, the second one reads the offset the first one has moved and doesnt
+// publish the same batch again
+func (repo *SQLRepository) OutboxConsumerTx(ctx context.Context, consumer string, fn func(Querier) error) error {
+	return repo.execTx(ctx, func(q *Queries) error {
+		if err := q.LockOutboxConsumer(ctx, consumer); err != nil {
+			return err
+		}
+		return fn(q)
+	})
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAccountOutboxEvents(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	from := createRandomMemberAccount(t)
+	to := createRandomMemberAccount(t)
+	from, err := testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: from.ID, Balance: 1000})
+	require.NoError(t, err)
+
+	last, err := testQueries.GetLastAccountOutboxEventID(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Zero(t, last)
+
+	var results []TransferTxResult
+	for i := 0; i < 3; i++ {
+		result, err := repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10})
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	events, err := testQueries.ListAccountOutboxEvents(context.Background(), ListAccountOutboxEventsParams{
+		AccountID:  to.ID,
+		LimitCount: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	// every event carries the balance of its own account after the transfer
+	for i, event := range events {
+		require.Equal(t, EventTransferReceived, event.EventType)
+
+		var payload TransferEvent
+		require.NoError(t, json.Unmarshal(event.Payload, &payload))
+		require.Equal(t, results[i].Transfer.PublicID, payload.TransferID)
+		require.Equal(t, to.Balance+int64(10*(i+1)), payload.Balance)
+	}
+
+	last, err = testQueries.GetLastAccountOutboxEventID(context.Background(), to.ID)
+	require.NoError(t, err)
+	require.Equal(t, events[2].ID, last)
+
+	// resuming after an event returns only the later ones
+	later, err := testQueries.ListAccountOutboxEvents(context.Background(), ListAccountOutboxEventsParams{
+		AccountID:  to.ID,
+		ID:         events[0].ID,
+		LimitCount: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, events[1:], later)
+
+	sent, err := testQueries.ListAccountOutboxEvents(context.Background(), ListAccountOutboxEventsParams{
+		AccountID:  from.ID,
+		LimitCount: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, sent, 3)
+
+	var payload TransferEvent
+	require.NoError(t, json.Unmarshal(sent[2].Payload, &payload))
+	require.Equal(t, results[2].Transfer.PublicID, payload.TransferID)
+	require.Equal(t, results[2].FromAccount.Balance, payload.Balance)
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken is returned for reset tokens that dont exist, have expired or have already been used.
+// the cases are deliberately not distinguished
+var ErrInvalidResetToken = errors.New("password reset token is invalid or has expired")
+
+type ChangePasswordTxParams struct {
+	Username       string    `json:"username"`
+	HashedPassword string    `json:"hashedPassword"`
+	Audit          AuditMeta `json:"audit"`
+}
+
+type ResetPasswordTxParams struct {
+	TokenHash      string    `json:"tokenHash"`
+	HashedPassword string    `json:"hashedPassword"`
+	Audit          AuditMeta `json:"audit"` // the actor defaults to the owner of the token, since resetting happens without being logged in
+}
+
+// ChangePasswordTx sets a new password and invalidates all pending reset tokens of the user
+func (repo *SQLRepository) ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (User, error) {
+	var user User
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = updatePassword(ctx, q, arg.Username, arg.HashedPassword)
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionPasswordChanged,
+			ResourceType: AuditResourceUser,
+			ResourceID:   user.Username,
+			After:        passwordChange{PasswordChangedAt: user.PasswordChangedAt},
+		})
+		return err
+	})
+
+	return user, err
+}
+
+// ResetPasswordTx redeems a reset token and sets a new password for its owner. the token is being marked
+// as used in the same transaction, so it can never be redeemed twice
+func (repo *SQLRepository) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error) {
+	var user User
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		token, err := q.UsePasswordResetToken(ctx, arg.TokenHash)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		user, err = updatePassword(ctx, q, token.Username, arg.HashedPassword)
+		if err != nil {
+			return err
+		}
+
+		meta := arg.Audit
+		if meta.Actor == "" {
+			meta.Actor = user.Username
+		}
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         meta,
+			Action:       AuditActionPasswordReset,
+			ResourceType: AuditResourceUser,
+			ResourceID:   user.Username,
+			After:        passwordChange{PasswordChangedAt: user.PasswordChangedAt},
+		})
+		return err
+	})
+
+	return user, err
+}
+
+// the audit log only records when the password changed, never the hash
+type passwordChange struct {
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+}
+
+func updatePassword(ctx context.Context, q *Queries, username, hashedPassword string) (User, error) {
+	// the timestamp comes from our clock instead of the database's, because it is compared
+	// against the IssuedAt of tokens which are created with our clock as well
+	user, err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+		Username:          username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	// reset links that were requested before the change shouldnt be able to undo it
+	err = q.InvalidatePasswordResetTokens(ctx, username)
+	return user, err
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestChangePasswordTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	pending := createRandomResetToken(t, user, time.Hour)
+
+	changed, err := repo.ChangePasswordTx(context.Background(), ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: library.RandomString(60),
+		Audit:          AuditMeta{Actor: user.Username},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, user.HashedPassword, changed.HashedPassword)
+	require.True(t, changed.PasswordChangedAt.After(user.PasswordChangedAt))
+
+	// tokens requested before the change cant be redeemed anymore
+	_, err = repo.UsePasswordResetToken(context.Background(), pending.TokenHash)
+	require.Error(t, err)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	token := createRandomResetToken(t, user, time.Hour)
+
+	arg := ResetPasswordTxParams{
+		TokenHash:      token.TokenHash,
+		HashedPassword: library.RandomString(60),
+	}
+
+	reset, err := repo.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, reset.Username)
+	require.Equal(t, arg.HashedPassword, reset.HashedPassword)
+
+	// single use
+	_, err = repo.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidResetToken)
+}
+
+func TestResetPasswordTxExpired(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	token := createRandomResetToken(t, user, -time.Minute)
+
+	_, err := repo.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenHash:      token.TokenHash,
+		HashedPassword: library.RandomString(60),
+	})
+	require.ErrorIs(t, err, ErrInvalidResetToken)
+
+	unchanged, err := repo.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.HashedPassword, unchanged.HashedPassword)
+}
+
+func createRandomResetToken(t *testing.T, user User, validFor time.Duration) PasswordResetToken {
+	token, err := testQueries.CreatePasswordResetToken(context.Background(), CreatePasswordResetTokenParams{
+		TokenHash: library.RandomString(64),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(validFor),
+	})
+	require.NoError(t, err)
+	require.False(t, token.UsedAt.Valid)
+
+	return token
+}
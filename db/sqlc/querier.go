@@ -4,25 +4,123 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
+	AcceptAccountInvitation(ctx context.Context, arg AcceptAccountInvitationParams) (AccountInvitation, error)
+	AccrueInterest(ctx context.Context, arg AccrueInterestParams) (int64, error)
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AnonymizeUser(ctx context.Context, arg AnonymizeUserParams) (User, error)
+	ClaimSecondFactorAttempt(ctx context.Context, arg ClaimSecondFactorAttemptParams) (UserTotp, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	CloseAccount(ctx context.Context, id int64) (Account, error)
+	CompleteTransferBatch(ctx context.Context, arg CompleteTransferBatchParams) (TransferBatch, error)
+	ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (UserTotp, error)
+	CountAccountTransfersSince(ctx context.Context, arg CountAccountTransfersSinceParams) (int64, error)
+	CountOpenAccounts(ctx context.Context, owner string) (int64, error)
+	CountWebhookSubscriptions(ctx context.Context, username string) (int64, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAccountInvitation(ctx context.Context, arg CreateAccountInvitationParams) (AccountInvitation, error)
+	CreateAccountMember(ctx context.Context, arg CreateAccountMemberParams) (AccountMember, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
+	CreateBeneficiary(ctx context.Context, arg CreateBeneficiaryParams) (Beneficiary, error)
+	CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) (EmailVerificationToken, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateInterestCapitalization(ctx context.Context, arg CreateInterestCapitalizationParams) (InterestCapitalization, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (Outbox, error)
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferBatch(ctx context.Context, arg CreateTransferBatchParams) (TransferBatch, error)
+	CreateTransferBatchItem(ctx context.Context, arg CreateTransferBatchItemParams) (TransferBatchItem, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhookDeliveries(ctx context.Context, arg CreateWebhookDeliveriesParams) (int64, error)
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteAccountInvitation(ctx context.Context, id int64) error
+	DeleteAccountMember(ctx context.Context, arg DeleteAccountMemberParams) error
+	DeleteBeneficiary(ctx context.Context, id int64) error
+	DeleteEmailVerificationTokens(ctx context.Context, username string) error
+	DeleteFeeRule(ctx context.Context, id int64) error
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteUserAccountInvitations(ctx context.Context, username string) error
+	DeleteUserAccountMemberships(ctx context.Context, username string) error
+	DeleteUserBeneficiaries(ctx context.Context, owner string) error
+	DeleteUserTOTP(ctx context.Context, username string) error
+	DeleteUserWebhookSubscriptions(ctx context.Context, username string) error
+	DeleteWebhookSubscription(ctx context.Context, id int64) error
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountByNumber(ctx context.Context, number string) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetAccountInvitation(ctx context.Context, id int64) (AccountInvitation, error)
+	GetAccountMember(ctx context.Context, arg GetAccountMemberParams) (AccountMember, error)
+	GetApplicableFeeRule(ctx context.Context, arg GetApplicableFeeRuleParams) (FeeRule, error)
+	GetBeneficiary(ctx context.Context, id int64) (Beneficiary, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetFeeRule(ctx context.Context, id int64) (FeeRule, error)
+	GetFeeRuleByScope(ctx context.Context, arg GetFeeRuleByScopeParams) (FeeRule, error)
+	GetInterestRate(ctx context.Context, arg GetInterestRateParams) (InterestRate, error)
+	GetLastAccountOutboxEventID(ctx context.Context, accountID int64) (int64, error)
+	GetLastAuditEvent(ctx context.Context) (AuditEvent, error)
+	GetLastInterestCapitalization(ctx context.Context, arg GetLastInterestCapitalizationParams) (InterestCapitalization, error)
+	GetOutboxConsumerOffset(ctx context.Context, name string) (int64, error)
+	GetSystemAccount(ctx context.Context, arg GetSystemAccountParams) (Account, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferBatch(ctx context.Context, id int64) (TransferBatch, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserForUpdate(ctx context.Context, username string) (User, error)
+	GetUserPasswordChangedAt(ctx context.Context, username string) (time.Time, error)
+	GetUserTOTP(ctx context.Context, username string) (UserTotp, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (WebhookSubscription, error)
+	InvalidatePasswordResetTokens(ctx context.Context, username string) error
+	ListAccountMembers(ctx context.Context, accountID int64) ([]AccountMember, error)
+	ListAccountOutboxEvents(ctx context.Context, arg ListAccountOutboxEventsParams) ([]Outbox, error)
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]ListAccountTransfersRow, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ListAuditEventsAfter(ctx context.Context, arg ListAuditEventsAfterParams) ([]AuditEvent, error)
+	ListBeneficiaries(ctx context.Context, arg ListBeneficiariesParams) ([]Beneficiary, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListFeeRules(ctx context.Context) ([]FeeRule, error)
+	ListInterestAccruals(ctx context.Context, arg ListInterestAccrualsParams) ([]InterestAccrual, error)
+	ListInterestRates(ctx context.Context) ([]InterestRate, error)
+	ListMemberAccounts(ctx context.Context, arg ListMemberAccountsParams) ([]Account, error)
+	ListOutboxEventsAfter(ctx context.Context, arg ListOutboxEventsAfterParams) ([]ListOutboxEventsAfterRow, error)
+	ListOwnerAccounts(ctx context.Context, owner string) ([]Account, error)
+	ListPendingAccountInvitations(ctx context.Context, invitee string) ([]ListPendingAccountInvitationsRow, error)
+	ListPendingInterestCapitalizations(ctx context.Context, before time.Time) ([]ListPendingInterestCapitalizationsRow, error)
+	ListTransferBatchItems(ctx context.Context, batchID int64) ([]ListTransferBatchItemsRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	ListUnusedRecoveryCodes(ctx context.Context, username string) ([]RecoveryCode, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]ListWebhookDeliveriesRow, error)
+	ListWebhookSubscriptions(ctx context.Context, username string) ([]WebhookSubscription, error)
+	LockAccounts(ctx context.Context, ids []int64) ([]Account, error)
+	LockAuditChain(ctx context.Context) error
+	LockOutboxConsumer(ctx context.Context, name string) error
+	MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error)
+	NotifyOutboxEvent(ctx context.Context, accountID int64) error
+	RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) (WebhookDelivery, error)
+	ResetSecondFactorAttempts(ctx context.Context, username string) error
+	RetryWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	SetOutboxConsumerOffset(ctx context.Context, arg SetOutboxConsumerOffsetParams) error
 	UpdateAccountBalance(ctx context.Context, arg UpdateAccountBalanceParams) (Account, error)
+	UpdateAccountInterestRate(ctx context.Context, arg UpdateAccountInterestRateParams) (Account, error)
+	UpdateAccountMemberRole(ctx context.Context, arg UpdateAccountMemberRoleParams) (AccountMember, error)
+	UpdateAccountNickname(ctx context.Context, arg UpdateAccountNicknameParams) (Account, error)
 	UpdateAccountOwner(ctx context.Context, arg UpdateAccountOwnerParams) (Account, error)
+	UpdateBeneficiary(ctx context.Context, arg UpdateBeneficiaryParams) (Beneficiary, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error)
+	UpsertFeeRule(ctx context.Context, arg UpsertFeeRuleParams) (FeeRule, error)
+	UpsertInterestRate(ctx context.Context, arg UpsertInterestRateParams) (InterestRate, error)
+	UpsertPendingTOTP(ctx context.Context, arg UpsertPendingTOTPParams) (UserTotp, error)
+	UseEmailVerificationToken(ctx context.Context, tokenHash string) (EmailVerificationToken, error)
+	UsePasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	UseRecoveryCode(ctx context.Context, id int64) (RecoveryCode, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (UserTotp, error)
 }
 
 var _ Querier = (*Queries)(nil)
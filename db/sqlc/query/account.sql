@@ -2,15 +2,22 @@
 INSERT INTO accounts (
 	owner,
 	balance,
-	currency
+	currency,
+	nickname,
+	type,
+	number
 ) VALUES (
-	$1, $2, $3
+	$1, $2, $3, $4, $5, $6
 ) RETURNING *;
 
 -- name: GetAccount :one
 SELECT * FROM accounts
 WHERE id = $1 LIMIT 1;
 
+-- name: GetAccountByNumber :one
+SELECT * FROM accounts
+WHERE number = $1 LIMIT 1;
+
 -- name: GetAccountForUpdate :one
 SELECT * FROM accounts
 WHERE id = $1 LIMIT 1
@@ -44,3 +51,50 @@ RETURNING *;
 -- name: DeleteAccount :exec
 DELETE FROM accounts
 WHERE id = $1;
+
+-- name: ListOwnerAccounts :many
+SELECT * FROM accounts
+WHERE owner = $1
+ORDER BY id;
+
+-- name: CountOpenAccounts :one
+SELECT count(*) FROM accounts
+WHERE owner = $1 AND closed_at IS NULL;
+
+-- name: CloseAccount :one
+UPDATE accounts
+SET closed_at = now()
+WHERE id = $1 AND closed_at IS NULL AND balance = 0
+RETURNING *;
+
+-- name: ListMemberAccounts :many
+SELECT accounts.* FROM accounts
+JOIN account_members ON account_members.account_id = accounts.id
+WHERE account_members.username = sqlc.arg(username)
+  AND (sqlc.narg(currency)::varchar IS NULL OR accounts.currency = sqlc.narg(currency))
+  AND (sqlc.narg(type)::varchar IS NULL OR accounts.type = sqlc.narg(type))
+ORDER BY accounts.id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: UpdateAccountNickname :one
+UPDATE accounts
+SET nickname = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateAccountInterestRate :one
+UPDATE accounts
+SET interest_rate_bps = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: GetSystemAccount :one
+SELECT * FROM accounts
+WHERE owner = '_system' AND type = $1 AND currency = $2 LIMIT 1;
+
+-- name: LockAccounts :many
+SELECT * FROM accounts
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+ORDER BY id DESC
+FOR NO KEY UPDATE;
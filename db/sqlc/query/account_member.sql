@@ -0,0 +1,70 @@
+-- name: CreateAccountMember :one
+INSERT INTO account_members (
+  account_id,
+  username,
+  role
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: GetAccountMember :one
+SELECT * FROM account_members
+WHERE account_id = $1 AND username = $2
+LIMIT 1;
+
+-- name: ListAccountMembers :many
+SELECT * FROM account_members
+WHERE account_id = $1
+ORDER BY created_at, username;
+
+-- name: UpdateAccountMemberRole :one
+UPDATE account_members
+SET role = $3
+WHERE account_id = $1 AND username = $2
+RETURNING *;
+
+-- name: DeleteAccountMember :exec
+DELETE FROM account_members
+WHERE account_id = $1 AND username = $2;
+
+-- name: DeleteUserAccountMemberships :exec
+DELETE FROM account_members
+WHERE username = $1 AND role <> 'owner';
+
+-- name: CreateAccountInvitation :one
+INSERT INTO account_invitations (
+  account_id,
+  inviter,
+  invitee,
+  role,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetAccountInvitation :one
+SELECT * FROM account_invitations
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListPendingAccountInvitations :many
+SELECT account_invitations.*, accounts.number AS account_number FROM account_invitations
+JOIN accounts ON accounts.id = account_invitations.account_id
+WHERE account_invitations.invitee = $1
+  AND account_invitations.accepted_at IS NULL
+  AND account_invitations.expires_at > now()
+ORDER BY account_invitations.id;
+
+-- name: AcceptAccountInvitation :one
+UPDATE account_invitations
+SET accepted_at = now()
+WHERE id = $1 AND invitee = $2 AND accepted_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteAccountInvitation :exec
+DELETE FROM account_invitations
+WHERE id = $1 AND accepted_at IS NULL;
+
+-- name: DeleteUserAccountInvitations :exec
+DELETE FROM account_invitations
+WHERE (invitee = $1 OR inviter = $1) AND accepted_at IS NULL;
@@ -0,0 +1,44 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor,
+  action,
+  resource_type,
+  resource_id,
+  ip_address,
+  user_agent,
+  request_id,
+  before,
+  after,
+  prev_hash,
+  hash,
+  created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING *;
+
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(7263548);
+
+-- name: GetLastAuditEvent :one
+SELECT * FROM audit_events
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE
+    (@actor::varchar = '' OR actor = @actor) AND
+    (@action::varchar = '' OR action = @action) AND
+    (@resource_type::varchar = '' OR resource_type = @resource_type) AND
+    (@resource_id::varchar = '' OR resource_id = @resource_id) AND
+    created_at >= @created_from AND
+    created_at < @created_to
+ORDER BY id
+LIMIT @limit_count
+OFFSET @offset_count;
+
+-- name: ListAuditEventsAfter :many
+SELECT * FROM audit_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
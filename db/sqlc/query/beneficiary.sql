@@ -0,0 +1,37 @@
+-- name: CreateBeneficiary :one
+INSERT INTO beneficiaries (
+	owner,
+	name,
+	account_number,
+	currency,
+	note
+) VALUES (
+	$1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetBeneficiary :one
+SELECT * FROM beneficiaries
+WHERE id = $1 LIMIT 1;
+
+-- name: ListBeneficiaries :many
+SELECT * FROM beneficiaries
+WHERE owner = $1
+ORDER BY name, id
+LIMIT $2
+OFFSET $3;
+
+-- name: UpdateBeneficiary :one
+UPDATE beneficiaries
+SET
+  name = COALESCE(sqlc.narg(name), name),
+  note = COALESCE(sqlc.narg(note), note)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteBeneficiary :exec
+DELETE FROM beneficiaries
+WHERE id = $1;
+
+-- name: DeleteUserBeneficiaries :exec
+DELETE FROM beneficiaries
+WHERE owner = $1;
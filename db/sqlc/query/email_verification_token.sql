@@ -0,0 +1,19 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (
+  token_hash,
+  username,
+  email,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens
+WHERE username = $1;
@@ -0,0 +1,42 @@
+-- name: UpsertFeeRule :one
+INSERT INTO fee_rules (
+  currency,
+  account_type,
+  flat_amount,
+  percentage_bps,
+  min_amount,
+  max_amount,
+  free_per_month
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (currency, account_type) DO UPDATE
+SET flat_amount = EXCLUDED.flat_amount,
+  percentage_bps = EXCLUDED.percentage_bps,
+  min_amount = EXCLUDED.min_amount,
+  max_amount = EXCLUDED.max_amount,
+  free_per_month = EXCLUDED.free_per_month,
+  updated_at = now()
+RETURNING *;
+
+-- name: GetFeeRule :one
+SELECT * FROM fee_rules
+WHERE id = $1 LIMIT 1;
+
+-- name: GetFeeRuleByScope :one
+SELECT * FROM fee_rules
+WHERE currency = $1 AND account_type = $2 LIMIT 1;
+
+-- name: GetApplicableFeeRule :one
+SELECT * FROM fee_rules
+WHERE currency = sqlc.arg(currency) AND account_type IN (sqlc.arg(account_type), '')
+ORDER BY account_type = ''
+LIMIT 1;
+
+-- name: ListFeeRules :many
+SELECT * FROM fee_rules
+ORDER BY currency, account_type;
+
+-- name: DeleteFeeRule :exec
+DELETE FROM fee_rules
+WHERE id = $1;
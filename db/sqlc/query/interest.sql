@@ -0,0 +1,88 @@
+-- name: UpsertInterestRate :one
+INSERT INTO interest_rates (
+  account_type,
+  currency,
+  annual_rate_bps
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (account_type, currency) DO UPDATE
+SET annual_rate_bps = EXCLUDED.annual_rate_bps, updated_at = now()
+RETURNING *;
+
+-- name: GetInterestRate :one
+SELECT * FROM interest_rates
+WHERE account_type = $1 AND currency = $2 LIMIT 1;
+
+-- name: ListInterestRates :many
+SELECT * FROM interest_rates
+ORDER BY account_type, currency;
+
+-- name: AccrueInterest :execrows
+INSERT INTO interest_accruals (
+  account_id,
+  accrual_date,
+  balance,
+  annual_rate_bps,
+  days_in_year
+)
+SELECT balances.id,
+  sqlc.arg(accrual_date)::date,
+  balances.balance,
+  balances.annual_rate_bps,
+  sqlc.arg(days_in_year)::integer
+FROM (
+  SELECT accounts.id,
+    accounts.balance - COALESCE((
+      SELECT SUM(entries.amount) FROM entries
+      WHERE entries.account_id = accounts.id
+        AND entries.created_at >= (sqlc.arg(accrual_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+    ), 0)::bigint AS balance,
+    COALESCE(accounts.interest_rate_bps, interest_rates.annual_rate_bps, 0) AS annual_rate_bps
+  FROM accounts
+  LEFT JOIN interest_rates ON interest_rates.account_type = accounts.type AND interest_rates.currency = accounts.currency
+  WHERE accounts.closed_at IS NULL
+    AND accounts.created_at::date <= sqlc.arg(accrual_date)::date
+) balances
+WHERE balances.balance > 0
+  AND balances.annual_rate_bps > 0
+  AND NOT EXISTS (
+    SELECT 1 FROM interest_capitalizations
+    WHERE interest_capitalizations.account_id = balances.id
+      AND interest_capitalizations.period = date_trunc('month', sqlc.arg(accrual_date)::date)::date
+  )
+ON CONFLICT (account_id, accrual_date) DO NOTHING;
+
+-- name: ListInterestAccruals :many
+SELECT * FROM interest_accruals
+WHERE account_id = sqlc.arg(account_id)
+  AND accrual_date >= sqlc.arg(period_start)
+  AND accrual_date < sqlc.arg(period_end)
+ORDER BY accrual_date;
+
+-- name: ListPendingInterestCapitalizations :many
+SELECT DISTINCT interest_accruals.account_id,
+  date_trunc('month', interest_accruals.accrual_date)::date AS period
+FROM interest_accruals
+LEFT JOIN interest_capitalizations ON interest_capitalizations.account_id = interest_accruals.account_id
+  AND interest_capitalizations.period = date_trunc('month', interest_accruals.accrual_date)::date
+WHERE interest_accruals.accrual_date < sqlc.arg(before)
+  AND interest_capitalizations.account_id IS NULL
+ORDER BY period, account_id;
+
+-- name: GetLastInterestCapitalization :one
+SELECT * FROM interest_capitalizations
+WHERE account_id = $1 AND period < $2
+ORDER BY period DESC
+LIMIT 1;
+
+-- name: CreateInterestCapitalization :one
+INSERT INTO interest_capitalizations (
+  account_id,
+  period,
+  amount,
+  carry,
+  transfer_id
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
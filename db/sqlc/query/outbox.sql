@@ -0,0 +1,48 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox (
+  event_type,
+  account_id,
+  payload
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: ListOutboxEventsAfter :many
+SELECT outbox.*, accounts.number AS account_number
+FROM outbox
+JOIN accounts ON accounts.id = outbox.account_id
+WHERE outbox.id > sqlc.arg(id)
+ORDER BY outbox.id
+LIMIT sqlc.arg(limit_count);
+
+-- name: LockOutboxConsumer :exec
+SELECT pg_advisory_xact_lock(7263549, hashtext(sqlc.arg(name)::text));
+
+-- name: GetOutboxConsumerOffset :one
+SELECT last_event_id FROM outbox_consumers
+WHERE name = $1 LIMIT 1;
+
+-- name: SetOutboxConsumerOffset :exec
+INSERT INTO outbox_consumers (
+  name,
+  last_event_id
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (name) DO UPDATE
+SET last_event_id = EXCLUDED.last_event_id,
+  updated_at = now();
+
+-- name: ListAccountOutboxEvents :many
+SELECT * FROM outbox
+WHERE account_id = sqlc.arg(account_id)
+AND id > sqlc.arg(id)
+ORDER BY id
+LIMIT sqlc.arg(limit_count);
+
+-- name: GetLastAccountOutboxEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS last_event_id FROM outbox
+WHERE account_id = $1;
+
+-- name: NotifyOutboxEvent :exec
+SELECT pg_notify('outbox_events', sqlc.arg(account_id)::bigint::text);
@@ -0,0 +1,19 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (
+  token_hash,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE username = $1 AND used_at IS NULL;
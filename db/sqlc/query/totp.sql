@@ -0,0 +1,66 @@
+-- name: UpsertPendingTOTP :one
+INSERT INTO user_totp (
+  username,
+  secret
+) VALUES (
+  $1, $2
+) ON CONFLICT (username) DO UPDATE
+SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE username = $1
+LIMIT 1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_totp
+SET confirmed_at = now(), last_used_step = $2
+WHERE username = $1 AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPStep :one
+UPDATE user_totp
+SET last_used_step = $2
+WHERE username = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+RETURNING *;
+
+-- name: ClaimSecondFactorAttempt :one
+UPDATE user_totp
+SET failed_attempts = failed_attempts + 1, last_failed_at = now()
+WHERE username = sqlc.arg(username) AND confirmed_at IS NOT NULL
+  AND (failed_attempts < sqlc.arg(max_failures) OR last_failed_at < sqlc.arg(locked_before))
+RETURNING *;
+
+-- name: ResetSecondFactorAttempts :exec
+UPDATE user_totp
+SET failed_attempts = 0, last_failed_at = NULL
+WHERE username = $1;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE username = $1;
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username,
+  code_hash
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes
+WHERE username = $1 AND used_at IS NULL
+ORDER BY id;
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
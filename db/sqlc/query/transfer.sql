@@ -2,9 +2,15 @@
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  description,
+  reference,
+  metadata,
+  fee,
+  quote_id,
+  public_id
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 ) RETURNING *;
 
 -- name: GetTransfer :one
@@ -18,4 +24,27 @@ WHERE
     to_account_id = $2
 ORDER BY id
 LIMIT $3
-OFFSET $4;
\ No newline at end of file
+OFFSET $4;
+
+-- name: ListAccountTransfers :many
+SELECT transfers.*,
+  from_account.number AS from_account_number,
+  to_account.number AS to_account_number,
+  from_account.currency AS currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE (transfers.from_account_id = sqlc.arg(account_id) OR transfers.to_account_id = sqlc.arg(account_id))
+  AND (
+    sqlc.narg(search)::varchar IS NULL
+    OR strpos(lower(transfers.description), lower(sqlc.narg(search))) > 0
+    OR strpos(lower(transfers.reference), lower(sqlc.narg(search))) > 0
+  )
+  AND (sqlc.narg(reference)::varchar IS NULL OR transfers.reference = sqlc.narg(reference))
+ORDER BY transfers.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: CountAccountTransfersSince :one
+SELECT count(*) FROM transfers
+WHERE from_account_id = $1 AND created_at >= $2;
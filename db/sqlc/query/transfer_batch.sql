@@ -0,0 +1,47 @@
+-- name: CreateTransferBatch :one
+INSERT INTO transfer_batches (
+  from_account_id,
+  created_by,
+  atomic,
+  item_count
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetTransferBatch :one
+SELECT * FROM transfer_batches
+WHERE id = $1 LIMIT 1;
+
+-- name: CompleteTransferBatch :one
+UPDATE transfer_batches
+SET status = $2,
+  succeeded = $3,
+  failed = $4,
+  total_amount = $5,
+  total_fee = $6,
+  completed_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateTransferBatchItem :one
+INSERT INTO transfer_batch_items (
+  batch_id,
+  line,
+  to_account_id,
+  amount,
+  description,
+  reference,
+  status,
+  error,
+  transfer_id,
+  fee
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING *;
+
+-- name: ListTransferBatchItems :many
+SELECT transfer_batch_items.*, accounts.number AS to_account_number
+FROM transfer_batch_items
+JOIN accounts ON accounts.id = transfer_batch_items.to_account_id
+WHERE transfer_batch_items.batch_id = $1
+ORDER BY transfer_batch_items.line;
@@ -10,4 +10,53 @@ RETURNING *;
 -- name: GetUser :one
 SELECT * FROM users
 WHERE username = $1 
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1
+LIMIT 1;
+
+-- name: GetUserPasswordChangedAt :one
+SELECT password_changed_at FROM users
+WHERE username = $1
+LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2, password_changed_at = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = now()
+WHERE username = $1 AND email = $2
+RETURNING *;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE username = $1
+LIMIT 1
+FOR UPDATE;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  email_verified_at = CASE WHEN COALESCE(sqlc.narg(email), email) = email THEN email_verified_at ELSE NULL END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: AnonymizeUser :one
+UPDATE users
+SET
+  hashed_password = '',
+  full_name = '',
+  email = $2,
+  email_verified_at = NULL,
+  password_changed_at = $3,
+  deleted_at = $3
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING *;
@@ -0,0 +1,87 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (
+  username,
+  url,
+  secret,
+  event_types
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1 LIMIT 1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE username = $1
+ORDER BY id;
+
+-- name: CountWebhookSubscriptions :one
+SELECT count(*) FROM webhook_subscriptions
+WHERE username = $1;
+
+-- name: DeleteWebhookSubscription :exec
+DELETE FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: DeleteUserWebhookSubscriptions :exec
+DELETE FROM webhook_subscriptions
+WHERE username = $1;
+
+-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (subscription_id, outbox_id)
+SELECT webhook_subscriptions.id, sqlc.arg(outbox_id)::bigint
+FROM webhook_subscriptions
+JOIN account_members ON account_members.username = webhook_subscriptions.username
+WHERE account_members.account_id = sqlc.arg(account_id)
+  AND sqlc.arg(event_type)::varchar = ANY(webhook_subscriptions.event_types);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+FROM webhook_subscriptions, outbox
+WHERE webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at, id
+    LIMIT sqlc.arg(limit_count)
+    FOR UPDATE SKIP LOCKED
+  )
+  AND webhook_subscriptions.id = webhook_deliveries.subscription_id
+  AND outbox.id = webhook_deliveries.outbox_id
+RETURNING webhook_deliveries.id, webhook_deliveries.outbox_id, webhook_deliveries.attempts,
+  webhook_subscriptions.url, webhook_subscriptions.secret, outbox.event_type, outbox.payload, outbox.created_at;
+
+-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+  status = $2,
+  next_attempt_at = $3,
+  last_status_code = $4,
+  last_error = $5,
+  delivered_at = $6
+WHERE id = $1
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 LIMIT 1;
+
+-- name: ListWebhookDeliveries :many
+SELECT webhook_deliveries.*, outbox.event_type
+FROM webhook_deliveries
+JOIN outbox ON outbox.id = webhook_deliveries.outbox_id
+WHERE webhook_deliveries.subscription_id = sqlc.arg(subscription_id)
+  AND (sqlc.narg(status)::varchar IS NULL OR webhook_deliveries.status = sqlc.narg(status))
+ORDER BY webhook_deliveries.id DESC
+LIMIT sqlc.arg(limit_count)
+OFFSET sqlc.arg(offset_count);
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending',
+  attempts = 0,
+  next_attempt_at = now()
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
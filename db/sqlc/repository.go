@@ -3,12 +3,33 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Repository interface {
 	Querier // autogenerated interface by sqlc that includes all sql functions of the repository
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	AppendAuditEvent(ctx context.Context, arg AppendAuditEventParams) (AuditEvent, error)
+	VerifyAuditChain(ctx context.Context) (AuditChainReport, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (User, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (User, error)
+	EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (UserTotp, error)
+	DisableTOTPTx(ctx context.Context, arg DisableTOTPTxParams) error
+	DeleteUserTx(ctx context.Context, arg DeleteUserTxParams) (User, error)
+	CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (Account, error)
+	AcceptAccountInvitationTx(ctx context.Context, arg AcceptAccountInvitationTxParams) (AccountMember, error)
+	TransferAccountOwnershipTx(ctx context.Context, arg TransferAccountOwnershipTxParams) (Account, error)
+	CapitalizeInterestTx(ctx context.Context, arg CapitalizeInterestTxParams) (CapitalizeInterestTxResult, error)
+	QuoteTransferFee(ctx context.Context, from Account, amount int64) (TransferFee, error)
+	TransferBatchTx(ctx context.Context, arg TransferBatchTxParams) (TransferBatchTxResult, error)
+	OutboxConsumerTx(ctx context.Context, consumer string, fn func(Querier) error) error
 }
 
 // SQLRepository provides all functions for SQL queries
@@ -49,17 +70,26 @@ func (repo *SQLRepository) execTx(ctx context.Context, fn func(*Queries) error)
 }
 
 type TransferTxParams struct {
-	FromAccountID int64 `json:"fromAccountID"`
-	ToAccountID   int64 `json:"toAccountID"`
-	Amount        int64 `json:"amount"`
+	FromAccountID int64           `json:"fromAccountID"`
+	ToAccountID   int64           `json:"toAccountID"`
+	Amount        int64           `json:"amount"`
+	Description   string          `json:"description"`
+	Reference     string          `json:"reference"`
+	Metadata      json.RawMessage `json:"metadata"` // stored as an empty object when nil
+	Audit         AuditMeta       `json:"audit"`    // who requested the transfer, recorded in the audit log as part of the transaction
+	// set when the transfer executes a quote. the quote id can only be used once and the transfer fails with
+	// ErrQuoteChanged if the fee differs from the quoted one
+	QuoteID   string `json:"quoteID"`
+	QuotedFee int64  `json:"quotedFee"`
 }
 
 type TransferTxResult struct {
-	Transfer    Transfer `json:"transfer"`
-	FromAccount Account  `json:"fromAccount"`
-	ToAccount   Account  `json:"toAccount"`
-	FromEntry   Entry    `json:"fromEntry"`
-	ToEntry     Entry    `json:"toEntry"`
+	Transfer    Transfer    `json:"transfer"`
+	FromAccount Account     `json:"fromAccount"`
+	ToAccount   Account     `json:"toAccount"`
+	FromEntry   Entry       `json:"fromEntry"`
+	ToEntry     Entry       `json:"toEntry"`
+	Fee         TransferFee `json:"fee"` // charged on top of the amount, see fee.go
 }
 
 // Transfer creates a money Transfer from a sender to a receiver account
@@ -71,58 +101,185 @@ func (repo *SQLRepository) TransferTx(ctx context.Context, arg TransferTxParams)
 	err = repo.execTx(ctx, func(q *Queries) error {
 		// this is the anonymous higher order function that is being called inside execTx as part of the transcation
 
-		trArgs := CreateTransferParams{
-			FromAccountID: arg.FromAccountID,
-			ToAccountID:   arg.ToAccountID,
-			Amount:        arg.Amount,
-		}
-		result.Transfer, err = q.CreateTransfer(ctx, trArgs)
-		// note how even though this function is being called "inside" execTx as a higher order function,
-		// it accesses result.Transfer which makes it a Closure.
-		// https://gobyexample.com/closures
+		// both accounts are locked before the fee is evaluated, so concurrent transfers of the sender cant use
+		// the same free transfer of the month
+		from, err := lockTransferAccounts(ctx, q, arg.FromAccountID, arg.ToAccountID)
 		if err != nil {
 			return err
 		}
 
-		fArgs := CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		}
-		result.FromEntry, err = q.CreateEntry(ctx, fArgs)
-		if err != nil {
-			return err
-		}
+		result, err = executeTransfer(ctx, q, from, arg)
+		// note how even though this function is being called "inside" execTx as a higher order function,
+		// it accesses result which makes it a Closure.
+		// https://gobyexample.com/closures
+		return err
+	})
 
-		tArgs := CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
-		}
+	return result, err
+}
 
-		result.ToEntry, err = q.CreateEntry(ctx, tArgs)
-		if err != nil {
-			return err
-		}
+// executeTransfer charges the fee, posts the transfer and records it in the audit log. the accounts of the transfer
+// have to be locked already, from is the locked sending account
+func executeTransfer(ctx context.Context, q *Queries, from Account, arg TransferTxParams) (TransferTxResult, error) {
+	fee, err := evaluateTransferFee(ctx, q, from, arg.Amount, time.Now())
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+	if arg.QuoteID != "" && fee.Amount != arg.QuotedFee {
+		return TransferTxResult{}, ErrQuoteChanged
+	}
 
-		var args AddMoneyParams
-		// arrange the order of accounts inside the sql transcations query based on the account id
-		// this is necessary to prevent a deadlock. all transaction operations should follow this pattern
-		// of ordering by some unique key such as the id so a deadlock situation never occurs
-		if arg.FromAccountID < arg.ToAccountID {
-			args = AddMoneyParams{arg.ToAccountID, arg.FromAccountID, arg.Amount, -arg.Amount}
-		} else {
-			args = AddMoneyParams{arg.FromAccountID, arg.ToAccountID, -arg.Amount, arg.Amount}
-		}
+	result, err := postTransfer(ctx, q, arg, fee)
+	if err != nil {
+		return result, err
+	}
+	if result.FromAccount.Balance < 0 {
+		return result, ErrInsufficientFunds
+	}
 
-		result.ToAccount, result.FromAccount, err = updateTransferBalances(ctx, q, args)
-		if err != nil {
-			return err
-		}
-		return nil
+	_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+		Meta:         arg.Audit,
+		Action:       AuditActionTransferCreated,
+		ResourceType: AuditResourceTransfer,
+		ResourceID:   strconv.FormatInt(result.Transfer.ID, 10),
+		Before: transferBalances{
+			FromAccountID:      result.FromAccount.ID,
+			FromAccountBalance: result.FromAccount.Balance + arg.Amount + fee.Amount,
+			ToAccountID:        result.ToAccount.ID,
+			ToAccountBalance:   result.ToAccount.Balance - arg.Amount,
+		},
+		After: result,
 	})
-
 	return result, err
 }
 
+// lockTransferAccounts locks both accounts of a transfer in the same order updateTransferBalances updates them,
+// and returns the sending account. ErrAccountClosed is returned if either of them is closed
+func lockTransferAccounts(ctx context.Context, q *Queries, fromAccountID, toAccountID int64) (Account, error) {
+	first, second := fromAccountID, toAccountID
+	if first < second {
+		first, second = second, first
+	}
+
+	a, err := q.GetAccountForUpdate(ctx, first)
+	if err != nil {
+		return Account{}, err
+	}
+	b, err := q.GetAccountForUpdate(ctx, second)
+	if err != nil {
+		return Account{}, err
+	}
+	// the accounts were checked before the transaction started, but could have been closed until they were locked
+	if a.ClosedAt.Valid || b.ClosedAt.Valid {
+		return Account{}, ErrAccountClosed
+	}
+
+	if a.ID == fromAccountID {
+		return a, nil
+	}
+	return b, nil
+}
+
+// postTransfer creates the transfer, both entries and updates both balances using the given queries, so other
+// transactions like the interest capitalization can post transfers as well. a fee is charged to the sender on top
+// of the amount and posted to the fee revenue account of the currency. the events of both accounts are written to
+// the outbox as part of the transfer
+func postTransfer(ctx context.Context, q *Queries, arg TransferTxParams, fee TransferFee) (TransferTxResult, error) {
+	result := TransferTxResult{Fee: fee}
+
+	// the sequential id would tell everyone who sees it how many transfers the bank makes
+	publicID, err := uuid.NewRandom()
+	if err != nil {
+		return result, err
+	}
+
+	trArgs := CreateTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		Description:   arg.Description,
+		Reference:     arg.Reference,
+		Metadata:      arg.Metadata,
+		Fee:           fee.Amount,
+		QuoteID:       sql.NullString{String: arg.QuoteID, Valid: arg.QuoteID != ""},
+		PublicID:      publicID,
+	}
+	if len(trArgs.Metadata) == 0 {
+		trArgs.Metadata = json.RawMessage("{}")
+	}
+	result.Transfer, err = q.CreateTransfer(ctx, trArgs)
+	if err != nil {
+		return result, err
+	}
+
+	fArgs := CreateEntryParams{
+		AccountID: arg.FromAccountID,
+		Amount:    -arg.Amount,
+	}
+	result.FromEntry, err = q.CreateEntry(ctx, fArgs)
+	if err != nil {
+		return result, err
+	}
+
+	tArgs := CreateEntryParams{
+		AccountID: arg.ToAccountID,
+		Amount:    arg.Amount,
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, tArgs)
+	if err != nil {
+		return result, err
+	}
+
+	// arrange the order of accounts inside the sql transcations query based on the account id
+	// this is necessary to prevent a deadlock. all transaction operations should follow this pattern
+	// of ordering by some unique key such as the id so a deadlock situation never occurs
+	if arg.FromAccountID < arg.ToAccountID {
+		args := AddMoneyParams{arg.ToAccountID, arg.FromAccountID, arg.Amount, -arg.Amount - fee.Amount}
+		result.ToAccount, result.FromAccount, err = updateTransferBalances(ctx, q, args)
+	} else {
+		args := AddMoneyParams{arg.FromAccountID, arg.ToAccountID, -arg.Amount - fee.Amount, arg.Amount}
+		result.FromAccount, result.ToAccount, err = updateTransferBalances(ctx, q, args)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if fee.Amount == 0 {
+		return result, appendTransferEvents(ctx, q, result)
+	}
+
+	// the revenue account is always updated last, after the accounts of the transfer, so it cant be part of a deadlock
+	revenue, err := systemAccount(ctx, q, AccountTypeFeeRevenue, result.FromAccount.Currency)
+	if err != nil {
+		return result, err
+	}
+
+	result.Fee.Entry, err = q.CreateEntry(ctx, CreateEntryParams{AccountID: arg.FromAccountID, Amount: -fee.Amount})
+	if err != nil {
+		return result, err
+	}
+	result.Fee.RevenueEntry, err = q.CreateEntry(ctx, CreateEntryParams{AccountID: revenue.ID, Amount: fee.Amount})
+	if err != nil {
+		return result, err
+	}
+	_, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{ID: revenue.ID, Amount: fee.Amount})
+	if err != nil {
+		return result, err
+	}
+
+	// the events are written last, since the outbox lock has to be taken after all row locks
+	return result, appendTransferEvents(ctx, q, result)
+}
+
+// balances of both transfer parties, recorded as the before-state of a transfer in the audit log
+type transferBalances struct {
+	FromAccountID      int64 `json:"fromAccountID"`
+	FromAccountBalance int64 `json:"fromAccountBalance"`
+	ToAccountID        int64 `json:"toAccountID"`
+	ToAccountBalance   int64 `json:"toAccountBalance"`
+}
+
 type AddMoneyParams struct {
 	accAID,
 	accBID,
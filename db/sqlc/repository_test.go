@@ -2,11 +2,66 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+func TestTransferTxDetails(t *testing.T) {
+	repo := NewRepository(testDB)
+	accA := createRandomAccount(t)
+	accB := createRandomAccount(t)
+
+	// send from the account with the bigger id, the result still has to keep sender and receiver apart
+	result, err := repo.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: accB.ID,
+		ToAccountID:   accA.ID,
+		Amount:        10,
+		Description:   "dinner",
+		Reference:     "INV-42",
+		Metadata:      json.RawMessage(`{"category":"food"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, accB.ID, result.FromAccount.ID)
+	require.Equal(t, accB.Balance-10, result.FromAccount.Balance)
+	require.Equal(t, accA.ID, result.ToAccount.ID)
+	require.Equal(t, accA.Balance+10, result.ToAccount.Balance)
+
+	require.Equal(t, "dinner", result.Transfer.Description)
+	require.Equal(t, "INV-42", result.Transfer.Reference)
+	require.JSONEq(t, `{"category":"food"}`, string(result.Transfer.Metadata))
+
+	// metadata defaults to an empty object
+	result, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: accA.ID, ToAccountID: accB.ID, Amount: 10})
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(result.Transfer.Metadata))
+}
+
+// the api checks the accounts before the transaction starts, an account closed after that check must not move money
+func TestTransferTxClosedAccount(t *testing.T) {
+	repo := NewRepository(testDB)
+	open := createRandomAccount(t)
+	closed := createRandomAccount(t)
+
+	_, err := testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: closed.ID, Balance: 0})
+	require.NoError(t, err)
+	_, err = repo.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: closed.ID})
+	require.NoError(t, err)
+
+	_, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: open.ID, ToAccountID: closed.ID, Amount: 10})
+	require.Equal(t, ErrAccountClosed, err)
+	_, err = repo.TransferTx(context.Background(), TransferTxParams{FromAccountID: closed.ID, ToAccountID: open.ID, Amount: 10})
+	require.Equal(t, ErrAccountClosed, err)
+
+	after, err := testQueries.GetAccount(context.Background(), open.ID)
+	require.NoError(t, err)
+	require.Equal(t, open.Balance, after.Balance)
+	after, err = testQueries.GetAccount(context.Background(), closed.ID)
+	require.NoError(t, err)
+	require.Zero(t, after.Balance)
+}
+
 func TestTransferTx(t *testing.T) {
 	repo := NewRepository(testDB)
 	accA := createRandomAccount(t)
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrTOTPNotPending is returned when confirming an enrollment that doesnt exist or has already been confirmed
+var ErrTOTPNotPending = errors.New("no pending two-factor enrollment")
+
+type EnableTOTPTxParams struct {
+	Username           string    `json:"username"`
+	Step               int64     `json:"step"`               // time step of the code that confirmed the enrollment, it cant be used again
+	RecoveryCodeHashes []string  `json:"recoveryCodeHashes"` // replace all previous recovery codes
+	Audit              AuditMeta `json:"audit"`
+}
+
+// EnableTOTPTx confirms a pending enrollment and stores a new set of recovery codes
+func (repo *SQLRepository) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (UserTotp, error) {
+	var totp UserTotp
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		var err error
+		totp, err = q.ConfirmUserTOTP(ctx, ConfirmUserTOTPParams{
+			Username:     arg.Username,
+			LastUsedStep: arg.Step,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrTOTPNotPending
+			}
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+		for _, hash := range arg.RecoveryCodeHashes {
+			_, err = q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{Username: arg.Username, CodeHash: hash})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionTOTPEnabled,
+			ResourceType: AuditResourceUser,
+			ResourceID:   arg.Username,
+			After:        totpState{ConfirmedAt: totp.ConfirmedAt, RecoveryCodes: len(arg.RecoveryCodeHashes)},
+		})
+		return err
+	})
+
+	return totp, err
+}
+
+type DisableTOTPTxParams struct {
+	Username string    `json:"username"`
+	Audit    AuditMeta `json:"audit"`
+}
+
+// DisableTOTPTx removes the second factor and all recovery codes of a user
+func (repo *SQLRepository) DisableTOTPTx(ctx context.Context, arg DisableTOTPTxParams) error {
+	return repo.execTx(ctx, func(q *Queries) error {
+		totp, err := q.GetUserTOTP(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteUserTOTP(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionTOTPDisabled,
+			ResourceType: AuditResourceUser,
+			ResourceID:   arg.Username,
+			Before:       totpState{ConfirmedAt: totp.ConfirmedAt},
+		})
+		return err
+	})
+}
+
+// the audit log never contains the secret or the codes
+type totpState struct {
+	ConfirmedAt   sql.NullTime `json:"confirmedAt"`
+	RecoveryCodes int          `json:"recoveryCodes,omitempty"`
+}
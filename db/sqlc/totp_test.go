@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+func TestEnableTOTPTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	createPendingTOTP(t, user)
+
+	enabled, err := repo.EnableTOTPTx(context.Background(), EnableTOTPTxParams{
+		Username:           user.Username,
+		Step:               100,
+		RecoveryCodeHashes: []string{library.RandomString(60), library.RandomString(60)},
+	})
+	require.NoError(t, err)
+	require.True(t, enabled.ConfirmedAt.Valid)
+	require.Equal(t, int64(100), enabled.LastUsedStep)
+
+	codes, err := testQueries.ListUnusedRecoveryCodes(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, codes, 2)
+
+	// an enabled secret cant be confirmed again or replaced by a new enrollment
+	_, err = repo.EnableTOTPTx(context.Background(), EnableTOTPTxParams{Username: user.Username, Step: 101})
+	require.ErrorIs(t, err, ErrTOTPNotPending)
+
+	_, err = testQueries.UpsertPendingTOTP(context.Background(), UpsertPendingTOTPParams{Username: user.Username, Secret: "OTHER"})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	createPendingTOTP(t, user)
+
+	_, err := repo.EnableTOTPTx(context.Background(), EnableTOTPTxParams{Username: user.Username, Step: 100})
+	require.NoError(t, err)
+
+	// the step used for confirming cant be replayed
+	_, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, LastUsedStep: 100})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	used, err := testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, LastUsedStep: 101})
+	require.NoError(t, err)
+	require.Equal(t, int64(101), used.LastUsedStep)
+}
+
+func TestDisableTOTPTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	user := createRandomUser(t)
+	createPendingTOTP(t, user)
+
+	_, err := repo.EnableTOTPTx(context.Background(), EnableTOTPTxParams{
+		Username:           user.Username,
+		Step:               100,
+		RecoveryCodeHashes: []string{library.RandomString(60)},
+	})
+	require.NoError(t, err)
+
+	err = repo.DisableTOTPTx(context.Background(), DisableTOTPTxParams{Username: user.Username})
+	require.NoError(t, err)
+
+	_, err = testQueries.GetUserTOTP(context.Background(), user.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	codes, err := testQueries.ListUnusedRecoveryCodes(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Empty(t, codes)
+}
+
+func createPendingTOTP(t *testing.T, user User) UserTotp {
+	totp, err := testQueries.UpsertPendingTOTP(context.Background(), UpsertPendingTOTPParams{
+		Username: user.Username,
+		Secret:   library.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.False(t, totp.ConfirmedAt.Valid)
+
+	return totp
+}
@@ -5,26 +5,70 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+const countAccountTransfersSince = `-- name: CountAccountTransfersSince :one
+SELECT count(*) FROM transfers
+WHERE from_account_id = $1 AND created_at >= $2
+`
+
+type CountAccountTransfersSinceParams struct {
+	FromAccountID int64     `json:"fromAccountID"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (q *Queries) CountAccountTransfersSince(ctx context.Context, arg CountAccountTransfersSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAccountTransfersSince, arg.FromAccountID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  description,
+  reference,
+  metadata,
+  fee,
+  quote_id,
+  public_id
 ) VALUES (
-  $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, from_account_id, to_account_id, amount, created_at, description, reference, metadata, fee, quote_id, public_id
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `json:"fromAccountID"`
-	ToAccountID   int64 `json:"toAccountID"`
-	Amount        int64 `json:"amount"`
+	FromAccountID int64           `json:"fromAccountID"`
+	ToAccountID   int64           `json:"toAccountID"`
+	Amount        int64           `json:"amount"`
+	Description   string          `json:"description"`
+	Reference     string          `json:"reference"`
+	Metadata      json.RawMessage `json:"metadata"`
+	Fee           int64           `json:"fee"`
+	QuoteID       sql.NullString  `json:"quoteID"`
+	PublicID      uuid.UUID       `json:"publicID"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.Description,
+		arg.Reference,
+		arg.Metadata,
+		arg.Fee,
+		arg.QuoteID,
+		arg.PublicID,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -32,12 +76,18 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.Fee,
+		&i.QuoteID,
+		&i.PublicID,
 	)
 	return i, err
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, description, reference, metadata, fee, quote_id, public_id FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -50,12 +100,107 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.Fee,
+		&i.QuoteID,
+		&i.PublicID,
 	)
 	return i, err
 }
 
+const listAccountTransfers = `-- name: ListAccountTransfers :many
+SELECT transfers.id, transfers.from_account_id, transfers.to_account_id, transfers.amount, transfers.created_at, transfers.description, transfers.reference, transfers.metadata, transfers.fee, transfers.quote_id, transfers.public_id,
+  from_account.number AS from_account_number,
+  to_account.number AS to_account_number,
+  from_account.currency AS currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE (transfers.from_account_id = $1 OR transfers.to_account_id = $1)
+  AND (
+    $2::varchar IS NULL
+    OR strpos(lower(transfers.description), lower($2)) > 0
+    OR strpos(lower(transfers.reference), lower($2)) > 0
+  )
+  AND ($3::varchar IS NULL OR transfers.reference = $3)
+ORDER BY transfers.id DESC
+LIMIT $4
+OFFSET $5
+`
+
+type ListAccountTransfersParams struct {
+	AccountID int64          `json:"accountID"`
+	Search    sql.NullString `json:"search"`
+	Reference sql.NullString `json:"reference"`
+	Limit     int32          `json:"limit"`
+	Offset    int32          `json:"offset"`
+}
+
+type ListAccountTransfersRow struct {
+	ID                int64           `json:"id"`
+	FromAccountID     int64           `json:"fromAccountID"`
+	ToAccountID       int64           `json:"toAccountID"`
+	Amount            int64           `json:"amount"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	Description       string          `json:"description"`
+	Reference         string          `json:"reference"`
+	Metadata          json.RawMessage `json:"metadata"`
+	Fee               int64           `json:"fee"`
+	QuoteID           sql.NullString  `json:"quoteID"`
+	PublicID          uuid.UUID       `json:"publicID"`
+	FromAccountNumber string          `json:"fromAccountNumber"`
+	ToAccountNumber   string          `json:"toAccountNumber"`
+	Currency          string          `json:"currency"`
+}
+
+func (q *Queries) ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]ListAccountTransfersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransfers,
+		arg.AccountID,
+		arg.Search,
+		arg.Reference,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountTransfersRow{}
+	for rows.Next() {
+		var i ListAccountTransfersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Description,
+			&i.Reference,
+			&i.Metadata,
+			&i.Fee,
+			&i.QuoteID,
+			&i.PublicID,
+			&i.FromAccountNumber,
+			&i.ToAccountNumber,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, description, reference, metadata, fee, quote_id, public_id FROM transfers
 WHERE 
     from_account_id = $1 OR
     to_account_id = $2
@@ -91,6 +236,12 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.Description,
+			&i.Reference,
+			&i.Metadata,
+			&i.Fee,
+			&i.QuoteID,
+			&i.PublicID,
 		); err != nil {
 			return nil, err
 		}
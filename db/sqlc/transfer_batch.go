@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// statuses of a transfer batch
+const (
+	TransferBatchProcessing      = "processing"
+	TransferBatchCompleted       = "completed"
+	TransferBatchPartiallyFailed = "partially-failed"
+	TransferBatchFailed          = "failed"
+)
+
+// statuses of a single item of a transfer batch
+const (
+	TransferBatchItemSucceeded = "succeeded"
+	TransferBatchItemFailed    = "failed"
+	TransferBatchItemSkipped   = "skipped" // not executed because another item of an atomic batch failed
+)
+
+type TransferBatchItemParams struct {
+	ToAccountID int64  `json:"toAccountID"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	Reference   string `json:"reference"`
+}
+
+type TransferBatchTxParams struct {
+	FromAccountID int64                     `json:"fromAccountID"`
+	CreatedBy     string                    `json:"createdBy"`
+	Atomic        bool                      `json:"atomic"` // either all items are executed or none
+	Items         []TransferBatchItemParams `json:"items"`
+	Audit         AuditMeta                 `json:"audit"`
+}
+
+type TransferBatchTxResult struct {
+	Batch TransferBatch       `json:"batch"`
+	Items []TransferBatchItem `json:"items"`
+}
+
+// TransferBatchTx executes many transfers from the same account in a single transaction. the sending account and
+// all receivers are locked once up front, every item then runs in a savepoint of its own, so a failing item only
+// rolls back its own transfer. in an atomic batch the first failing item rolls back all items instead.
+// failing items dont fail the batch, the result reports the status of every item
+func (repo *SQLRepository) TransferBatchTx(ctx context.Context, arg TransferBatchTxParams) (TransferBatchTxResult, error) {
+	var result TransferBatchTxResult
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		// the accounts are locked with the highest id first, the same order lockTransferAccounts uses, so the
+		// batch cant deadlock with single transfers
+		ids := []int64{arg.FromAccountID}
+		for _, item := range arg.Items {
+			ids = append(ids, item.ToAccountID)
+		}
+		locked, err := q.LockAccounts(ctx, ids)
+		if err != nil {
+			return err
+		}
+		accounts := make(map[int64]Account, len(locked))
+		for _, acc := range locked {
+			accounts[acc.ID] = acc
+		}
+		from, ok := accounts[arg.FromAccountID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if from.ClosedAt.Valid {
+			return ErrAccountClosed
+		}
+
+		// every item takes the lock of the audit chain and the outbox, the fee revenue account has to be locked
+		// before that like in single transfers
+		revenue, err := systemAccount(ctx, q, AccountTypeFeeRevenue, from.Currency)
+		if err != nil {
+			return err
+		}
+		if _, err = q.GetAccountForUpdate(ctx, revenue.ID); err != nil {
+			return err
+		}
+
+		batch, err := q.CreateTransferBatch(ctx, CreateTransferBatchParams{
+			FromAccountID: arg.FromAccountID,
+			CreatedBy:     arg.CreatedBy,
+			Atomic:        arg.Atomic,
+			ItemCount:     int32(len(arg.Items)),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := savepoint(ctx, q, "SAVEPOINT batch_items"); err != nil {
+			return err
+		}
+
+		items := make([]CreateTransferBatchItemParams, len(arg.Items))
+		failedLine := 0
+		for i, item := range arg.Items {
+			items[i] = CreateTransferBatchItemParams{
+				BatchID:     batch.ID,
+				Line:        int32(i + 1),
+				ToAccountID: item.ToAccountID,
+				Amount:      item.Amount,
+				Description: item.Description,
+				Reference:   item.Reference,
+				Status:      TransferBatchItemSucceeded,
+			}
+			if failedLine != 0 {
+				items[i].Status = TransferBatchItemSkipped
+				items[i].Error = fmt.Sprintf("not executed because line %d failed", failedLine)
+				continue
+			}
+
+			trf, err := executeBatchItem(ctx, q, from, accounts[item.ToAccountID], TransferTxParams{
+				FromAccountID: arg.FromAccountID,
+				ToAccountID:   item.ToAccountID,
+				Amount:        item.Amount,
+				Description:   item.Description,
+				Reference:     item.Reference,
+				Audit:         arg.Audit,
+			})
+			if err != nil {
+				if _, ok := err.(batchItemError); !ok {
+					return err
+				}
+				items[i].Status = TransferBatchItemFailed
+				items[i].Error = err.Error()
+				if arg.Atomic {
+					failedLine = i + 1
+				}
+				continue
+			}
+			items[i].TransferID = sql.NullInt64{Int64: trf.Transfer.ID, Valid: true}
+			items[i].Fee = trf.Transfer.Fee
+		}
+
+		if failedLine != 0 {
+			// undo the items that were executed before the failing one
+			if err := savepoint(ctx, q, "ROLLBACK TO SAVEPOINT batch_items"); err != nil {
+				return err
+			}
+			for i := 0; i < failedLine-1; i++ {
+				items[i].Status = TransferBatchItemSkipped
+				items[i].Error = fmt.Sprintf("rolled back because line %d failed", failedLine)
+				items[i].TransferID = sql.NullInt64{}
+				items[i].Fee = 0
+			}
+		}
+
+		summary := CompleteTransferBatchParams{ID: batch.ID}
+		result.Items = make([]TransferBatchItem, 0, len(items))
+		for _, item := range items {
+			created, err := q.CreateTransferBatchItem(ctx, item)
+			if err != nil {
+				return err
+			}
+			result.Items = append(result.Items, created)
+
+			if item.Status == TransferBatchItemSucceeded {
+				summary.Succeeded++
+				summary.TotalAmount += item.Amount
+				summary.TotalFee += item.Fee
+			} else {
+				summary.Failed++
+			}
+		}
+
+		switch {
+		case summary.Failed == 0:
+			summary.Status = TransferBatchCompleted
+		case summary.Succeeded == 0:
+			summary.Status = TransferBatchFailed
+		default:
+			summary.Status = TransferBatchPartiallyFailed
+		}
+		result.Batch, err = q.CompleteTransferBatch(ctx, summary)
+		if err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionTransferBatchCreated,
+			ResourceType: AuditResourceTransferBatch,
+			ResourceID:   strconv.FormatInt(result.Batch.ID, 10),
+			After:        result.Batch,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// batchItemError is the reason a single item of a batch failed, it is stored with the item and shown to the caller
+type batchItemError string
+
+func (e batchItemError) Error() string {
+	return string(e)
+}
+
+// executeBatchItem executes a single transfer of a batch in a savepoint. errors of the transfer itself roll back
+// the savepoint and are returned as batchItemError, any other error fails the whole batch
+func executeBatchItem(ctx context.Context, q *Queries, from Account, to Account, arg TransferTxParams) (TransferTxResult, error) {
+	// the receivers were checked when the batch was validated, but could have changed until they were locked
+	switch {
+	case to.ID == 0:
+		return TransferTxResult{}, batchItemError("receiving account does not exist")
+	case to.ID == from.ID:
+		return TransferTxResult{}, batchItemError("cannot make transfer to the same account")
+	case to.ClosedAt.Valid:
+		return TransferTxResult{}, batchItemError(fmt.Sprintf("account [%s] is closed", to.Number))
+	case to.Currency != from.Currency:
+		return TransferTxResult{}, batchItemError(fmt.Sprintf("invalid currency for account [%s]", to.Number))
+	}
+
+	if err := savepoint(ctx, q, "SAVEPOINT batch_item"); err != nil {
+		return TransferTxResult{}, err
+	}
+
+	result, err := executeTransfer(ctx, q, from, arg)
+	if err != nil {
+		if rbErr := savepoint(ctx, q, "ROLLBACK TO SAVEPOINT batch_item"); rbErr != nil {
+			return result, fmt.Errorf("batch item error: %v, rollback error: %v", err, rbErr)
+		}
+		if err == ErrInsufficientFunds {
+			return result, batchItemError(err.Error())
+		}
+		return result, batchItemError("transfer could not be executed")
+	}
+
+	return result, savepoint(ctx, q, "RELEASE SAVEPOINT batch_item")
+}
+
+// savepoint runs a savepoint statement inside the transaction of q
+func savepoint(ctx context.Context, q *Queries, stmt string) error {
+	_, err := q.db.ExecContext(ctx, stmt)
+	return err
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferBatchTx(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	from := createRandomSavingsAccount(t, "USD")
+	toA := createRandomSavingsAccount(t, "USD")
+	toB := createRandomSavingsAccount(t, "USD")
+
+	items := []TransferBatchItemParams{
+		{ToAccountID: toA.ID, Amount: 100, Reference: "SALARY-01"},
+		{ToAccountID: toB.ID, Amount: from.Balance}, // not covered after the first item
+		{ToAccountID: toB.ID, Amount: 300},
+	}
+
+	// every item is executed on its own, the second one fails
+	result, err := repo.TransferBatchTx(context.Background(), TransferBatchTxParams{
+		FromAccountID: from.ID,
+		CreatedBy:     from.Owner,
+		Items:         items,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchPartiallyFailed, result.Batch.Status)
+	require.Equal(t, int32(3), result.Batch.ItemCount)
+	require.Equal(t, int32(2), result.Batch.Succeeded)
+	require.Equal(t, int32(1), result.Batch.Failed)
+	require.Equal(t, int64(400), result.Batch.TotalAmount)
+	require.True(t, result.Batch.CompletedAt.Valid)
+
+	require.Len(t, result.Items, 3)
+	require.Equal(t, TransferBatchItemSucceeded, result.Items[0].Status)
+	require.True(t, result.Items[0].TransferID.Valid)
+	require.Equal(t, TransferBatchItemFailed, result.Items[1].Status)
+	require.Equal(t, ErrInsufficientFunds.Error(), result.Items[1].Error)
+	require.False(t, result.Items[1].TransferID.Valid)
+	require.Equal(t, TransferBatchItemSucceeded, result.Items[2].Status)
+
+	fromAfter, err := testQueries.GetAccount(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Equal(t, from.Balance-400, fromAfter.Balance)
+
+	trf, err := testQueries.GetTransfer(context.Background(), result.Items[0].TransferID.Int64)
+	require.NoError(t, err)
+	require.Equal(t, "SALARY-01", trf.Reference)
+
+	// the same batch executed atomically leaves everything as it was
+	result, err = repo.TransferBatchTx(context.Background(), TransferBatchTxParams{
+		FromAccountID: from.ID,
+		CreatedBy:     from.Owner,
+		Atomic:        true,
+		Items:         items,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchFailed, result.Batch.Status)
+	require.Zero(t, result.Batch.Succeeded)
+	require.Equal(t, int32(3), result.Batch.Failed)
+	require.Zero(t, result.Batch.TotalAmount)
+	require.Equal(t, TransferBatchItemSkipped, result.Items[0].Status)
+	require.False(t, result.Items[0].TransferID.Valid)
+	require.Equal(t, TransferBatchItemFailed, result.Items[1].Status)
+	require.Equal(t, TransferBatchItemSkipped, result.Items[2].Status)
+
+	fromAtomic, err := testQueries.GetAccount(context.Background(), from.ID)
+	require.NoError(t, err)
+	require.Equal(t, fromAfter.Balance, fromAtomic.Balance)
+
+	// the report is stored with the batch
+	batch, err := testQueries.GetTransferBatch(context.Background(), result.Batch.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchFailed, batch.Status)
+	require.Equal(t, from.ID, batch.FromAccountID)
+	require.True(t, batch.Atomic)
+
+	rows, err := testQueries.ListTransferBatchItems(context.Background(), batch.ID)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, toA.Number, rows[0].ToAccountNumber)
+	require.Equal(t, result.Items[1].Error, rows[1].Error)
+}
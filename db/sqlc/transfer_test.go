@@ -2,6 +2,9 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +17,9 @@ func createRandomTransfer(t *testing.T, from, to Account) Transfer {
 		FromAccountID: from.ID,
 		ToAccountID:   to.ID,
 		Amount:        library.RandomMoney(),
+		Description:   library.RandomString(20),
+		Reference:     library.RandomString(10),
+		Metadata:      json.RawMessage(`{"source":"test"}`),
 	}
 
 	trf, err := testQueries.CreateTransfer(context.Background(), args)
@@ -23,6 +29,9 @@ func createRandomTransfer(t *testing.T, from, to Account) Transfer {
 	require.Equal(t, args.FromAccountID, trf.FromAccountID)
 	require.Equal(t, args.ToAccountID, trf.ToAccountID)
 	require.Equal(t, args.Amount, trf.Amount)
+	require.Equal(t, args.Description, trf.Description)
+	require.Equal(t, args.Reference, trf.Reference)
+	require.JSONEq(t, string(args.Metadata), string(trf.Metadata))
 
 	require.NotZero(t, trf.ID)
 	require.NotZero(t, trf.CreatedAt)
@@ -78,3 +87,35 @@ func TestListeTransfers(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, empty, 0) // Offet of 4, even though only 4 entires match the args, should return 0 rows
 }
+
+func TestListAccountTransfers(t *testing.T) {
+	accA := createRandomAccount(t)
+	accB := createRandomAccount(t)
+
+	first := createRandomTransfer(t, accA, accB)
+	second := createRandomTransfer(t, accB, accA)
+	createRandomTransfer(t, accB, createRandomAccount(t))
+
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{AccountID: accA.ID, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+
+	// newest first, with the public numbers of both parties
+	require.Equal(t, second.ID, transfers[0].ID)
+	require.Equal(t, accB.Number, transfers[0].FromAccountNumber)
+	require.Equal(t, accA.Number, transfers[0].ToAccountNumber)
+	require.Equal(t, accB.Currency, transfers[0].Currency)
+
+	// search is case insensitive and matches parts of the description
+	search := sql.NullString{String: strings.ToUpper(first.Description[3:10]), Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{AccountID: accA.ID, Search: search, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, first.ID, transfers[0].ID)
+
+	reference := sql.NullString{String: second.Reference, Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{AccountID: accA.ID, Reference: reference, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, second.ID, transfers[0].ID)
+}
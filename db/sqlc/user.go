@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrOpenAccounts is returned when deleting a user that still has accounts which arent closed
+var ErrOpenAccounts = errors.New("all accounts have to be closed first")
+
+// ErrUserNotFound is returned when deleting a user that doesnt exist or has already been deleted
+var ErrUserNotFound = errors.New("user not found")
+
+type DeleteUserTxParams struct {
+	Username string    `json:"username"`
+	Audit    AuditMeta `json:"audit"`
+}
+
+// DeleteUserTx anonymizes a user whose accounts are all closed. the row itself is kept, since accounts and
+// with them the ledger reference the username. every token of the user stops working because the
+// password change timestamp is being bumped
+func (repo *SQLRepository) DeleteUserTx(ctx context.Context, arg DeleteUserTxParams) (User, error) {
+	var user User
+
+	err := repo.execTx(ctx, func(q *Queries) error {
+		// locking the user blocks accounts from being opened until the transaction is done,
+		// inserting an account takes a key share lock on its owner
+		_, err := q.GetUserForUpdate(ctx, arg.Username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		open, err := q.CountOpenAccounts(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrOpenAccounts
+		}
+
+		email, err := deletedUserEmail()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user, err = q.AnonymizeUser(ctx, AnonymizeUserParams{
+			Username:          arg.Username,
+			Email:             email,
+			PasswordChangedAt: now,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteUserTOTP(ctx, arg.Username); err != nil {
+			return err
+		}
+		// accounts the user doesnt own stay with their other members
+		if err = q.DeleteUserAccountMemberships(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteUserAccountInvitations(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteUserBeneficiaries(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteUserWebhookSubscriptions(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.DeleteEmailVerificationTokens(ctx, arg.Username); err != nil {
+			return err
+		}
+		if err = q.InvalidatePasswordResetTokens(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		_, err = appendAuditEvent(ctx, q, AppendAuditEventParams{
+			Meta:         arg.Audit,
+			Action:       AuditActionUserDeleted,
+			ResourceType: AuditResourceUser,
+			ResourceID:   arg.Username,
+			After:        userDeletion{DeletedAt: now},
+		})
+		return err
+	})
+
+	return user, err
+}
+
+type userDeletion struct {
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// emails are unique, so every deleted user needs its own placeholder. the .invalid tld can never be delivered to.
+// it is random rather than derived from the username, otherwise anyone could sign up with it first and
+// make the deletion fail
+func deletedUserEmail() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@deleted.invalid", id), nil
+}
@@ -5,8 +5,46 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
+const anonymizeUser = `-- name: AnonymizeUser :one
+UPDATE users
+SET
+  hashed_password = '',
+  full_name = '',
+  email = $2,
+  email_verified_at = NULL,
+  password_changed_at = $3,
+  deleted_at = $3
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at
+`
+
+type AnonymizeUserParams struct {
+	Username          string    `json:"username"`
+	Email             string    `json:"email"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+}
+
+func (q *Queries) AnonymizeUser(ctx context.Context, arg AnonymizeUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, anonymizeUser, arg.Username, arg.Email, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
 	username,
@@ -14,7 +52,7 @@ INSERT INTO users (
 	full_name,
 	email
  )  VALUES($1, $2, $3, $4) 
-RETURNING username, hashed_password, full_name, email, created_at, password_changed_at
+RETURNING username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at
 `
 
 type CreateUserParams struct {
@@ -39,12 +77,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.CreatedAt,
 		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, created_at, password_changed_at FROM users
+SELECT username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at FROM users
 WHERE username = $1 
 LIMIT 1
 `
@@ -59,6 +100,161 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.CreatedAt,
 		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserForUpdate = `-- name: GetUserForUpdate :one
+SELECT username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at FROM users
+WHERE username = $1
+LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetUserForUpdate(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserForUpdate, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserPasswordChangedAt = `-- name: GetUserPasswordChangedAt :one
+SELECT password_changed_at FROM users
+WHERE username = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserPasswordChangedAt(ctx context.Context, username string) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getUserPasswordChangedAt, username)
+	var password_changed_at time.Time
+	err := row.Scan(&password_changed_at)
+	return password_changed_at, err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = now()
+WHERE username = $1 AND email = $2
+RETURNING username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at
+`
+
+type MarkUserEmailVerifiedParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, arg.Username, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2, password_changed_at = $3
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashedPassword"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.Username, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET
+  full_name = COALESCE($1, full_name),
+  email = COALESCE($2, email),
+  email_verified_at = CASE WHEN COALESCE($2, email) = email THEN email_verified_at ELSE NULL END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, created_at, password_changed_at, role, email_verified_at, deleted_at
+`
+
+type UpdateUserProfileParams struct {
+	FullName sql.NullString `json:"fullName"`
+	Email    sql.NullString `json:"email"`
+	Username string         `json:"username"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.FullName, arg.Email, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.CreatedAt,
+		&i.PasswordChangedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +34,78 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, res.CreatedAt, user.CreatedAt, time.Second)
 }
 
+func TestUpdateUserProfile(t *testing.T) {
+	user := createRandomUser(t)
+	user, err := testQueries.MarkUserEmailVerified(context.Background(), MarkUserEmailVerifiedParams{Username: user.Username, Email: user.Email})
+	require.NoError(t, err)
+
+	// the same email keeps the verification
+	updated, err := testQueries.UpdateUserProfile(context.Background(), UpdateUserProfileParams{
+		Username: user.Username,
+		FullName: sql.NullString{String: "new name", Valid: true},
+		Email:    sql.NullString{String: user.Email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "new name", updated.FullName)
+	require.True(t, updated.EmailVerifiedAt.Valid)
+
+	newEmail := library.RandomOwner() + ".new@gmail.com"
+	updated, err = testQueries.UpdateUserProfile(context.Background(), UpdateUserProfileParams{
+		Username: user.Username,
+		Email:    sql.NullString{String: newEmail, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "new name", updated.FullName)
+	require.Equal(t, newEmail, updated.Email)
+	require.False(t, updated.EmailVerifiedAt.Valid)
+}
+
+func TestDeleteUserTx(t *testing.T) {
+	repo := NewRepository(testDB)
+	acc := createRandomAccount(t)
+	owner, err := testQueries.GetUser(context.Background(), acc.Owner)
+	require.NoError(t, err)
+	beneficiary := createRandomBeneficiary(t, owner)
+
+	_, err = repo.DeleteUserTx(context.Background(), DeleteUserTxParams{Username: acc.Owner})
+	require.ErrorIs(t, err, ErrOpenAccounts)
+
+	_, err = testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: acc.ID, Balance: 0})
+	require.NoError(t, err)
+	_, err = testQueries.CloseAccount(context.Background(), acc.ID)
+	require.NoError(t, err)
+
+	// a placeholder derived from the username could be taken by someone else before the deletion
+	_, err = testQueries.CreateUser(context.Background(), CreateUserParams{
+		Username:       library.RandomOwner(),
+		HashedPassword: owner.HashedPassword,
+		FullName:       library.RandomOwner(),
+		Email:          acc.Owner + "@deleted.invalid",
+	})
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteUserTx(context.Background(), DeleteUserTxParams{Username: acc.Owner})
+	require.NoError(t, err)
+	require.True(t, deleted.DeletedAt.Valid)
+	require.Empty(t, deleted.FullName)
+	require.Empty(t, deleted.HashedPassword)
+	require.NotContains(t, deleted.Email, "gmail")
+	require.NotContains(t, deleted.Email, acc.Owner)
+	require.True(t, strings.HasSuffix(deleted.Email, "@deleted.invalid"))
+
+	// the ledger still points to the user
+	closed, err := testQueries.GetAccount(context.Background(), acc.ID)
+	require.NoError(t, err)
+	require.Equal(t, acc.Owner, closed.Owner)
+
+	// saved payees are personal data and go away with the user
+	_, err = testQueries.GetBeneficiary(context.Background(), beneficiary.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = repo.DeleteUserTx(context.Background(), DeleteUserTxParams{Username: acc.Owner})
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
+
 func createRandomUser(t *testing.T) User {
 	hashedPw, err := auth.HashPassword(library.RandomString(10))
 	require.NoError(t, err)
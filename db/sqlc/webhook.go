@@ -0,0 +1,8 @@
+package db
+
+// statuses of a webhook delivery
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead" // failed too often, only retried on request
+)
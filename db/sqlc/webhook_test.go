@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTxQueuesWebhooks(t *testing.T) {
+	repo := NewRepository(testDB)
+
+	from := createRandomMemberAccount(t)
+	to := createRandomMemberAccount(t)
+	from, err := testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: from.ID, Balance: 1000000})
+	require.NoError(t, err)
+
+	received := createRandomWebhook(t, to.Owner, EventTransferReceived)
+	// the sender subscribed to other events only
+	sent := createRandomWebhook(t, from.Owner, EventAccountClosed)
+
+	result, err := repo.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        10,
+		Reference:     "WEBHOOK",
+	})
+	require.NoError(t, err)
+
+	deliveries, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		SubscriptionID: received.ID,
+		LimitCount:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, EventTransferReceived, deliveries[0].EventType)
+	require.Equal(t, WebhookDeliveryPending, deliveries[0].Status)
+
+	deliveries, err = testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		SubscriptionID: sent.ID,
+		LimitCount:     10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+
+	// both accounts got their event, whether anybody subscribed or not
+	var event TransferEvent
+	var payload json.RawMessage
+	err = testDB.QueryRow(`SELECT payload FROM outbox WHERE account_id = $1 AND event_type = $2`, to.ID, EventTransferReceived).Scan(&payload)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(payload, &event))
+	require.Equal(t, result.Transfer.PublicID, event.TransferID)
+	require.Equal(t, from.Number, event.FromAccount)
+	require.Equal(t, to.Number, event.ToAccount)
+	require.Equal(t, "WEBHOOK", event.Reference)
+
+	var count int
+	err = testDB.QueryRow(`SELECT count(*) FROM outbox WHERE account_id = $1 AND event_type = $2`, from.ID, EventTransferCreated).Scan(&count)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestWebhookDeliveryLifecycle(t *testing.T) {
+	acc := createRandomMemberAccount(t)
+	sub := createRandomWebhook(t, acc.Owner, EventAccountClosed)
+
+	_, err := testQueries.UpdateAccountBalance(context.Background(), UpdateAccountBalanceParams{ID: acc.ID, Balance: 0})
+	require.NoError(t, err)
+	closed, err := NewRepository(testDB).CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: acc.ID})
+	require.NoError(t, err)
+	require.True(t, closed.ClosedAt.Valid)
+
+	deliveries, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		SubscriptionID: sub.ID,
+		LimitCount:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+
+	failed, err := testQueries.RecordWebhookDeliveryAttempt(context.Background(), RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         WebhookDeliveryDead,
+		NextAttemptAt:  time.Now(),
+		LastStatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		LastError:      "receiver answered with 500",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), failed.Attempts)
+
+	// a dead delivery is never claimed again
+	claimed, err := testQueries.ClaimWebhookDeliveries(context.Background(), ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		LimitCount: 1000,
+	})
+	require.NoError(t, err)
+	for _, c := range claimed {
+		require.NotEqual(t, delivery.ID, c.ID)
+	}
+
+	retried, err := testQueries.RetryWebhookDelivery(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, WebhookDeliveryPending, retried.Status)
+	require.Zero(t, retried.Attempts)
+
+	_, err = testQueries.RetryWebhookDelivery(context.Background(), delivery.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// the subscription takes its deliveries with it
+	require.NoError(t, testQueries.DeleteWebhookSubscription(context.Background(), sub.ID))
+	_, err = testQueries.GetWebhookDelivery(context.Background(), delivery.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func createRandomWebhook(t *testing.T, username string, eventTypes ...string) WebhookSubscription {
+	sub, err := testQueries.CreateWebhookSubscription(context.Background(), CreateWebhookSubscriptionParams{
+		Username:   username,
+		Url:        "https://example.com/hooks",
+		Secret:     "whsec_test",
+		EventTypes: eventTypes,
+	})
+	require.NoError(t, err)
+	require.Equal(t, eventTypes, sub.EventTypes)
+
+	return sub
+}
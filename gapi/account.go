@@ -0,0 +1,161 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// roles that can send money from an account, viewers can just look at it
+var spendingRoles = []string{db.AccountRoleOwner, db.AccountRoleCoOwner}
+
+func (server *Server) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	payload, err := authPayload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCurrency("currency", req.GetCurrency()); err != nil {
+		return nil, err
+	}
+	if len(req.GetNickname()) > 50 {
+		return nil, invalidArgument("nickname", "must not be longer than 50 characters")
+	}
+	if err := validateAccountType("type", req.GetType()); err != nil {
+		return nil, err
+	}
+	if err := server.requireVerifiedEmail(ctx, payload.Username); err != nil {
+		return nil, err
+	}
+
+	arg := db.CreateAccountParams{
+		Owner:    payload.Username,
+		Currency: req.GetCurrency(),
+		Nickname: req.GetNickname(),
+		Type:     req.GetType(),
+	}
+	if arg.Type == "" {
+		arg.Type = db.AccountTypeChecking
+	}
+
+	acc, err := server.repository.CreateAccountTx(ctx, arg)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return nil, status.Error(codes.PermissionDenied, pqErr.Error())
+		}
+		return nil, internalError(err)
+	}
+
+	_, err = server.repository.AppendAuditEvent(ctx, db.AppendAuditEventParams{
+		Meta:         auditMeta(ctx, payload.Username),
+		Action:       db.AuditActionAccountCreated,
+		ResourceType: db.AuditResourceAccount,
+		ResourceID:   strconv.FormatInt(acc.ID, 10),
+		After:        acc,
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return &pb.CreateAccountResponse{Account: convertAccount(acc)}, nil
+}
+
+func (server *Server) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	payload, err := authPayload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	acc, err := server.loadAccount(ctx, "number", req.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	// every role can read the account
+	if err := server.authorizeAccount(ctx, acc, payload.Username); err != nil {
+		return nil, err
+	}
+
+	return &pb.GetAccountResponse{Account: convertAccount(acc)}, nil
+}
+
+func (server *Server) ListAccounts(ctx context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	payload, err := authPayload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetPage() < 1 {
+		return nil, invalidArgument("page", "must be at least 1")
+	}
+	if req.GetLimit() < 5 || req.GetLimit() > 50 {
+		return nil, invalidArgument("limit", "must be between 5 and 50")
+	}
+	if req.GetCurrency() != "" {
+		if err := validateCurrency("currency", req.GetCurrency()); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateAccountType("type", req.GetType()); err != nil {
+		return nil, err
+	}
+
+	// only list the accounts the caller is a member of
+	accs, err := server.repository.ListMemberAccounts(ctx, db.ListMemberAccountsParams{
+		Username: payload.Username,
+		Currency: sql.NullString{String: req.GetCurrency(), Valid: req.GetCurrency() != ""},
+		Type:     sql.NullString{String: req.GetType(), Valid: req.GetType() != ""},
+		Limit:    req.GetLimit(),
+		Offset:   (req.GetPage() - 1) * req.GetLimit(),
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	resp := &pb.ListAccountsResponse{Accounts: make([]*pb.Account, 0, len(accs))}
+	for _, acc := range accs {
+		resp.Accounts = append(resp.Accounts, convertAccount(acc))
+	}
+	return resp, nil
+}
+
+// loadAccount looks up an account by the number in the given request field
+func (server *Server) loadAccount(ctx context.Context, field, number string) (db.Account, error) {
+	if err := validateAccountNumber(field, number); err != nil {
+		return db.Account{}, err
+	}
+
+	acc, err := server.repository.GetAccountByNumber(ctx, number)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Account{}, status.Errorf(codes.NotFound, "account [%s] not found", number)
+		}
+		return db.Account{}, internalError(err)
+	}
+	return acc, nil
+}
+
+// authorizeAccount checks that the user is a member of the account and, if any roles are passed, has one of them
+func (server *Server) authorizeAccount(ctx context.Context, acc db.Account, username string, roles ...string) error {
+	member, err := server.repository.GetAccountMember(ctx, db.GetAccountMemberParams{AccountID: acc.ID, Username: username})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return status.Errorf(codes.PermissionDenied, "not authorized to access account [%s]", acc.Number)
+		}
+		return internalError(err)
+	}
+
+	if len(roles) == 0 {
+		return nil
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "the %s role is not allowed to do this on account [%s]", member.Role, acc.Number)
+}
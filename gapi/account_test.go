@@ -0,0 +1,245 @@
+package gapi
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+func TestCreateAccountRPC(t *testing.T) {
+	user, _ := randomUser(t)
+	acc := randomAccount(user.Username)
+	acc.Balance = 0
+
+	testCases := []struct {
+		name       string
+		req        *pb.CreateAccountRequest
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.CreateAccountResponse, err error)
+	}{
+		{
+			name: "OK",
+			req:  &pb.CreateAccountRequest{Currency: acc.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.CreateAccountParams{Owner: user.Username, Currency: acc.Currency, Type: db.AccountTypeChecking}
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(acc, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionAccountCreated)).Times(1)
+			},
+			check: func(t *testing.T, res *pb.CreateAccountResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, acc.Number, res.Account.Number)
+				require.Equal(t, acc.Currency, res.Account.Currency)
+				require.Nil(t, res.Account.ClosedAt)
+			},
+		},
+		{
+			name: "UnsupportedCurrency",
+			req:  &pb.CreateAccountRequest{Currency: "XYZ"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateAccountResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name: "InvalidType",
+			req:  &pb.CreateAccountRequest{Currency: acc.Currency, Type: "crypto"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateAccountResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			client := newTestClient(t, server)
+			res, err := client.CreateAccount(withAccessToken(t, server.tokenMaker, user.Username), tc.req)
+			tc.check(t, res, err)
+		})
+	}
+}
+
+func TestGetAccountRPC(t *testing.T) {
+	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
+	acc := randomAccount(user.Username)
+
+	testCases := []struct {
+		name       string
+		username   string
+		number     string
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.GetAccountResponse, err error)
+	}{
+		{
+			name:     "OK",
+			username: viewer.Username,
+			number:   acc.Number,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				expectAccountMember(repo, acc, viewer.Username, db.AccountRoleViewer)
+			},
+			check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, acc.Number, res.Account.Number)
+				require.Equal(t, acc.Balance, res.Account.Balance)
+				require.Equal(t, acc.Owner, res.Account.Owner)
+			},
+		},
+		{
+			name:     "NotMember",
+			username: viewer.Username,
+			number:   acc.Number,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(acc, nil)
+				repo.EXPECT().GetAccountMember(gomock.Any(), gomock.Any()).Times(1).Return(db.AccountMember{}, sql.ErrNoRows)
+			},
+			check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				requireCode(t, codes.PermissionDenied, err)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			number:   acc.Number,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(acc.Number)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				requireCode(t, codes.NotFound, err)
+			},
+		},
+		{
+			name:     "InvalidNumber",
+			username: user.Username,
+			number:   "DE00",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name:     "InternalError",
+			username: user.Username,
+			number:   acc.Number,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrConnDone)
+			},
+			check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				requireCode(t, codes.Internal, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			client := newTestClient(t, server)
+			res, err := client.GetAccount(withAccessToken(t, server.tokenMaker, tc.username), &pb.GetAccountRequest{Number: tc.number})
+			tc.check(t, res, err)
+		})
+	}
+}
+
+func TestListAccountsRPC(t *testing.T) {
+	user, _ := randomUser(t)
+	accs := []db.Account{randomAccount(user.Username), randomAccount(user.Username)}
+
+	testCases := []struct {
+		name       string
+		req        *pb.ListAccountsRequest
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.ListAccountsResponse, err error)
+	}{
+		{
+			name: "OK",
+			req:  &pb.ListAccountsRequest{Page: 2, Limit: 5, Type: db.AccountTypeChecking},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				arg := db.ListMemberAccountsParams{
+					Username: user.Username,
+					Type:     sql.NullString{String: db.AccountTypeChecking, Valid: true},
+					Limit:    5,
+					Offset:   5,
+				}
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Eq(arg)).Times(1).Return(accs, nil)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				require.NoError(t, err)
+				require.Len(t, res.Accounts, len(accs))
+				for i, acc := range accs {
+					require.Equal(t, acc.Number, res.Accounts[i].Number)
+				}
+			},
+		},
+		{
+			name: "InvalidLimit",
+			req:  &pb.ListAccountsRequest{Page: 1, Limit: 100},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name: "InvalidPage",
+			req:  &pb.ListAccountsRequest{Limit: 5},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			client := newTestClient(t, server)
+			res, err := client.ListAccounts(withAccessToken(t, server.tokenMaker, user.Username), tc.req)
+			tc.check(t, res, err)
+		})
+	}
+}
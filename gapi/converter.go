@@ -0,0 +1,53 @@
+package gapi
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// convertUser only returns insensitive information, like newUserResponse of the http api
+func convertUser(user db.User) *pb.User {
+	resp := &pb.User{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		PasswordChangedAt: timestamppb.New(user.PasswordChangedAt),
+		CreatedAt:         timestamppb.New(user.CreatedAt),
+	}
+	if user.EmailVerifiedAt.Valid {
+		resp.EmailVerifiedAt = timestamppb.New(user.EmailVerifiedAt.Time)
+	}
+	return resp
+}
+
+func convertAccount(acc db.Account) *pb.Account {
+	resp := &pb.Account{
+		Number:    acc.Number,
+		Owner:     acc.Owner,
+		Nickname:  acc.Nickname,
+		Type:      acc.Type,
+		Balance:   acc.Balance,
+		Currency:  acc.Currency,
+		CreatedAt: timestamppb.New(acc.CreatedAt),
+	}
+	if acc.ClosedAt.Valid {
+		resp.ClosedAt = timestamppb.New(acc.ClosedAt.Time)
+	}
+	return resp
+}
+
+// convertTransfer only contains what the sender is allowed to see, the receivers balance and the internal ids stay hidden
+func convertTransfer(trf db.TransferTxResult) *pb.Transfer {
+	return &pb.Transfer{
+		FromAccount: trf.FromAccount.Number,
+		ToAccount:   trf.ToAccount.Number,
+		Amount:      trf.Transfer.Amount,
+		Currency:    trf.FromAccount.Currency,
+		Description: trf.Transfer.Description,
+		Reference:   trf.Transfer.Reference,
+		Fee:         trf.Transfer.Fee,
+		CreatedAt:   timestamppb.New(trf.Transfer.CreatedAt),
+	}
+}
@@ -0,0 +1,143 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+const (
+	authorizationKey = "authorization"
+	authTypeBearer   = "bearer"
+
+	requestIDKey       = "x-request-id"
+	userAgentKey       = "user-agent"
+	maxRequestIDLength = 128
+)
+
+// methods that can be called without an access token
+var publicMethods = map[string]bool{
+	"/pb.Bank/CreateUser": true,
+	"/pb.Bank/LoginUser":  true,
+}
+
+type contextKey int
+
+const (
+	authPayloadContextKey contextKey = iota // the auth payload of the caller, see authPayload
+	requestIDContextKey
+)
+
+// authInterceptor is the grpc version of the authMiddleware of the http api. it rejects calls without a valid
+// access token in the authorization metadata, and tokens issued before the users last password change
+func authInterceptor(tokenMaker auth.TokenMaker, repo db.Repository) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(authorizationKey)
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+		}
+
+		fields := strings.Fields(values[0])
+		if len(fields) < 2 {
+			return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+		}
+
+		authType := strings.ToLower(fields[0])
+		if authType != authTypeBearer {
+			return nil, status.Errorf(codes.Unauthenticated, "unsupported authorization type %v", authType)
+		}
+
+		payload, err := tokenMaker.VerifyToken(fields[1])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		if payload.Scope != auth.ScopeAccess {
+			// e.g. the token of an unfinished two-factor login
+			return nil, status.Error(codes.Unauthenticated, "token is not an access token")
+		}
+
+		passwordChangedAt, err := repo.GetUserPasswordChangedAt(ctx, payload.Username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return nil, internalError(err)
+		}
+
+		if payload.IssuedAt.Before(passwordChangedAt) {
+			return nil, status.Error(codes.Unauthenticated, "token was issued before the last password change")
+		}
+
+		return handler(context.WithValue(ctx, authPayloadContextKey, payload), req)
+	}
+}
+
+// requestIDInterceptor makes sure every call carries an id that can be correlated across logs and the audit trail.
+// an id sent by the client is being reused, otherwise a new one is generated. it is sent back in the header metadata
+func requestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDKey); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID)); err != nil {
+			return nil, internalError(fmt.Errorf("cannot send request id: %w", err))
+		}
+
+		return handler(context.WithValue(ctx, requestIDContextKey, requestID), req)
+	}
+}
+
+// authPayload returns the payload of the access token the call was authorized with
+func authPayload(ctx context.Context) (*auth.Payload, error) {
+	payload, ok := ctx.Value(authPayloadContextKey).(*auth.Payload)
+	if !ok {
+		return nil, internalError(errors.New("call was not authorized"))
+	}
+	return payload, nil
+}
+
+// auditMeta describes who made the call, like auditMeta of the http api
+func auditMeta(ctx context.Context, actor string) db.AuditMeta {
+	meta := db.AuditMeta{Actor: actor}
+	meta.RequestID, _ = ctx.Value(requestIDContextKey).(string)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		meta.IPAddress = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.IPAddress); err == nil {
+			meta.IPAddress = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(userAgentKey); len(values) > 0 {
+			meta.UserAgent = values[0]
+		}
+	}
+
+	return meta
+}
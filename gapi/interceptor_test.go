@@ -0,0 +1,198 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+type eqAuditActionMatcher struct {
+	action string
+}
+
+// matches any AppendAuditEventParams that records the given action and carries a request id
+func (eq eqAuditActionMatcher) Matches(x interface{}) bool {
+	arg, ok := x.(db.AppendAuditEventParams)
+	if !ok {
+		return false
+	}
+
+	return arg.Action == eq.action && arg.Meta.RequestID != ""
+}
+
+func (eq eqAuditActionMatcher) String() string {
+	return fmt.Sprintf("records audit action %v", eq.action)
+}
+
+func eqAuditAction(action string) gomock.Matcher {
+	return eqAuditActionMatcher{action}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	user, _ := randomUser(t)
+	req := &pb.ListAccountsRequest{Page: 1, Limit: 5}
+
+	testCases := []struct {
+		name       string
+		setupAuth  func(t *testing.T, tm auth.TokenMaker) context.Context
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.ListAccountsResponse, err error)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				return withAccessToken(t, tm, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(time.Now().Add(-time.Hour), nil)
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(1).Return([]db.Account{}, nil)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				require.NoError(t, err)
+				require.Empty(t, res.Accounts)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				return context.Background()
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "UnsupportedAuthorizationType",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				token, err := tm.CreateToken(user.Username, time.Minute)
+				require.NoError(t, err)
+				return metadata.AppendToOutgoingContext(context.Background(), authorizationKey, "basic "+token)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				token, err := tm.CreateToken(user.Username, -time.Minute)
+				require.NoError(t, err)
+				return metadata.AppendToOutgoingContext(context.Background(), authorizationKey, "bearer "+token)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "TwoFactorToken",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				token, err := tm.CreateScopedToken(user.Username, auth.ScopeTwoFactor, time.Minute)
+				require.NoError(t, err)
+				return metadata.AppendToOutgoingContext(context.Background(), authorizationKey, "bearer "+token)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "IssuedBeforePasswordChange",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				return withAccessToken(t, tm, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(time.Now().Add(time.Minute), nil)
+				repo.EXPECT().ListMemberAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "UserDeleted",
+			setupAuth: func(t *testing.T, tm auth.TokenMaker) context.Context {
+				return withAccessToken(t, tm, user.Username)
+			},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(time.Time{}, sql.ErrNoRows)
+			},
+			check: func(t *testing.T, res *pb.ListAccountsResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			server := newTestServer(t, repo)
+			client := newTestClient(t, server)
+
+			res, err := client.ListAccounts(tc.setupAuth(t, server.tokenMaker), req)
+			tc.check(t, res, err)
+		})
+	}
+}
+
+func TestRequestIDInterceptor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(2).Return(db.User{}, sql.ErrConnDone)
+
+	client := newTestClient(t, newTestServer(t, repo))
+	req := &pb.LoginUserRequest{Username: "someone", Password: "secret123"}
+
+	// an id sent by the client is reused, even if the call fails
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDKey, "client-id")
+	_, err := client.LoginUser(ctx, req, grpc.Header(&header))
+	requireCode(t, codes.Internal, err)
+	require.Equal(t, []string{"client-id"}, header.Get(requestIDKey))
+
+	_, err = client.LoginUser(context.Background(), req, grpc.Header(&header))
+	requireCode(t, codes.Internal, err)
+	require.Len(t, header.Get(requestIDKey), 1)
+	require.NotEqual(t, "client-id", header.Get(requestIDKey)[0])
+}
@@ -0,0 +1,123 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/maxeth/go-bank-app/auth"
+	"github.com/maxeth/go-bank-app/config"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/library"
+	"github.com/maxeth/go-bank-app/notify"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+func newTestServer(t *testing.T, repo db.Repository) *Server {
+	conf := config.Config{
+		TokenSummetricKey:   library.RandomString(32),
+		AccessTokenDuration: time.Second * 15,
+		PasswordMinLength:   8,
+
+		EmailVerificationTokenDuration: time.Hour,
+		TwoFactorTokenDuration:         time.Minute,
+		MaxBodySize:                    1 << 20,
+	}
+
+	tokenMaker, err := auth.NewPasetoMaker(conf.TokenSummetricKey)
+	require.NoError(t, err)
+
+	return NewServer(conf, repo, tokenMaker, notify.NewLogNotifier(nil))
+}
+
+// newTestClient serves the server on an in-memory listener and returns a client connected to it
+func newTestClient(t *testing.T, server *Server) pb.BankClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	grpcServer := server.newGRPCServer(nil)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewBankClient(conn)
+}
+
+// withAccessToken adds the authorization metadata of the user to an outgoing call
+func withAccessToken(t *testing.T, tm auth.TokenMaker, username string) context.Context {
+	token, err := tm.CreateToken(username, time.Minute)
+	require.NoError(t, err)
+
+	md := metadata.Pairs(authorizationKey, fmt.Sprintf("%s %s", authTypeBearer, token))
+	return metadata.NewOutgoingContext(context.Background(), md)
+}
+
+// makes authInterceptor accept every token, for tests that arent about password changes
+func allowAnyToken(repo *mockdb.MockRepository) {
+	repo.EXPECT().GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).AnyTimes().Return(time.Time{}, nil)
+}
+
+func requireCode(t *testing.T, code codes.Code, err error) {
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, code, st.Code(), st.Message())
+}
+
+func randomUser(t *testing.T) (user db.User, password string) {
+	password = library.RandomString(15)
+	hashedPw, err := auth.HashPassword(password)
+	require.NoError(t, err)
+
+	user = db.User{
+		Username:          library.RandomString(10),
+		Email:             library.RandomString(15) + ".lastname@gmail.com",
+		HashedPassword:    hashedPw,
+		FullName:          library.RandomString(5),
+		PasswordChangedAt: time.Now().Add(-time.Hour),
+		CreatedAt:         time.Now().Add(-time.Hour),
+	}
+
+	return
+}
+
+func randomAccount(owner string) db.Account {
+	number, err := library.NewAccountNumber()
+	if err != nil {
+		panic(err)
+	}
+
+	return db.Account{
+		ID:       library.RandomInt(1, 100),
+		Number:   number,
+		Owner:    owner,
+		Balance:  library.RandomBalance(),
+		Currency: library.RandomCurrency(),
+		Type:     db.AccountTypeChecking,
+	}
+}
+
+func expectAccountMember(repo *mockdb.MockRepository, acc db.Account, username, role string) {
+	repo.EXPECT().
+		GetAccountMember(gomock.Any(), gomock.Eq(db.GetAccountMemberParams{AccountID: acc.ID, Username: username})).
+		Times(1).
+		Return(db.AccountMember{AccountID: acc.ID, Username: username, Role: role}, nil)
+}
@@ -0,0 +1,81 @@
+package gapi
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/maxeth/go-bank-app/auth"
+	"github.com/maxeth/go-bank-app/config"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/notify"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// Server implements the grpc api on top of the same repository and tokens as the http api, see proto/service_bank.proto
+type Server struct {
+	pb.UnimplementedBankServer
+	config     config.Config
+	repository db.Repository
+	tokenMaker auth.TokenMaker
+	notifier   notify.Notifier
+
+	passwordPolicy auth.PasswordPolicy
+}
+
+func NewServer(conf config.Config, repo db.Repository, tokenMaker auth.TokenMaker, notifier notify.Notifier) *Server {
+	return &Server{
+		config:     conf,
+		repository: repo,
+		tokenMaker: tokenMaker,
+		notifier:   notifier,
+		passwordPolicy: auth.PasswordPolicy{
+			MinLength:     conf.PasswordMinLength,
+			RequireUpper:  conf.PasswordRequireUpper,
+			RequireLower:  conf.PasswordRequireLower,
+			RequireDigit:  conf.PasswordRequireDigit,
+			RequireSymbol: conf.PasswordRequireSymbol,
+		},
+	}
+}
+
+// newGRPCServer creates a grpc server with every interceptor and the bank service registered. without tls settings
+// it serves plain text
+func (server *Server) newGRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			requestIDInterceptor(),
+			authInterceptor(server.tokenMaker, server.repository),
+		),
+		// same limit as for the bodies of http requests
+		grpc.MaxRecvMsgSize(int(server.config.MaxBodySize)),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(options...)
+	pb.RegisterBankServer(grpcServer, server)
+
+	return grpcServer
+}
+
+// Start serves the grpc api with the tls settings of the http api, see api.NewTLSConfig. access tokens and
+// passwords would go over the network in plain text otherwise, so tlsConfig is only nil without a certificate
+func (server *Server) Start(address string, tlsConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %w", address, err)
+	}
+
+	return server.newGRPCServer(tlsConfig).Serve(listener)
+}
+
+func internalError(err error) error {
+	return status.Error(codes.Internal, err.Error())
+}
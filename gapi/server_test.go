@@ -0,0 +1,175 @@
+package gapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/maxeth/go-bank-app/api"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// the grpc server is served with the tls settings of the http api, including mutual tls
+func TestTLSServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, "test ca", nil)
+	server := newTestCertificate(t, "localhost", &ca)
+	client := newTestCertificate(t, "ledger.internal", &ca)
+
+	writePEM := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, data, 0600))
+		return path
+	}
+	caFile := writePEM("ca.crt", ca.certPEM)
+	certFile := writePEM("server.crt", server.certPEM)
+	keyFile := writePEM("server.key", server.keyPEM)
+
+	clientCert, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	testCases := []struct {
+		name               string
+		clientCA           bool
+		clientAuthRequired bool
+		dialOption         grpc.DialOption
+		code               codes.Code
+	}{
+		{
+			name:       "TLS",
+			dialOption: grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost"})),
+			// the call made it to the server, which wants an access token
+			code: codes.Unauthenticated,
+		},
+		{
+			name:       "PlainTextClient",
+			dialOption: grpc.WithInsecure(),
+			code:       codes.Unavailable,
+		},
+		{
+			name:               "ClientCertificate",
+			clientCA:           true,
+			clientAuthRequired: true,
+			dialOption:         grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: []tls.Certificate{clientCert}})),
+			code:               codes.Unauthenticated,
+		},
+		{
+			name:               "ClientCertificateMissing",
+			clientCA:           true,
+			clientAuthRequired: true,
+			dialOption:         grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost"})),
+			code:               codes.Unavailable,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			bank := newTestServer(t, mockdb.NewMockRepository(ctrl))
+			bank.config.TLSCertFile = certFile
+			bank.config.TLSKeyFile = keyFile
+			if tc.clientCA {
+				bank.config.TLSClientCAFile = caFile
+			}
+			bank.config.TLSClientAuthRequired = tc.clientAuthRequired
+
+			tlsConfig, err := api.NewTLSConfig(bank.config)
+			require.NoError(t, err)
+
+			listener := bufconn.Listen(1024 * 1024)
+			grpcServer := bank.newGRPCServer(tlsConfig)
+			go grpcServer.Serve(listener)
+			defer grpcServer.Stop()
+
+			conn, err := grpc.DialContext(context.Background(), "bufnet",
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return listener.Dial()
+				}),
+				tc.dialOption,
+			)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err = pb.NewBankClient(conn).ListAccounts(ctx, &pb.ListAccountsRequest{})
+			requireCode(t, tc.code, err)
+		})
+	}
+}
+
+// testCertificate is a certificate with its key, pem encoded for the config files
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCertificate issues a certificate for localhost signed by the ca, or a self-signed ca without one
+func newTestCertificate(t *testing.T, commonName string, ca *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, parentKey := template, key
+	if ca == nil {
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+		template.BasicConstraintsValid = true
+		template.IsCA = true
+	} else {
+		parent, parentKey = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
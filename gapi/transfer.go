@@ -0,0 +1,148 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// CreateTransfer runs the checks of POST /transfers. beneficiaries, metadata and quotes are only offered by the http api
+func (server *Server) CreateTransfer(ctx context.Context, req *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	payload, err := authPayload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetAmount() <= 0 {
+		return nil, invalidArgument("amount", "must be positive")
+	}
+	if err := validateCurrency("currency", req.GetCurrency()); err != nil {
+		return nil, err
+	}
+	if err := validateFreeText("description", req.GetDescription(), 140); err != nil {
+		return nil, err
+	}
+	if err := validateReference("reference", req.GetReference()); err != nil {
+		return nil, err
+	}
+	if err := server.requireVerifiedEmail(ctx, payload.Username); err != nil {
+		return nil, err
+	}
+
+	accFrom, err := server.loadTransferAccount(ctx, "from_account", req.GetFromAccount(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+	if err := server.authorizeAccount(ctx, accFrom, payload.Username, spendingRoles...); err != nil {
+		return nil, err
+	}
+	if accFrom.Balance-req.GetAmount() < 0 {
+		return nil, status.Error(codes.FailedPrecondition, "sender does not have enough balance to perform this transfer")
+	}
+
+	accTo, err := server.loadTransferAccount(ctx, "to_account", req.GetToAccount(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+	if accTo.ID == accFrom.ID {
+		return nil, invalidArgument("to_account", "cannot make transfer to the same account")
+	}
+
+	// large transfers need a fresh second factor, a stolen access token alone isnt enough to move them
+	if server.config.TOTPStepUpAmount > 0 && req.GetAmount() > server.config.TOTPStepUpAmount {
+		if err := server.checkTOTP(ctx, payload.Username, req.GetTotpCode()); err != nil {
+			return nil, err
+		}
+	}
+
+	trf, err := server.repository.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: accFrom.ID,
+		ToAccountID:   accTo.ID,
+		Amount:        req.GetAmount(),
+		Description:   req.GetDescription(),
+		Reference:     req.GetReference(),
+		Audit:         auditMeta(ctx, payload.Username),
+	})
+	if err != nil {
+		if err == db.ErrInsufficientFunds || err == db.ErrAccountClosed {
+			// the amount alone was covered but the fee wasnt, or an account was closed since it was checked
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, internalError(err)
+	}
+
+	return &pb.CreateTransferResponse{
+		Transfer: convertTransfer(trf),
+		Balance:  trf.FromAccount.Balance,
+	}, nil
+}
+
+// loadTransferAccount looks up an open account of the transfer and checks its currency
+func (server *Server) loadTransferAccount(ctx context.Context, field, number, currency string) (db.Account, error) {
+	acc, err := server.loadAccount(ctx, field, number)
+	if err != nil {
+		return db.Account{}, err
+	}
+	// the fee revenue and interest expense accounts of the bank are only posted to by the bank itself
+	if acc.Owner == db.SystemUsername {
+		return db.Account{}, status.Errorf(codes.NotFound, "account [%s] not found", number)
+	}
+	if acc.ClosedAt.Valid {
+		return db.Account{}, status.Errorf(codes.FailedPrecondition, "account [%s] is closed", acc.Number)
+	}
+	if acc.Currency != currency {
+		return db.Account{}, invalidArgument(field, "expected currency %s received %s", acc.Currency, currency)
+	}
+	return acc, nil
+}
+
+// checkTOTP checks a code of the callers authenticator app. unlike at login, recovery codes cant be used here
+func (server *Server) checkTOTP(ctx context.Context, username, code string) error {
+	if code == "" {
+		return status.Errorf(codes.Unauthenticated, "transfers above %d require a totp code", server.config.TOTPStepUpAmount)
+	}
+
+	totp, err := server.repository.GetUserTOTP(ctx, username)
+	if err != nil && err != sql.ErrNoRows {
+		return internalError(err)
+	}
+	if err == sql.ErrNoRows || !totp.ConfirmedAt.Valid {
+		return status.Error(codes.PermissionDenied, "two-factor authentication is not enabled")
+	}
+
+	// shares the limit of failed attempts with the http api, every attempt is counted before the code is checked
+	_, err = server.repository.ClaimSecondFactorAttempt(ctx, db.ClaimSecondFactorAttemptParams{
+		Username:     username,
+		MaxFailures:  auth.MaxSecondFactorFailures,
+		LockedBefore: time.Now().Add(-auth.SecondFactorLockout),
+	})
+	if err == sql.ErrNoRows {
+		return status.Error(codes.ResourceExhausted, "too many invalid two-factor codes, try again later")
+	}
+	if err != nil {
+		return internalError(err)
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid two-factor code")
+	}
+	// fails if a code of this step has been accepted before
+	_, err = server.repository.UseTOTPStep(ctx, db.UseTOTPStepParams{Username: username, LastUsedStep: step})
+	if err == sql.ErrNoRows {
+		return status.Error(codes.Unauthenticated, "invalid two-factor code")
+	}
+	if err != nil {
+		return internalError(err)
+	}
+	if err = server.repository.ResetSecondFactorAttempts(ctx, username); err != nil {
+		return internalError(err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package gapi
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+func TestCreateTransferRPC(t *testing.T) {
+	user, _ := randomUser(t)
+	viewer, _ := randomUser(t)
+	other, _ := randomUser(t)
+
+	from := randomAccount(user.Username)
+	to := randomAccount(other.Username)
+	to.Currency = from.Currency
+	to.ID = from.ID + 1
+	from.Balance = 1000
+	amount := int64(10)
+
+	testCases := []struct {
+		name       string
+		username   string
+		req        *pb.CreateTransferRequest
+		stepUp     int64
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.CreateTransferResponse, err error)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency, Reference: "INV-1"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(to, nil)
+				repo.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.TransferTxParams) (db.TransferTxResult, error) {
+						require.Equal(t, from.ID, arg.FromAccountID)
+						require.Equal(t, to.ID, arg.ToAccountID)
+						require.Equal(t, amount, arg.Amount)
+						require.Equal(t, "INV-1", arg.Reference)
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						require.NotEmpty(t, arg.Audit.RequestID)
+
+						result := db.TransferTxResult{
+							Transfer:    db.Transfer{FromAccountID: from.ID, ToAccountID: to.ID, Amount: amount, Reference: arg.Reference, Fee: 2},
+							FromAccount: from,
+							ToAccount:   to,
+						}
+						result.FromAccount.Balance -= amount + 2
+						return result, nil
+					})
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, from.Number, res.Transfer.FromAccount)
+				require.Equal(t, to.Number, res.Transfer.ToAccount)
+				require.Equal(t, int64(2), res.Transfer.Fee)
+				require.Equal(t, from.Balance-amount-2, res.Balance)
+			},
+		},
+		{
+			name:     "Viewer",
+			username: viewer.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, viewer.Username, db.AccountRoleViewer)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.PermissionDenied, err)
+			},
+		},
+		{
+			name:     "SystemReceiver",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				system := to
+				system.Owner = db.SystemUsername
+
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(system, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.NotFound, err)
+			},
+		},
+		{
+			name:     "CurrencyMismatch",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: otherCurrency(from.Currency)},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name:     "SameAccount",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: from.Number, Amount: amount, Currency: from.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(2).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name:     "FeeNotCovered",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: from.Balance, Currency: from.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(to, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.FailedPrecondition, err)
+			},
+		},
+		{
+			name:     "ClosedDuringTransfer",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(to, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrAccountClosed)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.FailedPrecondition, err)
+			},
+		},
+		{
+			name:     "TOTPCodeMissing",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency},
+			stepUp:   amount - 1,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(to, nil)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name:     "TOTPLockedOut",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency, TotpCode: "123456"},
+			stepUp:   amount - 1,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(from.Number)).Times(1).Return(from, nil)
+				expectAccountMember(repo, from, user.Username, db.AccountRoleOwner)
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Eq(to.Number)).Times(1).Return(to, nil)
+				repo.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, Secret: "JBSWY3DPEHPK3PXP", ConfirmedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+				repo.EXPECT().
+					ClaimSecondFactorAttempt(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ClaimSecondFactorAttemptParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, int32(auth.MaxSecondFactorFailures), arg.MaxFailures)
+						return db.UserTotp{}, sql.ErrNoRows
+					})
+				repo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.ResourceExhausted, err)
+			},
+		},
+		{
+			name:     "InvalidReference",
+			username: user.Username,
+			req:      &pb.CreateTransferRequest{FromAccount: from.Number, ToAccount: to.Number, Amount: amount, Currency: from.Currency, Reference: "INV_1"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetAccountByNumber(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			allowAnyToken(repo)
+
+			server := newTestServer(t, repo)
+			server.config.TOTPStepUpAmount = tc.stepUp
+			client := newTestClient(t, server)
+			res, err := client.CreateTransfer(withAccessToken(t, server.tokenMaker, tc.username), tc.req)
+			tc.check(t, res, err)
+		})
+	}
+}
+
+// otherCurrency returns a supported currency different from the given one
+func otherCurrency(currency string) string {
+	if currency == "EUR" {
+		return "USD"
+	}
+	return "EUR"
+}
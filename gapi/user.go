@@ -0,0 +1,202 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/maxeth/go-bank-app/auth"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/notify"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+// auditUser has the fields the http api records for a new user, so audit events look the same for both apis.
+// the name and email stay out of the append-only audit log
+type auditUser struct {
+	Username          string    `json:"username"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+func (server *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	if err := validateUsername("username", req.GetUsername()); err != nil {
+		return nil, err
+	}
+	if req.GetFullName() == "" {
+		return nil, invalidArgument("full_name", "is required")
+	}
+	if err := validateEmail("email", req.GetEmail()); err != nil {
+		return nil, err
+	}
+	if err := server.passwordPolicy.Validate(req.GetPassword(), req.GetUsername()); err != nil {
+		return nil, invalidArgument("password", err.Error())
+	}
+
+	hashedPw, err := auth.HashPassword(req.GetPassword())
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	user, err := server.repository.CreateUser(ctx, db.CreateUserParams{
+		Username:       req.GetUsername(),
+		HashedPassword: hashedPw,
+		FullName:       req.GetFullName(),
+		Email:          req.GetEmail(),
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			// unique attribute (username/email) already exists
+			return nil, status.Error(codes.AlreadyExists, pqErr.Error())
+		}
+		return nil, internalError(err)
+	}
+
+	_, err = server.repository.AppendAuditEvent(ctx, db.AppendAuditEventParams{
+		Meta:         auditMeta(ctx, user.Username),
+		Action:       db.AuditActionUserCreated,
+		ResourceType: db.AuditResourceUser,
+		ResourceID:   user.Username,
+		After: auditUser{
+			Username:          user.Username,
+			PasswordChangedAt: user.PasswordChangedAt,
+			CreatedAt:         user.CreatedAt,
+		},
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	// the user exists at this point, failing the call would only make the client retry with a taken username.
+	// a new token can be requested through the resend route of the http api instead
+	if err := server.sendEmailVerification(ctx, user); err != nil {
+		log.Printf("cannot send verification email to %s: %v", user.Username, err)
+	}
+
+	return &pb.CreateUserResponse{User: convertUser(user)}, nil
+}
+
+// sendEmailVerification sends the same message as the http api, the token is redeemed at POST /users/verify-email
+func (server *Server) sendEmailVerification(ctx context.Context, user db.User) error {
+	token, hash, err := auth.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	verification, err := server.repository.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		TokenHash: hash,
+		Username:  user.Username,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(server.config.EmailVerificationTokenDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	return server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Use the following token to verify your email address. It expires at %s.\n\n%s\n\n"+
+			"If you didnt sign up, you can ignore this message.", verification.ExpiresAt.Format(time.RFC1123), token),
+	})
+}
+
+func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (*pb.LoginUserResponse, error) {
+	if err := validateUsername("username", req.GetUsername()); err != nil {
+		return nil, err
+	}
+	if len(req.GetPassword()) < 6 {
+		return nil, invalidArgument("password", "must be at least 6 characters")
+	}
+
+	user, err := server.repository.GetUser(ctx, req.GetUsername())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if auditErr := server.recordLoginFailure(ctx, req.GetUsername(), "unknown user"); auditErr != nil {
+				return nil, internalError(auditErr)
+			}
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, internalError(err)
+	}
+
+	if err = auth.CheckPassword(user.HashedPassword, req.GetPassword()); err != nil {
+		if auditErr := server.recordLoginFailure(ctx, req.GetUsername(), "wrong password"); auditErr != nil {
+			return nil, internalError(auditErr)
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	// users with two-factor authentication get a token that only allows completing the login with a code
+	totp, err := server.repository.GetUserTOTP(ctx, user.Username)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, internalError(err)
+	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		token, err := server.tokenMaker.CreateScopedToken(user.Username, auth.ScopeTwoFactor, server.config.TwoFactorTokenDuration)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		return &pb.LoginUserResponse{TwoFactorRequired: true, TwoFactorToken: token}, nil
+	}
+
+	expiresAt := time.Now().Add(server.config.AccessTokenDuration)
+	accessToken, err := server.tokenMaker.CreateToken(user.Username, server.config.AccessTokenDuration)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	_, err = server.repository.AppendAuditEvent(ctx, db.AppendAuditEventParams{
+		Meta:         auditMeta(ctx, user.Username),
+		Action:       db.AuditActionLoginSucceeded,
+		ResourceType: db.AuditResourceUser,
+		ResourceID:   user.Username,
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return &pb.LoginUserResponse{
+		User:                 convertUser(user),
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: timestamppb.New(expiresAt),
+	}, nil
+}
+
+// the attempted username is recorded as actor, since there is no authenticated caller for a failed login
+func (server *Server) recordLoginFailure(ctx context.Context, username, reason string) error {
+	_, err := server.repository.AppendAuditEvent(ctx, db.AppendAuditEventParams{
+		Meta:         auditMeta(ctx, username),
+		Action:       db.AuditActionLoginFailed,
+		ResourceType: db.AuditResourceUser,
+		ResourceID:   username,
+		After:        map[string]string{"reason": reason},
+	})
+	return err
+}
+
+// requireVerifiedEmail is the grpc version of the verifiedEmailMiddleware of the http api
+func (server *Server) requireVerifiedEmail(ctx context.Context, username string) error {
+	if !server.config.RequireVerifiedEmail {
+		return nil
+	}
+
+	user, err := server.repository.GetUser(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		return internalError(err)
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return status.Error(codes.PermissionDenied, "email address has to be verified first")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/maxeth/go-bank-app/auth"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/pb"
+)
+
+func TestCreateUserRPC(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name       string
+		req        *pb.CreateUserRequest
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, res *pb.CreateUserResponse, err error)
+	}{
+		{
+			name: "OK",
+			req:  &pb.CreateUserRequest{Username: user.Username, Password: password, FullName: user.FullName, Email: user.Email},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateUserParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Email, arg.Email)
+						require.NoError(t, auth.CheckPassword(arg.HashedPassword, password))
+						return user, nil
+					})
+				repo.EXPECT().
+					AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionUserCreated)).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.AppendAuditEventParams) (db.AuditEvent, error) {
+						// the audit log is append-only, the name and email of the user stay out of it
+						data, err := json.Marshal(arg.After)
+						require.NoError(t, err)
+						require.NotContains(t, string(data), user.FullName)
+						require.NotContains(t, string(data), user.Email)
+						return db.AuditEvent{}, nil
+					})
+				repo.EXPECT().
+					CreateEmailVerificationToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.EmailVerificationToken{Username: user.Username}, nil)
+			},
+			check: func(t *testing.T, res *pb.CreateUserResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user.Username, res.User.Username)
+				require.Equal(t, user.Email, res.User.Email)
+				require.Nil(t, res.User.EmailVerifiedAt)
+			},
+		},
+		{
+			name: "InvalidEmail",
+			req:  &pb.CreateUserRequest{Username: user.Username, Password: password, FullName: user.FullName, Email: "not an email"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateUserResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name: "InvalidUsername",
+			req:  &pb.CreateUserRequest{Username: "not-alphanum", Password: password, FullName: user.FullName, Email: user.Email},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateUserResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name: "WeakPassword",
+			req:  &pb.CreateUserRequest{Username: user.Username, Password: "short", FullName: user.FullName, Email: user.Email},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateUserResponse, err error) {
+				requireCode(t, codes.InvalidArgument, err)
+			},
+		},
+		{
+			name: "DuplicateUsername",
+			req:  &pb.CreateUserRequest{Username: user.Username, Password: password, FullName: user.FullName, Email: user.Email},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pq.Error{Code: "23505"})
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, res *pb.CreateUserResponse, err error) {
+				requireCode(t, codes.AlreadyExists, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			client := newTestClient(t, newTestServer(t, repo))
+			res, err := client.CreateUser(context.Background(), tc.req)
+			tc.check(t, res, err)
+		})
+	}
+}
+
+func TestLoginUserRPC(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name       string
+		req        *pb.LoginUserRequest
+		buildStubs func(repo *mockdb.MockRepository)
+		check      func(t *testing.T, server *Server, res *pb.LoginUserResponse, err error)
+	}{
+		{
+			name: "OK",
+			req:  &pb.LoginUserRequest{Username: user.Username, Password: password},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginSucceeded)).Times(1)
+			},
+			check: func(t *testing.T, server *Server, res *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user.Username, res.User.Username)
+				require.False(t, res.TwoFactorRequired)
+
+				// the token works for the http api as well, both use the same token maker
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Username)
+				require.Equal(t, auth.ScopeAccess, payload.Scope)
+			},
+		},
+		{
+			name: "TwoFactorRequired",
+			req:  &pb.LoginUserRequest{Username: user.Username, Password: password},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, ConfirmedAt: sql.NullTime{Valid: true}}, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, server *Server, res *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.True(t, res.TwoFactorRequired)
+				require.Empty(t, res.AccessToken)
+				require.Nil(t, res.User)
+
+				payload, err := server.tokenMaker.VerifyToken(res.TwoFactorToken)
+				require.NoError(t, err)
+				require.Equal(t, auth.ScopeTwoFactor, payload.Scope)
+			},
+		},
+		{
+			name: "WrongPassword",
+			req:  &pb.LoginUserRequest{Username: user.Username, Password: "wrongpassword"},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).Times(1)
+			},
+			check: func(t *testing.T, server *Server, res *pb.LoginUserResponse, err error) {
+				requireCode(t, codes.Unauthenticated, err)
+			},
+		},
+		{
+			name: "UnknownUser",
+			req:  &pb.LoginUserRequest{Username: user.Username, Password: password},
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.User{}, sql.ErrNoRows)
+				repo.EXPECT().AppendAuditEvent(gomock.Any(), eqAuditAction(db.AuditActionLoginFailed)).Times(1)
+			},
+			check: func(t *testing.T, server *Server, res *pb.LoginUserResponse, err error) {
+				requireCode(t, codes.NotFound, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+
+			server := newTestServer(t, repo)
+			client := newTestClient(t, server)
+			res, err := client.LoginUser(context.Background(), tc.req)
+			tc.check(t, server, res, err)
+		})
+	}
+}
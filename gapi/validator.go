@@ -0,0 +1,94 @@
+package gapi
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+// the checks of the binding tags of the http api. they return an InvalidArgument status naming the field
+
+func invalidArgument(field, format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, "invalid %s: %s", field, fmt.Sprintf(format, args...))
+}
+
+func validateUsername(field, value string) error {
+	if value == "" {
+		return invalidArgument(field, "is required")
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return invalidArgument(field, "must only contain letters and digits")
+		}
+	}
+	return nil
+}
+
+func validateEmail(field, value string) error {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return invalidArgument(field, "must be an email address")
+	}
+	return nil
+}
+
+func validateCurrency(field, value string) error {
+	if !library.IsSupportedCurrency(value) {
+		return invalidArgument(field, "unsupported currency %q", value)
+	}
+	return nil
+}
+
+func validateAccountNumber(field, value string) error {
+	// checks the format and the mod-97 check digits, so typos are caught before the account is looked up
+	if !library.IsValidAccountNumber(value) {
+		return invalidArgument(field, "must be an account number")
+	}
+	return nil
+}
+
+func validateAccountType(field, value string) error {
+	if value != "" && value != "checking" && value != "savings" {
+		return invalidArgument(field, "must be checking or savings")
+	}
+	return nil
+}
+
+// validateFreeText allows any printable character, control characters like newlines would break statements and exports
+func validateFreeText(field, value string, maxLength int) error {
+	if utf8.RuneCountInString(value) > maxLength {
+		return invalidArgument(field, "must not be longer than %d characters", maxLength)
+	}
+	if !utf8.ValidString(value) {
+		return invalidArgument(field, "must be valid utf-8")
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return invalidArgument(field, "must only contain printable characters")
+		}
+	}
+	return nil
+}
+
+// validateReference allows the character set of SEPA remittance references
+func validateReference(field, value string) error {
+	if len(value) > 35 {
+		return invalidArgument(field, "must not be longer than 35 characters")
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("/-?:().,'+ ", r):
+		default:
+			return invalidArgument(field, "invalid character %q", r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	library "github.com/maxeth/go-bank-app/library"
+)
+
+const interestUsage = "usage: interest run [YYYY-MM-DD]"
+
+// runInterest executes the interest subcommand, e.g. `main interest run` from a daily cron job shortly after
+// midnight (UTC). it accrues the interest of the given day, yesterday by default, on the balances at the end of that
+// day and pays out every month that has ended by then. running it twice for the same day changes nothing, a missed
+// day can be caught up by passing its date unless its month has already been paid out
+func runInterest(ctx context.Context, repo db.Repository, args []string) error {
+	if len(args) == 0 || args[0] != "run" || len(args) > 2 {
+		return errors.New(interestUsage)
+	}
+
+	today := truncateToDay(time.Now().UTC())
+	date := today.AddDate(0, 0, -1)
+	if len(args) > 1 {
+		var err error
+		date, err = time.Parse("2006-01-02", args[1])
+		if err != nil {
+			return fmt.Errorf("invalid date %q", args[1])
+		}
+	}
+	if !date.Before(today) {
+		return fmt.Errorf("interest for %s can only be accrued once the day is over", date.Format("2006-01-02"))
+	}
+
+	accrued, err := repo.AccrueInterest(ctx, db.AccrueInterestParams{
+		AccrualDate: date,
+		DaysInYear:  int32(library.DaysInYear(date.Year())),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("accrued interest of %d accounts for %s\n", accrued, date.Format("2006-01-02"))
+
+	// every month that ended on or before the accrual date can be paid out
+	next := date.AddDate(0, 0, 1)
+	pending, err := repo.ListPendingInterestCapitalizations(ctx, time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return err
+	}
+
+	// a failing account doesnt stop the others, it is retried with the next run
+	failed := 0
+	for _, p := range pending {
+		period := truncateToDay(p.Period)
+		result, err := repo.CapitalizeInterestTx(ctx, db.CapitalizeInterestTxParams{AccountID: p.AccountID, Period: period})
+		if err != nil {
+			log.Printf("cannot capitalize interest of account [%d] for %s: %v", p.AccountID, period.Format("2006-01"), err)
+			failed++
+			continue
+		}
+		fmt.Printf("paid %d interest to account [%d] for %s\n", result.Capitalization.Amount, p.AccountID, period.Format("2006-01"))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d capitalizations failed", failed, len(pending))
+	}
+	return nil
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,69 @@
+package library
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AccountNumberPrefix is the two-letter prefix of every public account number. it takes the place of the country code
+// in an IBAN.
+const AccountNumberPrefix = "GO"
+
+// accountNumberBodyLength is the amount of random digits after the prefix and the check digits
+const accountNumberBodyLength = 16
+
+// NewAccountNumber generates a random public account number in the form GOkk dddd dddd dddd dddd (without spaces),
+// where kk are mod-97 check digits calculated the same way as for an IBAN.
+func NewAccountNumber() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < accountNumberBodyLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+
+	body := sb.String()
+	// check digits are 98 minus the remainder of the rearranged number with "00" in place of the check digits
+	check := 98 - mod97(body+AccountNumberPrefix+"00")
+
+	return fmt.Sprintf("%s%02d%s", AccountNumberPrefix, check, body), nil
+}
+
+// IsValidAccountNumber checks the format and the mod-97 checksum of an account number
+func IsValidAccountNumber(number string) bool {
+	if len(number) != len(AccountNumberPrefix)+2+accountNumberBodyLength {
+		return false
+	}
+
+	if !strings.HasPrefix(number, AccountNumberPrefix) {
+		return false
+	}
+
+	for _, c := range number[len(AccountNumberPrefix):] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	// move the prefix and the check digits to the end, a valid number then has a remainder of 1
+	return mod97(number[4:]+number[:4]) == 1
+}
+
+// mod97 calculates the remainder of the number represented by s divided by 97, letters count as 10 (A) to 35 (Z)
+func mod97(s string) int {
+	remainder := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		}
+	}
+
+	return remainder
+}
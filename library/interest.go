@@ -0,0 +1,36 @@
+package library
+
+import (
+	"math/big"
+	"time"
+)
+
+// BasisPointsPerUnit is the amount of basis points in a rate of 100%
+const BasisPointsPerUnit = 10000
+
+// DaysInYear returns 366 for leap years and 365 otherwise. interest is accrued actual/actual, so a day in a leap
+// year earns a bit less than a day in a regular year
+func DaysInYear(year int) int {
+	if time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366 {
+		return 366
+	}
+	return 365
+}
+
+// DailyInterest returns the exact interest a balance in minor units earns in one day at the given annual rate,
+// balance * annualRateBps / 10000 / daysInYear. the result is a fraction of minor units and is never rounded
+func DailyInterest(balance int64, annualRateBps int32, daysInYear int) *big.Rat {
+	num := new(big.Int).Mul(big.NewInt(balance), big.NewInt(int64(annualRateBps)))
+	den := big.NewInt(int64(BasisPointsPerUnit) * int64(daysInYear))
+
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// SplitMinorUnits splits a non-negative amount of minor units into the whole units that can be paid out and the
+// fraction that is left over
+func SplitMinorUnits(amount *big.Rat) (int64, *big.Rat) {
+	whole := new(big.Int).Quo(amount.Num(), amount.Denom())
+	rest := new(big.Rat).Sub(amount, new(big.Rat).SetInt(whole))
+
+	return whole.Int64(), rest
+}
@@ -1,39 +1,120 @@
 package main
 
 import (
+	"context"
+	"log"
 	"math/rand"
+	"os"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	"github.com/maxeth/go-bank-app/api"
 	"github.com/maxeth/go-bank-app/config"
 	db "github.com/maxeth/go-bank-app/db/sqlc"
-)
-
-const (
-	address = "0.0.0.0:8080"
+	"github.com/maxeth/go-bank-app/gapi"
+	"github.com/maxeth/go-bank-app/webhook"
 )
 
 func main() {
+	// the config command has to work even if the configuration is invalid, so it loads the config on its own
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(".", os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	conf, err := config.New(".")
 	if err != nil {
-		panic("cannot connect to db")
+		log.Fatalf("cannot load config: %v", err)
+	}
+
+	if conf.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
 	}
 
-	conn, err := db.GetOrCreate(conf)
+	conn, err := db.Open(conf)
 	if err != nil {
-		panic("cannot connect to db")
+		log.Fatalf("cannot connect to db: %v", err)
+	}
+	defer conn.Close()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), conn, os.Args[2:]); err != nil {
+			log.Fatalf("migrate failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "interest" {
+		if err := runInterest(context.Background(), db.NewRepository(conn), os.Args[2:]); err != nil {
+			log.Fatalf("interest failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "outbox" {
+		if err := runOutbox(conf, db.NewRepository(conn), os.Args[2:]); err != nil {
+			log.Fatalf("outbox failed: %v", err)
+		}
+		return
 	}
 
-	repo := db.NewRepository(conn.DB)
+	if conf.MigrateOnStart {
+		if err := migrateOnStart(context.Background(), conn); err != nil {
+			log.Fatalf("cannot migrate db: %v", err)
+		}
+	}
+
+	repo := db.NewRepository(conn)
 	server, err := api.NewServer(conf, repo)
 	if err != nil {
-		panic("couldnt create new instance of a server")
+		log.Fatalf("cannot create server: %v", err)
+	}
+
+	// the outbox is shared through the database, so any number of instances can deliver webhooks at the same time
+	worker := webhook.NewWorker(repo, webhook.Config{
+		MaxAttempts:  conf.WebhookMaxAttempts,
+		RetryBackoff: conf.WebhookRetryBackoff,
+		Timeout:      conf.WebhookTimeout,
+		PollInterval: conf.WebhookPollInterval,
+		// receivers of local setups run on localhost, in production deliveries only go to public addresses
+		AllowPrivateNetworks: !conf.IsProduction(),
+	})
+	go worker.Run(context.Background())
+
+	// every instance listens on its own, so event streams get woken up no matter which instance made the change
+	go func() {
+		if err := db.ListenOutbox(context.Background(), conf, server.NotifyAccountEvents); err != nil {
+			log.Printf("cannot listen for outbox events: %v", err)
+		}
+	}()
+
+	// the grpc api runs next to the http api, with its own token maker and notifier built from the same config
+	tokenMaker, err := api.NewTokenMaker(conf)
+	if err != nil {
+		log.Fatalf("cannot create token maker: %v", err)
+	}
+	notifier, err := api.NewNotifier(conf)
+	if err != nil {
+		log.Fatalf("cannot create notifier: %v", err)
+	}
+	// the grpc api is served over tls with the certificate of the http api as soon as one is configured
+	tlsConfig, err := api.NewTLSConfig(conf)
+	if err != nil {
+		log.Fatalf("cannot create tls config: %v", err)
 	}
+	grpcServer := gapi.NewServer(conf, repo, tokenMaker, notifier)
+	go func() {
+		if err := grpcServer.Start(conf.GRPCServerAddress, tlsConfig); err != nil {
+			log.Fatalf("grpc server couldn't start: %v", err)
+		}
+	}()
 
-	err = server.Start(address)
+	err = server.Start(conf.ServerAddress)
 	if err != nil {
-		panic("server couldn't start")
+		log.Fatalf("server couldn't start: %v", err)
 	}
 
 }
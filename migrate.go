@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/maxeth/go-bank-app/db/migration"
+)
+
+const migrateUsage = "usage: migrate up [n] | down [n] | version | force <version>"
+
+// runMigrate executes the migrate subcommand, e.g. `main migrate up` or `main migrate force 2`
+func runMigrate(ctx context.Context, conn *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migration.New(conn)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up", "down":
+		// without a step count all pending (up) or all applied (down) migrations are being run
+		n := 0
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+
+		if args[0] == "up" {
+			err = migrator.Up(ctx, n)
+		} else {
+			err = migrator.Down(ctx, n)
+		}
+		if err == migration.ErrNoChange {
+			fmt.Println("no change")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < migration.NilVersion {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+
+	case "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Printf("version %d (dirty)\n", version)
+	} else {
+		fmt.Printf("version %d\n", version)
+	}
+
+	return nil
+}
+
+// migrateOnStart applies all pending migrations before the server starts
+func migrateOnStart(ctx context.Context, conn *sql.DB) error {
+	migrator, err := migration.New(conn)
+	if err != nil {
+		return err
+	}
+
+	err = migrator.Up(ctx, 0)
+	if err != nil && err != migration.ErrNoChange {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxeth/go-bank-app/library"
+	"github.com/stretchr/testify/require"
+)
+
+func randomMessage() Message {
+	return Message{
+		To:      library.RandomOwner() + "@example.com",
+		Subject: library.RandomString(10),
+		Body:    library.RandomString(40),
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewLogNotifier(log.New(&buf, "", 0))
+
+	msg := randomMessage()
+	require.NoError(t, n.Notify(context.Background(), msg))
+
+	require.Contains(t, buf.String(), msg.To)
+	require.Contains(t, buf.String(), msg.Subject)
+	require.Contains(t, buf.String(), msg.Body)
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	n, err := NewFileNotifier(path)
+	require.NoError(t, err)
+
+	sent := []Message{randomMessage(), randomMessage()}
+	for _, msg := range sent {
+		require.NoError(t, n.Notify(context.Background(), msg))
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var received []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		received = append(received, msg)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, received, len(sent))
+	for i := range sent {
+		require.Equal(t, sent[i].To, received[i].To)
+		require.Equal(t, sent[i].Body, received[i].Body)
+		require.NotZero(t, received[i].SentAt)
+	}
+}
+
+func TestNewFileNotifierRequiresPath(t *testing.T) {
+	_, err := NewFileNotifier("")
+	require.Error(t, err)
+}
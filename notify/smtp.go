@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// default timeout for a whole delivery if the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig describes the mail server messages are being delivered through
+type SMTPConfig struct {
+	Address  string // host:port
+	Username string // no authentication if empty
+	Password string
+	From     string
+}
+
+// SMTPNotifier delivers messages as plain text emails. the connection is upgraded with STARTTLS
+// whenever the server supports it, credentials are only sent over tls or to localhost
+type SMTPNotifier struct {
+	config SMTPConfig
+	host   string
+}
+
+func NewSMTPNotifier(config SMTPConfig) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address: %w", err)
+	}
+	if config.From == "" {
+		return nil, errors.New("smtp notifier requires a from address")
+	}
+
+	return &SMTPNotifier{config: config, host: host}, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+
+	body, err := n.compose(msg)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.config.Address)
+	if err != nil {
+		return err
+	}
+
+	// net/smtp doesnt know about contexts, the deadline makes sure a hanging server cant block the request forever
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+
+	if n.config.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection to anything but localhost
+		if err := c.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.config.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// compose builds the email including its headers. line endings and dot-stuffing are handled by the smtp client
+func (n *SMTPNotifier) compose(msg Message) ([]byte, error) {
+	// a line break in a header value would allow injecting additional headers or recipients
+	for _, v := range []string{msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errors.New("header values must not contain line breaks")
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\n", n.config.From)
+	fmt.Fprintf(&buf, "To: %s\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\n", msg.SentAt.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\n")
+	buf.WriteString("\n")
+	buf.WriteString(msg.Body)
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// receivedMail is what the fake server got from a single smtp session
+type receivedMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startFakeSMTPServer accepts a single smtp session on localhost and sends the result to the returned channel.
+// it speaks just enough of the protocol for net/smtp and doesnt offer STARTTLS
+func startFakeSMTPServer(t *testing.T) (string, <-chan receivedMail) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan receivedMail, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var mail receivedMail
+
+		tp.PrintfLine("220 localhost fake smtp")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				// AUTH PLAIN <base64(\x00user\x00password)>
+				fields := strings.Fields(line)
+				decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+				mail.auth = string(decoded)
+				tp.PrintfLine("235 authenticated")
+			case "MAIL":
+				mail.from = strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				mail.to = append(mail.to, strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">"))
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- mail
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := startFakeSMTPServer(t)
+
+	n, err := NewSMTPNotifier(SMTPConfig{
+		Address:  addr,
+		Username: "bank",
+		Password: "secret",
+		From:     "noreply@bank.example",
+	})
+	require.NoError(t, err)
+
+	msg := randomMessage()
+	msg.Body = "first line\n.starts with a dot"
+	require.NoError(t, n.Notify(context.Background(), msg))
+
+	mail := <-received
+	require.Equal(t, "\x00bank\x00secret", mail.auth)
+	require.Equal(t, "noreply@bank.example", mail.from)
+	require.Equal(t, []string{msg.To}, mail.to)
+	require.Contains(t, mail.data, "To: "+msg.To+"\n")
+	require.Contains(t, mail.data, "Subject: "+msg.Subject+"\n")
+	require.Contains(t, mail.data, "Content-Type: text/plain; charset=utf-8\n")
+	// dot-stuffing has been undone by the server
+	require.Contains(t, mail.data, "\n\nfirst line\n.starts with a dot\n")
+}
+
+func TestSMTPNotifierRejectsHeaderInjection(t *testing.T) {
+	n, err := NewSMTPNotifier(SMTPConfig{Address: "127.0.0.1:25", From: "noreply@bank.example"})
+	require.NoError(t, err)
+
+	msg := randomMessage()
+	msg.Subject = "hello\r\nBcc: someone@example.com"
+
+	// fails before connecting, there is no server listening
+	err = n.Notify(context.Background(), msg)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line breaks")
+}
+
+func TestNewSMTPNotifierValidatesConfig(t *testing.T) {
+	_, err := NewSMTPNotifier(SMTPConfig{Address: "localhost", From: "noreply@bank.example"})
+	require.Error(t, err)
+
+	_, err = NewSMTPNotifier(SMTPConfig{Address: "localhost:25"})
+	require.Error(t, err)
+}
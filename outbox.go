@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/maxeth/go-bank-app/config"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/maxeth/go-bank-app/outbox"
+)
+
+const outboxUsage = "usage: outbox relay <consumer>"
+
+// runOutbox executes the outbox subcommand. `main outbox relay <consumer>` publishes all events after the offset of
+// the consumer through the configured publisher and keeps running until it is interrupted. every batch is relayed
+// while holding an advisory lock of the consumer, so relays of the same consumer wait for each other instead of
+// publishing the same events twice or out of order
+func runOutbox(conf config.Config, repo db.Repository, args []string) error {
+	if len(args) != 2 || args[0] != "relay" || args[1] == "" {
+		return errors.New(outboxUsage)
+	}
+
+	var publisher outbox.Publisher = outbox.NewStdoutPublisher()
+	if conf.OutboxPublisher == config.OutboxPublisherFile {
+		var err error
+		publisher, err = outbox.NewFilePublisher(conf.OutboxFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return outbox.NewRelay(repo, publisher, args[1], conf.OutboxPollInterval).Run(ctx)
+}
@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is a domain event as it is handed to publishers. events of the same account are always published in the
+// order they happened, but can be published more than once
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Account   string          `json:"account"` // number of the account the event happened to
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"` // see db.TransferEvent and db.AccountEvent
+}
+
+// Publisher hands events to a consumer. the relay only moves on once Publish returned without error, so a
+// batch has to be published completely or the error returned
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+// WriterPublisher writes every event as a json line
+type WriterPublisher struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewStdoutPublisher writes the events to stdout, e.g. to pipe them into another program
+func NewStdoutPublisher() *WriterPublisher {
+	return NewWriterPublisher(os.Stdout)
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return writeEvents(p.w, events)
+}
+
+// FilePublisher appends every event as a json line to a file. the file is synced before Publish returns,
+// so published events survive a crash
+type FilePublisher struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file publisher requires a path")
+	}
+	return &FilePublisher{path: path}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// events contain account numbers and amounts, only the owner can read the file
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err = writeEvents(f, events); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeEvents(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelPublisher sends the events to a channel, meant for tests and consumers inside the same process
+type ChannelPublisher struct {
+	C chan Event
+}
+
+// NewChannelPublisher creates a publisher with a channel of the given buffer size. Publish blocks while the
+// buffer is full
+func NewChannelPublisher(size int) *ChannelPublisher {
+	return &ChannelPublisher{C: make(chan Event, size)}
+}
+
+func (p *ChannelPublisher) Publish(ctx context.Context, events []Event) error {
+	for _, event := range events {
+		select {
+		case p.C <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+// how many events are published at once
+const relayBatchSize = 100
+
+// Relay publishes the events of the outbox table to a single consumer. the position of every consumer is stored
+// in the outbox_consumers table after each published batch, so a restarted relay continues where it stopped and
+// republishes at most the batch it was working on. relays of the same consumer take turns
+type Relay struct {
+	repo         db.Repository
+	publisher    Publisher
+	consumer     string
+	pollInterval time.Duration
+}
+
+func NewRelay(repo db.Repository, publisher Publisher, consumer string, pollInterval time.Duration) *Relay {
+	return &Relay{
+		repo:         repo,
+		publisher:    publisher,
+		consumer:     consumer,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run publishes events until the context is cancelled. failed batches are retried after the poll interval
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cannot relay outbox events to %s: %v", r.consumer, err)
+		}
+
+		// a full batch means there are probably more events waiting
+		if err == nil && n == relayBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RelayOnce publishes the next batch of events after the offset of the consumer and moves the offset behind it.
+// it returns how many events were published. the batch is read, published and acknowledged while holding the lock
+// of the consumer, so a second relay started by mistake waits instead of publishing the batch twice
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	var n int
+
+	err := r.repo.OutboxConsumerTx(ctx, r.consumer, func(q db.Querier) error {
+		offset, err := q.GetOutboxConsumerOffset(ctx, r.consumer)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// ids are assigned in commit order, see appendOutboxEvent, so no event can show up behind the offset later
+		rows, err := q.ListOutboxEventsAfter(ctx, db.ListOutboxEventsAfterParams{ID: offset, LimitCount: relayBatchSize})
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		events := make([]Event, len(rows))
+		for i, row := range rows {
+			events[i] = Event{
+				ID:        row.ID,
+				Type:      row.EventType,
+				Account:   row.AccountNumber,
+				CreatedAt: row.CreatedAt,
+				Data:      row.Payload,
+			}
+		}
+
+		if err = r.publisher.Publish(ctx, events); err != nil {
+			return err
+		}
+
+		err = q.SetOutboxConsumerOffset(ctx, db.SetOutboxConsumerOffsetParams{
+			Name:        r.consumer,
+			LastEventID: events[len(events)-1].ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		n = len(events)
+		return nil
+	})
+
+	return n, err
+}
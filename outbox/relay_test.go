@@ -0,0 +1,305 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+const testConsumer = "ledger"
+
+func TestRelayOnce(t *testing.T) {
+	rows := randomOutboxRows(5, 10)
+	errPublish := errors.New("broker unavailable")
+
+	testCases := []struct {
+		name       string
+		buildStubs func(repo *mockdb.MockRepository)
+		publisher  func() Publisher
+		check      func(t *testing.T, publisher Publisher, n int, err error)
+	}{
+		{
+			name: "FirstRun",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetOutboxConsumerOffset(gomock.Any(), gomock.Eq(testConsumer)).
+					Times(1).
+					Return(int64(0), sql.ErrNoRows)
+				repo.EXPECT().
+					ListOutboxEventsAfter(gomock.Any(), gomock.Eq(db.ListOutboxEventsAfterParams{ID: 0, LimitCount: relayBatchSize})).
+					Times(1).
+					Return(rows, nil)
+				repo.EXPECT().
+					SetOutboxConsumerOffset(gomock.Any(), gomock.Eq(db.SetOutboxConsumerOffsetParams{Name: testConsumer, LastEventID: 14})).
+					Times(1).
+					Return(nil)
+			},
+			publisher: func() Publisher { return NewChannelPublisher(len(rows)) },
+			check: func(t *testing.T, publisher Publisher, n int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, len(rows), n)
+
+				c := publisher.(*ChannelPublisher).C
+				for _, row := range rows {
+					event := <-c
+					require.Equal(t, row.ID, event.ID)
+					require.Equal(t, row.EventType, event.Type)
+					require.Equal(t, row.AccountNumber, event.Account)
+					require.JSONEq(t, string(row.Payload), string(event.Data))
+				}
+			},
+		},
+		{
+			name: "ContinuesAfterOffset",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetOutboxConsumerOffset(gomock.Any(), gomock.Eq(testConsumer)).
+					Times(1).
+					Return(int64(9), nil)
+				repo.EXPECT().
+					ListOutboxEventsAfter(gomock.Any(), gomock.Eq(db.ListOutboxEventsAfterParams{ID: 9, LimitCount: relayBatchSize})).
+					Times(1).
+					Return(rows, nil)
+				repo.EXPECT().
+					SetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			publisher: func() Publisher { return NewChannelPublisher(len(rows)) },
+			check: func(t *testing.T, publisher Publisher, n int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, len(rows), n)
+			},
+		},
+		{
+			name: "NoEvents",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(14), nil)
+				repo.EXPECT().
+					ListOutboxEventsAfter(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListOutboxEventsAfterRow{}, nil)
+				repo.EXPECT().
+					SetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			publisher: func() Publisher { return NewChannelPublisher(0) },
+			check: func(t *testing.T, publisher Publisher, n int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, n)
+			},
+		},
+		{
+			name: "PublishFailed",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(9), nil)
+				repo.EXPECT().
+					ListOutboxEventsAfter(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(rows, nil)
+				// the batch is published again on the next run
+				repo.EXPECT().
+					SetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			publisher: func() Publisher { return failingPublisher{errPublish} },
+			check: func(t *testing.T, publisher Publisher, n int, err error) {
+				require.Equal(t, errPublish, err)
+				require.Zero(t, n)
+			},
+		},
+		{
+			name: "OffsetError",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), sql.ErrConnDone)
+				repo.EXPECT().
+					ListOutboxEventsAfter(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			publisher: func() Publisher { return NewChannelPublisher(0) },
+			check: func(t *testing.T, publisher Publisher, n int, err error) {
+				require.Equal(t, sql.ErrConnDone, err)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			tc.buildStubs(repo)
+			repo.EXPECT().
+				OutboxConsumerTx(gomock.Any(), gomock.Eq(testConsumer), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ context.Context, _ string, fn func(db.Querier) error) error {
+					return fn(repo)
+				})
+
+			publisher := tc.publisher()
+			n, err := NewRelay(repo, publisher, testConsumer, time.Second).RelayOnce(context.Background())
+			tc.check(t, publisher, n, err)
+		})
+	}
+}
+
+// relays of the same consumer take turns through the consumer lock, which the mutex stands in for here
+func TestRelayOnceConcurrently(t *testing.T) {
+	rows := randomOutboxRows(1, 5)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var lock sync.Mutex
+	var offset int64
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().
+		OutboxConsumerTx(gomock.Any(), gomock.Eq(testConsumer), gomock.Any()).
+		Times(2).
+		DoAndReturn(func(_ context.Context, _ string, fn func(db.Querier) error) error {
+			lock.Lock()
+			defer lock.Unlock()
+			return fn(repo)
+		})
+	repo.EXPECT().
+		GetOutboxConsumerOffset(gomock.Any(), gomock.Eq(testConsumer)).
+		Times(2).
+		DoAndReturn(func(context.Context, string) (int64, error) { return offset, nil })
+	repo.EXPECT().
+		ListOutboxEventsAfter(gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(func(_ context.Context, arg db.ListOutboxEventsAfterParams) ([]db.ListOutboxEventsAfterRow, error) {
+			after := []db.ListOutboxEventsAfterRow{}
+			for _, row := range rows {
+				if row.ID > arg.ID {
+					after = append(after, row)
+				}
+			}
+			return after, nil
+		})
+	repo.EXPECT().
+		SetOutboxConsumerOffset(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.SetOutboxConsumerOffsetParams) error {
+			offset = arg.LastEventID
+			return nil
+		})
+
+	publisher := NewChannelPublisher(2 * len(rows))
+	relay := NewRelay(repo, publisher, testConsumer, time.Second)
+
+	var wg sync.WaitGroup
+	published := make([]int, 2)
+	errs := make([]error, 2)
+	for i := range published {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			published[i], errs[i] = relay.RelayOnce(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.Equal(t, len(rows), published[0]+published[1])
+	require.Len(t, publisher.C, len(rows))
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err := NewFilePublisher(path)
+	require.NoError(t, err)
+
+	rows := randomOutboxRows(1, 4)
+	events := make([]Event, len(rows))
+	for i, row := range rows {
+		events[i] = Event{ID: row.ID, Type: row.EventType, Account: row.AccountNumber, CreatedAt: row.CreatedAt, Data: row.Payload}
+	}
+
+	// a second publish appends to the file
+	require.NoError(t, publisher.Publish(context.Background(), events[:2]))
+	require.NoError(t, publisher.Publish(context.Background(), events[2:]))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var ids []int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		ids = append(ids, event.ID)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []int64{1, 2, 3, 4}, ids)
+
+	_, err = NewFilePublisher("")
+	require.Error(t, err)
+}
+
+func TestChannelPublisherCancelled(t *testing.T) {
+	publisher := NewChannelPublisher(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rows := randomOutboxRows(1, 2)
+	err := publisher.Publish(ctx, []Event{{ID: rows[0].ID}, {ID: rows[1].ID}})
+	require.Equal(t, context.Canceled, err)
+}
+
+type failingPublisher struct {
+	err error
+}
+
+func (p failingPublisher) Publish(ctx context.Context, events []Event) error {
+	return p.err
+}
+
+// randomOutboxRows returns events with consecutive ids, alternating between two accounts
+func randomOutboxRows(firstID int64, n int) []db.ListOutboxEventsAfterRow {
+	rows := make([]db.ListOutboxEventsAfterRow, n)
+	for i := range rows {
+		account, eventType := "DE00000000000000000001", db.EventTransferCreated
+		if i%2 == 1 {
+			account, eventType = "DE00000000000000000002", db.EventTransferReceived
+		}
+
+		rows[i] = db.ListOutboxEventsAfterRow{
+			ID:            firstID + int64(i),
+			EventType:     eventType,
+			AccountID:     int64(i%2 + 1),
+			Payload:       json.RawMessage(`{"amount":100}`),
+			CreatedAt:     time.Now(),
+			AccountNumber: account,
+		}
+	}
+	return rows
+}
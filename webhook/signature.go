@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// headers every delivery is sent with
+const (
+	HeaderID        = "X-Webhook-ID"    // id of the event, the same for every attempt so receivers can drop duplicates
+	HeaderEvent     = "X-Webhook-Event" // type of the event
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signatures are prefixed with the algorithm, so it can be changed later without breaking receivers
+const signaturePrefix = "sha256="
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+	ErrExpiredTimestamp = errors.New("webhook timestamp is too old")
+)
+
+// Sign returns the signature header of a delivery: the hex encoded HMAC-SHA256 over the unix timestamp,
+// a dot and the body, keyed with the secret of the subscription
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received delivery. timestamps older than tolerance
+// are rejected, so a captured delivery cant be replayed later
+func Verify(secret string, signature string, timestamp string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	sent := time.Unix(unix, 0)
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, sent, body))) {
+		return ErrInvalidSignature
+	}
+	if time.Since(sent) > tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+)
+
+// how many deliveries a worker claims at once
+const claimBatchSize = 20
+
+// the wait time between retries doubles after every failed attempt, but never exceeds this
+const maxRetryBackoff = 6 * time.Hour
+
+// at most this much of a response is read, so the connection can be reused
+const maxDiscardBodySize = 4 << 10
+
+type Config struct {
+	MaxAttempts  int           // deliveries are dead-lettered after this many failed attempts
+	RetryBackoff time.Duration // wait time after the first failed attempt
+	Timeout      time.Duration // how long a receiver can take to answer
+	PollInterval time.Duration // how often the outbox is checked for due deliveries when it was empty
+
+	// lets deliveries reach loopback and private addresses, only for dev and test where receivers run locally
+	AllowPrivateNetworks bool
+}
+
+// Event is the body of every delivery
+type Event struct {
+	ID        int64           `json:"id"` // the same for every attempt
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"` // see db.TransferEvent and db.AccountEvent
+}
+
+// Worker sends the queued webhook deliveries. several workers can run at the same time, every delivery is
+// claimed by one of them for as long as sending it can take
+type Worker struct {
+	repo   db.Repository
+	client *http.Client
+	config Config
+}
+
+func NewWorker(repo db.Repository, config Config) *Worker {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !config.AllowPrivateNetworks {
+		// a proxy would make the connection instead of the dialer, and the receiver address would go unchecked
+		transport.Proxy = nil
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+		transport.DialContext = dialer.DialContext
+	}
+
+	return &Worker{
+		repo:   repo,
+		config: config,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+			// a redirect is treated as a failed attempt, receivers have to update the url of their subscription
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run sends due deliveries until the context is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		n, err := w.DeliverDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cannot deliver webhooks: %v", err)
+		}
+
+		// a full batch means there are probably more deliveries waiting
+		if err == nil && n == claimBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.config.PollInterval):
+		}
+	}
+}
+
+// DeliverDue claims the deliveries that are due and sends them one after another. it returns how many deliveries
+// were claimed
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	// the lease has to cover sending the whole batch, otherwise another worker could pick up the last deliveries
+	lease := time.Duration(claimBatchSize+1) * w.config.Timeout
+	deliveries, err := w.repo.ClaimWebhookDeliveries(ctx, db.ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(lease),
+		LimitCount: claimBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err := w.deliver(ctx, delivery); err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliver sends a single delivery and records the outcome. a failed attempt is retried later or dead-lettered,
+// only errors of the repository are returned
+func (w *Worker) deliver(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow) error {
+	body, err := json.Marshal(Event{
+		ID:        delivery.OutboxID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	statusCode, sendErr := w.send(ctx, delivery, body)
+
+	now := time.Now()
+	arg := db.RecordWebhookDeliveryAttemptParams{
+		ID:            delivery.ID,
+		Status:        db.WebhookDeliverySucceeded,
+		NextAttemptAt: now,
+		DeliveredAt:   sql.NullTime{Time: now, Valid: true},
+	}
+	if statusCode != 0 {
+		arg.LastStatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+	if sendErr != nil {
+		arg.LastError = sendErr.Error()
+		arg.DeliveredAt = sql.NullTime{}
+
+		attempts := int(delivery.Attempts) + 1
+		if attempts >= w.config.MaxAttempts {
+			arg.Status = db.WebhookDeliveryDead
+		} else {
+			arg.Status = db.WebhookDeliveryPending
+			arg.NextAttemptAt = now.Add(w.backoff(attempts))
+		}
+	}
+
+	_, err = w.repo.RecordWebhookDeliveryAttempt(ctx, arg)
+	return err
+}
+
+// send posts the signed body to the receiver. every answer but a 2xx counts as failed
+func (w *Worker) send(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, fmt.Sprint(delivery.OutboxID))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(timestamp.Unix()))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// the body isnt kept, the delivery log is readable by the subscriber and must not echo what internal hosts answer
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDiscardBodySize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait time after the given number of failed attempts
+func (w *Worker) backoff(attempts int) time.Duration {
+	wait := w.config.RetryBackoff
+	for i := 1; i < attempts && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return wait
+}
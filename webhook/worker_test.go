@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/maxeth/go-bank-app/db/mock"
+	db "github.com/maxeth/go-bank-app/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "whsec_test"
+
+var testConfig = Config{
+	MaxAttempts:  3,
+	RetryBackoff: time.Minute,
+	Timeout:      time.Second,
+	PollInterval: time.Second,
+	// the receivers of the tests listen on localhost
+	AllowPrivateNetworks: true,
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	signature := Sign(testSecret, now, body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	require.NoError(t, Verify(testSecret, signature, timestamp, body, time.Minute))
+	require.Equal(t, ErrInvalidSignature, Verify("whsec_other", signature, timestamp, body, time.Minute))
+	require.Equal(t, ErrInvalidSignature, Verify(testSecret, signature, timestamp, []byte(`{"id":2}`), time.Minute))
+	require.Equal(t, ErrInvalidSignature, Verify(testSecret, signature, "yesterday", body, time.Minute))
+
+	old := now.Add(-time.Hour)
+	oldTimestamp := strconv.FormatInt(old.Unix(), 10)
+	require.Equal(t, ErrExpiredTimestamp, Verify(testSecret, Sign(testSecret, old, body), oldTimestamp, body, time.Minute))
+}
+
+func TestDeliverDue(t *testing.T) {
+	payload := json.RawMessage(`{"transferID":"0b6e2a51-2c7e-4f7d-9a39-5d1f3c0e8a44","amount":100}`)
+
+	testCases := []struct {
+		name     string
+		status   int // answer of the receiver, 0 if it cant be reached
+		attempts int32
+		blocked  bool // deliver with private networks blocked, like in production
+		check    func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams)
+	}{
+		{
+			name:   "Delivered",
+			status: http.StatusNoContent,
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliverySucceeded, arg.Status)
+				require.Equal(t, int32(http.StatusNoContent), arg.LastStatusCode.Int32)
+				require.True(t, arg.DeliveredAt.Valid)
+				require.Empty(t, arg.LastError)
+			},
+		},
+		{
+			name:     "Retried",
+			status:   http.StatusInternalServerError,
+			attempts: 1,
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliveryPending, arg.Status)
+				require.Equal(t, int32(http.StatusInternalServerError), arg.LastStatusCode.Int32)
+				require.False(t, arg.DeliveredAt.Valid)
+				require.Equal(t, "receiver answered with 500", arg.LastError)
+				// the second failed attempt waits twice the backoff
+				require.WithinDuration(t, time.Now().Add(2*time.Minute), arg.NextAttemptAt, 5*time.Second)
+			},
+		},
+		{
+			name:     "DeadLettered",
+			status:   http.StatusBadGateway,
+			attempts: 2,
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliveryDead, arg.Status)
+				require.Equal(t, int32(http.StatusBadGateway), arg.LastStatusCode.Int32)
+			},
+		},
+		{
+			name:   "Redirect",
+			status: http.StatusMovedPermanently,
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliveryPending, arg.Status)
+				require.Equal(t, int32(http.StatusMovedPermanently), arg.LastStatusCode.Int32)
+			},
+		},
+		{
+			name:    "Blocked",
+			status:  http.StatusNoContent,
+			blocked: true,
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliveryPending, arg.Status)
+				require.False(t, arg.LastStatusCode.Valid)
+				require.Contains(t, arg.LastError, ErrBlockedAddress.Error())
+			},
+		},
+		{
+			name: "Unreachable",
+			check: func(t *testing.T, arg db.RecordWebhookDeliveryAttemptParams) {
+				require.Equal(t, db.WebhookDeliveryPending, arg.Status)
+				require.False(t, arg.LastStatusCode.Valid)
+				require.NotEmpty(t, arg.LastError)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.NoError(t, Verify(testSecret, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, time.Minute))
+				require.Equal(t, "42", r.Header.Get(HeaderID))
+				require.Equal(t, db.EventTransferReceived, r.Header.Get(HeaderEvent))
+
+				var event Event
+				require.NoError(t, json.Unmarshal(body, &event))
+				require.Equal(t, int64(42), event.ID)
+				require.Equal(t, db.EventTransferReceived, event.Type)
+				require.JSONEq(t, string(payload), string(event.Data))
+
+				if tc.status == http.StatusMovedPermanently {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tc.status)
+				if tc.status >= http.StatusInternalServerError {
+					w.Write([]byte("cannot reach 10.0.0.5:5432"))
+				}
+			}))
+			defer receiver.Close()
+			if tc.status == 0 {
+				receiver.Close()
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			delivery := db.ClaimWebhookDeliveriesRow{
+				ID:        7,
+				OutboxID:  42,
+				Attempts:  tc.attempts,
+				Url:       receiver.URL,
+				Secret:    testSecret,
+				EventType: db.EventTransferReceived,
+				Payload:   payload,
+				CreatedAt: time.Now(),
+			}
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().
+				ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return([]db.ClaimWebhookDeliveriesRow{delivery}, nil)
+			repo.EXPECT().
+				RecordWebhookDeliveryAttempt(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ interface{}, arg db.RecordWebhookDeliveryAttemptParams) (db.WebhookDelivery, error) {
+					require.Equal(t, delivery.ID, arg.ID)
+					tc.check(t, arg)
+					return db.WebhookDelivery{ID: arg.ID, Status: arg.Status}, nil
+				})
+
+			config := testConfig
+			config.AllowPrivateNetworks = !tc.blocked
+			n, err := NewWorker(repo, config).DeliverDue(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"93.184.216.34", "8.8.8.8", "2606:2800:220:1:248:1893:25c8:1946"} {
+		require.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0",
+		"224.0.0.1", "255.255.255.255", "::1", "::", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "64:ff9b::a00:1",
+	} {
+		require.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := NewWorker(nil, Config{RetryBackoff: time.Minute})
+
+	require.Equal(t, time.Minute, w.backoff(1))
+	require.Equal(t, 2*time.Minute, w.backoff(2))
+	require.Equal(t, 8*time.Minute, w.backoff(4))
+	require.Equal(t, maxRetryBackoff, w.backoff(100))
+}